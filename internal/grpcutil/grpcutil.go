@@ -0,0 +1,97 @@
+// Package grpcutil holds the plumbing shared by the plugin's own gRPC services. The artifact service's proto is
+// defined upstream, so the RPCs the plugin adds are served as separate services whose descriptors are written by
+// hand around the artifact message and the protobuf well-known types. Any gRPC client can call them without
+// generated code.
+package grpcutil
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+)
+
+// UnaryHandler adapts a method of the service implementation S, serving the RPC with the full method name, to a
+// gRPC method handler
+func UnaryHandler[S any, Req any, PReq interface {
+	*Req
+	proto.Message
+}, Resp any](method string, call func(S, context.Context, PReq) (Resp, error)) grpc.MethodHandler {
+	return func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		req := PReq(new(Req))
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		handler := func(ctx context.Context, req any) (any, error) {
+			return call(srv.(S), ctx, req.(PReq))
+		}
+		if interceptor == nil {
+			return handler(ctx, req)
+		}
+		return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: method}, handler)
+	}
+}
+
+// ServerStreamHandler adapts a method of the service implementation S, serving a server-streaming RPC from its
+// single request message, to a gRPC stream handler
+func ServerStreamHandler[S any, Req any, PReq interface {
+	*Req
+	proto.Message
+}](call func(S, PReq, grpc.ServerStream) error) grpc.StreamHandler {
+	return func(srv any, stream grpc.ServerStream) error {
+		req := PReq(new(Req))
+		if err := stream.RecvMsg(req); err != nil {
+			return err
+		}
+		return call(srv.(S), req, stream)
+	}
+}
+
+// WithLogger returns ctx carrying the logger for the RPC, which is the request logger attached by the interceptors
+// if there is one, and otherwise logger
+func WithLogger(ctx context.Context, logger logging.Logger) context.Context {
+	if logging.GetLoggerFromContextOrNil(ctx) != nil {
+		return ctx
+	}
+	return logging.WithLogger(ctx, logger)
+}
+
+// FirstValue returns the first value of the metadata key, or an empty string when it isn't set
+func FirstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// RunWithTimeout runs op with a context that expires after timeout. If op hasn't returned by then,
+// a DeadlineExceeded status naming the operation is returned without waiting any longer for op.
+func RunWithTimeout(ctx context.Context, operation string, timeout time.Duration, op func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- op(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return status.Errorf(codes.DeadlineExceeded, "%s timed out after %s", operation, timeout)
+		}
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return status.Errorf(codes.DeadlineExceeded, "%s timed out after %s", operation, timeout)
+		}
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
@@ -0,0 +1,121 @@
+package grpcutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+)
+
+type echoServer struct{ prefix string }
+
+func (s *echoServer) Echo(_ context.Context, req *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+	return wrapperspb.String(s.prefix + req.GetValue()), nil
+}
+
+func TestUnaryHandler(t *testing.T) {
+	handler := UnaryHandler("/test.Echo/Echo", (*echoServer).Echo)
+	dec := func(req any) error {
+		req.(*wrapperspb.StringValue).Value = "world"
+		return nil
+	}
+	srv := &echoServer{prefix: "hello "}
+
+	resp, err := handler(srv, t.Context(), dec, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", resp.(*wrapperspb.StringValue).GetValue())
+
+	var method string
+	interceptor := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		method = info.FullMethod
+		return handler(ctx, req)
+	}
+	resp, err = handler(srv, t.Context(), dec, interceptor)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", resp.(*wrapperspb.StringValue).GetValue())
+	assert.Equal(t, "/test.Echo/Echo", method)
+}
+
+func TestWithLogger(t *testing.T) {
+	logger := logging.RequireLoggerFromContext(logging.TestContext(t.Context()))
+	ctx := WithLogger(t.Context(), logger)
+	assert.Equal(t, logger, logging.GetLoggerFromContextOrNil(ctx))
+
+	requestCtx := logging.TestContext(t.Context())
+	assert.Equal(t, requestCtx, WithLogger(requestCtx, logger))
+}
+
+func TestFirstValue(t *testing.T) {
+	md := metadata.Pairs("artifact-key", "a", "artifact-key", "b")
+	assert.Equal(t, "a", FirstValue(md, "artifact-key"))
+	assert.Empty(t, FirstValue(md, "artifact-missing"))
+}
+
+// recvStream is a server stream whose single request message is the value
+type recvStream struct {
+	grpc.ServerStream
+	value string
+}
+
+func (s *recvStream) RecvMsg(m any) error {
+	m.(*wrapperspb.StringValue).Value = s.value
+	return nil
+}
+
+func TestServerStreamHandler(t *testing.T) {
+	var got string
+	handler := ServerStreamHandler(func(s *echoServer, req *wrapperspb.StringValue, _ grpc.ServerStream) error {
+		got = s.prefix + req.GetValue()
+		return nil
+	})
+	require.NoError(t, handler(&echoServer{prefix: "hello "}, &recvStream{value: "world"}))
+	assert.Equal(t, "hello world", got)
+}
+
+func TestRunWithTimeout(t *testing.T) {
+	t.Run("Completes in time", func(t *testing.T) {
+		err := RunWithTimeout(t.Context(), "Load", time.Second, func(context.Context) error {
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("Returns the operation error", func(t *testing.T) {
+		err := RunWithTimeout(t.Context(), "Load", time.Second, func(context.Context) error {
+			return errors.New("no such key")
+		})
+		require.EqualError(t, err, "no such key")
+	})
+
+	t.Run("Driver ignoring the deadline", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		err := RunWithTimeout(t.Context(), "Load", 10*time.Millisecond, func(context.Context) error {
+			<-release
+			return nil
+		})
+		require.Error(t, err)
+		assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+		assert.Contains(t, err.Error(), "Load timed out after 10ms")
+	})
+
+	t.Run("Driver honouring the deadline", func(t *testing.T) {
+		err := RunWithTimeout(t.Context(), "Save", 10*time.Millisecond, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		require.Error(t, err)
+		assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+		assert.Contains(t, err.Error(), "Save timed out after 10ms")
+	})
+}
@@ -1,32 +1,132 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
+	"unicode"
 
+	"github.com/minio/minio-go/v7"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
+	argoerrs "github.com/argoproj/argo-workflows/v3/errors"
 	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
 	"github.com/argoproj/argo-workflows/v3/util/logging"
+	"github.com/pipekit/artifact-plugin-s3/internal/grpcutil"
 	"github.com/pipekit/artifact-plugin-s3/pkg/artifact"
+	"github.com/pipekit/artifact-plugin-s3/pkg/audit"
+	"github.com/pipekit/artifact-plugin-s3/pkg/cleanup"
+	"github.com/pipekit/artifact-plugin-s3/pkg/concurrency"
+	"github.com/pipekit/artifact-plugin-s3/pkg/metrics"
+	"github.com/pipekit/artifact-plugin-s3/pkg/multipart"
+	"github.com/pipekit/artifact-plugin-s3/pkg/object"
+	"github.com/pipekit/artifact-plugin-s3/pkg/query"
+	"github.com/pipekit/artifact-plugin-s3/pkg/readonly"
+	"github.com/pipekit/artifact-plugin-s3/pkg/requestlog"
 	"github.com/pipekit/artifact-plugin-s3/pkg/s3"
+	"github.com/pipekit/artifact-plugin-s3/pkg/tracing"
+	"github.com/pipekit/artifact-plugin-s3/pkg/version"
 )
 
 type artifactServer struct {
 	artifact.UnimplementedArtifactServiceServer
+	logger logging.Logger
+	// audit records every Delete
+	audit *audit.Logger
 }
 
 const (
-	logLevel  = logging.Debug
-	logFormat = logging.JSON
+	// envVarLogLevel and envVarLogFormat configure the logger, defaulting to defaultLogLevel and defaultLogFormat
+	envVarLogLevel   = "LOG_LEVEL"
+	envVarLogFormat  = "LOG_FORMAT"
+	defaultLogLevel  = logging.Info
+	defaultLogFormat = logging.JSON
+
+	// envVarAuditLogFile is the file audit events are appended to, as JSON lines. Unset writes them to stderr
+	// along with the server's logs.
+	envVarAuditLogFile = "AUDIT_LOG_FILE"
+
+	// envVarMaxMsgBytes overrides the maximum gRPC message size the server will send or receive
+	envVarMaxMsgBytes = "ARTIFACT_PLUGIN_MAX_MSG_BYTES"
+	// defaultMaxMsgBytes is 16MB, four times the gRPC default, so ListObjects responses for
+	// large prefixes don't fail with ResourceExhausted
+	defaultMaxMsgBytes = 16 * 1024 * 1024
+
+	// envVarMaxConcurrentRPCs limits how many RPCs are served at once, RPCs over the limit fail with
+	// ResourceExhausted. Unset or 0 means unlimited.
+	envVarMaxConcurrentRPCs = "MAX_CONCURRENT_RPCS"
+
+	// envVarMetricsPort is the HTTP port serving Prometheus metrics on /metrics, metrics aren't served when unset
+	envVarMetricsPort = "ARTIFACT_PLUGIN_METRICS_PORT"
+
+	// envVarShutdownTimeout is how long in-flight RPCs may take to finish after a shutdown signal, as a Go
+	// duration, before they are cancelled
+	envVarShutdownTimeout  = "SHUTDOWN_TIMEOUT"
+	defaultShutdownTimeout = 30 * time.Second
+
+	// envVarTLSCertFile and envVarTLSKeyFile are the paths of the PEM certificate and key the server uses for TLS.
+	// Both must be set to enable TLS, the server is plaintext without them.
+	envVarTLSCertFile = "TLS_CERT_FILE"
+	envVarTLSKeyFile  = "TLS_KEY_FILE"
+
+	// envVarAllowTCP must be true for the server to listen on a TCP address, as the server is unauthenticated
+	envVarAllowTCP = "ALLOW_TCP"
+
+	// envVarSocketCheckRelaxed set to true makes a failure to stat the socket after listening a warning rather than
+	// fatal, for read-only root filesystems where the stat can fail although the socket serves
+	envVarSocketCheckRelaxed = "SOCKET_CHECK_RELAXED"
+
+	// envVarReadOnly set to true rejects every RPC which may modify storage, for a server dedicated to inputs
+	envVarReadOnly = "READ_ONLY"
+
+	// headerObjectCount and headerTotalBytes are the Save response headers holding the number and combined size
+	// of the objects uploaded, and headerSkipped whether the upload was skipped as the object was unchanged
+	headerObjectCount = "artifact-object-count"
+	headerTotalBytes  = "artifact-total-bytes"
+	headerSkipped     = "artifact-skipped"
+
+	// headerPageSize and headerContinuationToken are the ListObjects request metadata asking for a page of at most
+	// that many objects, starting from the token, and headerContinuationToken also the response header holding the
+	// token of the next page, empty after the last one
+	headerPageSize          = "artifact-page-size"
+	headerContinuationToken = "artifact-continuation-token"
 )
 
-var logger = logging.NewSlogLogger(logLevel, logFormat)
+var serverMetrics = metrics.New()
+
+// readMethods are the RPCs which never modify storage, the only ones served with READ_ONLY
+var readMethods = []string{
+	"/artifact.ArtifactService/Load",
+	"/artifact.ArtifactService/OpenStream",
+	"/artifact.ArtifactService/ListObjects",
+	"/artifact.ArtifactService/IsDirectory",
+	query.SelectObjectContentMethod,
+	object.ExistsMethod,
+	object.ListBucketsMethod,
+	object.ReadObjectMethod,
+	object.CheckBucketMethod,
+	object.StatMethod,
+	version.GetVersionMethod,
+}
 
 // validatePluginArtifact validates that an artifact has proper plugin configuration
 func validatePluginArtifact(artifact *artifact.Artifact) error {
@@ -45,18 +145,25 @@ func validatePluginArtifact(artifact *artifact.Artifact) error {
 	return nil
 }
 
-// getDriver extracts and validates plugin configuration from an artifact
-func getDriver(ctx context.Context, artifact *artifact.Artifact) (*s3.ArtifactDriver, *wfv1.Artifact, error) {
+// getDriver extracts and validates plugin configuration from an artifact.
+// keyRequired rejects an empty key, for operations that act on a specific object or prefix.
+func getDriver(ctx context.Context, artifact *artifact.Artifact, keyRequired bool) (*s3.ArtifactDriver, *wfv1.Artifact, error) {
 	if err := validatePluginArtifact(artifact); err != nil {
 		return nil, nil, err
 	}
+	if keyRequired && artifact.Plugin.Key == "" {
+		return nil, nil, status.Error(codes.InvalidArgument, "plugin artifact key is required")
+	}
 
 	pluginArtifact := artifact.Plugin
 
 	// Resolve S3 configuration and credentials
 	driver, argoArtifact, err := s3.DriverAndArtifactFromConfig(ctx, pluginArtifact.Configuration, pluginArtifact.Key)
+	if errors.Is(err, s3.ErrInvalidConfig) {
+		return nil, nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 	if err != nil {
-		return nil, nil, status.Error(codes.Internal, err.Error())
+		return nil, nil, toStatusError(err)
 	}
 
 	logger := logging.RequireLoggerFromContext(ctx)
@@ -65,32 +172,52 @@ func getDriver(ctx context.Context, artifact *artifact.Artifact) (*s3.ArtifactDr
 	return driver, argoArtifact, nil
 }
 
+// redactRequest returns a copy of the request, for logging, with secret values in the plugin configuration masked
+func redactRequest[T proto.Message](req T) T {
+	req = proto.Clone(req).(T)
+	var a *artifact.Artifact
+	switch r := any(req).(type) {
+	case *artifact.LoadArtifactRequest:
+		a = r.GetInputArtifact()
+	case *artifact.SaveArtifactRequest:
+		a = r.GetOutputArtifact()
+	case interface{ GetArtifact() *artifact.Artifact }:
+		a = r.GetArtifact()
+	}
+	if a.GetPlugin() != nil {
+		a.Plugin.Configuration = s3.RedactConfiguration(a.Plugin.Configuration)
+	}
+	return req
+}
+
+// withLogger returns ctx carrying the logger for the RPC, which is the request logger attached by the
+// interceptors if there is one
+func (s *artifactServer) withLogger(ctx context.Context) (context.Context, logging.Logger) {
+	if logger := logging.GetLoggerFromContextOrNil(ctx); logger != nil {
+		return ctx, logger
+	}
+	return logging.WithLogger(ctx, s.logger), s.logger
+}
+
 func (s *artifactServer) Load(ctx context.Context, req *artifact.LoadArtifactRequest) (*artifact.LoadArtifactResponse, error) {
-	ctx = logging.WithLogger(ctx, logger)
-	logger.WithField("request", req).Info(ctx, "Load artifact request")
+	ctx, logger := s.withLogger(ctx)
+	logger.WithField("request", redactRequest(req)).Debug(ctx, "Load artifact request")
 
 	if req.InputArtifact == nil {
-		return &artifact.LoadArtifactResponse{
-			Success: false,
-			Error:   "input artifact is required",
-		}, nil
+		return nil, status.Error(codes.InvalidArgument, "input artifact is required")
 	}
 
-	driver, argoArtifact, err := getDriver(ctx, req.InputArtifact)
+	driver, argoArtifact, err := getDriver(ctx, req.InputArtifact, true)
 	if err != nil {
-		return &artifact.LoadArtifactResponse{
-			Success: false,
-			Error:   err.Error(),
-		}, nil
+		return nil, toStatusError(err)
 	}
 
 	// Load the artifact
-	err = driver.Load(ctx, argoArtifact, req.Path)
+	err = grpcutil.RunWithTimeout(ctx, "Load", driver.OperationTimeout, func(ctx context.Context) error {
+		return driver.Load(ctx, argoArtifact, req.Path)
+	})
 	if err != nil {
-		return &artifact.LoadArtifactResponse{
-			Success: false,
-			Error:   err.Error(),
-		}, nil
+		return nil, toStatusError(err)
 	}
 
 	return &artifact.LoadArtifactResponse{
@@ -99,28 +226,174 @@ func (s *artifactServer) Load(ctx context.Context, req *artifact.LoadArtifactReq
 }
 
 func (s *artifactServer) OpenStream(req *artifact.OpenStreamRequest, stream artifact.ArtifactService_OpenStreamServer) error {
-	ctx := logging.WithLogger(stream.Context(), logger)
-	logger.WithField("request", req).Info(ctx, "Open stream request")
+	ctx, logger := s.withLogger(stream.Context())
+	logger.WithField("request", redactRequest(req)).Debug(ctx, "Open stream request")
+	defer serverMetrics.StreamStarted()()
 
-	driver, argoArtifact, err := getDriver(ctx, req.Artifact)
+	driver, argoArtifact, err := getDriver(ctx, req.Artifact, true)
 	if err != nil {
 		return err
 	}
 
-	// Open stream
-	reader, err := driver.OpenStream(ctx, argoArtifact)
+	// Open stream, the timeout only bounds opening as the transfer is paced by the client. The reader uses
+	// the RPC's context rather than the timeout's, as it is read after opening, and is cancelled with the RPC.
+	streamCtx, cancelStream := context.WithCancel(ctx)
+	defer cancelStream()
+	var reader io.ReadCloser
+	err = grpcutil.RunWithTimeout(ctx, "OpenStream", driver.OperationTimeout, func(ctx context.Context) error {
+		r, err := driver.OpenStream(streamCtx, argoArtifact)
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			// Too late, the handler has already given up
+			r.Close()
+			return ctx.Err()
+		}
+		reader = r
+		return nil
+	})
 	if err != nil {
-		return status.Error(codes.Internal, err.Error())
+		return toStatusError(err)
 	}
 	defer reader.Close()
 
-	// Stream data in chunks
-	buffer := make([]byte, 1024*1024) // 1MB chunks
+	return sendChunks(reader, driver.StreamChunkSize, stream)
+}
+
+// toStatusError converts a driver error into a gRPC status error, so callers can tell failures apart by code.
+// Errors which already carry a status, such as timeouts from grpcutil.RunWithTimeout, are returned unchanged.
+func toStatusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	code := codes.Internal
+	var argoErr argoerrs.ArgoError
+	var minioErr minio.ErrorResponse
+	var netErr net.Error
+	var secretErr *s3.SecretResolutionError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, s3.ErrInvalidConfig):
+		code = codes.InvalidArgument
+	case errors.Is(err, s3.ErrObjectTooLarge):
+		code = codes.ResourceExhausted
+	case errors.Is(err, s3.ErrDestinationExists):
+		code = codes.AlreadyExists
+	case errors.As(err, &secretErr):
+		code = secretErrorCode(secretErr.Category)
+	case errors.As(err, &minioErr):
+		code = s3ErrorCode(minioErr)
+	case errors.As(err, &argoErr):
+		code = argoErrorCode(argoErr.Code())
+	case errors.As(err, &netErr):
+		code = codes.Unavailable
+	}
+	return status.Error(code, err.Error())
+}
+
+// s3ErrorCode maps an S3 error response to a gRPC code, by its S3 error code or else its HTTP status
+func s3ErrorCode(err minio.ErrorResponse) codes.Code {
+	switch err.Code {
+	case "NoSuchKey", "NoSuchBucket", "NoSuchUpload":
+		return codes.NotFound
+	case "AccessDenied", "AllAccessDisabled", "InvalidAccessKeyId", "SignatureDoesNotMatch", "ExpiredToken", "InvalidToken":
+		return codes.PermissionDenied
+	case "RequestTimeout":
+		return codes.DeadlineExceeded
+	case "SlowDown", "ServiceUnavailable", "InternalError":
+		return codes.Unavailable
+	case "PreconditionFailed":
+		return codes.FailedPrecondition
+	}
+	switch {
+	case err.StatusCode == http.StatusNotFound:
+		return codes.NotFound
+	case err.StatusCode == http.StatusUnauthorized || err.StatusCode == http.StatusForbidden:
+		return codes.PermissionDenied
+	case err.StatusCode == http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case err.StatusCode >= http.StatusInternalServerError:
+		return codes.Unavailable
+	}
+	return codes.Internal
+}
+
+// secretErrorCode maps why a secret referenced by the configuration couldn't be resolved to a gRPC code
+func secretErrorCode(category s3.SecretErrorCategory) codes.Code {
+	switch category {
+	case s3.SecretNotFound, s3.SecretKeyNotFound:
+		return codes.FailedPrecondition
+	case s3.SecretForbidden:
+		return codes.PermissionDenied
+	}
+	return codes.Unavailable
+}
+
+// argoErrorCode maps an Argo error code, as returned by the driver, to a gRPC code
+func argoErrorCode(code string) codes.Code {
+	switch code {
+	case argoerrs.CodeNotFound:
+		return codes.NotFound
+	case argoerrs.CodeBadRequest:
+		return codes.InvalidArgument
+	case argoerrs.CodeUnauthorized, argoerrs.CodeForbidden:
+		return codes.PermissionDenied
+	case argoerrs.CodeTimeout:
+		return codes.DeadlineExceeded
+	case argoerrs.CodeNotImplemented:
+		return codes.Unimplemented
+	}
+	return codes.Internal
+}
+
+// chunkBufferPools holds a *sync.Pool of OpenStream read buffers for each chunk size in use
+var chunkBufferPools sync.Map
+
+// getChunkBuffer returns a buffer of chunkSize bytes, reusing one returned by putChunkBuffer when available
+func getChunkBuffer(chunkSize int) *[]byte {
+	pool, ok := chunkBufferPools.Load(chunkSize)
+	if !ok {
+		pool, _ = chunkBufferPools.LoadOrStore(chunkSize, &sync.Pool{
+			New: func() any {
+				buffer := make([]byte, chunkSize)
+				return &buffer
+			},
+		})
+	}
+	return pool.(*sync.Pool).Get().(*[]byte)
+}
+
+// putChunkBuffer returns a buffer from getChunkBuffer for reuse
+func putChunkBuffer(buffer *[]byte) {
+	if pool, ok := chunkBufferPools.Load(len(*buffer)); ok {
+		pool.(*sync.Pool).Put(buffer)
+	}
+}
+
+// sendChunks streams the reader to the client in chunks of chunkSize bytes, followed by an end marker.
+// It stops as soon as the client cancels the stream or its deadline passes.
+//
+// gRPC doesn't allow a message to be modified once it has been sent, as interceptors and stats handlers may
+// still hold it, so each response gets its own copy of the chunk. The read buffer is then never referred to by
+// a response, and is reused for the next read and returned to the pool once the stream ends.
+func sendChunks(reader io.Reader, chunkSize int, stream artifact.ArtifactService_OpenStreamServer) error {
+	buffer := getChunkBuffer(chunkSize)
+	defer putChunkBuffer(buffer)
 	for {
-		n, err := reader.Read(buffer)
+		if err := stream.Context().Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		n, err := reader.Read(*buffer)
 		if n > 0 {
 			response := &artifact.OpenStreamResponse{
-				Data:  buffer[:n],
+				Data:  bytes.Clone((*buffer)[:n]),
 				IsEnd: false,
 			}
 			if err := stream.Send(response); err != nil {
@@ -141,31 +414,41 @@ func (s *artifactServer) OpenStream(req *artifact.OpenStreamRequest, stream arti
 }
 
 func (s *artifactServer) Save(ctx context.Context, req *artifact.SaveArtifactRequest) (*artifact.SaveArtifactResponse, error) {
-	ctx = logging.WithLogger(ctx, logger)
-	logger.WithField("request", req).Info(ctx, "Save artifact request")
+	ctx, logger := s.withLogger(ctx)
+	logger.WithField("request", redactRequest(req)).Debug(ctx, "Save artifact request")
 
 	if req.OutputArtifact == nil {
-		return &artifact.SaveArtifactResponse{
-			Success: false,
-			Error:   "output artifact is required",
-		}, nil
+		return nil, status.Error(codes.InvalidArgument, "output artifact is required")
 	}
 
-	driver, argoArtifact, err := getDriver(ctx, req.OutputArtifact)
+	driver, argoArtifact, err := getDriver(ctx, req.OutputArtifact, true)
 	if err != nil {
-		return &artifact.SaveArtifactResponse{
-			Success: false,
-			Error:   err.Error(),
-		}, nil
+		return nil, toStatusError(err)
 	}
 
 	// Save the artifact
-	err = driver.Save(ctx, req.Path, argoArtifact)
+	var stats s3.SaveStats
+	err = grpcutil.RunWithTimeout(ctx, "Save", driver.OperationTimeout, func(ctx context.Context) error {
+		saved, err := driver.SaveWithStats(ctx, req.Path, argoArtifact)
+		if err != nil {
+			return err
+		}
+		stats = saved
+		return nil
+	})
 	if err != nil {
-		return &artifact.SaveArtifactResponse{
-			Success: false,
-			Error:   err.Error(),
-		}, nil
+		return nil, toStatusError(err)
+	}
+
+	serverMetrics.ObserveBytes("Save", s3.LocalPathSize(req.Path))
+	logger.WithFields(logging.Fields{"objectCount": stats.ObjectCount, "totalBytes": stats.TotalBytes, "skipped": stats.Skipped}).Info(ctx, "Saved artifact")
+	// SaveArtifactResponse has no fields for these, so they are returned as response headers
+	if err := grpc.SetHeader(ctx, metadata.Pairs(
+		headerObjectCount, strconv.Itoa(stats.ObjectCount),
+		headerTotalBytes, strconv.FormatInt(stats.TotalBytes, 10),
+		headerSkipped, strconv.FormatBool(stats.Skipped),
+	)); err != nil {
+		logger.WithError(err).Debug(ctx, "Failed to set the save statistics headers")
 	}
 
 	return &artifact.SaveArtifactResponse{
@@ -174,24 +457,31 @@ func (s *artifactServer) Save(ctx context.Context, req *artifact.SaveArtifactReq
 }
 
 func (s *artifactServer) Delete(ctx context.Context, req *artifact.DeleteArtifactRequest) (*artifact.DeleteArtifactResponse, error) {
-	ctx = logging.WithLogger(ctx, logger)
-	logger.WithField("request", req).Info(ctx, "Delete artifact request")
+	ctx, logger := s.withLogger(ctx)
+	logger.WithField("request", redactRequest(req)).Debug(ctx, "Delete artifact request")
 
-	driver, argoArtifact, err := getDriver(ctx, req.Artifact)
+	driver, argoArtifact, err := getDriver(ctx, req.Artifact, true)
 	if err != nil {
-		return &artifact.DeleteArtifactResponse{
-			Success: false,
-			Error:   err.Error(),
-		}, nil
+		return nil, toStatusError(err)
 	}
 
 	// Delete the artifact
-	err = driver.Delete(ctx, argoArtifact)
+	var keys []string
+	err = grpcutil.RunWithTimeout(ctx, "Delete", driver.OperationTimeout, func(ctx context.Context) error {
+		var err error
+		keys, err = driver.DeleteWithKeys(ctx, argoArtifact)
+		// Recorded once the driver returns rather than the RPC, so a delete outliving its timeout is still audited
+		s.audit.Record(ctx, audit.Event{Action: "delete", Bucket: argoArtifact.S3.Bucket, Keys: keys, DryRun: driver.DryRun, Err: err})
+		return err
+	})
 	if err != nil {
-		return &artifact.DeleteArtifactResponse{
-			Success: false,
-			Error:   err.Error(),
-		}, nil
+		return nil, toStatusError(err)
+	}
+
+	// DeleteArtifactResponse has no field for it, so the number of objects deleted, or with dryRun which would have
+	// been, is returned as a response header
+	if err := grpc.SetHeader(ctx, metadata.Pairs(headerObjectCount, strconv.Itoa(len(keys)))); err != nil {
+		logger.WithError(err).Debug(ctx, "Failed to set the delete object count header")
 	}
 
 	return &artifact.DeleteArtifactResponse{
@@ -200,22 +490,42 @@ func (s *artifactServer) Delete(ctx context.Context, req *artifact.DeleteArtifac
 }
 
 func (s *artifactServer) ListObjects(ctx context.Context, req *artifact.ListObjectsRequest) (*artifact.ListObjectsResponse, error) {
-	ctx = logging.WithLogger(ctx, logger)
-	logger.WithField("request", req).Info(ctx, "List objects request")
+	ctx, logger := s.withLogger(ctx)
+	logger.WithField("request", redactRequest(req)).Debug(ctx, "List objects request")
 
-	driver, argoArtifact, err := getDriver(ctx, req.Artifact)
+	// ListObjectsRequest has no fields for paging, so it is requested in metadata
+	md, _ := metadata.FromIncomingContext(ctx)
+	var pageSize int
+	if value := grpcutil.FirstValue(md, headerPageSize); value != "" {
+		var err error
+		pageSize, err = strconv.Atoi(value)
+		if err != nil || pageSize <= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid %s %q, must be a positive integer", headerPageSize, value)
+		}
+	}
+	continuationToken := grpcutil.FirstValue(md, headerContinuationToken)
+
+	driver, argoArtifact, err := getDriver(ctx, req.Artifact, false)
 	if err != nil {
-		return &artifact.ListObjectsResponse{
-			Error: err.Error(),
-		}, nil
+		return nil, toStatusError(err)
 	}
 
-	// List objects
-	objects, err := driver.ListObjects(ctx, argoArtifact)
+	// List objects, everything in one page unless a page size is given
+	var objects []string
+	var nextToken string
+	err = grpcutil.RunWithTimeout(ctx, "ListObjects", driver.OperationTimeout, func(ctx context.Context) error {
+		var err error
+		objects, nextToken, err = driver.ListObjectsPage(ctx, argoArtifact, pageSize, continuationToken)
+		return err
+	})
 	if err != nil {
-		return &artifact.ListObjectsResponse{
-			Error: err.Error(),
-		}, nil
+		return nil, toStatusError(err)
+	}
+
+	if pageSize > 0 {
+		if err := grpc.SetHeader(ctx, metadata.Pairs(headerContinuationToken, nextToken)); err != nil {
+			logger.WithError(err).Debug(ctx, "Failed to set the continuation token header")
+		}
 	}
 
 	return &artifact.ListObjectsResponse{
@@ -224,22 +534,23 @@ func (s *artifactServer) ListObjects(ctx context.Context, req *artifact.ListObje
 }
 
 func (s *artifactServer) IsDirectory(ctx context.Context, req *artifact.IsDirectoryRequest) (*artifact.IsDirectoryResponse, error) {
-	ctx = logging.WithLogger(ctx, logger)
-	logger.WithField("request", req).Info(ctx, "Is directory request")
+	ctx, logger := s.withLogger(ctx)
+	logger.WithField("request", redactRequest(req)).Debug(ctx, "Is directory request")
 
-	driver, argoArtifact, err := getDriver(ctx, req.Artifact)
+	driver, argoArtifact, err := getDriver(ctx, req.Artifact, false)
 	if err != nil {
-		return &artifact.IsDirectoryResponse{
-			Error: err.Error(),
-		}, nil
+		return nil, toStatusError(err)
 	}
 
 	// Check if it's a directory
-	isDir, err := driver.IsDirectory(ctx, argoArtifact)
+	var isDir bool
+	err = grpcutil.RunWithTimeout(ctx, "IsDirectory", driver.OperationTimeout, func(ctx context.Context) error {
+		var err error
+		isDir, err = driver.IsDirectory(ctx, argoArtifact)
+		return err
+	})
 	if err != nil {
-		return &artifact.IsDirectoryResponse{
-			Error: err.Error(),
-		}, nil
+		return nil, toStatusError(err)
 	}
 
 	return &artifact.IsDirectoryResponse{
@@ -247,80 +558,548 @@ func (s *artifactServer) IsDirectory(ctx context.Context, req *artifact.IsDirect
 	}, nil
 }
 
-// startServer creates and configures the gRPC server with the artifact service,
-// sets up the Unix socket listener, and returns both for the caller to manage.
+// maxMsgBytes returns the maximum gRPC message size from the environment, falling back
+// to defaultMaxMsgBytes when it is unset or not a positive integer
+func maxMsgBytes(ctx context.Context) int {
+	value, ok := os.LookupEnv(envVarMaxMsgBytes)
+	if !ok {
+		return defaultMaxMsgBytes
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size <= 0 {
+		logging.RequireLoggerFromContext(ctx).WithFields(logging.Fields{
+			"envVar":  envVarMaxMsgBytes,
+			"value":   value,
+			"default": defaultMaxMsgBytes,
+		}).Warn(ctx, "Ignoring invalid max message size")
+		return defaultMaxMsgBytes
+	}
+	return size
+}
+
+// maxConcurrentRPCs returns the concurrent RPC limit from the environment, 0 (unlimited) when it is unset
+// or not a non-negative integer
+func maxConcurrentRPCs(ctx context.Context) int {
+	value, ok := os.LookupEnv(envVarMaxConcurrentRPCs)
+	if !ok {
+		return 0
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		logging.RequireLoggerFromContext(ctx).WithFields(logging.Fields{
+			"envVar": envVarMaxConcurrentRPCs,
+			"value":  value,
+		}).Warn(ctx, "Ignoring invalid concurrent RPC limit")
+		return 0
+	}
+	return limit
+}
+
+// shutdownTimeout returns the graceful shutdown timeout from the environment, falling back to
+// defaultShutdownTimeout when it is unset or not a positive duration
+func shutdownTimeout(ctx context.Context) time.Duration {
+	value, ok := os.LookupEnv(envVarShutdownTimeout)
+	if !ok {
+		return defaultShutdownTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		logging.RequireLoggerFromContext(ctx).WithFields(logging.Fields{
+			"envVar":  envVarShutdownTimeout,
+			"value":   value,
+			"default": defaultShutdownTimeout,
+		}).Warn(ctx, "Ignoring invalid shutdown timeout")
+		return defaultShutdownTimeout
+	}
+	return timeout
+}
+
+// readOnlyMode returns whether READ_ONLY is set to true. It is false when unset, and any value which isn't a
+// boolean is an error, so a typo fails startup rather than leaving the server writable.
+func readOnlyMode() (bool, error) {
+	value := os.Getenv(envVarReadOnly)
+	if value == "" {
+		return false, nil
+	}
+	readOnly, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q, must be true or false", envVarReadOnly, value)
+	}
+	return readOnly, nil
+}
+
+// serverCredentials returns the TLS credentials from TLS_CERT_FILE and TLS_KEY_FILE, or nil when neither is set
+func serverCredentials() (credentials.TransportCredentials, error) {
+	certFile, keyFile := os.Getenv(envVarTLSCertFile), os.Getenv(envVarTLSKeyFile)
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("%s and %s must be set together", envVarTLSCertFile, envVarTLSKeyFile)
+	}
+	creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the TLS certificate: %w", err)
+	}
+	return creds, nil
+}
+
+// startServer creates and configures the gRPC server with the artifact, multipart upload and health services,
+// sets up the Unix socket or TCP listener, and returns them for the caller to manage.
 // This function handles socket cleanup and directory creation but does not start
-// serving - that's left to the caller.
-func startServer(ctx context.Context, socketPath string) (*grpc.Server, net.Listener, error) {
+// serving - that's left to the caller. The health server reports SERVING once the
+// listener is up.
+func startServer(ctx context.Context, address listenAddress, service artifact.ArtifactServiceServer, uploads *multipart.Server, cleanups *cleanup.Server) (*grpc.Server, *health.Server, net.Listener, error) {
 	// Remove any existing socket file
-	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
-		return nil, nil, err
+	if address.network == "unix" {
+		if err := os.Remove(address.address); err != nil && !os.IsNotExist(err) {
+			return nil, nil, nil, err
+		}
 	}
 
-	// Create the Unix socket listener
-	listener, err := net.Listen("unix", socketPath)
+	// Create the Unix socket or TCP listener
+	listener, err := net.Listen(address.network, address.address)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	// Create and configure the gRPC server
-	server := grpc.NewServer()
-	artifact.RegisterArtifactServiceServer(server, &artifactServer{})
+	creds, err := serverCredentials()
+	if err != nil {
+		_ = listener.Close()
+		return nil, nil, nil, err
+	}
+	readOnly, err := readOnlyMode()
+	if err != nil {
+		_ = listener.Close()
+		return nil, nil, nil, err
+	}
+	msgSize := maxMsgBytes(ctx)
+	logger := logging.RequireLoggerFromContext(ctx)
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		tracing.UnaryServerInterceptor(), serverMetrics.UnaryServerInterceptor(), requestlog.UnaryServerInterceptor(logger),
+	}
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		tracing.StreamServerInterceptor(), serverMetrics.StreamServerInterceptor(), requestlog.StreamServerInterceptor(logger),
+	}
+	if readOnly {
+		// Rejected before the limiter, so an RPC which won't be served doesn't take a slot
+		logger.Info(ctx, "Serving read-only, RPCs which may modify storage are rejected")
+		guard := readonly.New(readMethods...)
+		unaryInterceptors = append(unaryInterceptors, guard.UnaryServerInterceptor())
+		streamInterceptors = append(streamInterceptors, guard.StreamServerInterceptor())
+	}
+	if limit := maxConcurrentRPCs(ctx); limit > 0 {
+		// Limit after tracing, metrics and logging so rejected RPCs are still traced, counted and logged
+		limiter := concurrency.New(limit)
+		unaryInterceptors = append(unaryInterceptors, limiter.UnaryServerInterceptor())
+		streamInterceptors = append(streamInterceptors, limiter.StreamServerInterceptor())
+	}
+	serverOpts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(msgSize),
+		grpc.MaxSendMsgSize(msgSize),
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+		grpc.StatsHandler(serverMetrics.StatsHandler()),
+		grpc.StatsHandler(uploads),
+	}
+	if creds != nil {
+		logging.RequireLoggerFromContext(ctx).WithField("certFile", os.Getenv(envVarTLSCertFile)).Info(ctx, "Serving with TLS")
+		serverOpts = append(serverOpts, grpc.Creds(creds))
+	}
+	server := grpc.NewServer(serverOpts...)
+	artifact.RegisterArtifactServiceServer(server, service)
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	version.Register(server)
+	uploads.Register(server)
+	cleanups.Register(server)
+	newQueryServer(ctx).Register(server)
+	newObjectServer(ctx).Register(server)
+
+	return server, healthServer, listener, nil
+}
+
+// newMultipartServer returns the multipart upload service, which resolves the driver for an upload from its
+// artifact as the artifact service does
+func newMultipartServer(ctx context.Context) *multipart.Server {
+	resolve := func(ctx context.Context, a *artifact.Artifact) (multipart.Uploader, *wfv1.Artifact, time.Duration, error) {
+		driver, argoArtifact, err := getDriver(ctx, a, true)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		return driver, argoArtifact, driver.OperationTimeout, nil
+	}
+	return multipart.New(logging.RequireLoggerFromContext(ctx), resolve, toStatusError)
+}
+
+// newCleanupServer returns the cleanup service, which resolves the driver for a request from its artifact as the
+// artifact service does, and records the deletes with auditLogger
+func newCleanupServer(ctx context.Context, auditLogger *audit.Logger) *cleanup.Server {
+	resolve := func(ctx context.Context, a *artifact.Artifact) (cleanup.Deleter, *wfv1.Artifact, bool, time.Duration, error) {
+		driver, argoArtifact, err := getDriver(ctx, a, true)
+		if err != nil {
+			return nil, nil, false, 0, err
+		}
+		return driver, argoArtifact, driver.DryRun, driver.OperationTimeout, nil
+	}
+	return cleanup.New(logging.RequireLoggerFromContext(ctx), resolve, toStatusError, auditLogger)
+}
+
+// newQueryServer returns the S3 Select service, which resolves the driver for a query from its artifact as the
+// artifact service does
+func newQueryServer(ctx context.Context) *query.Server {
+	resolve := func(ctx context.Context, a *artifact.Artifact) (query.Selector, *wfv1.Artifact, time.Duration, error) {
+		driver, argoArtifact, err := getDriver(ctx, a, true)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		return driver, argoArtifact, driver.OperationTimeout, nil
+	}
+	return query.New(logging.RequireLoggerFromContext(ctx), resolve, toStatusError, s3.DefaultStreamChunkSize)
+}
+
+// newObjectServer returns the object service, which resolves the driver for a request from its artifact as the
+// artifact service does
+func newObjectServer(ctx context.Context) *object.Server {
+	resolve := func(ctx context.Context, a *artifact.Artifact, keyRequired bool) (object.Store, *wfv1.Artifact, time.Duration, error) {
+		driver, argoArtifact, err := getDriver(ctx, a, keyRequired)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		return driver, argoArtifact, driver.OperationTimeout, nil
+	}
+	return object.New(logging.RequireLoggerFromContext(ctx), resolve, toStatusError)
+}
 
-	return server, listener, nil
+// startMetricsServer serves Prometheus metrics on the port from ARTIFACT_PLUGIN_METRICS_PORT.
+// It returns nil when the port isn't set.
+func startMetricsServer(ctx context.Context) *http.Server {
+	port, ok := os.LookupEnv(envVarMetricsPort)
+	if !ok || port == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", serverMetrics.Handler())
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	logger := logging.RequireLoggerFromContext(ctx)
+	go func() {
+		logger.WithField("address", server.Addr).Info(ctx, "Serving metrics")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.WithError(err).Error(ctx, "Metrics server failed")
+		}
+	}()
+	return server
 }
 
-// parseArgs validates command line arguments and returns the socket path
-func parseArgs(ctx context.Context) string {
-	if len(os.Args) != 2 {
-		logger.WithField("usage", "artifact-server <unix-socket-path>").WithFatal().Error(ctx, "Usage")
+// parseArgs validates command line arguments and returns the addresses to listen on
+func parseArgs(ctx context.Context) []listenAddress {
+	logger := logging.RequireLoggerFromContext(ctx)
+	if len(os.Args) < 2 {
+		logger.WithField("usage", "artifact-server <unix-socket-path|unix://path|tcp://host:port>...").WithFatal().Error(ctx, "Usage")
 	}
-	return os.Args[1]
+	addresses, err := parseListenAddresses(strings.Join(os.Args[1:], " "))
+	if err != nil {
+		logger.WithError(err).WithFatal().Error(ctx, "Invalid listen address")
+	}
+	return addresses
 }
 
-// verifySocket checks the socket file was created properly with correct permissions
-func verifySocket(ctx context.Context, socketPath string) {
+// parseListenAddresses parses one or more listen addresses separated by commas or whitespace, each of which
+// must be distinct
+func parseListenAddresses(args string) ([]listenAddress, error) {
+	var addresses []listenAddress
+	for _, arg := range strings.FieldsFunc(args, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		address, err := parseListenAddress(arg)
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(addresses, address) {
+			return nil, fmt.Errorf("listen address %s is given more than once", arg)
+		}
+		addresses = append(addresses, address)
+	}
+	if len(addresses) == 0 {
+		return nil, errors.New("no listen address given")
+	}
+	return addresses, nil
+}
+
+// listenAddress is the network and address the server listens on
+type listenAddress struct {
+	network string
+	address string
+}
+
+// socketPath returns the path of the Unix socket, or "" when listening on TCP
+func (a listenAddress) socketPath() string {
+	if a.network != "unix" {
+		return ""
+	}
+	return a.address
+}
+
+// parseListenAddress parses unix://path, tcp://host:port or a bare Unix socket path. TCP is only allowed
+// when ALLOW_TCP is true, so an unauthenticated server isn't exposed by accident.
+func parseListenAddress(arg string) (listenAddress, error) {
+	scheme, address, found := strings.Cut(arg, "://")
+	if !found {
+		scheme, address = "unix", arg
+	}
+	switch scheme {
+	case "unix":
+		if address == "" {
+			return listenAddress{}, errors.New("unix socket path is empty")
+		}
+		return listenAddress{network: "unix", address: address}, nil
+	case "tcp":
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return listenAddress{}, fmt.Errorf("invalid TCP address %q: %w", address, err)
+		}
+		if allowed, _ := strconv.ParseBool(os.Getenv(envVarAllowTCP)); !allowed {
+			return listenAddress{}, fmt.Errorf("listening on TCP address %s exposes an unauthenticated server, set %s=1 to allow it", address, envVarAllowTCP)
+		}
+		return listenAddress{network: "tcp", address: address}, nil
+	}
+	return listenAddress{}, fmt.Errorf("unsupported listen address scheme %q, expected unix or tcp", scheme)
+}
+
+// verifySocket checks the socket file was created properly with correct permissions. Failing to stat it is an
+// error, unless SOCKET_CHECK_RELAXED is true when it is only logged, as the listener was already created.
+func verifySocket(ctx context.Context, socketPath string) error {
+	logger := logging.RequireLoggerFromContext(ctx)
 	socketInfo, err := os.Stat(socketPath)
 	if err != nil {
-		logger.WithError(err).WithFatal().Error(ctx, "Failed to get socket file info")
+		if relaxed, _ := strconv.ParseBool(os.Getenv(envVarSocketCheckRelaxed)); relaxed {
+			logger.WithError(err).WithField("socketPath", socketPath).Warn(ctx, "Failed to get socket file info, serving anyway")
+			return nil
+		}
+		return fmt.Errorf("failed to get socket file info: %w", err)
 	}
 	logger.WithFields(logging.Fields{
 		"socketPath": socketPath,
 		"mode":       socketInfo.Mode().String(),
 		"size":       socketInfo.Size(),
 	}).Info(ctx, "Unix socket created successfully")
+	return nil
+}
+
+// listeningServer is a gRPC server with its health service and the listener it serves on
+type listeningServer struct {
+	address      listenAddress
+	server       *grpc.Server
+	healthServer *health.Server
+	listener     net.Listener
+}
+
+// startServers starts a server on each address, all serving the same artifact service. When one fails to start,
+// the listeners already created are closed.
+func startServers(ctx context.Context, addresses []listenAddress, auditLogger *audit.Logger) ([]listeningServer, error) {
+	service := &artifactServer{logger: logging.RequireLoggerFromContext(ctx), audit: auditLogger}
+	uploads := newMultipartServer(ctx)
+	cleanups := newCleanupServer(ctx, auditLogger)
+	servers := make([]listeningServer, 0, len(addresses))
+	for _, address := range addresses {
+		server, healthServer, listener, err := startServer(ctx, address, service, uploads, cleanups)
+		if err != nil {
+			for _, started := range servers {
+				_ = started.listener.Close()
+				removeSocket(ctx, started.address.socketPath())
+			}
+			return nil, fmt.Errorf("failed to listen on %s: %w", address.address, err)
+		}
+		servers = append(servers, listeningServer{address: address, server: server, healthServer: healthServer, listener: listener})
+	}
+	return servers, nil
+}
+
+// serveAll serves every server until they have all stopped, returning early with the first error one fails with
+func serveAll(servers []listeningServer) error {
+	errs := make(chan error, len(servers))
+	for _, s := range servers {
+		go func() { errs <- s.server.Serve(s.listener) }()
+	}
+	for range servers {
+		if err := <-errs; err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// setupSignalHandling configures graceful shutdown on SIGTERM
-func setupSignalHandling(ctx context.Context, server *grpc.Server) {
+// setupSignalHandling configures graceful shutdown on SIGTERM and SIGINT
+func setupSignalHandling(ctx context.Context, servers []listeningServer, metricsServer *http.Server) {
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGTERM)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	go handleSignal(ctx, sigCh, servers, metricsServer, shutdownTimeout(ctx))
+}
+
+// handleSignal waits for a shutdown signal, then shuts down every server
+func handleSignal(ctx context.Context, sigCh <-chan os.Signal, servers []listeningServer, metricsServer *http.Server, timeout time.Duration) {
+	sig := <-sigCh
+	logger := logging.RequireLoggerFromContext(ctx)
+	logger.WithFields(logging.Fields{"signal": sig.String(), "timeout": timeout}).Info(ctx, "Received signal, shutting down gracefully")
+	shutdownServers(ctx, servers, metricsServer, timeout)
+}
+
+// shutdownServers reports NOT_SERVING on every server, drains them all together within timeout, along with the
+// metrics server when there is one, then removes their socket files
+func shutdownServers(ctx context.Context, servers []listeningServer, metricsServer *http.Server, timeout time.Duration) {
+	for _, s := range servers {
+		s.healthServer.Shutdown()
+	}
+	var wg sync.WaitGroup
+	if metricsServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stopMetricsServer(ctx, metricsServer, timeout)
+		}()
+	}
+	for _, s := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stopServer(ctx, s.server, timeout)
+		}()
+	}
+	wg.Wait()
+	for _, s := range servers {
+		removeSocket(ctx, s.address.socketPath())
+	}
+}
+
+// stopServer waits up to timeout for in-flight RPCs to finish, then cancels any still running so a stream
+// that never ends can't hold up shutdown
+func stopServer(ctx context.Context, server *grpc.Server, timeout time.Duration) {
+	stopped := make(chan struct{})
 	go func() {
-		<-sigCh
-		logger.Info(ctx, "Received SIGTERM, shutting down gracefully")
 		server.GracefulStop()
+		close(stopped)
 	}()
+	select {
+	case <-stopped:
+	case <-time.After(timeout):
+		logging.RequireLoggerFromContext(ctx).WithField("timeout", timeout).Warn(ctx, "Graceful shutdown timed out, cancelling in-flight RPCs")
+		server.Stop()
+		<-stopped
+	}
+}
+
+// stopMetricsServer waits up to timeout for in-flight scrapes to finish, then closes any connections still open
+func stopMetricsServer(ctx context.Context, server *http.Server, timeout time.Duration) {
+	shutdownCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logging.RequireLoggerFromContext(ctx).WithError(err).Warn(ctx, "Metrics server shutdown timed out, closing its connections")
+		_ = server.Close()
+	}
+}
+
+// removeSocket removes the socket file once serving has ended, so a restarted server never races a stale file.
+// There is nothing to remove when listening on TCP, where socketPath is empty.
+func removeSocket(ctx context.Context, socketPath string) {
+	if socketPath == "" {
+		return
+	}
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		logging.RequireLoggerFromContext(ctx).WithError(err).WithField("socketPath", socketPath).Warn(ctx, "Failed to remove socket file")
+	}
+}
+
+// newAuditLogger returns the audit logger, appending to the AUDIT_LOG_FILE when it is set
+func newAuditLogger() (*audit.Logger, error) {
+	path := os.Getenv(envVarAuditLogFile)
+	if path == "" {
+		return audit.New(os.Stderr), nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", envVarAuditLogFile, err)
+	}
+	return audit.New(f), nil
+}
+
+// newLogger builds the logger from LOG_LEVEL and LOG_FORMAT, defaulting to info level JSON logs
+func newLogger() (logging.Logger, error) {
+	level, err := logging.ParseLevelOr(os.Getenv(envVarLogLevel), defaultLogLevel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", envVarLogLevel, err)
+	}
+	format, err := logging.TypeFromStringOr(os.Getenv(envVarLogFormat), defaultLogFormat)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", envVarLogFormat, err)
+	}
+	return logging.NewSlogLogger(level, format), nil
 }
 
 func main() {
+	logger, err := newLogger()
+	if err != nil {
+		logger = logging.NewSlogLogger(defaultLogLevel, defaultLogFormat)
+		logger.WithError(err).WithFatal().Error(context.Background(), "Failed to configure logging")
+	}
 	ctx := logging.WithLogger(context.Background(), logger)
-	socketPath := parseArgs(ctx)
+	addresses := parseArgs(ctx)
 
-	server, listener, err := startServer(ctx, socketPath)
+	shutdownTracing, err := tracing.Setup(ctx)
+	if err != nil {
+		logger.WithError(err).WithFatal().Error(ctx, "Failed to set up tracing")
+	}
+	defer func() {
+		if err := shutdownTracing(ctx); err != nil {
+			logger.WithError(err).Error(ctx, "Failed to shut down tracing")
+		}
+	}()
+
+	if err := s3.ValidateDefaults(ctx); err != nil {
+		logger.WithError(err).WithFatal().Error(ctx, "Invalid plugin defaults")
+	}
+	if err := s3.ValidateKeyPrefix(); err != nil {
+		logger.WithError(err).WithFatal().Error(ctx, "Invalid key prefix")
+	}
+
+	auditLogger, err := newAuditLogger()
+	if err != nil {
+		logger.WithError(err).WithFatal().Error(ctx, "Failed to configure audit logging")
+	}
+	servers, err := startServers(ctx, addresses, auditLogger)
 	if err != nil {
 		logger.WithError(err).WithFatal().Error(ctx, "Failed to start server")
 	}
-	defer listener.Close()
+	build := version.Get()
+	for _, s := range servers {
+		defer s.listener.Close()
+		defer removeSocket(ctx, s.address.socketPath())
 
-	verifySocket(ctx, socketPath)
-	logger.WithField("socketPath", socketPath).Info(ctx, "Starting artifact plugin server")
+		if socketPath := s.address.socketPath(); socketPath != "" {
+			if err := verifySocket(ctx, socketPath); err != nil {
+				logger.WithError(err).WithFatal().Error(ctx, "Failed to verify socket")
+			}
+		} else {
+			logger.WithField("address", s.address.address).Warn(ctx, "Listening on TCP, the server is unauthenticated")
+		}
+		logger.WithFields(logging.Fields{
+			"network": s.address.network, "address": s.address.address, "version": build.Version, "commit": build.Commit, "goVersion": build.GoVersion,
+		}).Info(ctx, "Starting artifact plugin server")
+	}
 
-	setupSignalHandling(ctx, server)
+	metricsServer := startMetricsServer(ctx)
+	setupSignalHandling(ctx, servers, metricsServer)
 
-	// Log when server is ready to accept connections
-	logger.WithField("address", listener.Addr().String()).Info(ctx, "Server ready to accept connections")
+	// Log when the servers are ready to accept connections
+	for _, s := range servers {
+		logger.WithField("address", s.listener.Addr().String()).Info(ctx, "Server ready to accept connections")
+	}
 
-	if err := server.Serve(listener); err != nil {
+	if err := serveAll(servers); err != nil {
 		logger.WithError(err).WithFatal().Error(ctx, "Failed to serve")
 	}
 }
@@ -3,16 +3,28 @@ package main
 import (
 	"context"
 	"errors"
+	"io"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/prometheus/common/expfmt"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 
+	"github.com/argoproj/argo-workflows/v3/util/logging"
 	"github.com/pipekit/artifact-plugin-s3/pkg/artifact"
+	"github.com/pipekit/artifact-plugin-s3/pkg/audit"
 )
 
 // TestArtifactPluginServer_EndToEnd spins up the real artifact plugin server
@@ -25,11 +37,11 @@ func TestArtifactPluginServer_EndToEnd(t *testing.T) {
 	tmpDir := t.TempDir()
 	socketPath := filepath.Join(tmpDir, "artifact-plugin.sock")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(logging.TestContext(t.Context()), 10*time.Second)
 	defer cancel()
 
 	// Use the actual startServer function from main.go
-	srv, lis, err := startServer(ctx, socketPath)
+	srv, _, lis, err := startServer(ctx, listenAddress{network: "unix", address: socketPath}, &artifactServer{logger: logging.RequireLoggerFromContext(ctx)}, newMultipartServer(ctx), newCleanupServer(ctx, nil))
 	if err != nil {
 		t.Fatalf("failed to start artifact plugin server: %v", err)
 	}
@@ -84,3 +96,287 @@ func TestArtifactPluginServer_EndToEnd(t *testing.T) {
 	}
 	t.Logf("connection reached Idle state")
 }
+
+// TestArtifactPluginServer_HealthCheck verifies the health service reports SERVING once the
+// server is up and NOT_SERVING after it has been shut down.
+func TestArtifactPluginServer_HealthCheck(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	socketPath := filepath.Join(tmpDir, "artifact-plugin.sock")
+
+	ctx, cancel := context.WithTimeout(logging.TestContext(t.Context()), 10*time.Second)
+	defer cancel()
+
+	srv, healthServer, lis, err := startServer(ctx, listenAddress{network: "unix", address: socketPath}, &artifactServer{logger: logging.RequireLoggerFromContext(ctx)}, newMultipartServer(ctx), newCleanupServer(ctx, nil))
+	if err != nil {
+		t.Fatalf("failed to start artifact plugin server: %v", err)
+	}
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(func() {
+		srv.Stop()
+		_ = lis.Close()
+	})
+
+	conn, err := grpc.NewClient("unix://"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to create grpc client: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	client := healthpb.NewHealthClient(conn)
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("health check failed: %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("expected SERVING, got %v", resp.GetStatus())
+	}
+
+	healthServer.Shutdown()
+	resp, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("health check failed: %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("expected NOT_SERVING, got %v", resp.GetStatus())
+	}
+}
+
+// TestArtifactPluginServer_MaxMsgSize verifies the server honours ARTIFACT_PLUGIN_MAX_MSG_BYTES:
+// requests under the limit are handled and requests over it fail cleanly with ResourceExhausted.
+func TestArtifactPluginServer_MaxMsgSize(t *testing.T) {
+	t.Setenv(envVarMaxMsgBytes, "1024")
+
+	tmpDir := t.TempDir()
+	socketPath := filepath.Join(tmpDir, "artifact-plugin.sock")
+
+	ctx, cancel := context.WithTimeout(logging.TestContext(t.Context()), 10*time.Second)
+	defer cancel()
+
+	srv, _, lis, err := startServer(ctx, listenAddress{network: "unix", address: socketPath}, &artifactServer{logger: logging.RequireLoggerFromContext(ctx)}, newMultipartServer(ctx), newCleanupServer(ctx, nil))
+	if err != nil {
+		t.Fatalf("failed to start artifact plugin server: %v", err)
+	}
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(func() {
+		srv.Stop()
+		_ = lis.Close()
+	})
+
+	conn, err := grpc.NewClient("unix://"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to create grpc client: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	client := artifact.NewArtifactServiceClient(conn)
+	listObjects := func(configuration string) (*artifact.ListObjectsResponse, error) {
+		return client.ListObjects(ctx, &artifact.ListObjectsRequest{
+			Artifact: &artifact.Artifact{
+				Plugin: &artifact.PluginArtifact{Configuration: configuration},
+			},
+		})
+	}
+
+	// A small request fits and reaches the handler, which rejects the configuration
+	_, err = listObjects("unknownField: true")
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected small request to be handled and rejected with InvalidArgument, got %v", err)
+	}
+
+	_, err = listObjects(strings.Repeat("a", 4096))
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+}
+
+// TestArtifactPluginServer_Metrics performs RPCs against the server and scrapes the metrics
+// endpoint to verify the per-method request counters were incremented.
+func TestArtifactPluginServer_Metrics(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	socketPath := filepath.Join(tmpDir, "artifact-plugin.sock")
+
+	ctx, cancel := context.WithTimeout(logging.TestContext(t.Context()), 10*time.Second)
+	defer cancel()
+
+	srv, _, lis, err := startServer(ctx, listenAddress{network: "unix", address: socketPath}, &artifactServer{logger: logging.RequireLoggerFromContext(ctx)}, newMultipartServer(ctx), newCleanupServer(ctx, nil))
+	if err != nil {
+		t.Fatalf("failed to start artifact plugin server: %v", err)
+	}
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(func() {
+		srv.Stop()
+		_ = lis.Close()
+	})
+
+	metricsServer := httptest.NewServer(serverMetrics.Handler())
+	t.Cleanup(metricsServer.Close)
+
+	conn, err := grpc.NewClient("unix://"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to create grpc client: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	client := artifact.NewArtifactServiceClient(conn)
+
+	before := scrapeRequestCount(t, metricsServer.URL, "Delete", "failure")
+
+	// Both requests fail validation, which the handler reports as InvalidArgument
+	for range 2 {
+		_, err := client.Delete(ctx, &artifact.DeleteArtifactRequest{})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected delete without an artifact to fail with InvalidArgument, got %v", err)
+		}
+	}
+
+	if after := scrapeRequestCount(t, metricsServer.URL, "Delete", "failure"); after != before+2 {
+		t.Fatalf("expected Delete failure count to increase by 2, went from %v to %v", before, after)
+	}
+}
+
+// scrapeRequestCount scrapes the metrics endpoint and returns the request count for the method and status
+func scrapeRequestCount(t *testing.T, url, method, status string) float64 {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("failed to scrape metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to parse metrics: %v", err)
+	}
+	family, ok := families["artifact_plugin_rpc_requests_total"]
+	if !ok {
+		return 0
+	}
+	for _, m := range family.GetMetric() {
+		labels := map[string]string{}
+		for _, label := range m.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		if labels["method"] == method && labels["status"] == status {
+			return m.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+// TestArtifactPluginServer_SignalShutdown verifies a SIGINT stops the server and removes the socket file
+func TestArtifactPluginServer_SignalShutdown(t *testing.T) {
+	t.Parallel()
+
+	socketPath := filepath.Join(t.TempDir(), "artifact-plugin.sock")
+
+	ctx, cancel := context.WithTimeout(logging.TestContext(t.Context()), 10*time.Second)
+	defer cancel()
+
+	srv, healthServer, lis, err := startServer(ctx, listenAddress{network: "unix", address: socketPath}, &artifactServer{logger: logging.RequireLoggerFromContext(ctx)}, newMultipartServer(ctx), newCleanupServer(ctx, nil))
+	if err != nil {
+		t.Fatalf("failed to start artifact plugin server: %v", err)
+	}
+	// Keep the socket file when the listener closes so only the handler can remove it
+	lis.(*net.UnixListener).SetUnlinkOnClose(false)
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(lis)
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	handled := make(chan struct{})
+	go func() {
+		servers := []listeningServer{{address: listenAddress{network: "unix", address: socketPath}, server: srv, healthServer: healthServer, listener: lis}}
+		handleSignal(ctx, sigCh, servers, nil, defaultShutdownTimeout)
+		close(handled)
+	}()
+	sigCh <- syscall.SIGINT
+
+	select {
+	case err := <-served:
+		// GracefulStop may win the race with Serve starting up
+		if err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			t.Fatalf("server stopped with an error: %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("server did not stop after SIGINT")
+	}
+	select {
+	case <-handled:
+	case <-ctx.Done():
+		t.Fatal("signal handler did not finish")
+	}
+	if _, err := os.Stat(socketPath); !os.IsNotExist(err) {
+		t.Fatalf("expected the socket file to be removed, stat returned %v", err)
+	}
+}
+
+// TestArtifactPluginServer_MultipleSockets verifies a server is started on each socket, every one reachable, and
+// that shutting down stops them all and removes their socket files
+func TestArtifactPluginServer_MultipleSockets(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	socketPaths := []string{filepath.Join(tmpDir, "backend-a.sock"), filepath.Join(tmpDir, "backend-b.sock")}
+
+	ctx, cancel := context.WithTimeout(logging.TestContext(t.Context()), 10*time.Second)
+	defer cancel()
+
+	addresses, err := parseListenAddresses(strings.Join(socketPaths, ","))
+	if err != nil {
+		t.Fatalf("failed to parse the socket paths: %v", err)
+	}
+	servers, err := startServers(ctx, addresses, audit.New(io.Discard))
+	if err != nil {
+		t.Fatalf("failed to start artifact plugin servers: %v", err)
+	}
+	for _, s := range servers {
+		// Keep the socket files when the listeners close so only the shutdown can remove them
+		s.listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- serveAll(servers)
+	}()
+
+	for _, socketPath := range socketPaths {
+		conn, err := grpc.NewClient("unix://"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatalf("failed to create grpc client for %s: %v", socketPath, err)
+		}
+		resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		_ = conn.Close()
+		if err != nil {
+			t.Fatalf("health check on %s failed: %v", socketPath, err)
+		}
+		if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			t.Fatalf("expected SERVING on %s, got %v", socketPath, resp.GetStatus())
+		}
+	}
+
+	shutdownServers(ctx, servers, nil, defaultShutdownTimeout)
+	select {
+	case err := <-served:
+		if err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			t.Fatalf("servers stopped with an error: %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("servers did not stop after shutdown")
+	}
+	for _, socketPath := range socketPaths {
+		if _, err := os.Stat(socketPath); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be removed, stat returned %v", socketPath, err)
+		}
+	}
+}
@@ -1,18 +1,58 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/big"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 
+	argoerrs "github.com/argoproj/argo-workflows/v3/errors"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
 	"github.com/pipekit/artifact-plugin-s3/pkg/artifact"
+	"github.com/pipekit/artifact-plugin-s3/pkg/audit"
+	"github.com/pipekit/artifact-plugin-s3/pkg/cleanup"
+	"github.com/pipekit/artifact-plugin-s3/pkg/multipart"
+	"github.com/pipekit/artifact-plugin-s3/pkg/object"
+	"github.com/pipekit/artifact-plugin-s3/pkg/query"
+	"github.com/pipekit/artifact-plugin-s3/pkg/s3"
+	"github.com/pipekit/artifact-plugin-s3/pkg/version"
 )
 
 // TestServerStartAndConnectUnixSocket spins up the gRPC server on a Unix domain socket and
@@ -32,7 +72,7 @@ func TestServerStartAndConnectUnixSocket(t *testing.T) {
 
 	// Create the gRPC server and register our service implementation.
 	grpcServer := grpc.NewServer()
-	artifact.RegisterArtifactServiceServer(grpcServer, &artifactServer{})
+	artifact.RegisterArtifactServiceServer(grpcServer, &artifactServer{logger: logging.RequireLoggerFromContext(logging.TestContext(t.Context()))})
 
 	// Start serving in the background.
 	go func() {
@@ -84,3 +124,958 @@ func TestServerStartAndConnectUnixSocket(t *testing.T) {
 		}
 	}
 }
+
+// fakeOpenStreamServer records the responses sent on an OpenStream server stream.
+type fakeOpenStreamServer struct {
+	grpc.ServerStream
+	ctx       context.Context
+	responses []*artifact.OpenStreamResponse
+	// onSend is called after each response is recorded
+	onSend func()
+}
+
+func (f *fakeOpenStreamServer) Context() context.Context {
+	return f.ctx
+}
+
+// Send records the response itself, as an interceptor or stats handler may, so a response sharing memory
+// with a later one shows
+func (f *fakeOpenStreamServer) Send(response *artifact.OpenStreamResponse) error {
+	f.responses = append(f.responses, response)
+	if f.onSend != nil {
+		f.onSend()
+	}
+	return nil
+}
+
+// TestSendChunks verifies the payload is split into chunks of the configured size followed by an end marker.
+func TestSendChunks(t *testing.T) {
+	t.Parallel()
+
+	chunkSize := 64 * 1024
+	// Each chunk differs, so a buffer reused before its response was sent would show
+	payload := slices.Concat(bytes.Repeat([]byte("a"), chunkSize), bytes.Repeat([]byte("b"), chunkSize), bytes.Repeat([]byte("c"), chunkSize), []byte("d"))
+	stream := &fakeOpenStreamServer{ctx: t.Context()}
+
+	err := sendChunks(bytes.NewReader(payload), chunkSize, stream)
+	require.NoError(t, err)
+
+	// 3 full chunks, 1 partial chunk and the end marker
+	require.Len(t, stream.responses, 5)
+	var received []byte
+	for _, response := range stream.responses[:4] {
+		assert.False(t, response.IsEnd)
+		received = append(received, response.Data...)
+	}
+	assert.Len(t, stream.responses[3].Data, 1)
+	assert.True(t, stream.responses[4].IsEnd)
+	assert.Equal(t, payload, received)
+}
+
+// patternReader returns size bytes in reads of varying lengths, each read filled with its own byte value
+type patternReader struct {
+	size, read, reads int
+	lengths           []int
+}
+
+func (r *patternReader) Read(p []byte) (int, error) {
+	if r.read == r.size {
+		return 0, io.EOF
+	}
+	n := min(len(p), r.lengths[r.reads%len(r.lengths)], r.size-r.read)
+	for i := range n {
+		p[i] = byte(r.reads)
+	}
+	r.read += n
+	r.reads++
+	return n, nil
+}
+
+// TestSendChunks_Patterns verifies every chunk keeps its own data while the read buffer is reused, including
+// reads shorter than the buffer which only overwrite part of it
+func TestSendChunks_Patterns(t *testing.T) {
+	t.Parallel()
+
+	chunkSize := 64 * 1024
+	lengths := []int{chunkSize, 1, chunkSize / 3, chunkSize, 7, chunkSize/2 + 1}
+	reader := &patternReader{size: 10 * chunkSize, lengths: lengths}
+	var expected []byte
+	for i := 0; len(expected) < reader.size; i++ {
+		n := min(lengths[i%len(lengths)], reader.size-len(expected))
+		expected = append(expected, bytes.Repeat([]byte{byte(i)}, n)...)
+	}
+	stream := &fakeOpenStreamServer{ctx: t.Context()}
+
+	require.NoError(t, sendChunks(reader, chunkSize, stream))
+
+	require.Len(t, stream.responses, reader.reads+1)
+	var received []byte
+	for i, response := range stream.responses[:reader.reads] {
+		assert.Equal(t, bytes.Repeat([]byte{byte(i)}, len(response.Data)), response.Data, "chunk %d", i)
+		received = append(received, response.Data...)
+	}
+	assert.Equal(t, expected, received)
+	assert.True(t, stream.responses[reader.reads].IsEnd)
+}
+
+// discardOpenStreamServer drops every response, for benchmarking
+type discardOpenStreamServer struct {
+	grpc.ServerStream
+}
+
+func (discardOpenStreamServer) Context() context.Context {
+	return context.Background()
+}
+
+func (discardOpenStreamServer) Send(*artifact.OpenStreamResponse) error {
+	return nil
+}
+
+// BenchmarkSendChunks streams a small object per iteration. With the read buffer pooled, an iteration allocates
+// only the responses and their copies of the data rather than a new 1MB buffer.
+func BenchmarkSendChunks(b *testing.B) {
+	chunkSize := 1024 * 1024
+	payload := bytes.Repeat([]byte("a"), 64*1024)
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := sendChunks(bytes.NewReader(payload), chunkSize, discardOpenStreamServer{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestSendChunks_ClientCancel verifies the read loop stops once the client cancels the stream.
+func TestSendChunks_ClientCancel(t *testing.T) {
+	t.Parallel()
+
+	chunkSize := 64 * 1024
+	payload := bytes.Repeat([]byte("a"), 10*chunkSize)
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	// Cancel the client context after the first chunk has been sent
+	stream := &fakeOpenStreamServer{ctx: ctx, onSend: cancel}
+
+	err := sendChunks(bytes.NewReader(payload), chunkSize, stream)
+	require.Error(t, err)
+	assert.Equal(t, codes.Canceled, status.Code(err))
+	require.Len(t, stream.responses, 1)
+	assert.False(t, stream.responses[0].IsEnd)
+}
+
+func TestRemoveSocket(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	socketPath := filepath.Join(t.TempDir(), "artifact-plugin.sock")
+
+	// Leave the socket file behind on close, as a killed server would
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, listener.Close())
+	require.FileExists(t, socketPath)
+
+	removeSocket(ctx, socketPath)
+	assert.NoFileExists(t, socketPath)
+
+	// Removing an already removed socket is a no-op
+	removeSocket(ctx, socketPath)
+}
+
+func TestMaxMsgBytes(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+
+	tests := map[string]struct {
+		value    string
+		set      bool
+		expected int
+	}{
+		"Unset":    {expected: defaultMaxMsgBytes},
+		"Valid":    {value: "33554432", set: true, expected: 32 * 1024 * 1024},
+		"Invalid":  {value: "lots", set: true, expected: defaultMaxMsgBytes},
+		"Negative": {value: "-1", set: true, expected: defaultMaxMsgBytes},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.set {
+				t.Setenv(envVarMaxMsgBytes, tc.value)
+			}
+			assert.Equal(t, tc.expected, maxMsgBytes(ctx))
+		})
+	}
+}
+
+func TestMaxConcurrentRPCs(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+
+	tests := map[string]struct {
+		value    string
+		set      bool
+		expected int
+	}{
+		"Unset":    {expected: 0},
+		"Valid":    {value: "32", set: true, expected: 32},
+		"Invalid":  {value: "many", set: true, expected: 0},
+		"Negative": {value: "-1", set: true, expected: 0},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.set {
+				t.Setenv(envVarMaxConcurrentRPCs, tc.value)
+			}
+			assert.Equal(t, tc.expected, maxConcurrentRPCs(ctx))
+		})
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+
+	tests := map[string]struct {
+		value    string
+		set      bool
+		expected time.Duration
+	}{
+		"Unset":    {expected: defaultShutdownTimeout},
+		"Valid":    {value: "2m", set: true, expected: 2 * time.Minute},
+		"Invalid":  {value: "soon", set: true, expected: defaultShutdownTimeout},
+		"Zero":     {value: "0s", set: true, expected: defaultShutdownTimeout},
+		"Negative": {value: "-5s", set: true, expected: defaultShutdownTimeout},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.set {
+				t.Setenv(envVarShutdownTimeout, tc.value)
+			}
+			assert.Equal(t, tc.expected, shutdownTimeout(ctx))
+		})
+	}
+}
+
+// blockingStreamServer serves OpenStream by blocking until the stream is cancelled
+type blockingStreamServer struct {
+	artifact.UnimplementedArtifactServiceServer
+	started chan struct{}
+}
+
+func (s *blockingStreamServer) OpenStream(_ *artifact.OpenStreamRequest, stream artifact.ArtifactService_OpenStreamServer) error {
+	close(s.started)
+	<-stream.Context().Done()
+	return stream.Context().Err()
+}
+
+// TestStopServer_Timeout verifies a stream that never finishes is cancelled once the shutdown timeout passes
+func TestStopServer_Timeout(t *testing.T) {
+	t.Parallel()
+	ctx := logging.TestContext(t.Context())
+
+	socketPath := filepath.Join(t.TempDir(), "artifact-plugin.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	service := &blockingStreamServer{started: make(chan struct{})}
+	artifact.RegisterArtifactServiceServer(server, service)
+	go func() { _ = server.Serve(listener) }()
+
+	conn, err := grpc.NewClient("unix://"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	stream, err := artifact.NewArtifactServiceClient(conn).OpenStream(t.Context(), &artifact.OpenStreamRequest{})
+	require.NoError(t, err)
+	select {
+	case <-service.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not start")
+	}
+
+	stopped := make(chan struct{})
+	start := time.Now()
+	go func() {
+		stopServer(ctx, server, 100*time.Millisecond)
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop after the shutdown timeout")
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond, "the stream should get the timeout to finish")
+
+	_, err = stream.Recv()
+	require.Error(t, err)
+}
+
+// TestShutdownServers_Metrics verifies the metrics server is shut down along with the gRPC servers
+func TestShutdownServers_Metrics(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	metricsServer := &http.Server{Handler: http.NotFoundHandler(), ReadHeaderTimeout: time.Second}
+	served := make(chan error, 1)
+	go func() { served <- metricsServer.Serve(listener) }()
+
+	shutdownServers(ctx, nil, metricsServer, time.Second)
+	select {
+	case err := <-served:
+		require.ErrorIs(t, err, http.ErrServerClosed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("metrics server did not stop")
+	}
+	_, err = net.DialTimeout("tcp", listener.Addr().String(), time.Second)
+	require.Error(t, err, "the metrics port should no longer accept connections")
+}
+
+func TestParseListenAddress(t *testing.T) {
+	tests := map[string]struct {
+		arg      string
+		allowTCP string
+		expected listenAddress
+		errMsg   string
+	}{
+		"Bare socket path":       {arg: "/tmp/plugin.sock", expected: listenAddress{network: "unix", address: "/tmp/plugin.sock"}},
+		"Unix scheme":            {arg: "unix:///tmp/plugin.sock", expected: listenAddress{network: "unix", address: "/tmp/plugin.sock"}},
+		"Empty unix path":        {arg: "unix://", errMsg: "unix socket path is empty"},
+		"TCP allowed":            {arg: "tcp://127.0.0.1:7070", allowTCP: "1", expected: listenAddress{network: "tcp", address: "127.0.0.1:7070"}},
+		"TCP on all interfaces":  {arg: "tcp://:7070", allowTCP: "true", expected: listenAddress{network: "tcp", address: ":7070"}},
+		"TCP not allowed":        {arg: "tcp://127.0.0.1:7070", errMsg: "set ALLOW_TCP=1 to allow it"},
+		"TCP explicitly refused": {arg: "tcp://127.0.0.1:7070", allowTCP: "0", errMsg: "set ALLOW_TCP=1 to allow it"},
+		"TCP without port":       {arg: "tcp://127.0.0.1", allowTCP: "1", errMsg: "invalid TCP address"},
+		"Unsupported scheme":     {arg: "http://127.0.0.1:7070", errMsg: `unsupported listen address scheme "http"`},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(envVarAllowTCP, tc.allowTCP)
+			address, err := parseListenAddress(tc.arg)
+			if tc.errMsg != "" {
+				require.ErrorContains(t, err, tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, address)
+		})
+	}
+
+	assert.Equal(t, "/tmp/plugin.sock", listenAddress{network: "unix", address: "/tmp/plugin.sock"}.socketPath())
+	assert.Empty(t, listenAddress{network: "tcp", address: "127.0.0.1:7070"}.socketPath())
+}
+
+func TestParseListenAddresses(t *testing.T) {
+	t.Setenv(envVarAllowTCP, "1")
+	a := listenAddress{network: "unix", address: "/tmp/a.sock"}
+	b := listenAddress{network: "unix", address: "/tmp/b.sock"}
+
+	tests := map[string]struct {
+		args     string
+		expected []listenAddress
+		errMsg   string
+	}{
+		"Single":            {args: "/tmp/a.sock", expected: []listenAddress{a}},
+		"Comma separated":   {args: "/tmp/a.sock,/tmp/b.sock", expected: []listenAddress{a, b}},
+		"Space separated":   {args: "/tmp/a.sock /tmp/b.sock", expected: []listenAddress{a, b}},
+		"Mixed separators":  {args: "unix:///tmp/a.sock, tcp://127.0.0.1:7070", expected: []listenAddress{a, {network: "tcp", address: "127.0.0.1:7070"}}},
+		"Duplicate":         {args: "/tmp/a.sock,unix:///tmp/a.sock", errMsg: "given more than once"},
+		"Empty":             {args: " , ", errMsg: "no listen address given"},
+		"One invalid entry": {args: "/tmp/a.sock,http://127.0.0.1:7070", errMsg: "unsupported listen address scheme"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			addresses, err := parseListenAddresses(tc.args)
+			if tc.errMsg != "" {
+				require.ErrorContains(t, err, tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, addresses)
+		})
+	}
+}
+
+// TestVerifySocket verifies a socket which can't be stat'd only stops startup unless the check is relaxed
+func TestVerifySocket(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	socketPath := filepath.Join(t.TempDir(), "plugin.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	missingPath := filepath.Join(t.TempDir(), "missing.sock")
+
+	for name, tc := range map[string]struct {
+		path    string
+		relaxed string
+		errMsg  string
+	}{
+		"Socket exists":         {path: socketPath},
+		"Stat fails":            {path: missingPath, errMsg: "failed to get socket file info"},
+		"Stat fails, relaxed":   {path: missingPath, relaxed: "true"},
+		"Stat fails, unrelaxed": {path: missingPath, relaxed: "false", errMsg: "failed to get socket file info"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(envVarSocketCheckRelaxed, tc.relaxed)
+			err := verifySocket(ctx, tc.path)
+			if tc.errMsg != "" {
+				require.ErrorContains(t, err, tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+// TestStartServer_TCP verifies the server can be reached over TCP
+func TestStartServer_TCP(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	server, _, listener, err := startServer(ctx, listenAddress{network: "tcp", address: "127.0.0.1:0"}, &artifactServer{logger: logging.RequireLoggerFromContext(ctx)}, newMultipartServer(ctx), newCleanupServer(ctx, nil))
+	require.NoError(t, err)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	response, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, response.Status)
+
+	// GetVersion is served alongside the artifact service
+	info, err := version.Fetch(ctx, conn)
+	require.NoError(t, err)
+	assert.NotEmpty(t, info.Version)
+
+	// So are the multipart upload RPCs, which resolve the artifact as the artifact service does
+	err = conn.Invoke(ctx, multipart.InitMultipartMethod, &artifact.Artifact{}, &wrapperspb.StringValue{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// And the cleanup RPC
+	cleanupCtx := metadata.AppendToOutgoingContext(ctx, cleanup.HeaderMaxAge, "168h")
+	err = conn.Invoke(cleanupCtx, cleanup.DeleteOlderThanMethod, &artifact.Artifact{}, &wrapperspb.Int64Value{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// And the S3 Select RPC
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, query.SelectObjectContentMethod)
+	require.NoError(t, err)
+	require.NoError(t, stream.SendMsg(&artifact.Artifact{}))
+	require.NoError(t, stream.CloseSend())
+	err = stream.RecvMsg(&wrapperspb.BytesValue{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+// newStubS3Server serves a bucket holding the objects, enough for the read RPCs to run against
+func newStubS3Server(t *testing.T, bucket string, objects map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprintf(w, "<ListAllMyBucketsResult><Buckets><Bucket><Name>%s</Name><CreationDate>2025-01-01T00:00:00.000Z</CreationDate></Bucket></Buckets></ListAllMyBucketsResult>", bucket)
+			return
+		}
+		if strings.Trim(r.URL.Path, "/") == bucket && r.Method == http.MethodHead {
+			return
+		}
+		if r.URL.Query().Get("list-type") == "2" {
+			// Pages by max-keys, with the next key as the continuation token
+			query := r.URL.Query()
+			prefix, token := query.Get("prefix"), query.Get("continuation-token")
+			maxKeys, err := strconv.Atoi(query.Get("max-keys"))
+			if err != nil {
+				maxKeys = 1000
+			}
+			var contents strings.Builder
+			listed, nextToken := 0, ""
+			for _, key := range slices.Sorted(maps.Keys(objects)) {
+				if !strings.HasPrefix(key, prefix) || key < token {
+					continue
+				}
+				if listed == maxKeys {
+					nextToken = key
+					break
+				}
+				fmt.Fprintf(&contents, "<Contents><Key>%s</Key><Size>%d</Size><ETag>\"etag\"</ETag></Contents>", key, len(objects[key]))
+				listed++
+			}
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprintf(w, "<ListBucketResult><Name>%s</Name><Prefix>%s</Prefix><IsTruncated>%t</IsTruncated><NextContinuationToken>%s</NextContinuationToken>%s</ListBucketResult>",
+				bucket, prefix, nextToken != "", nextToken, contents.String())
+			return
+		}
+		data, ok := objects[strings.TrimPrefix(r.URL.Path, "/"+bucket+"/")]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>")
+			return
+		}
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodGet {
+			_, _ = io.WriteString(w, data)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestStartServer_ReadOnly verifies READ_ONLY rejects every RPC which may modify storage, while the read RPCs
+// are served as usual
+func TestStartServer_ReadOnly(t *testing.T) {
+	t.Setenv(envVarReadOnly, "1")
+	ctx := logging.TestContext(t.Context())
+	s3Server := newStubS3Server(t, "my-bucket", map[string]string{"reports/summary.csv": "id,status\n1,ok\n"})
+	server, _, listener, err := startServer(ctx, listenAddress{network: "tcp", address: "127.0.0.1:0"}, &artifactServer{logger: logging.RequireLoggerFromContext(ctx)}, newMultipartServer(ctx), newCleanupServer(ctx, nil))
+	require.NoError(t, err)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	client := artifact.NewArtifactServiceClient(conn)
+
+	// Credentials which could write, so only the read-only mode stops the mutating RPCs
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	configuration := fmt.Sprintf("endpoint: %s\nbucket: my-bucket\nregion: us-east-1\ninsecure: true\nuseSDKCreds: true\n", strings.TrimPrefix(s3Server.URL, "http://"))
+	plugin := func(key string) *artifact.Artifact {
+		return &artifact.Artifact{Plugin: &artifact.PluginArtifact{Key: key, Configuration: configuration}}
+	}
+
+	t.Run("Mutating RPCs", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "out.csv")
+		require.NoError(t, os.WriteFile(path, []byte("new"), 0o600))
+		_, err := client.Save(ctx, &artifact.SaveArtifactRequest{Path: path, OutputArtifact: plugin("reports/new.csv")})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err), "Save")
+		_, err = client.Delete(ctx, &artifact.DeleteArtifactRequest{Artifact: plugin("reports/summary.csv")})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err), "Delete")
+
+		for method, req := range map[string]proto.Message{
+			multipart.InitMultipartMethod:     plugin("reports/new.csv"),
+			multipart.UploadPartMethod:        wrapperspb.Bytes([]byte("part")),
+			multipart.CompleteMultipartMethod: wrapperspb.String("upload"),
+			multipart.AbortMultipartMethod:    wrapperspb.String("upload"),
+			cleanup.DeleteOlderThanMethod:     plugin("reports/"),
+			object.CopyMethod:                 plugin("reports/summary.csv"),
+			object.PresignedURLMethod:         plugin("reports/summary.csv"),
+			object.WriteObjectMethod:          wrapperspb.Bytes([]byte("new")),
+			object.MoveMethod:                 plugin("reports/summary.csv"),
+		} {
+			err := conn.Invoke(ctx, method, req, &emptypb.Empty{})
+			assert.Equal(t, codes.PermissionDenied, status.Code(err), method)
+		}
+	})
+
+	t.Run("Read RPCs", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "summary.csv")
+		_, err := client.Load(ctx, &artifact.LoadArtifactRequest{InputArtifact: plugin("reports/summary.csv"), Path: path})
+		require.NoError(t, err)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "id,status\n1,ok\n", string(data))
+
+		stream, err := client.OpenStream(ctx, &artifact.OpenStreamRequest{Artifact: plugin("reports/summary.csv")})
+		require.NoError(t, err)
+		var streamed []byte
+		for {
+			response, err := stream.Recv()
+			require.NoError(t, err)
+			streamed = append(streamed, response.Data...)
+			if response.IsEnd {
+				break
+			}
+		}
+		assert.Equal(t, "id,status\n1,ok\n", string(streamed))
+
+		objects, err := client.ListObjects(ctx, &artifact.ListObjectsRequest{Artifact: plugin("reports/")})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"reports/summary.csv"}, objects.Objects)
+
+		isDir, err := client.IsDirectory(ctx, &artifact.IsDirectoryRequest{Artifact: plugin("reports/")})
+		require.NoError(t, err)
+		assert.True(t, isDir.IsDirectory)
+
+		exists := &wrapperspb.BoolValue{}
+		require.NoError(t, conn.Invoke(ctx, object.ExistsMethod, plugin("reports/summary.csv"), exists))
+		assert.True(t, exists.GetValue())
+
+		inline := &wrapperspb.BytesValue{}
+		require.NoError(t, conn.Invoke(ctx, object.ReadObjectMethod, plugin("reports/summary.csv"), inline))
+		assert.Equal(t, "id,status\n1,ok\n", string(inline.GetValue()))
+
+		stat := &structpb.Struct{}
+		require.NoError(t, conn.Invoke(ctx, object.StatMethod, plugin("reports/summary.csv"), stat))
+		assert.Equal(t, float64(len("id,status\n1,ok\n")), stat.GetFields()["size"].GetNumberValue())
+		assert.Equal(t, "etag", stat.GetFields()["etag"].GetStringValue())
+		err = conn.Invoke(ctx, object.StatMethod, plugin("reports/missing.csv"), &structpb.Struct{})
+		assert.Equal(t, codes.NotFound, status.Code(err), "a missing object should be told apart from a permission error")
+
+		check := &structpb.Struct{}
+		require.NoError(t, conn.Invoke(ctx, object.CheckBucketMethod, plugin(""), check))
+		assert.Equal(t, "Reachable", check.GetFields()["result"].GetStringValue())
+
+		buckets := &structpb.ListValue{}
+		require.NoError(t, conn.Invoke(ctx, object.ListBucketsMethod, plugin(""), buckets))
+		assert.Equal(t, []any{map[string]any{"name": "my-bucket", "creationDate": "2025-01-01T00:00:00Z"}}, buckets.AsSlice())
+
+		_, err = version.Fetch(ctx, conn)
+		require.NoError(t, err)
+		_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+	})
+}
+
+// TestListObjects_Pages verifies ListObjects pages through a listing over the socket with the page size and
+// continuation token metadata
+func TestListObjects_Pages(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	s3Server := newStubS3Server(t, "my-bucket", map[string]string{"reports/a.csv": "a", "reports/b.csv": "b", "reports/c.csv": "c"})
+	server, _, listener, err := startServer(ctx, listenAddress{network: "tcp", address: "127.0.0.1:0"}, &artifactServer{logger: logging.RequireLoggerFromContext(ctx)}, newMultipartServer(ctx), newCleanupServer(ctx, nil))
+	require.NoError(t, err)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	client := artifact.NewArtifactServiceClient(conn)
+
+	configuration := fmt.Sprintf("endpoint: %s\nbucket: my-bucket\nregion: us-east-1\ninsecure: true\nanonymous: true\n", strings.TrimPrefix(s3Server.URL, "http://"))
+	req := &artifact.ListObjectsRequest{Artifact: &artifact.Artifact{Plugin: &artifact.PluginArtifact{Key: "reports/", Configuration: configuration}}}
+	list := func(md ...string) ([]string, metadata.MD, error) {
+		var header metadata.MD
+		response, err := client.ListObjects(metadata.AppendToOutgoingContext(ctx, md...), req, grpc.Header(&header))
+		return response.GetObjects(), header, err
+	}
+
+	first, header, err := list(headerPageSize, "2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reports/a.csv", "reports/b.csv"}, first)
+	token := header.Get(headerContinuationToken)
+	require.Len(t, token, 1)
+	require.NotEmpty(t, token[0])
+
+	second, header, err := list(headerPageSize, "2", headerContinuationToken, token[0])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reports/c.csv"}, second)
+	assert.Equal(t, []string{""}, header.Get(headerContinuationToken), "the last page should have no next token")
+
+	all, header, err := list()
+	require.NoError(t, err)
+	assert.Equal(t, append(first, second...), all, "the pages should hold every object once")
+	assert.Empty(t, header.Get(headerContinuationToken))
+
+	t.Run("Invalid page size", func(t *testing.T) {
+		_, _, err := list(headerPageSize, "-1")
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+// TestReadOnlyMode verifies READ_ONLY defaults to false and a value which isn't a boolean fails startup
+func TestReadOnlyMode(t *testing.T) {
+	for value, expected := range map[string]bool{"": false, "true": true, "1": true, "false": false, "0": false} {
+		t.Setenv(envVarReadOnly, value)
+		readOnly, err := readOnlyMode()
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, readOnly, value)
+	}
+
+	ctx := logging.TestContext(t.Context())
+	for _, value := range []string{"yes", "on", "ture"} {
+		t.Setenv(envVarReadOnly, value)
+		_, err := readOnlyMode()
+		require.ErrorContains(t, err, "invalid READ_ONLY", value)
+
+		_, err = startServers(ctx, []listenAddress{{network: "unix", address: filepath.Join(t.TempDir(), "test.sock")}}, nil)
+		require.ErrorContains(t, err, "invalid READ_ONLY", value)
+	}
+}
+
+// TestGetDriver_InvalidACL verifies an unknown canned ACL is rejected as an invalid argument
+func TestGetDriver_InvalidACL(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	a := &artifact.Artifact{Plugin: &artifact.PluginArtifact{Key: "report.csv", Configuration: "bucket: my-bucket\nuseSDKCreds: true\nacl: owner-only\n"}}
+	_, _, err := getDriver(ctx, a, true)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, err.Error(), "acl must be one of")
+}
+
+// TestDelete_Audit verifies a Delete is recorded in the audit log with the key it deleted and the caller's identity
+func TestDelete_Audit(t *testing.T) {
+	// Answers the dry run's existence check for any key
+	s3Server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+	}))
+	t.Cleanup(s3Server.Close)
+
+	var auditLog bytes.Buffer
+	ctx := logging.TestContext(t.Context())
+	server := &artifactServer{logger: logging.RequireLoggerFromContext(ctx), audit: audit.New(&auditLog)}
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(audit.MetadataActor, "workflow-controller"))
+	configuration := fmt.Sprintf("endpoint: %s\nbucket: my-bucket\nregion: us-east-1\ninsecure: true\nanonymous: true\ndryRun: true\n", strings.TrimPrefix(s3Server.URL, "http://"))
+	req := &artifact.DeleteArtifactRequest{Artifact: &artifact.Artifact{Plugin: &artifact.PluginArtifact{Key: "reports/old.csv", Configuration: configuration}}}
+	headers := &headerRecorder{}
+	_, err := server.Delete(grpc.NewContextWithServerTransportStream(ctx, headers), req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, headers.header.Get(headerObjectCount), "the dry run should return how many objects would be deleted")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(auditLog.Bytes(), &record))
+	assert.Equal(t, "delete", record["action"])
+	assert.Equal(t, "my-bucket", record["bucket"])
+	assert.Equal(t, []any{"reports/old.csv"}, record["keys"])
+	assert.Equal(t, "workflow-controller", record["actor"])
+	assert.Equal(t, true, record["dryRun"])
+	assert.NotEmpty(t, record["time"])
+}
+
+func TestSave_Timeout(t *testing.T) {
+	// Holds every request until the test ends, so the save outlives its timeout
+	release := make(chan struct{})
+	s3Server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(s3Server.Close)
+	t.Cleanup(func() { close(release) })
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	require.NoError(t, os.WriteFile(path, []byte("data"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "access-key"), []byte("AKIAEXAMPLE"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret-key"), []byte("secret"), 0o600))
+	ctx := logging.TestContext(t.Context())
+	server := &artifactServer{logger: logging.RequireLoggerFromContext(ctx), audit: audit.New(io.Discard)}
+	configuration := fmt.Sprintf("endpoint: %s\nbucket: my-bucket\nregion: us-east-1\ninsecure: true\naccessKeyFile: %s\nsecretKeyFile: %s\noperationTimeoutSeconds: 1\n",
+		strings.TrimPrefix(s3Server.URL, "http://"), filepath.Join(dir, "access-key"), filepath.Join(dir, "secret-key"))
+	req := &artifact.SaveArtifactRequest{Path: path, OutputArtifact: &artifact.Artifact{Plugin: &artifact.PluginArtifact{Key: "reports/out.txt", Configuration: configuration}}}
+	_, err := server.Save(ctx, req)
+	require.Error(t, err)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Contains(t, err.Error(), "Save timed out after 1s")
+}
+
+// headerRecorder is a grpc.ServerTransportStream recording the response headers an RPC handler sets
+type headerRecorder struct {
+	header metadata.MD
+}
+
+func (h *headerRecorder) Method() string { return "" }
+
+func (h *headerRecorder) SetHeader(md metadata.MD) error {
+	h.header = metadata.Join(h.header, md)
+	return nil
+}
+
+func (h *headerRecorder) SendHeader(md metadata.MD) error { return h.SetHeader(md) }
+
+func (h *headerRecorder) SetTrailer(metadata.MD) error { return nil }
+
+// TestNewAuditLogger verifies audit events are appended to the AUDIT_LOG_FILE
+func TestNewAuditLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	require.NoError(t, os.WriteFile(path, []byte("{}\n"), 0o600))
+	t.Setenv(envVarAuditLogFile, path)
+	auditLogger, err := newAuditLogger()
+	require.NoError(t, err)
+	auditLogger.Record(t.Context(), audit.Event{Action: "delete", Bucket: "my-bucket", Keys: []string{"reports/old.csv"}})
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[1], `"keys":["reports/old.csv"]`)
+
+	t.Setenv(envVarAuditLogFile, filepath.Join(t.TempDir(), "missing", "audit.log"))
+	_, err = newAuditLogger()
+	require.ErrorContains(t, err, "invalid AUDIT_LOG_FILE")
+}
+
+// writeTestCertificate writes a self-signed certificate for 127.0.0.1 and its key, returning their paths and a
+// pool trusting the certificate
+func writeTestCertificate(t *testing.T) (string, string, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "artifact-plugin-test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
+
+// TestStartServer_TLS verifies the server serves TLS when a certificate is configured
+func TestStartServer_TLS(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	certFile, keyFile, pool := writeTestCertificate(t)
+	t.Setenv(envVarTLSCertFile, certFile)
+	t.Setenv(envVarTLSKeyFile, keyFile)
+
+	server, _, listener, err := startServer(ctx, listenAddress{network: "tcp", address: "127.0.0.1:0"}, &artifactServer{logger: logging.RequireLoggerFromContext(ctx)}, newMultipartServer(ctx), newCleanupServer(ctx, nil))
+	require.NoError(t, err)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	check := func(creds credentials.TransportCredentials) error {
+		conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(creds))
+		require.NoError(t, err)
+		defer conn.Close()
+		checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		_, err = healthpb.NewHealthClient(conn).Check(checkCtx, &healthpb.HealthCheckRequest{})
+		return err
+	}
+
+	t.Run("Client trusting the CA", func(t *testing.T) {
+		require.NoError(t, check(credentials.NewClientTLSFromCert(pool, "")))
+	})
+
+	t.Run("Plaintext client", func(t *testing.T) {
+		require.Error(t, check(insecure.NewCredentials()))
+	})
+
+	t.Run("Client not trusting the CA", func(t *testing.T) {
+		require.Error(t, check(credentials.NewClientTLSFromCert(x509.NewCertPool(), "")))
+	})
+}
+
+func TestServerCredentials(t *testing.T) {
+	certFile, keyFile, _ := writeTestCertificate(t)
+
+	tests := map[string]struct {
+		certFile, keyFile string
+		enabled           bool
+		errMsg            string
+	}{
+		"Unset":           {},
+		"Both set":        {certFile: certFile, keyFile: keyFile, enabled: true},
+		"Only the cert":   {certFile: certFile, errMsg: "TLS_CERT_FILE and TLS_KEY_FILE must be set together"},
+		"Only the key":    {keyFile: keyFile, errMsg: "TLS_CERT_FILE and TLS_KEY_FILE must be set together"},
+		"Missing file":    {certFile: filepath.Join(t.TempDir(), "missing.crt"), keyFile: keyFile, errMsg: "failed to load the TLS certificate"},
+		"Mismatched pair": {certFile: keyFile, keyFile: certFile, errMsg: "failed to load the TLS certificate"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(envVarTLSCertFile, tc.certFile)
+			t.Setenv(envVarTLSKeyFile, tc.keyFile)
+			creds, err := serverCredentials()
+			if tc.errMsg != "" {
+				require.ErrorContains(t, err, tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.enabled, creds != nil)
+		})
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	tests := map[string]struct {
+		level    string
+		format   string
+		expected logging.Level
+		errMsg   string
+	}{
+		"Defaults":         {expected: logging.Info},
+		"Debug text":       {level: "debug", format: "text", expected: logging.Debug},
+		"Case insensitive": {level: "WARN", format: "JSON", expected: logging.Warn},
+		"Invalid level":    {level: "verbose", errMsg: "invalid LOG_LEVEL"},
+		"Invalid format":   {format: "xml", errMsg: "invalid LOG_FORMAT"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(envVarLogLevel, tc.level)
+			t.Setenv(envVarLogFormat, tc.format)
+			logger, err := newLogger()
+			if tc.errMsg != "" {
+				require.ErrorContains(t, err, tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, logger.Level())
+		})
+	}
+}
+
+func TestRedactRequest(t *testing.T) {
+	req := &artifact.SaveArtifactRequest{
+		Path: "/tmp/out",
+		OutputArtifact: &artifact.Artifact{
+			Plugin: &artifact.PluginArtifact{Configuration: "bucket: my-bucket\nsecretKey: wJalrXUtnFEMIEXAMPLEKEY\n"},
+		},
+	}
+
+	redacted := redactRequest(req)
+	assert.NotContains(t, redacted.GetOutputArtifact().GetPlugin().GetConfiguration(), "wJalrXUtnFEMIEXAMPLEKEY")
+	assert.Contains(t, redacted.GetOutputArtifact().GetPlugin().GetConfiguration(), "my-bucket")
+	assert.Equal(t, "/tmp/out", redacted.GetPath())
+	assert.Contains(t, req.GetOutputArtifact().GetPlugin().GetConfiguration(), "wJalrXUtnFEMIEXAMPLEKEY", "the request itself must not be modified")
+
+	assert.Nil(t, redactRequest(&artifact.DeleteArtifactRequest{}).GetArtifact())
+}
+
+func TestToStatusError(t *testing.T) {
+	// The driver wraps S3 errors with context, and with the backoff's timeout once the retries are exhausted
+	wrap := func(err error) error {
+		return fmt.Errorf("timed out waiting for the condition: %w", fmt.Errorf("failed to get file: %w", err))
+	}
+
+	for name, tc := range map[string]struct {
+		err  error
+		code codes.Code
+	}{
+		"No such key":           {err: wrap(minio.ErrorResponse{Code: "NoSuchKey", StatusCode: 404}), code: codes.NotFound},
+		"No such bucket":        {err: wrap(minio.ErrorResponse{Code: "NoSuchBucket", StatusCode: 404}), code: codes.NotFound},
+		"Access denied":         {err: wrap(minio.ErrorResponse{Code: "AccessDenied", StatusCode: 403}), code: codes.PermissionDenied},
+		"Invalid access key":    {err: wrap(minio.ErrorResponse{Code: "InvalidAccessKeyId", StatusCode: 403}), code: codes.PermissionDenied},
+		"Slow down":             {err: wrap(minio.ErrorResponse{Code: "SlowDown", StatusCode: 503}), code: codes.Unavailable},
+		"Unknown server error":  {err: wrap(minio.ErrorResponse{Code: "Whatever", StatusCode: 502}), code: codes.Unavailable},
+		"S3 request timeout":    {err: wrap(minio.ErrorResponse{Code: "RequestTimeout", StatusCode: 400}), code: codes.DeadlineExceeded},
+		"Precondition failed":   {err: wrap(minio.ErrorResponse{Code: "PreconditionFailed", StatusCode: 412}), code: codes.FailedPrecondition},
+		"Connection refused":    {err: wrap(&url.Error{Op: "Get", URL: "http://minio:9000", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}), code: codes.Unavailable},
+		"Context deadline":      {err: wrap(context.DeadlineExceeded), code: codes.DeadlineExceeded},
+		"Context canceled":      {err: wrap(context.Canceled), code: codes.Canceled},
+		"Argo not found":        {err: argoerrs.New(argoerrs.CodeNotFound, "no key found"), code: codes.NotFound},
+		"Argo bad request":      {err: argoerrs.New(argoerrs.CodeBadRequest, "different buckets"), code: codes.InvalidArgument},
+		"Invalid configuration": {err: fmt.Errorf("%w: bucket is required", s3.ErrInvalidConfig), code: codes.InvalidArgument},
+		"Object too large":      {err: fmt.Errorf("%w: config.yaml is 9000000 bytes", s3.ErrObjectTooLarge), code: codes.ResourceExhausted},
+		"Destination exists":    {err: fmt.Errorf("%w: /tmp/artifact", s3.ErrDestinationExists), code: codes.AlreadyExists},
+		"Secret not found":      {err: fmt.Errorf("failed to resolve access key: %w", &s3.SecretResolutionError{Namespace: "argo", Name: "my-cred", Key: "accesskey", Category: s3.SecretNotFound, Err: errors.New("not found")}), code: codes.FailedPrecondition},
+		"Secret key not found":  {err: &s3.SecretResolutionError{Namespace: "argo", Name: "my-cred", Key: "accesskey", Category: s3.SecretKeyNotFound}, code: codes.FailedPrecondition},
+		"Secret forbidden":      {err: &s3.SecretResolutionError{Namespace: "argo", Name: "my-cred", Key: "accesskey", Category: s3.SecretForbidden, Err: errors.New("forbidden")}, code: codes.PermissionDenied},
+		"Secret lookup failed":  {err: &s3.SecretResolutionError{Namespace: "argo", Name: "my-cred", Key: "accesskey", Category: s3.SecretLookupFailed, Err: errors.New("unavailable")}, code: codes.Unavailable},
+		"Existing status":       {err: status.Error(codes.ResourceExhausted, "too many requests"), code: codes.ResourceExhausted},
+		"Anything else":         {err: errors.New("disk full"), code: codes.Internal},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := toStatusError(tc.err)
+			assert.Equal(t, tc.code, status.Code(err))
+			assert.Contains(t, err.Error(), tc.err.Error())
+		})
+	}
+
+	require.NoError(t, toStatusError(nil))
+}
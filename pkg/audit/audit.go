@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"context"
+	"io"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+	"github.com/pipekit/artifact-plugin-s3/pkg/requestlog"
+)
+
+// MetadataActor is the metadata key a caller identifies itself with, recorded as the actor of its audit events
+const MetadataActor = "x-actor"
+
+// unknownActor is recorded as the actor of a caller which didn't identify itself
+const unknownActor = "unknown"
+
+// Event is an audited operation
+type Event struct {
+	// Action is the operation, such as delete
+	Action string
+	Bucket string
+	// Keys are the keys the operation acted on, or with DryRun would have
+	Keys   []string
+	DryRun bool
+	// Err is the error the operation failed with, nil when it succeeded
+	Err error
+}
+
+// Logger writes audit events as JSON records, through a logger of its own so they can be sent apart from the
+// server's logs. A nil Logger records nothing.
+type Logger struct {
+	logger logging.Logger
+}
+
+// New returns a Logger writing to w
+func New(w io.Writer) *Logger {
+	return &Logger{logger: logging.NewSlogLoggerCustom(logging.Info, logging.JSON, w)}
+}
+
+// Record writes the event at info level along with the caller's identity and request ID, and the time it is
+// recorded at
+func (l *Logger) Record(ctx context.Context, event Event) {
+	if l == nil {
+		return
+	}
+	fields := logging.Fields{
+		"audit":     true,
+		"action":    event.Action,
+		"bucket":    event.Bucket,
+		"keys":      event.Keys,
+		"dryRun":    event.DryRun,
+		"actor":     Actor(ctx),
+		"requestId": requestlog.ID(ctx),
+		"success":   event.Err == nil,
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil && p.Addr.String() != "" {
+		fields["peer"] = p.Addr.String()
+	}
+	logger := l.logger.WithFields(fields)
+	if event.Err != nil {
+		logger = logger.WithError(event.Err)
+	}
+	logger.Info(ctx, "Audit event")
+}
+
+// Actor returns the identity the caller gave in the x-actor metadata, or unknown when it gave none
+func Actor(ctx context.Context) string {
+	if values := metadata.ValueFromIncomingContext(ctx, MetadataActor); len(values) > 0 && values[0] != "" {
+		return values[0]
+	}
+	return unknownActor
+}
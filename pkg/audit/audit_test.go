@@ -0,0 +1,47 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRecord(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf)
+	ctx := metadata.NewIncomingContext(t.Context(), metadata.Pairs(MetadataActor, "ci-bot"))
+	logger.Record(ctx, Event{Action: "delete", Bucket: "my-bucket", Keys: []string{"reports/a.csv", "reports/b.csv"}})
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, true, record["audit"])
+	assert.Equal(t, "delete", record["action"])
+	assert.Equal(t, "my-bucket", record["bucket"])
+	assert.Equal(t, []any{"reports/a.csv", "reports/b.csv"}, record["keys"])
+	assert.Equal(t, "ci-bot", record["actor"])
+	assert.Equal(t, false, record["dryRun"])
+	assert.Equal(t, true, record["success"])
+	assert.NotEmpty(t, record["time"])
+
+	t.Run("Failure", func(t *testing.T) {
+		buf.Reset()
+		logger.Record(t.Context(), Event{Action: "delete", Bucket: "my-bucket", Keys: []string{"reports/a.csv"}, DryRun: true, Err: errors.New("access denied")})
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+		assert.Equal(t, "unknown", record["actor"])
+		assert.Equal(t, true, record["dryRun"])
+		assert.Equal(t, false, record["success"])
+		assert.Contains(t, buf.String(), "access denied")
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		var logger *Logger
+		assert.NotPanics(t, func() { logger.Record(t.Context(), Event{Action: "delete"}) })
+	})
+}
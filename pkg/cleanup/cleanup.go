@@ -0,0 +1,106 @@
+package cleanup
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+	"github.com/pipekit/artifact-plugin-s3/internal/grpcutil"
+	"github.com/pipekit/artifact-plugin-s3/pkg/artifact"
+	"github.com/pipekit/artifact-plugin-s3/pkg/audit"
+)
+
+const (
+	// ServiceName is the gRPC service serving cleanups alongside the artifact service
+	ServiceName = "artifactplugin.s3.Cleanup"
+	// DeleteOlderThanMethod is the full gRPC method name of the service's RPC
+	DeleteOlderThanMethod = "/" + ServiceName + "/DeleteOlderThan"
+
+	// HeaderMaxAge is the request metadata carrying the age, as a Go duration such as 720h, beyond which objects
+	// are deleted
+	HeaderMaxAge = "artifact-max-age"
+	// HeaderDryRun is the request metadata which, when true, previews the delete without deleting anything
+	HeaderDryRun = "artifact-dry-run"
+)
+
+// Deleter is the driver's age based delete API
+type Deleter interface {
+	DeleteOlderThan(ctx context.Context, artifact *wfv1.Artifact, maxAge time.Duration, dryRun bool) ([]string, error)
+}
+
+// Resolver returns the deleter and Argo artifact for the artifact of a DeleteOlderThan request, whether the
+// artifact's configuration only previews deletes, and the timeout bounding the delete
+type Resolver func(ctx context.Context, artifact *artifact.Artifact) (Deleter, *wfv1.Artifact, bool, time.Duration, error)
+
+// Server serves age based deletes of the objects under an artifact's key prefix, recording each in the audit log
+type Server struct {
+	logger   logging.Logger
+	resolve  Resolver
+	toStatus func(error) error
+	audit    *audit.Logger
+}
+
+// New returns a Server resolving each request's deleter with resolve, converting their errors to gRPC status errors
+// with toStatus, and recording the deletes with auditLogger
+func New(logger logging.Logger, resolve Resolver, toStatus func(error) error, auditLogger *audit.Logger) *Server {
+	return &Server{logger: logger, resolve: resolve, toStatus: toStatus, audit: auditLogger}
+}
+
+// serviceDesc describes the cleanup service. The age and dry run flag are passed as request metadata, so the
+// request is the artifact message alone and the response the count as an Int64Value.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "DeleteOlderThan", Handler: grpcutil.UnaryHandler(DeleteOlderThanMethod, (*Server).DeleteOlderThan)},
+	},
+	Metadata: "cleanup",
+}
+
+// Register registers the cleanup service on server
+func (s *Server) Register(server grpc.ServiceRegistrar) {
+	server.RegisterService(&serviceDesc, s)
+}
+
+// DeleteOlderThan deletes the objects under the artifact's key prefix last modified longer ago than the
+// artifact-max-age metadata, returning how many were deleted, or with artifact-dry-run how many would have been
+func (s *Server) DeleteOlderThan(ctx context.Context, req *artifact.Artifact) (*wrapperspb.Int64Value, error) {
+	ctx = grpcutil.WithLogger(ctx, s.logger)
+	md, _ := metadata.FromIncomingContext(ctx)
+	maxAgeValue, dryRunValue := grpcutil.FirstValue(md, HeaderMaxAge), grpcutil.FirstValue(md, HeaderDryRun)
+	maxAge, err := time.ParseDuration(maxAgeValue)
+	if err != nil || maxAge <= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s %q, must be a positive duration such as 720h", HeaderMaxAge, maxAgeValue)
+	}
+	dryRun := false
+	if dryRunValue != "" {
+		if dryRun, err = strconv.ParseBool(dryRunValue); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid %s %q", HeaderDryRun, dryRunValue)
+		}
+	}
+
+	deleter, argoArtifact, configDryRun, timeout, err := s.resolve(ctx, req)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	var keys []string
+	err = grpcutil.RunWithTimeout(ctx, "DeleteOlderThan", timeout, func(ctx context.Context) error {
+		var err error
+		keys, err = deleter.DeleteOlderThan(ctx, argoArtifact, maxAge, dryRun)
+		// Recorded once the deleter returns rather than the RPC, so a delete outliving its timeout is still audited
+		s.audit.Record(ctx, audit.Event{Action: "deleteOlderThan", Bucket: argoArtifact.S3.Bucket, Keys: keys, DryRun: dryRun || configDryRun, Err: err})
+		return err
+	})
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return wrapperspb.Int64(int64(len(keys))), nil
+}
@@ -0,0 +1,130 @@
+package cleanup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+	"github.com/pipekit/artifact-plugin-s3/pkg/artifact"
+	"github.com/pipekit/artifact-plugin-s3/pkg/audit"
+)
+
+// fakeDeleter returns its keys as deleted, recording the arguments of the last call
+type fakeDeleter struct {
+	keys   []string
+	err    error
+	maxAge time.Duration
+	dryRun bool
+}
+
+func (f *fakeDeleter) DeleteOlderThan(_ context.Context, _ *wfv1.Artifact, maxAge time.Duration, dryRun bool) ([]string, error) {
+	f.maxAge, f.dryRun = maxAge, dryRun
+	return f.keys, f.err
+}
+
+// startServer serves the cleanup service over bufconn, returning a client connected to it
+func startServer(t *testing.T, deleter Deleter, configDryRun bool, auditLog *bytes.Buffer) *grpc.ClientConn {
+	t.Helper()
+	resolve := func(_ context.Context, a *artifact.Artifact) (Deleter, *wfv1.Artifact, bool, time.Duration, error) {
+		if a.GetPlugin().GetKey() == "" {
+			return nil, nil, false, 0, status.Error(codes.InvalidArgument, "plugin artifact key is required")
+		}
+		return deleter, &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: a.GetPlugin().GetKey()}}}, configDryRun, time.Minute, nil
+	}
+	s := New(logging.RequireLoggerFromContext(logging.TestContext(t.Context())), resolve, func(err error) error { return err }, audit.New(auditLog))
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	s.Register(server)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func deleteOlderThan(ctx context.Context, conn *grpc.ClientConn, key string, md ...string) (int64, error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, md...)
+	count := &wrapperspb.Int64Value{}
+	err := conn.Invoke(ctx, DeleteOlderThanMethod, &artifact.Artifact{Plugin: &artifact.PluginArtifact{Key: key}}, count)
+	return count.GetValue(), err
+}
+
+func TestDeleteOlderThan(t *testing.T) {
+	var auditLog bytes.Buffer
+	deleter := &fakeDeleter{keys: []string{"runs/a/out.log", "runs/c/out.log"}}
+	conn := startServer(t, deleter, false, &auditLog)
+
+	count, err := deleteOlderThan(t.Context(), conn, "runs/", HeaderMaxAge, "168h", audit.MetadataActor, "nightly-cleanup")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	assert.Equal(t, 168*time.Hour, deleter.maxAge)
+	assert.False(t, deleter.dryRun)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(auditLog.Bytes(), &record))
+	assert.Equal(t, "deleteOlderThan", record["action"])
+	assert.Equal(t, []any{"runs/a/out.log", "runs/c/out.log"}, record["keys"])
+	assert.Equal(t, "nightly-cleanup", record["actor"])
+	assert.Equal(t, false, record["dryRun"])
+
+	t.Run("Dry run", func(t *testing.T) {
+		auditLog.Reset()
+		count, err := deleteOlderThan(t.Context(), conn, "runs/", HeaderMaxAge, "168h", HeaderDryRun, "true")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+		assert.True(t, deleter.dryRun)
+		assert.Contains(t, auditLog.String(), `"dryRun":true`)
+	})
+
+	t.Run("Configured dry run", func(t *testing.T) {
+		var auditLog bytes.Buffer
+		_, err := deleteOlderThan(t.Context(), startServer(t, &fakeDeleter{}, true, &auditLog), "runs/", HeaderMaxAge, "168h")
+		require.NoError(t, err)
+		assert.Contains(t, auditLog.String(), `"dryRun":true`)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, md := range [][]string{
+			nil,
+			{HeaderMaxAge, "7d"},
+			{HeaderMaxAge, "-1h"},
+			{HeaderMaxAge, "168h", HeaderDryRun, "maybe"},
+		} {
+			_, err := deleteOlderThan(t.Context(), conn, "runs/", md...)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err), md)
+		}
+		_, err := deleteOlderThan(t.Context(), conn, "", HeaderMaxAge, "168h")
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Deleter error", func(t *testing.T) {
+		var auditLog bytes.Buffer
+		deleter := &fakeDeleter{keys: []string{"runs/a/out.log"}, err: status.Error(codes.Unavailable, "s3 is down")}
+		_, err := deleteOlderThan(t.Context(), startServer(t, deleter, false, &auditLog), "runs/", HeaderMaxAge, "168h")
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(auditLog.Bytes(), &record))
+		assert.Equal(t, false, record["success"])
+		assert.Equal(t, []any{"runs/a/out.log"}, record["keys"])
+	})
+}
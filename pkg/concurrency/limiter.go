@@ -0,0 +1,67 @@
+package concurrency
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Limiter bounds the number of RPCs served at once, rejecting any RPC over the limit
+// instead of queueing it. Health checks are never limited.
+type Limiter struct {
+	sem chan struct{}
+}
+
+// New creates a limiter allowing up to limit concurrent RPCs
+func New(limit int) *Limiter {
+	return &Limiter{sem: make(chan struct{}, limit)}
+}
+
+// UnaryServerInterceptor rejects unary RPCs over the limit with ResourceExhausted
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if exempt(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		release, err := l.acquire(info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects streaming RPCs over the limit with ResourceExhausted
+func (l *Limiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if exempt(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		release, err := l.acquire(info.FullMethod)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return handler(srv, ss)
+	}
+}
+
+// acquire takes a slot without blocking, returning the function releasing it
+func (l *Limiter) acquire(fullMethod string) (func(), error) {
+	select {
+	case l.sem <- struct{}{}:
+		return func() { <-l.sem }, nil
+	default:
+		return nil, status.Errorf(codes.ResourceExhausted, "%s rejected: %d concurrent RPCs already in progress", fullMethod, cap(l.sem))
+	}
+}
+
+// exempt reports whether the method is a health check, which must keep answering under load
+func exempt(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/"+healthpb.Health_ServiceDesc.ServiceName+"/")
+}
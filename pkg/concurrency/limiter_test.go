@@ -0,0 +1,67 @@
+package concurrency
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := New(2).UnaryServerInterceptor()
+	load := &grpc.UnaryServerInfo{FullMethod: "/artifact.ArtifactService/Load"}
+
+	// Saturate the limit with handlers blocked until released
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	for range 2 {
+		go func() {
+			_, err := interceptor(t.Context(), nil, load, func(context.Context, any) (any, error) {
+				started <- struct{}{}
+				<-release
+				return nil, nil
+			})
+			done <- err
+		}()
+		<-started
+	}
+
+	_, err := interceptor(t.Context(), nil, load, func(context.Context, any) (any, error) {
+		t.Fatal("handler called over the limit")
+		return nil, nil
+	})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// Health checks are served regardless of the limit
+	health := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+	_, err = interceptor(t.Context(), nil, health, func(context.Context, any) (any, error) { return nil, nil })
+	require.NoError(t, err)
+
+	close(release)
+	require.NoError(t, <-done)
+	require.NoError(t, <-done)
+
+	// Slots are released once the handlers return
+	_, err = interceptor(t.Context(), nil, load, func(context.Context, any) (any, error) { return nil, nil })
+	require.NoError(t, err)
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	interceptor := New(1).StreamServerInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/artifact.ArtifactService/OpenStream"}
+
+	err := interceptor(nil, nil, info, func(any, grpc.ServerStream) error {
+		nested := interceptor(nil, nil, info, func(any, grpc.ServerStream) error {
+			t.Fatal("handler called over the limit")
+			return nil
+		})
+		assert.Equal(t, codes.ResourceExhausted, status.Code(nested))
+		return nil
+	})
+	require.NoError(t, err)
+}
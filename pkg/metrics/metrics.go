@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/stats"
+)
+
+const (
+	namespace = "artifact_plugin"
+
+	statusSuccess = "success"
+	statusFailure = "failure"
+)
+
+// Metrics records per RPC request counts, latencies and bytes transferred, and the streams and connections open
+type Metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	latency         *prometheus.HistogramVec
+	bytes           *prometheus.HistogramVec
+	activeStreams   prometheus.Gauge
+	openConnections prometheus.Gauge
+}
+
+// New creates the RPC metrics in their own registry
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rpc_requests_total",
+			Help:      "Total number of RPC requests, by method and status.",
+		}, []string{"method", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "rpc_duration_seconds",
+			Help:      "RPC latency in seconds, by method.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+		}, []string{"method"}),
+		bytes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "transferred_bytes",
+			Help:      "Bytes transferred per RPC, by method.",
+			Buckets:   prometheus.ExponentialBuckets(1024, 4, 12),
+		}, []string{"method"}),
+		activeStreams: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "active_streams",
+			Help:      "Number of OpenStream calls in progress.",
+		}),
+		openConnections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "open_connections",
+			Help:      "Number of open gRPC connections.",
+		}),
+	}
+	m.registry.MustRegister(m.requests, m.latency, m.bytes, m.activeStreams, m.openConnections)
+	return m
+}
+
+// StreamStarted counts an OpenStream call as in progress until the returned function is called, which should be
+// deferred so cancelled and failed streams are counted as finished too
+func (m *Metrics) StreamStarted() func() {
+	m.activeStreams.Inc()
+	return m.activeStreams.Dec
+}
+
+// StatsHandler returns a gRPC stats handler counting the server's open connections
+func (m *Metrics) StatsHandler() stats.Handler {
+	return &connectionStatsHandler{openConnections: m.openConnections}
+}
+
+// connectionStatsHandler tracks the lifecycle of connections, ignoring the RPCs on them
+type connectionStatsHandler struct {
+	openConnections prometheus.Gauge
+}
+
+func (h *connectionStatsHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h *connectionStatsHandler) HandleRPC(context.Context, stats.RPCStats) {}
+
+func (h *connectionStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h *connectionStatsHandler) HandleConn(_ context.Context, s stats.ConnStats) {
+	switch s.(type) {
+	case *stats.ConnBegin:
+		h.openConnections.Inc()
+	case *stats.ConnEnd:
+		h.openConnections.Dec()
+	}
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveBytes records the number of bytes transferred by a method
+func (m *Metrics) ObserveBytes(method string, n int64) {
+	m.bytes.WithLabelValues(method).Observe(float64(n))
+}
+
+// errorResponse is implemented by the artifact responses which report failures in an Error field
+type errorResponse interface {
+	GetError() string
+}
+
+// UnaryServerInterceptor counts and times unary RPCs. A gRPC error, or a response with a non-empty
+// Error field, counts as a failure.
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		failed := err != nil
+		if r, ok := resp.(errorResponse); ok && r.GetError() != "" {
+			failed = true
+		}
+		m.observe(methodName(info.FullMethod), start, failed)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor counts and times streaming RPCs, and records the bytes sent
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		method := methodName(info.FullMethod)
+		counting := &countingServerStream{ServerStream: ss}
+		err := handler(srv, counting)
+		m.ObserveBytes(method, counting.sent)
+		m.observe(method, start, err != nil)
+		return err
+	}
+}
+
+func (m *Metrics) observe(method string, start time.Time, failed bool) {
+	status := statusSuccess
+	if failed {
+		status = statusFailure
+	}
+	m.requests.WithLabelValues(method, status).Inc()
+	m.latency.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// methodName returns the bare method name of a full gRPC method, e.g. Load for /artifact.ArtifactService/Load
+func methodName(fullMethod string) string {
+	return path.Base(fullMethod)
+}
+
+// dataMessage is implemented by streamed messages carrying a data payload
+type dataMessage interface {
+	GetData() []byte
+}
+
+// countingServerStream counts the payload bytes sent on a server stream
+type countingServerStream struct {
+	grpc.ServerStream
+	sent int64
+}
+
+func (s *countingServerStream) SendMsg(msg any) error {
+	if err := s.ServerStream.SendMsg(msg); err != nil {
+		return err
+	}
+	if d, ok := msg.(dataMessage); ok {
+		s.sent += int64(len(d.GetData()))
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeResponse struct {
+	err string
+}
+
+func (r *fakeResponse) GetError() string {
+	return r.err
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	m := New()
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/artifact.ArtifactService/Load"}
+
+	respond := func(resp any, err error) grpc.UnaryHandler {
+		return func(context.Context, any) (any, error) {
+			return resp, err
+		}
+	}
+
+	_, err := interceptor(t.Context(), nil, info, respond(&fakeResponse{}, nil))
+	require.NoError(t, err)
+	_, err = interceptor(t.Context(), nil, info, respond(&fakeResponse{err: "no such key"}, nil))
+	require.NoError(t, err)
+	_, err = interceptor(t.Context(), nil, info, respond(nil, errors.New("boom")))
+	require.Error(t, err)
+
+	assert.InDelta(t, 1, testutil.ToFloat64(m.requests.WithLabelValues("Load", statusSuccess)), 0)
+	assert.InDelta(t, 2, testutil.ToFloat64(m.requests.WithLabelValues("Load", statusFailure)), 0)
+}
+
+type fakeDataMessage struct {
+	data []byte
+}
+
+func (m *fakeDataMessage) GetData() []byte {
+	return m.data
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+}
+
+func (s *fakeServerStream) SendMsg(any) error {
+	return nil
+}
+
+func TestCountingServerStream(t *testing.T) {
+	stream := &countingServerStream{ServerStream: &fakeServerStream{}}
+	require.NoError(t, stream.SendMsg(&fakeDataMessage{data: make([]byte, 10)}))
+	require.NoError(t, stream.SendMsg(&fakeDataMessage{data: make([]byte, 5)}))
+	require.NoError(t, stream.SendMsg("not a data message"))
+	assert.Equal(t, int64(15), stream.sent)
+}
+
+func TestStreamStarted(t *testing.T) {
+	m := New()
+	done := m.StreamStarted()
+	assert.InDelta(t, 1, testutil.ToFloat64(m.activeStreams), 0)
+	secondDone := m.StreamStarted()
+	assert.InDelta(t, 2, testutil.ToFloat64(m.activeStreams), 0)
+
+	done()
+	secondDone()
+	assert.InDelta(t, 0, testutil.ToFloat64(m.activeStreams), 0)
+}
+
+// TestStatsHandler verifies a real connection is counted while it is open
+func TestStatsHandler(t *testing.T) {
+	m := New()
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.StatsHandler(m.StatsHandler()))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	_, err = healthpb.NewHealthClient(conn).Check(t.Context(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.InDelta(t, 1, testutil.ToFloat64(m.openConnections), 0)
+
+	require.NoError(t, conn.Close())
+	assert.Eventually(t, func() bool { return testutil.ToFloat64(m.openConnections) == 0 }, 5*time.Second, 10*time.Millisecond)
+}
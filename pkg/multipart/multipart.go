@@ -0,0 +1,266 @@
+package multipart
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+	"github.com/pipekit/artifact-plugin-s3/internal/grpcutil"
+	"github.com/pipekit/artifact-plugin-s3/pkg/artifact"
+	"github.com/pipekit/artifact-plugin-s3/pkg/s3"
+)
+
+const (
+	// ServiceName is the gRPC service serving multipart uploads alongside the artifact service
+	ServiceName = "artifactplugin.s3.Multipart"
+	// InitMultipartMethod, UploadPartMethod, CompleteMultipartMethod and AbortMultipartMethod are the full gRPC
+	// method names of the service's RPCs
+	InitMultipartMethod     = "/" + ServiceName + "/InitMultipart"
+	UploadPartMethod        = "/" + ServiceName + "/UploadPart"
+	CompleteMultipartMethod = "/" + ServiceName + "/CompleteMultipart"
+	AbortMultipartMethod    = "/" + ServiceName + "/AbortMultipart"
+
+	// HeaderUploadID and HeaderPartNumber are the request metadata carrying the upload ID and part number of an
+	// UploadPart call, whose message is the part's data
+	HeaderUploadID   = "artifact-upload-id"
+	HeaderPartNumber = "artifact-part-number"
+
+	// abortTimeout bounds aborting the uploads of a closed connection
+	abortTimeout = time.Minute
+)
+
+// Uploader is the driver's multipart upload API
+type Uploader interface {
+	InitMultipart(ctx context.Context, artifact *wfv1.Artifact) (string, error)
+	UploadPart(ctx context.Context, artifact *wfv1.Artifact, uploadID string, partNumber int, data []byte) (s3.MultipartPart, error)
+	CompleteMultipart(ctx context.Context, artifact *wfv1.Artifact, uploadID string, parts []s3.MultipartPart) error
+	AbortMultipart(ctx context.Context, artifact *wfv1.Artifact, uploadID string) error
+}
+
+// Resolver returns the uploader and Argo artifact for the artifact of an InitMultipart request, and the timeout
+// bounding each of the upload's calls to the uploader
+type Resolver func(ctx context.Context, artifact *artifact.Artifact) (Uploader, *wfv1.Artifact, time.Duration, error)
+
+// Server serves multipart uploads, which the client threads through by the upload ID InitMultipart returns. An
+// upload can only be continued on the connection which started it, and is aborted if that connection closes
+// before the upload is completed or aborted.
+type Server struct {
+	logger   logging.Logger
+	resolve  Resolver
+	toStatus func(error) error
+
+	mu      sync.Mutex
+	uploads map[string]*upload
+}
+
+// upload is a multipart upload in progress
+type upload struct {
+	uploader Uploader
+	artifact *wfv1.Artifact
+	timeout  time.Duration
+	// conn is the connection which started the upload, nil when it wasn't started through a tracked connection
+	conn *connection
+
+	mu    sync.Mutex
+	parts map[int]s3.MultipartPart
+}
+
+// connection identifies a client connection, through the context of the RPCs on it. It isn't empty, as pointers
+// to zero-size values may all be equal.
+type connection struct{ _ byte }
+
+type connectionKey struct{}
+
+// New returns a Server resolving each upload's uploader with resolve, and converting their errors to gRPC status
+// errors with toStatus
+func New(logger logging.Logger, resolve Resolver, toStatus func(error) error) *Server {
+	return &Server{logger: logger, resolve: resolve, toStatus: toStatus, uploads: map[string]*upload{}}
+}
+
+// serviceDesc describes the multipart service, whose upload ID and part number are passed as request metadata so
+// that each RPC's message is a well-known type
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "InitMultipart", Handler: grpcutil.UnaryHandler(InitMultipartMethod, (*Server).InitMultipart)},
+		{MethodName: "UploadPart", Handler: grpcutil.UnaryHandler(UploadPartMethod, (*Server).UploadPart)},
+		{MethodName: "CompleteMultipart", Handler: grpcutil.UnaryHandler(CompleteMultipartMethod, (*Server).CompleteMultipart)},
+		{MethodName: "AbortMultipart", Handler: grpcutil.UnaryHandler(AbortMultipartMethod, (*Server).AbortMultipart)},
+	},
+	Metadata: "multipart",
+}
+
+// Register registers the multipart service on server. The server must also have the Server as a stats handler
+// for uploads to be aborted when their connection closes.
+func (s *Server) Register(server grpc.ServiceRegistrar) {
+	server.RegisterService(&serviceDesc, s)
+}
+
+// InitMultipart starts a multipart upload to the artifact and returns its upload ID
+func (s *Server) InitMultipart(ctx context.Context, req *artifact.Artifact) (*wrapperspb.StringValue, error) {
+	ctx = grpcutil.WithLogger(ctx, s.logger)
+	uploader, argoArtifact, timeout, err := s.resolve(ctx, req)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	var uploadID string
+	err = grpcutil.RunWithTimeout(ctx, "InitMultipart", timeout, func(ctx context.Context) error {
+		var err error
+		uploadID, err = uploader.InitMultipart(ctx, argoArtifact)
+		return err
+	})
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	conn, _ := ctx.Value(connectionKey{}).(*connection)
+	s.mu.Lock()
+	s.uploads[uploadID] = &upload{uploader: uploader, artifact: argoArtifact, timeout: timeout, conn: conn, parts: map[int]s3.MultipartPart{}}
+	s.mu.Unlock()
+	return wrapperspb.String(uploadID), nil
+}
+
+// UploadPart uploads the request's data as a part of the upload, whose ID and part number are given in the
+// artifact-upload-id and artifact-part-number metadata
+func (s *Server) UploadPart(ctx context.Context, req *wrapperspb.BytesValue) (*emptypb.Empty, error) {
+	ctx = grpcutil.WithLogger(ctx, s.logger)
+	md, _ := metadata.FromIncomingContext(ctx)
+	uploadID, partNumberValue := grpcutil.FirstValue(md, HeaderUploadID), grpcutil.FirstValue(md, HeaderPartNumber)
+	partNumber, err := strconv.Atoi(partNumberValue)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s %q", HeaderPartNumber, partNumberValue)
+	}
+	u, err := s.lookup(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	var part s3.MultipartPart
+	err = grpcutil.RunWithTimeout(ctx, "UploadPart", u.timeout, func(ctx context.Context) error {
+		var err error
+		part, err = u.uploader.UploadPart(ctx, u.artifact, uploadID, partNumber, req.GetValue())
+		return err
+	})
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	u.mu.Lock()
+	u.parts[partNumber] = part
+	u.mu.Unlock()
+	return &emptypb.Empty{}, nil
+}
+
+// CompleteMultipart assembles the parts uploaded so far into the artifact's object. The upload remains in
+// progress if completing it fails, so it can be retried or aborted.
+func (s *Server) CompleteMultipart(ctx context.Context, req *wrapperspb.StringValue) (*emptypb.Empty, error) {
+	ctx = grpcutil.WithLogger(ctx, s.logger)
+	uploadID := req.GetValue()
+	u, err := s.lookup(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	u.mu.Lock()
+	parts := slices.Collect(maps.Values(u.parts))
+	u.mu.Unlock()
+	err = grpcutil.RunWithTimeout(ctx, "CompleteMultipart", u.timeout, func(ctx context.Context) error {
+		return u.uploader.CompleteMultipart(ctx, u.artifact, uploadID, parts)
+	})
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	s.remove(uploadID)
+	return &emptypb.Empty{}, nil
+}
+
+// AbortMultipart abandons the upload, deleting the parts uploaded so far
+func (s *Server) AbortMultipart(ctx context.Context, req *wrapperspb.StringValue) (*emptypb.Empty, error) {
+	ctx = grpcutil.WithLogger(ctx, s.logger)
+	uploadID := req.GetValue()
+	u, err := s.lookup(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	err = grpcutil.RunWithTimeout(ctx, "AbortMultipart", u.timeout, func(ctx context.Context) error {
+		return u.uploader.AbortMultipart(ctx, u.artifact, uploadID)
+	})
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	s.remove(uploadID)
+	return &emptypb.Empty{}, nil
+}
+
+// lookup returns the upload in progress with the ID, as long as it was started on the RPC's connection
+func (s *Server) lookup(ctx context.Context, uploadID string) (*upload, error) {
+	conn, _ := ctx.Value(connectionKey{}).(*connection)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.uploads[uploadID]
+	if !ok || u.conn != conn {
+		return nil, status.Errorf(codes.NotFound, "no multipart upload %q is in progress on this connection", uploadID)
+	}
+	return u, nil
+}
+
+func (s *Server) remove(uploadID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.uploads, uploadID)
+}
+
+func (s *Server) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (s *Server) HandleRPC(context.Context, stats.RPCStats) {}
+
+// TagConn identifies the connection in the context of the RPCs on it
+func (s *Server) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return context.WithValue(ctx, connectionKey{}, &connection{})
+}
+
+// HandleConn aborts the uploads still in progress when their connection closes
+func (s *Server) HandleConn(ctx context.Context, connStats stats.ConnStats) {
+	if _, ok := connStats.(*stats.ConnEnd); !ok {
+		return
+	}
+	conn, _ := ctx.Value(connectionKey{}).(*connection)
+	s.mu.Lock()
+	abandoned := map[string]*upload{}
+	for uploadID, u := range s.uploads {
+		if conn != nil && u.conn == conn {
+			abandoned[uploadID] = u
+			delete(s.uploads, uploadID)
+		}
+	}
+	s.mu.Unlock()
+	if len(abandoned) == 0 {
+		return
+	}
+
+	// The connection is closing, so the aborts outlive its context
+	ctx, cancel := context.WithTimeout(logging.WithLogger(context.WithoutCancel(ctx), s.logger), abortTimeout)
+	go func() {
+		defer cancel()
+		for uploadID, u := range abandoned {
+			log := s.logger.WithFields(logging.Fields{"uploadId": uploadID, "key": u.artifact.S3.Key})
+			if err := u.uploader.AbortMultipart(ctx, u.artifact, uploadID); err != nil {
+				log.WithError(err).Warn(ctx, "Failed to abort the multipart upload of a closed connection")
+				continue
+			}
+			log.Info(ctx, "Aborted the multipart upload of a closed connection")
+		}
+	}()
+}
@@ -0,0 +1,217 @@
+package multipart
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+	"github.com/pipekit/artifact-plugin-s3/pkg/artifact"
+	"github.com/pipekit/artifact-plugin-s3/pkg/s3"
+)
+
+// fakeUploader records the multipart uploads made through it
+type fakeUploader struct {
+	mu        sync.Mutex
+	parts     map[string]map[int][]byte
+	completed map[string][]s3.MultipartPart
+	aborted   []string
+}
+
+func newFakeUploader() *fakeUploader {
+	return &fakeUploader{parts: map[string]map[int][]byte{}, completed: map[string][]s3.MultipartPart{}}
+}
+
+func (f *fakeUploader) InitMultipart(_ context.Context, artifact *wfv1.Artifact) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	uploadID := artifact.S3.Key + "-" + strconv.Itoa(len(f.parts)+1)
+	f.parts[uploadID] = map[int][]byte{}
+	return uploadID, nil
+}
+
+func (f *fakeUploader) UploadPart(_ context.Context, _ *wfv1.Artifact, uploadID string, partNumber int, data []byte) (s3.MultipartPart, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.parts[uploadID][partNumber] = data
+	return s3.MultipartPart{PartNumber: partNumber, ETag: "etag-" + strconv.Itoa(partNumber)}, nil
+}
+
+func (f *fakeUploader) CompleteMultipart(_ context.Context, _ *wfv1.Artifact, uploadID string, parts []s3.MultipartPart) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.completed[uploadID] = parts
+	return nil
+}
+
+func (f *fakeUploader) AbortMultipart(_ context.Context, _ *wfv1.Artifact, uploadID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.aborted = append(f.aborted, uploadID)
+	return nil
+}
+
+func (f *fakeUploader) abortedUploads() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.aborted...)
+}
+
+// startServer serves the multipart service over bufconn, returning a function connecting a new client to it
+func startServer(t *testing.T, uploader Uploader) func() *grpc.ClientConn {
+	t.Helper()
+	return startServerWithTimeout(t, uploader, time.Minute)
+}
+
+// startServerWithTimeout serves the multipart service over bufconn with the uploader's calls bounded by timeout,
+// returning a function connecting a new client to it
+func startServerWithTimeout(t *testing.T, uploader Uploader, timeout time.Duration) func() *grpc.ClientConn {
+	t.Helper()
+	resolve := func(_ context.Context, a *artifact.Artifact) (Uploader, *wfv1.Artifact, time.Duration, error) {
+		if a.GetPlugin().GetKey() == "" {
+			return nil, nil, 0, status.Error(codes.InvalidArgument, "plugin artifact key is required")
+		}
+		return uploader, &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: a.GetPlugin().GetKey()}}}, timeout, nil
+	}
+	s := New(logging.RequireLoggerFromContext(logging.TestContext(t.Context())), resolve, func(err error) error { return err })
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.StatsHandler(s))
+	s.Register(server)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	return func() *grpc.ClientConn {
+		conn, err := grpc.NewClient("passthrough:///bufconn",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+		return conn
+	}
+}
+
+func initMultipart(t *testing.T, conn *grpc.ClientConn, key string) string {
+	t.Helper()
+	uploadID := &wrapperspb.StringValue{}
+	req := &artifact.Artifact{Plugin: &artifact.PluginArtifact{Key: key}}
+	require.NoError(t, conn.Invoke(t.Context(), InitMultipartMethod, req, uploadID))
+	return uploadID.GetValue()
+}
+
+func uploadPart(ctx context.Context, conn *grpc.ClientConn, uploadID string, partNumber int, data string) error {
+	ctx = metadata.AppendToOutgoingContext(ctx, HeaderUploadID, uploadID, HeaderPartNumber, strconv.Itoa(partNumber))
+	return conn.Invoke(ctx, UploadPartMethod, wrapperspb.Bytes([]byte(data)), &emptypb.Empty{})
+}
+
+func TestMultipart(t *testing.T) {
+	uploader := newFakeUploader()
+	connect := startServer(t, uploader)
+	conn := connect()
+
+	t.Run("Complete", func(t *testing.T) {
+		uploadID := initMultipart(t, conn, "logs/main.log")
+		require.NoError(t, uploadPart(t.Context(), conn, uploadID, 1, "first chunk, "))
+		require.NoError(t, uploadPart(t.Context(), conn, uploadID, 2, "second chunk"))
+		require.NoError(t, conn.Invoke(t.Context(), CompleteMultipartMethod, wrapperspb.String(uploadID), &emptypb.Empty{}))
+
+		assert.Equal(t, map[int][]byte{1: []byte("first chunk, "), 2: []byte("second chunk")}, uploader.parts[uploadID])
+		assert.ElementsMatch(t, []s3.MultipartPart{{PartNumber: 1, ETag: "etag-1"}, {PartNumber: 2, ETag: "etag-2"}}, uploader.completed[uploadID])
+		err := uploadPart(t.Context(), conn, uploadID, 3, "after completing")
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		uploadID := initMultipart(t, conn, "logs/aborted.log")
+		require.NoError(t, uploadPart(t.Context(), conn, uploadID, 1, "never completed"))
+		require.NoError(t, conn.Invoke(t.Context(), AbortMultipartMethod, wrapperspb.String(uploadID), &emptypb.Empty{}))
+
+		assert.Contains(t, uploader.abortedUploads(), uploadID)
+		err := conn.Invoke(t.Context(), CompleteMultipartMethod, wrapperspb.String(uploadID), &emptypb.Empty{})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("Other connection", func(t *testing.T) {
+		uploadID := initMultipart(t, conn, "logs/other.log")
+		err := uploadPart(t.Context(), connect(), uploadID, 1, "from elsewhere")
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		err := conn.Invoke(t.Context(), InitMultipartMethod, &artifact.Artifact{Plugin: &artifact.PluginArtifact{}}, &wrapperspb.StringValue{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		uploadID := initMultipart(t, conn, "logs/invalid.log")
+		ctx := metadata.AppendToOutgoingContext(t.Context(), HeaderUploadID, uploadID)
+		err = conn.Invoke(ctx, UploadPartMethod, wrapperspb.Bytes([]byte("no part number")), &emptypb.Empty{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+// TestMultipart_Disconnect verifies the uploads in progress on a connection are aborted when it closes
+func TestMultipart_Disconnect(t *testing.T) {
+	uploader := newFakeUploader()
+	connect := startServer(t, uploader)
+	conn, other := connect(), connect()
+
+	uploadID := initMultipart(t, conn, "logs/abandoned.log")
+	require.NoError(t, uploadPart(t.Context(), conn, uploadID, 1, "abandoned"))
+	otherID := initMultipart(t, other, "logs/kept.log")
+	require.NoError(t, conn.Close())
+
+	assert.Eventually(t, func() bool { return len(uploader.abortedUploads()) > 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{uploadID}, uploader.abortedUploads())
+	require.NoError(t, uploadPart(t.Context(), other, otherID, 1, "still in progress"))
+}
+
+func TestMultipart_UploaderError(t *testing.T) {
+	connect := startServer(t, failingUploader{newFakeUploader()})
+	conn := connect()
+	uploadID := initMultipart(t, conn, "logs/failing.log")
+	err := uploadPart(t.Context(), conn, uploadID, 1, "data")
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+// failingUploader fails every part upload
+type failingUploader struct {
+	*fakeUploader
+}
+
+func (failingUploader) UploadPart(context.Context, *wfv1.Artifact, string, int, []byte) (s3.MultipartPart, error) {
+	return s3.MultipartPart{}, status.Error(codes.Unavailable, "s3 is down")
+}
+
+func TestMultipart_Timeout(t *testing.T) {
+	uploader := slowUploader{fakeUploader: newFakeUploader(), release: make(chan struct{})}
+	defer close(uploader.release)
+	conn := startServerWithTimeout(t, uploader, 10*time.Millisecond)()
+	uploadID := initMultipart(t, conn, "logs/slow.log")
+	err := uploadPart(t.Context(), conn, uploadID, 1, "data")
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Contains(t, err.Error(), "UploadPart timed out after 10ms")
+}
+
+// slowUploader ignores the deadline of part uploads, returning only once released
+type slowUploader struct {
+	*fakeUploader
+	release chan struct{}
+}
+
+func (s slowUploader) UploadPart(context.Context, *wfv1.Artifact, string, int, []byte) (s3.MultipartPart, error) {
+	<-s.release
+	return s3.MultipartPart{}, nil
+}
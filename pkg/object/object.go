@@ -0,0 +1,335 @@
+package object
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/minio/minio-go/v7"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+	"github.com/pipekit/artifact-plugin-s3/internal/grpcutil"
+	"github.com/pipekit/artifact-plugin-s3/pkg/artifact"
+	"github.com/pipekit/artifact-plugin-s3/pkg/s3"
+)
+
+const (
+	// ServiceName is the gRPC service serving single object operations alongside the artifact service
+	ServiceName = "artifactplugin.s3.Object"
+	// ExistsMethod, CopyMethod, PresignedURLMethod, ListBucketsMethod, WriteObjectMethod, ReadObjectMethod,
+	// CheckBucketMethod, MoveMethod and StatMethod are the full gRPC method names of the service's RPCs
+	ExistsMethod       = "/" + ServiceName + "/Exists"
+	CopyMethod         = "/" + ServiceName + "/Copy"
+	PresignedURLMethod = "/" + ServiceName + "/PresignedURL"
+	ListBucketsMethod  = "/" + ServiceName + "/ListBuckets"
+	WriteObjectMethod  = "/" + ServiceName + "/WriteObject"
+	ReadObjectMethod   = "/" + ServiceName + "/ReadObject"
+	CheckBucketMethod  = "/" + ServiceName + "/CheckBucket"
+	MoveMethod         = "/" + ServiceName + "/Move"
+	StatMethod         = "/" + ServiceName + "/Stat"
+
+	// HeaderDestinationKey is the request metadata carrying the key a Copy or Move writes to, with the same
+	// configuration as the artifact copied
+	HeaderDestinationKey = "artifact-destination-key"
+	// HeaderPresignMethod and HeaderPresignExpiry are the request metadata carrying the HTTP method, GET or PUT
+	// and defaulting to GET, and the validity, as a Go duration such as 15m, of a PresignedURL
+	HeaderPresignMethod = "artifact-presign-method"
+	HeaderPresignExpiry = "artifact-presign-expiry"
+	// HeaderArtifact is the binary request metadata carrying the serialized Artifact of an RPC whose message is
+	// the object's data, and HeaderContentType the Content-Type a WriteObject stores, detected from the data when
+	// it is unset
+	HeaderArtifact    = "artifact-bin"
+	HeaderContentType = "artifact-content-type"
+)
+
+// Store is the driver's object API
+type Store interface {
+	Exists(ctx context.Context, artifact *wfv1.Artifact) (bool, error)
+	Copy(ctx context.Context, src, dst *wfv1.Artifact) error
+	PresignedURL(ctx context.Context, artifact *wfv1.Artifact, method string, expiry time.Duration) (string, error)
+	ListBuckets(ctx context.Context) ([]minio.BucketInfo, error)
+	WriteObject(ctx context.Context, artifact *wfv1.Artifact, data []byte, contentType string) error
+	ReadObject(ctx context.Context, artifact *wfv1.Artifact) ([]byte, error)
+	CheckBucket(ctx context.Context, artifact *wfv1.Artifact) s3.BucketCheck
+	Move(ctx context.Context, src, dst *wfv1.Artifact) error
+	Stat(ctx context.Context, artifact *wfv1.Artifact) (*s3.ObjectStat, error)
+}
+
+// Resolver returns the store and Argo artifact for the artifact of a request, and the timeout bounding the store's
+// calls. keyRequired rejects an empty key, for the RPCs which act on an object.
+type Resolver func(ctx context.Context, artifact *artifact.Artifact, keyRequired bool) (Store, *wfv1.Artifact, time.Duration, error)
+
+// Server serves operations on a single artifact which the artifact service has no RPC for
+type Server struct {
+	logger   logging.Logger
+	resolve  Resolver
+	toStatus func(error) error
+}
+
+// New returns a Server resolving each request's store with resolve, and converting their errors to gRPC status
+// errors with toStatus
+func New(logger logging.Logger, resolve Resolver, toStatus func(error) error) *Server {
+	return &Server{logger: logger, resolve: resolve, toStatus: toStatus}
+}
+
+// serviceDesc describes the object service. Each request is the artifact message, or the object's data with the
+// artifact in the artifact-bin metadata, with any other argument passed as request metadata, and each response a
+// well-known type.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Exists", Handler: grpcutil.UnaryHandler(ExistsMethod, (*Server).Exists)},
+		{MethodName: "Copy", Handler: grpcutil.UnaryHandler(CopyMethod, (*Server).Copy)},
+		{MethodName: "PresignedURL", Handler: grpcutil.UnaryHandler(PresignedURLMethod, (*Server).PresignedURL)},
+		{MethodName: "ListBuckets", Handler: grpcutil.UnaryHandler(ListBucketsMethod, (*Server).ListBuckets)},
+		{MethodName: "WriteObject", Handler: grpcutil.UnaryHandler(WriteObjectMethod, (*Server).WriteObject)},
+		{MethodName: "ReadObject", Handler: grpcutil.UnaryHandler(ReadObjectMethod, (*Server).ReadObject)},
+		{MethodName: "CheckBucket", Handler: grpcutil.UnaryHandler(CheckBucketMethod, (*Server).CheckBucket)},
+		{MethodName: "Move", Handler: grpcutil.UnaryHandler(MoveMethod, (*Server).Move)},
+		{MethodName: "Stat", Handler: grpcutil.UnaryHandler(StatMethod, (*Server).Stat)},
+	},
+	Metadata: "object",
+}
+
+// Register registers the object service on server
+func (s *Server) Register(server grpc.ServiceRegistrar) {
+	server.RegisterService(&serviceDesc, s)
+}
+
+// Exists reports whether the artifact exists without downloading it. A key ending in / is a directory, which
+// exists if any object is under it.
+func (s *Server) Exists(ctx context.Context, req *artifact.Artifact) (*wrapperspb.BoolValue, error) {
+	ctx = grpcutil.WithLogger(ctx, s.logger)
+	store, argoArtifact, timeout, err := s.resolve(ctx, req, true)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	var exists bool
+	err = grpcutil.RunWithTimeout(ctx, "Exists", timeout, func(ctx context.Context) error {
+		var err error
+		exists, err = store.Exists(ctx, argoArtifact)
+		return err
+	})
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return wrapperspb.Bool(exists), nil
+}
+
+// Copy copies the artifact server-side to the key in the artifact-destination-key metadata, in the same bucket
+func (s *Server) Copy(ctx context.Context, req *artifact.Artifact) (*emptypb.Empty, error) {
+	ctx = grpcutil.WithLogger(ctx, s.logger)
+	store, src, dst, timeout, err := s.resolvePair(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	err = grpcutil.RunWithTimeout(ctx, "Copy", timeout, func(ctx context.Context) error {
+		return store.Copy(ctx, src, dst)
+	})
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// Move moves the artifact to the key in the artifact-destination-key metadata, in the same bucket. The artifact is
+// only deleted once its copy is confirmed, so a failure leaves it in place.
+func (s *Server) Move(ctx context.Context, req *artifact.Artifact) (*emptypb.Empty, error) {
+	ctx = grpcutil.WithLogger(ctx, s.logger)
+	store, src, dst, timeout, err := s.resolvePair(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	err = grpcutil.RunWithTimeout(ctx, "Move", timeout, func(ctx context.Context) error {
+		return store.Move(ctx, src, dst)
+	})
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// PresignedURL returns a URL which an external tool can use to GET or PUT the artifact directly, for the method
+// and validity in the artifact-presign-method and artifact-presign-expiry metadata
+func (s *Server) PresignedURL(ctx context.Context, req *artifact.Artifact) (*wrapperspb.StringValue, error) {
+	ctx = grpcutil.WithLogger(ctx, s.logger)
+	md, _ := metadata.FromIncomingContext(ctx)
+	method, expiryValue := grpcutil.FirstValue(md, HeaderPresignMethod), grpcutil.FirstValue(md, HeaderPresignExpiry)
+	if method == "" {
+		method = http.MethodGet
+	}
+	expiry, err := time.ParseDuration(expiryValue)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s %q, must be a duration such as 15m", HeaderPresignExpiry, expiryValue)
+	}
+	store, argoArtifact, timeout, err := s.resolve(ctx, req, true)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	var url string
+	err = grpcutil.RunWithTimeout(ctx, "PresignedURL", timeout, func(ctx context.Context) error {
+		var err error
+		url, err = store.PresignedURL(ctx, argoArtifact, method, expiry)
+		return err
+	})
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return wrapperspb.String(url), nil
+}
+
+// ListBuckets lists the buckets the artifact's credentials can see, whose key may be empty, returning each bucket's
+// name and creationDate in a ListValue of Structs
+func (s *Server) ListBuckets(ctx context.Context, req *artifact.Artifact) (*structpb.ListValue, error) {
+	ctx = grpcutil.WithLogger(ctx, s.logger)
+	store, _, timeout, err := s.resolve(ctx, req, false)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	var buckets []minio.BucketInfo
+	err = grpcutil.RunWithTimeout(ctx, "ListBuckets", timeout, func(ctx context.Context) error {
+		var err error
+		buckets, err = store.ListBuckets(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	values := make([]any, 0, len(buckets))
+	for _, bucket := range buckets {
+		values = append(values, map[string]any{"name": bucket.Name, "creationDate": bucket.CreationDate.UTC().Format(time.RFC3339)})
+	}
+	return structpb.NewList(values)
+}
+
+// WriteObject stores the request's data as the artifact in the artifact-bin metadata, with the Content-Type in the
+// artifact-content-type metadata. The data must fit within the configured inline object size.
+func (s *Server) WriteObject(ctx context.Context, req *wrapperspb.BytesValue) (*emptypb.Empty, error) {
+	ctx = grpcutil.WithLogger(ctx, s.logger)
+	md, _ := metadata.FromIncomingContext(ctx)
+	a, err := artifactFromMetadata(md)
+	if err != nil {
+		return nil, err
+	}
+	store, argoArtifact, timeout, err := s.resolve(ctx, a, true)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	err = grpcutil.RunWithTimeout(ctx, "WriteObject", timeout, func(ctx context.Context) error {
+		return store.WriteObject(ctx, argoArtifact, req.GetValue(), grpcutil.FirstValue(md, HeaderContentType))
+	})
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ReadObject returns the contents of the artifact, which must fit within the configured inline object size.
+// Larger objects are read with the artifact service's OpenStream.
+func (s *Server) ReadObject(ctx context.Context, req *artifact.Artifact) (*wrapperspb.BytesValue, error) {
+	ctx = grpcutil.WithLogger(ctx, s.logger)
+	store, argoArtifact, timeout, err := s.resolve(ctx, req, true)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	var data []byte
+	err = grpcutil.RunWithTimeout(ctx, "ReadObject", timeout, func(ctx context.Context) error {
+		var err error
+		data, err = store.ReadObject(ctx, argoArtifact)
+		return err
+	})
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return wrapperspb.Bytes(data), nil
+}
+
+// CheckBucket checks the artifact's bucket can be reached with its configuration, whose key may be empty, without
+// reading or writing any object. It returns the result, such as Reachable or AuthFailure, and the message
+// explaining it in a Struct, so a failure to reach the bucket is reported rather than returned as an error.
+func (s *Server) CheckBucket(ctx context.Context, req *artifact.Artifact) (*structpb.Struct, error) {
+	ctx = grpcutil.WithLogger(ctx, s.logger)
+	store, argoArtifact, timeout, err := s.resolve(ctx, req, false)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	var check s3.BucketCheck
+	err = grpcutil.RunWithTimeout(ctx, "CheckBucket", timeout, func(ctx context.Context) error {
+		check = store.CheckBucket(ctx, argoArtifact)
+		return nil
+	})
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return structpb.NewStruct(map[string]any{"result": string(check.Result), "message": check.Message})
+}
+
+// Stat returns the size, etag, contentType and lastModified time of the artifact's object in a Struct, without
+// downloading it. A missing object is a NotFound error, so it can be told apart from a permission error.
+func (s *Server) Stat(ctx context.Context, req *artifact.Artifact) (*structpb.Struct, error) {
+	ctx = grpcutil.WithLogger(ctx, s.logger)
+	store, argoArtifact, timeout, err := s.resolve(ctx, req, true)
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	var stat *s3.ObjectStat
+	err = grpcutil.RunWithTimeout(ctx, "Stat", timeout, func(ctx context.Context) error {
+		var err error
+		stat, err = store.Stat(ctx, argoArtifact)
+		return err
+	})
+	if err != nil {
+		return nil, s.toStatus(err)
+	}
+	return structpb.NewStruct(map[string]any{
+		"size":         stat.Size,
+		"etag":         stat.ETag,
+		"contentType":  stat.ContentType,
+		"lastModified": stat.LastModified.UTC().Format(time.RFC3339),
+	})
+}
+
+// artifactFromMetadata returns the Artifact serialized in the artifact-bin metadata
+func artifactFromMetadata(md metadata.MD) (*artifact.Artifact, error) {
+	values := md.Get(HeaderArtifact)
+	if len(values) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "%s is required", HeaderArtifact)
+	}
+	a := &artifact.Artifact{}
+	if err := proto.Unmarshal([]byte(values[0]), a); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s: %v", HeaderArtifact, err)
+	}
+	return a, nil
+}
+
+// resolvePair resolves the artifact of a request and its destination, the same artifact with the key in the
+// artifact-destination-key metadata, and the timeout bounding the store's calls
+func (s *Server) resolvePair(ctx context.Context, req *artifact.Artifact) (Store, *wfv1.Artifact, *wfv1.Artifact, time.Duration, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	dstKey := grpcutil.FirstValue(md, HeaderDestinationKey)
+	if dstKey == "" {
+		return nil, nil, nil, 0, status.Errorf(codes.InvalidArgument, "%s is required", HeaderDestinationKey)
+	}
+	store, src, timeout, err := s.resolve(ctx, req, true)
+	if err != nil {
+		return nil, nil, nil, 0, s.toStatus(err)
+	}
+	dstReq := proto.Clone(req).(*artifact.Artifact)
+	dstReq.Plugin.Key = dstKey
+	_, dst, _, err := s.resolve(ctx, dstReq, true)
+	if err != nil {
+		return nil, nil, nil, 0, s.toStatus(err)
+	}
+	return store, src, dst, timeout, nil
+}
@@ -0,0 +1,347 @@
+package object
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/minio/minio-go/v7"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+	"github.com/pipekit/artifact-plugin-s3/pkg/artifact"
+	"github.com/pipekit/artifact-plugin-s3/pkg/s3"
+)
+
+// fakeStore keeps objects in memory by key
+type fakeStore struct {
+	objects      map[string][]byte
+	contentTypes map[string]string
+	err          error
+}
+
+func (f *fakeStore) Exists(_ context.Context, a *wfv1.Artifact) (bool, error) {
+	_, ok := f.objects[a.S3.Key]
+	return ok, f.err
+}
+
+func (f *fakeStore) Copy(_ context.Context, src, dst *wfv1.Artifact) error {
+	if f.err != nil {
+		return f.err
+	}
+	data, ok := f.objects[src.S3.Key]
+	if !ok {
+		return status.Errorf(codes.NotFound, "%s not found", src.S3.Key)
+	}
+	f.objects[dst.S3.Key] = data
+	return nil
+}
+
+func (f *fakeStore) PresignedURL(_ context.Context, a *wfv1.Artifact, method string, expiry time.Duration) (string, error) {
+	if method != http.MethodGet && method != http.MethodPut {
+		return "", status.Errorf(codes.InvalidArgument, "invalid method %q", method)
+	}
+	return "https://s3.example.com/" + a.S3.Bucket + "/" + a.S3.Key + "?method=" + method + "&expiry=" + expiry.String(), f.err
+}
+
+func (f *fakeStore) ListBuckets(context.Context) ([]minio.BucketInfo, error) {
+	return []minio.BucketInfo{
+		{Name: "artifacts", CreationDate: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{Name: "logs", CreationDate: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+	}, f.err
+}
+
+func (f *fakeStore) WriteObject(_ context.Context, a *wfv1.Artifact, data []byte, contentType string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.objects[a.S3.Key], f.contentTypes[a.S3.Key] = data, contentType
+	return nil
+}
+
+func (f *fakeStore) ReadObject(_ context.Context, a *wfv1.Artifact) ([]byte, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	data, ok := f.objects[a.S3.Key]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "%s not found", a.S3.Key)
+	}
+	return data, nil
+}
+
+func (f *fakeStore) CheckBucket(_ context.Context, a *wfv1.Artifact) s3.BucketCheck {
+	if f.err != nil {
+		return s3.BucketCheck{Result: s3.BucketAuthFailure, Message: f.err.Error()}
+	}
+	return s3.BucketCheck{Result: s3.BucketReachable, Message: "bucket " + a.S3.Bucket + " is reachable"}
+}
+
+func (f *fakeStore) Move(ctx context.Context, src, dst *wfv1.Artifact) error {
+	if src.S3.Key == dst.S3.Key {
+		return status.Errorf(codes.InvalidArgument, "cannot move %s onto itself", src.S3.Key)
+	}
+	if err := f.Copy(ctx, src, dst); err != nil {
+		return err
+	}
+	delete(f.objects, src.S3.Key)
+	return nil
+}
+
+func (f *fakeStore) Stat(_ context.Context, a *wfv1.Artifact) (*s3.ObjectStat, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	data, ok := f.objects[a.S3.Key]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "%s not found", a.S3.Key)
+	}
+	return &s3.ObjectStat{Size: int64(len(data)), ETag: "etag", ContentType: f.contentTypes[a.S3.Key], LastModified: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)}, nil
+}
+
+// startServer serves the object service over bufconn, returning a client connected to it
+func startServer(t *testing.T, store Store) *grpc.ClientConn {
+	t.Helper()
+	return startServerWithTimeout(t, store, time.Minute)
+}
+
+// startServerWithTimeout serves the object service over bufconn with the store's calls bounded by timeout,
+// returning a client connected to it
+func startServerWithTimeout(t *testing.T, store Store, timeout time.Duration) *grpc.ClientConn {
+	t.Helper()
+	resolve := func(_ context.Context, a *artifact.Artifact, keyRequired bool) (Store, *wfv1.Artifact, time.Duration, error) {
+		if keyRequired && a.GetPlugin().GetKey() == "" {
+			return nil, nil, 0, status.Error(codes.InvalidArgument, "plugin artifact key is required")
+		}
+		return store, argoArtifact(a.GetPlugin().GetKey()), timeout, nil
+	}
+	s := New(logging.RequireLoggerFromContext(logging.TestContext(t.Context())), resolve, func(err error) error { return err })
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	s.Register(server)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func argoArtifact(key string) *wfv1.Artifact {
+	return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: key}}}
+}
+
+func pluginArtifact(key string) *artifact.Artifact {
+	return &artifact.Artifact{Plugin: &artifact.PluginArtifact{Key: key}}
+}
+
+func TestExists(t *testing.T) {
+	conn := startServer(t, &fakeStore{objects: map[string][]byte{"runs/a/out.log": []byte("log")}})
+
+	for key, expected := range map[string]bool{"runs/a/out.log": true, "runs/b/out.log": false} {
+		exists := &wrapperspb.BoolValue{}
+		require.NoError(t, conn.Invoke(t.Context(), ExistsMethod, pluginArtifact(key), exists))
+		assert.Equal(t, expected, exists.GetValue(), key)
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		err := conn.Invoke(t.Context(), ExistsMethod, pluginArtifact(""), &wrapperspb.BoolValue{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Store error", func(t *testing.T) {
+		conn := startServer(t, &fakeStore{err: status.Error(codes.Unavailable, "s3 is down")})
+		err := conn.Invoke(t.Context(), ExistsMethod, pluginArtifact("runs/a/out.log"), &wrapperspb.BoolValue{})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
+
+func TestCopy(t *testing.T) {
+	store := &fakeStore{objects: map[string][]byte{"runs/a/out.log": []byte("log")}}
+	conn := startServer(t, store)
+
+	ctx := metadata.AppendToOutgoingContext(t.Context(), HeaderDestinationKey, "archive/a/out.log")
+	require.NoError(t, conn.Invoke(ctx, CopyMethod, pluginArtifact("runs/a/out.log"), &emptypb.Empty{}))
+	assert.Equal(t, []byte("log"), store.objects["archive/a/out.log"])
+	assert.Equal(t, []byte("log"), store.objects["runs/a/out.log"])
+
+	t.Run("Missing source", func(t *testing.T) {
+		err := conn.Invoke(ctx, CopyMethod, pluginArtifact("runs/b/out.log"), &emptypb.Empty{})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("Missing destination", func(t *testing.T) {
+		err := conn.Invoke(t.Context(), CopyMethod, pluginArtifact("runs/a/out.log"), &emptypb.Empty{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestPresignedURL(t *testing.T) {
+	conn := startServer(t, &fakeStore{})
+	presign := func(md ...string) (string, error) {
+		url := &wrapperspb.StringValue{}
+		err := conn.Invoke(metadata.AppendToOutgoingContext(t.Context(), md...), PresignedURLMethod, pluginArtifact("runs/a/out.log"), url)
+		return url.GetValue(), err
+	}
+
+	url, err := presign(HeaderPresignExpiry, "15m")
+	require.NoError(t, err)
+	assert.Equal(t, "https://s3.example.com/my-bucket/runs/a/out.log?method=GET&expiry=15m0s", url)
+
+	url, err = presign(HeaderPresignMethod, http.MethodPut, HeaderPresignExpiry, "1h")
+	require.NoError(t, err)
+	assert.Equal(t, "https://s3.example.com/my-bucket/runs/a/out.log?method=PUT&expiry=1h0m0s", url)
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, md := range [][]string{
+			nil,
+			{HeaderPresignExpiry, "1d"},
+			{HeaderPresignMethod, http.MethodDelete, HeaderPresignExpiry, "15m"},
+		} {
+			_, err := presign(md...)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err), md)
+		}
+	})
+}
+
+func TestListBuckets(t *testing.T) {
+	buckets := &structpb.ListValue{}
+	require.NoError(t, startServer(t, &fakeStore{}).Invoke(t.Context(), ListBucketsMethod, pluginArtifact(""), buckets))
+	assert.Equal(t, []any{
+		map[string]any{"name": "artifacts", "creationDate": "2025-01-02T03:04:05Z"},
+		map[string]any{"name": "logs", "creationDate": "2024-06-01T00:00:00Z"},
+	}, buckets.AsSlice())
+
+	t.Run("Store error", func(t *testing.T) {
+		conn := startServer(t, &fakeStore{err: status.Error(codes.PermissionDenied, "s3:ListAllMyBuckets is required")})
+		err := conn.Invoke(t.Context(), ListBucketsMethod, pluginArtifact(""), &structpb.ListValue{})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+}
+
+// withArtifact returns ctx carrying the artifact with the key in the artifact-bin metadata
+func withArtifact(t *testing.T, ctx context.Context, key string) context.Context {
+	t.Helper()
+	data, err := proto.Marshal(pluginArtifact(key))
+	require.NoError(t, err)
+	return metadata.AppendToOutgoingContext(ctx, HeaderArtifact, string(data))
+}
+
+func TestWriteReadObject(t *testing.T) {
+	store := &fakeStore{objects: map[string][]byte{}, contentTypes: map[string]string{}}
+	conn := startServer(t, store)
+
+	ctx := metadata.AppendToOutgoingContext(withArtifact(t, t.Context(), "runs/a/result.json"), HeaderContentType, "application/json")
+	require.NoError(t, conn.Invoke(ctx, WriteObjectMethod, wrapperspb.Bytes([]byte(`{"ok":true}`)), &emptypb.Empty{}))
+	assert.Equal(t, "application/json", store.contentTypes["runs/a/result.json"])
+
+	data := &wrapperspb.BytesValue{}
+	require.NoError(t, conn.Invoke(t.Context(), ReadObjectMethod, pluginArtifact("runs/a/result.json"), data))
+	assert.JSONEq(t, `{"ok":true}`, string(data.GetValue()))
+
+	t.Run("Missing", func(t *testing.T) {
+		err := conn.Invoke(t.Context(), ReadObjectMethod, pluginArtifact("runs/b/result.json"), &wrapperspb.BytesValue{})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("Invalid artifact", func(t *testing.T) {
+		err := conn.Invoke(t.Context(), WriteObjectMethod, wrapperspb.Bytes([]byte("x")), &emptypb.Empty{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		ctx := metadata.AppendToOutgoingContext(t.Context(), HeaderArtifact, "\xff")
+		err = conn.Invoke(ctx, WriteObjectMethod, wrapperspb.Bytes([]byte("x")), &emptypb.Empty{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		err = conn.Invoke(withArtifact(t, t.Context(), ""), WriteObjectMethod, wrapperspb.Bytes([]byte("x")), &emptypb.Empty{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestCheckBucket(t *testing.T) {
+	check := &structpb.Struct{}
+	require.NoError(t, startServer(t, &fakeStore{}).Invoke(t.Context(), CheckBucketMethod, pluginArtifact(""), check))
+	assert.Equal(t, map[string]any{"result": "Reachable", "message": "bucket my-bucket is reachable"}, check.AsMap())
+
+	t.Run("Unreachable", func(t *testing.T) {
+		conn := startServer(t, &fakeStore{err: errors.New("Access Denied")})
+		require.NoError(t, conn.Invoke(t.Context(), CheckBucketMethod, pluginArtifact(""), check))
+		assert.Equal(t, map[string]any{"result": "AuthFailure", "message": "Access Denied"}, check.AsMap())
+	})
+}
+
+func TestMove(t *testing.T) {
+	store := &fakeStore{objects: map[string][]byte{"runs/a/out.log": []byte("log")}}
+	conn := startServer(t, store)
+
+	ctx := metadata.AppendToOutgoingContext(t.Context(), HeaderDestinationKey, "archive/a/out.log")
+	require.NoError(t, conn.Invoke(ctx, MoveMethod, pluginArtifact("runs/a/out.log"), &emptypb.Empty{}))
+	assert.Equal(t, map[string][]byte{"archive/a/out.log": []byte("log")}, store.objects)
+
+	t.Run("Onto itself", func(t *testing.T) {
+		err := conn.Invoke(ctx, MoveMethod, pluginArtifact("archive/a/out.log"), &emptypb.Empty{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Contains(t, store.objects, "archive/a/out.log")
+	})
+
+	t.Run("Missing destination", func(t *testing.T) {
+		err := conn.Invoke(t.Context(), MoveMethod, pluginArtifact("archive/a/out.log"), &emptypb.Empty{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestStat(t *testing.T) {
+	conn := startServer(t, &fakeStore{objects: map[string][]byte{"runs/a/out.log": []byte("log")}, contentTypes: map[string]string{"runs/a/out.log": "text/plain"}})
+
+	stat := &structpb.Struct{}
+	require.NoError(t, conn.Invoke(t.Context(), StatMethod, pluginArtifact("runs/a/out.log"), stat))
+	assert.Equal(t, map[string]any{"size": float64(3), "etag": "etag", "contentType": "text/plain", "lastModified": "2025-01-02T03:04:05Z"}, stat.AsMap())
+
+	t.Run("Missing", func(t *testing.T) {
+		err := conn.Invoke(t.Context(), StatMethod, pluginArtifact("runs/b/out.log"), &structpb.Struct{})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		err := conn.Invoke(t.Context(), StatMethod, pluginArtifact(""), &structpb.Struct{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+// slowStore is a store whose Stat ignores its deadline, returning only once released
+type slowStore struct {
+	*fakeStore
+	release chan struct{}
+}
+
+func (s *slowStore) Stat(context.Context, *wfv1.Artifact) (*s3.ObjectStat, error) {
+	<-s.release
+	return &s3.ObjectStat{}, nil
+}
+
+func TestOperationTimeout(t *testing.T) {
+	store := &slowStore{fakeStore: &fakeStore{}, release: make(chan struct{})}
+	defer close(store.release)
+	conn := startServerWithTimeout(t, store, 10*time.Millisecond)
+
+	err := conn.Invoke(t.Context(), StatMethod, pluginArtifact("runs/a/out.log"), &structpb.Struct{})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Contains(t, err.Error(), "Stat timed out after 10ms")
+}
@@ -0,0 +1,143 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+	"github.com/pipekit/artifact-plugin-s3/internal/grpcutil"
+	"github.com/pipekit/artifact-plugin-s3/pkg/artifact"
+	"github.com/pipekit/artifact-plugin-s3/pkg/s3"
+)
+
+const (
+	// ServiceName is the gRPC service serving S3 Select queries alongside the artifact service
+	ServiceName = "artifactplugin.s3.Select"
+	// SelectObjectContentMethod is the full gRPC method name of the service's RPC
+	SelectObjectContentMethod = "/" + ServiceName + "/SelectObjectContent"
+
+	// HeaderExpression, HeaderInputFormat, HeaderOutputFormat, HeaderCSVHeader, HeaderJSONType and
+	// HeaderCompression are the request metadata carrying the query, whose fields of s3.SelectQuery they set
+	HeaderExpression   = "artifact-select-expression"
+	HeaderInputFormat  = "artifact-select-input-format"
+	HeaderOutputFormat = "artifact-select-output-format"
+	HeaderCSVHeader    = "artifact-select-csv-header"
+	HeaderJSONType     = "artifact-select-json-type"
+	HeaderCompression  = "artifact-select-compression"
+)
+
+// Selector is the driver's S3 Select API
+type Selector interface {
+	Select(ctx context.Context, artifact *wfv1.Artifact, query s3.SelectQuery) (io.ReadCloser, error)
+}
+
+// Resolver returns the selector and Argo artifact for the artifact of a SelectObjectContent request, and the
+// timeout bounding starting the query
+type Resolver func(ctx context.Context, artifact *artifact.Artifact) (Selector, *wfv1.Artifact, time.Duration, error)
+
+// Server serves S3 Select queries over artifacts, streaming the selected records back
+type Server struct {
+	logger    logging.Logger
+	resolve   Resolver
+	toStatus  func(error) error
+	chunkSize int
+}
+
+// New returns a Server resolving each query's selector with resolve, converting their errors to gRPC status
+// errors with toStatus, and streaming the records in messages of up to chunkSize bytes
+func New(logger logging.Logger, resolve Resolver, toStatus func(error) error, chunkSize int) *Server {
+	return &Server{logger: logger, resolve: resolve, toStatus: toStatus, chunkSize: chunkSize}
+}
+
+// serviceDesc describes the select service. The query is passed as request metadata, so the request is the
+// artifact message alone and the records are streamed back as BytesValue chunks.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*any)(nil),
+	Streams: []grpc.StreamDesc{
+		{StreamName: "SelectObjectContent", Handler: grpcutil.ServerStreamHandler((*Server).SelectObjectContent), ServerStreams: true},
+	},
+	Metadata: "query",
+}
+
+// Register registers the select service on server
+func (s *Server) Register(server grpc.ServiceRegistrar) {
+	server.RegisterService(&serviceDesc, s)
+}
+
+// SelectObjectContent runs the query given in the request metadata over the artifact, streaming the selected
+// records back in google.protobuf.BytesValue messages. The stream ends once every record has been sent.
+func (s *Server) SelectObjectContent(req *artifact.Artifact, stream grpc.ServerStream) error {
+	ctx := grpcutil.WithLogger(stream.Context(), s.logger)
+	md, _ := metadata.FromIncomingContext(ctx)
+	query := s3.SelectQuery{
+		Expression:   grpcutil.FirstValue(md, HeaderExpression),
+		InputFormat:  grpcutil.FirstValue(md, HeaderInputFormat),
+		OutputFormat: grpcutil.FirstValue(md, HeaderOutputFormat),
+		CSVHeader:    grpcutil.FirstValue(md, HeaderCSVHeader),
+		JSONType:     grpcutil.FirstValue(md, HeaderJSONType),
+		Compression:  grpcutil.FirstValue(md, HeaderCompression),
+	}
+	selector, argoArtifact, timeout, err := s.resolve(ctx, req)
+	if err != nil {
+		return s.toStatus(err)
+	}
+
+	// The timeout only bounds starting the query as the records are paced by the client. They are read with the
+	// RPC's context rather than the timeout's, as they are read after it starts, and are cancelled with the RPC.
+	recordsCtx, cancelRecords := context.WithCancel(ctx)
+	defer cancelRecords()
+	var records io.ReadCloser
+	err = grpcutil.RunWithTimeout(ctx, "SelectObjectContent", timeout, func(ctx context.Context) error {
+		r, err := selector.Select(recordsCtx, argoArtifact, query)
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			// Too late, the handler has already given up
+			r.Close()
+			return ctx.Err()
+		}
+		records = r
+		return nil
+	})
+	if err != nil {
+		return s.toStatus(err)
+	}
+	defer records.Close()
+	return s.send(records, stream)
+}
+
+// send streams the records in chunks of up to chunkSize bytes. S3 Select returns records in small events, so
+// each chunk is filled before it is sent rather than sending one message per read.
+func (s *Server) send(records io.Reader, stream grpc.ServerStream) error {
+	buffer := make([]byte, s.chunkSize)
+	for {
+		if err := stream.Context().Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		n, err := io.ReadFull(records, buffer)
+		if n > 0 {
+			// gRPC may still hold a sent message, so each gets its own copy of the chunk
+			if err := stream.SendMsg(wrapperspb.Bytes(bytes.Clone(buffer[:n]))); err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return s.toStatus(err)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	argoerrs "github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+	"github.com/pipekit/artifact-plugin-s3/pkg/artifact"
+	"github.com/pipekit/artifact-plugin-s3/pkg/s3"
+)
+
+// fakeSelector returns its records for any query, recording the last query it ran
+type fakeSelector struct {
+	records string
+	err     error
+	query   s3.SelectQuery
+}
+
+func (f *fakeSelector) Select(_ context.Context, _ *wfv1.Artifact, query s3.SelectQuery) (io.ReadCloser, error) {
+	f.query = query
+	if f.err != nil {
+		return nil, f.err
+	}
+	return io.NopCloser(strings.NewReader(f.records)), nil
+}
+
+// startServer serves the select service over bufconn, returning a client connected to it
+func startServer(t *testing.T, selector Selector, chunkSize int) *grpc.ClientConn {
+	t.Helper()
+	return startServerWithTimeout(t, selector, chunkSize, time.Minute)
+}
+
+// startServerWithTimeout serves the select service over bufconn with starting a query bounded by timeout,
+// returning a client connected to it
+func startServerWithTimeout(t *testing.T, selector Selector, chunkSize int, timeout time.Duration) *grpc.ClientConn {
+	t.Helper()
+	resolve := func(_ context.Context, a *artifact.Artifact) (Selector, *wfv1.Artifact, time.Duration, error) {
+		if a.GetPlugin().GetKey() == "" {
+			return nil, nil, 0, status.Error(codes.InvalidArgument, "plugin artifact key is required")
+		}
+		return selector, &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{Key: a.GetPlugin().GetKey()}}}, timeout, nil
+	}
+	toStatus := func(err error) error {
+		if argoerrs.IsCode(argoerrs.CodeBadRequest, err) {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		return err
+	}
+	s := New(logging.RequireLoggerFromContext(logging.TestContext(t.Context())), resolve, toStatus, chunkSize)
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	s.Register(server)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// selectObjectContent runs the query over the key, returning the chunks the records were streamed in
+func selectObjectContent(ctx context.Context, conn *grpc.ClientConn, key string, md ...string) ([]string, error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, md...)
+	stream, err := conn.NewStream(ctx, &serviceDesc.Streams[0], SelectObjectContentMethod)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(&artifact.Artifact{Plugin: &artifact.PluginArtifact{Key: key}}); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	var chunks []string
+	for {
+		chunk := &wrapperspb.BytesValue{}
+		if err := stream.RecvMsg(chunk); err != nil {
+			if errors.Is(err, io.EOF) {
+				return chunks, nil
+			}
+			return chunks, err
+		}
+		chunks = append(chunks, string(chunk.GetValue()))
+	}
+}
+
+func TestSelectObjectContent(t *testing.T) {
+	selector := &fakeSelector{records: "run-2,failed\nrun-5,failed\n"}
+	conn := startServer(t, selector, 16)
+
+	chunks, err := selectObjectContent(t.Context(), conn, "runs.csv",
+		HeaderExpression, "SELECT * FROM S3Object s WHERE s.status = 'failed'",
+		HeaderInputFormat, "CSV",
+		HeaderCSVHeader, "USE",
+		HeaderCompression, "GZIP",
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"run-2,failed\nrun", "-5,failed\n"}, chunks)
+	assert.Equal(t, s3.SelectQuery{
+		Expression:  "SELECT * FROM S3Object s WHERE s.status = 'failed'",
+		InputFormat: "CSV",
+		CSVHeader:   "USE",
+		Compression: "GZIP",
+	}, selector.query)
+
+	t.Run("No records", func(t *testing.T) {
+		chunks, err := selectObjectContent(t.Context(), startServer(t, &fakeSelector{}, 16), "runs.csv", HeaderExpression, "SELECT * FROM S3Object", HeaderInputFormat, "CSV")
+		require.NoError(t, err)
+		assert.Empty(t, chunks)
+	})
+
+	t.Run("Invalid artifact", func(t *testing.T) {
+		_, err := selectObjectContent(t.Context(), conn, "", HeaderExpression, "SELECT * FROM S3Object", HeaderInputFormat, "CSV")
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Selector error", func(t *testing.T) {
+		conn := startServer(t, &fakeSelector{err: argoerrs.New(argoerrs.CodeBadRequest, "select expression is required")}, 16)
+		_, err := selectObjectContent(t.Context(), conn, "runs.csv", HeaderInputFormat, "CSV")
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Contains(t, err.Error(), "select expression is required")
+	})
+}
+
+// slowSelector ignores the deadline of starting a query, returning only once released
+type slowSelector struct {
+	release chan struct{}
+}
+
+func (s slowSelector) Select(context.Context, *wfv1.Artifact, s3.SelectQuery) (io.ReadCloser, error) {
+	<-s.release
+	return io.NopCloser(strings.NewReader("")), nil
+}
+
+func TestSelectObjectContent_Timeout(t *testing.T) {
+	selector := slowSelector{release: make(chan struct{})}
+	defer close(selector.release)
+	conn := startServerWithTimeout(t, selector, 1024, 10*time.Millisecond)
+
+	_, err := selectObjectContent(t.Context(), conn, "data/runs.csv", HeaderExpression, "SELECT * FROM S3Object", HeaderInputFormat, "CSV")
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Contains(t, err.Error(), "SelectObjectContent timed out after 10ms")
+}
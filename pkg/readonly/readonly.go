@@ -0,0 +1,54 @@
+package readonly
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Guard serves only the RPCs known not to modify storage, rejecting any other with PermissionDenied before it
+// reaches its handler. An RPC added later is therefore rejected until it is known to be read-only. Health checks
+// are always served.
+type Guard struct {
+	readMethods map[string]bool
+}
+
+// New creates a guard serving the read-only RPCs with the full method names
+func New(readMethods ...string) *Guard {
+	g := &Guard{readMethods: make(map[string]bool, len(readMethods))}
+	for _, method := range readMethods {
+		g.readMethods[method] = true
+	}
+	return g
+}
+
+// UnaryServerInterceptor rejects unary RPCs which may modify storage with PermissionDenied
+func (g *Guard) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := g.check(info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects streaming RPCs which may modify storage with PermissionDenied
+func (g *Guard) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := g.check(info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func (g *Guard) check(fullMethod string) error {
+	if g.readMethods[fullMethod] || strings.HasPrefix(fullMethod, "/"+healthpb.Health_ServiceDesc.ServiceName+"/") {
+		return nil
+	}
+	return status.Errorf(codes.PermissionDenied, "%s rejected: the plugin is read-only", fullMethod)
+}
@@ -0,0 +1,59 @@
+package readonly
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := New("/artifact.ArtifactService/Load").UnaryServerInterceptor()
+	call := func(method string) (bool, error) {
+		called := false
+		_, err := interceptor(t.Context(), nil, &grpc.UnaryServerInfo{FullMethod: method}, func(context.Context, any) (any, error) {
+			called = true
+			return nil, nil
+		})
+		return called, err
+	}
+
+	called, err := call("/artifact.ArtifactService/Load")
+	require.NoError(t, err)
+	assert.True(t, called)
+
+	called, err = call("/grpc.health.v1.Health/Check")
+	require.NoError(t, err)
+	assert.True(t, called)
+
+	for _, method := range []string{"/artifact.ArtifactService/Save", "/artifact.ArtifactService/Delete", "/artifact.ArtifactService/Unknown"} {
+		called, err := call(method)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err), method)
+		assert.False(t, called, method)
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	interceptor := New("/artifact.ArtifactService/OpenStream").StreamServerInterceptor()
+	call := func(method string) (bool, error) {
+		called := false
+		err := interceptor(nil, nil, &grpc.StreamServerInfo{FullMethod: method}, func(any, grpc.ServerStream) error {
+			called = true
+			return nil
+		})
+		return called, err
+	}
+
+	called, err := call("/artifact.ArtifactService/OpenStream")
+	require.NoError(t, err)
+	assert.True(t, called)
+
+	called, err = call("/artifactplugin.s3.Upload/Stream")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.ErrorContains(t, err, "the plugin is read-only")
+	assert.False(t, called)
+}
@@ -0,0 +1,91 @@
+package requestlog
+
+import (
+	"context"
+	"path"
+	"time"
+
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MetadataRequestID is the metadata key carrying the request ID. The caller's value is used when present,
+// and the request ID is always echoed back in the response trailer under it.
+const MetadataRequestID = "x-request-id"
+
+type requestIDKey struct{}
+
+// ID returns the request ID of the RPC ctx belongs to, or "" outside of an RPC
+func ID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// UnaryServerInterceptor logs each unary RPC's method, duration, gRPC code and request ID once it completes.
+// The handler's context carries logger, with the method and request ID attached.
+func UnaryServerInterceptor(logger logging.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, rpcLogger := withRequestLogger(ctx, logger, info.FullMethod)
+		// Outside of a server transport, e.g. when called directly in tests, there is no trailer to set
+		_ = grpc.SetTrailer(ctx, metadata.Pairs(MetadataRequestID, ID(ctx)))
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCompletion(ctx, rpcLogger, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor logs each streaming RPC's method, duration, gRPC code and request ID once it completes.
+// The stream's context carries logger, with the method and request ID attached.
+func StreamServerInterceptor(logger logging.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, rpcLogger := withRequestLogger(ss.Context(), logger, info.FullMethod)
+		ss.SetTrailer(metadata.Pairs(MetadataRequestID, ID(ctx)))
+		start := time.Now()
+		err := handler(srv, &loggedServerStream{ServerStream: ss, ctx: ctx})
+		logCompletion(ctx, rpcLogger, start, err)
+		return err
+	}
+}
+
+// withRequestLogger returns ctx carrying the RPC's request ID, and logger with the method and request ID attached
+func withRequestLogger(ctx context.Context, logger logging.Logger, fullMethod string) (context.Context, logging.Logger) {
+	id := requestID(ctx)
+	logger = logger.WithFields(logging.Fields{"method": path.Base(fullMethod), "requestId": id})
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	return logging.WithLogger(ctx, logger), logger
+}
+
+// logCompletion logs the outcome of an RPC, as a warning when it failed
+func logCompletion(ctx context.Context, logger logging.Logger, start time.Time, err error) {
+	code := status.Code(err)
+	logger = logger.WithFields(logging.Fields{"duration": time.Since(start).String(), "code": code.String()})
+	if code != codes.OK {
+		logger.WithError(err).Warn(ctx, "RPC failed")
+		return
+	}
+	logger.Info(ctx, "RPC completed")
+}
+
+// requestID returns the caller's request ID from the incoming metadata, or generates a UUID
+func requestID(ctx context.Context) string {
+	if values := metadata.ValueFromIncomingContext(ctx, MetadataRequestID); len(values) > 0 && values[0] != "" {
+		return values[0]
+	}
+	return uuid.NewString()
+}
+
+// loggedServerStream replaces the stream context with one carrying the RPC logger
+type loggedServerStream struct {
+	grpc.ServerStream
+	// nolint: containedctx
+	ctx context.Context
+}
+
+func (s *loggedServerStream) Context() context.Context {
+	return s.ctx
+}
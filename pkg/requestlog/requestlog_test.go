@@ -0,0 +1,131 @@
+package requestlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// captureLogger returns a JSON logger writing to the returned buffer
+func captureLogger() (logging.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	return logging.NewSlogLoggerCustom(logging.Debug, logging.JSON, &buf), &buf
+}
+
+// logEntries decodes the JSON log lines written to buf
+func logEntries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var entries []map[string]any
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		var entry map[string]any
+		require.NoError(t, decoder.Decode(&entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	logger, buf := captureLogger()
+	info := &grpc.UnaryServerInfo{FullMethod: "/artifact.ArtifactService/Load"}
+
+	_, err := UnaryServerInterceptor(logger)(t.Context(), nil, info, func(ctx context.Context, _ any) (any, error) {
+		logging.RequireLoggerFromContext(ctx).Info(ctx, "handling")
+		return nil, status.Error(codes.NotFound, "no such key")
+	})
+	require.Error(t, err)
+
+	entries := logEntries(t, buf)
+	require.Len(t, entries, 2)
+	handling, completed := entries[0], entries[1]
+	assert.Equal(t, "handling", handling["msg"])
+	assert.Equal(t, "RPC failed", completed["msg"])
+	assert.Equal(t, "WARN", completed["level"])
+	assert.Equal(t, "Load", completed["method"])
+	assert.Equal(t, "NotFound", completed["code"])
+	assert.NotEmpty(t, completed["duration"])
+	// Without a caller's request ID one is generated, and the handler logs with the same one
+	_, err = uuid.Parse(completed["requestId"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, completed["requestId"], handling["requestId"])
+}
+
+// TestRequestIDPropagation verifies over a real connection that the caller's request ID reaches the handler's
+// context and logs, and is echoed back in the response trailer
+func TestRequestIDPropagation(t *testing.T) {
+	logger, buf := captureLogger()
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(UnaryServerInterceptor(logger)))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	ctx := metadata.AppendToOutgoingContext(t.Context(), MetadataRequestID, "caller-id")
+	var trailer metadata.MD
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"}, grpc.Trailer(&trailer))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, []string{"caller-id"}, trailer.Get(MetadataRequestID))
+
+	entries := logEntries(t, buf)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "caller-id", entries[0]["requestId"])
+	assert.Equal(t, "NotFound", entries[0]["code"])
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	logger, buf := captureLogger()
+	info := &grpc.StreamServerInfo{FullMethod: "/artifact.ArtifactService/OpenStream"}
+	ctx := metadata.NewIncomingContext(t.Context(), metadata.Pairs(MetadataRequestID, "caller-id"))
+
+	stream := &fakeServerStream{ctx: ctx}
+	err := StreamServerInterceptor(logger)(nil, stream, info, func(_ any, ss grpc.ServerStream) error {
+		assert.NotNil(t, logging.GetLoggerFromContextOrNil(ss.Context()))
+		assert.Equal(t, "caller-id", ID(ss.Context()))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"caller-id"}, stream.trailer.Get(MetadataRequestID))
+
+	entries := logEntries(t, buf)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "RPC completed", entries[0]["msg"])
+	assert.Equal(t, "OpenStream", entries[0]["method"])
+	assert.Equal(t, "OK", entries[0]["code"])
+	assert.Equal(t, "caller-id", entries[0]["requestId"])
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	// nolint: containedctx
+	ctx     context.Context
+	trailer metadata.MD
+}
+
+func (s *fakeServerStream) SetTrailer(md metadata.MD) {
+	s.trailer = metadata.Join(s.trailer, md)
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
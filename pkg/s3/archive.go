@@ -0,0 +1,97 @@
+package s3
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/argoproj/argo-workflows/v3/util/file"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+)
+
+// archiveForSave returns the local path Save should upload. A directory is archived into a single temporary
+// file in TempDir when archiving is configured, the returned cleanup function removes it.
+func (s3Driver *ArtifactDriver) archiveForSave(ctx context.Context, path string) (string, func(), error) {
+	noop := func() {}
+	if s3Driver.Archive == "" || s3Driver.Archive == ArchiveNone {
+		return path, noop, nil
+	}
+	isDir, err := file.IsDirectory(path)
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to test if %s is a directory: %v", path, err)
+	}
+	if !isDir {
+		return path, noop, nil
+	}
+	archive, err := os.CreateTemp(s3Driver.TempDir, "artifact-*."+s3Driver.Archive)
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to create archive: %v", err)
+	}
+	cleanup := func() { _ = os.Remove(archive.Name()) }
+	logging.RequireLoggerFromContext(ctx).WithFields(logging.Fields{"path": path, "archive": s3Driver.Archive}).Info(ctx, "Archiving directory")
+	err = archiveDirectory(path, s3Driver.Archive, s3Driver.CompressionLevel, archive)
+	if closeErr := archive.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", noop, fmt.Errorf("failed to archive %s: %v", path, err)
+	}
+	return archive.Name(), cleanup, nil
+}
+
+// archiveDirectory writes the regular files under root to w as a tar archive, gzip compressed at level for tar.gz
+func archiveDirectory(root, format string, level int, w io.Writer) error {
+	if format == ArchiveTarGz {
+		gzw, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return err
+		}
+		if err := archiveDirectory(root, ArchiveTar, level, gzw); err != nil {
+			return err
+		}
+		return gzw.Close()
+	}
+
+	tw := tar.NewWriter(w)
+	err := filepath.WalkDir(root, func(localPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(root, localPath)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		f, err := os.Open(localPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
@@ -0,0 +1,101 @@
+package s3
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+)
+
+// readTarGz returns the contents of each file in a tar.gz archive keyed by name
+func readTarGz(t *testing.T, path string) map[string]string {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	gzr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gzr)
+	files := map[string]string{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = string(data)
+	}
+}
+
+func TestArchiveForSave(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nested", "b.txt"), []byte("b"), 0o600))
+
+	t.Run("Directory is archived into a single object", func(t *testing.T) {
+		driver := &ArtifactDriver{Archive: ArchiveTarGz, CompressionLevel: gzip.BestSpeed}
+		uploadPath, cleanup, err := driver.archiveForSave(ctx, dir)
+		require.NoError(t, err)
+		assert.NotEqual(t, dir, uploadPath)
+		assert.Equal(t, map[string]string{"a.txt": "a", "nested/b.txt": "b"}, readTarGz(t, uploadPath))
+
+		s3cli := &mockS3Client{files: map[string][]string{}, mockedErrs: map[string]error{}}
+		archive, err := os.Open(uploadPath)
+		require.NoError(t, err)
+		defer archive.Close()
+		done, err := saveS3Artifact(ctx, s3cli, archive, -1, &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				S3: &wfv1.S3Artifact{
+					S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+					Key:      "my-wf/outputs.tgz",
+				},
+			},
+		})
+		require.NoError(t, err)
+		assert.True(t, done)
+		assert.Equal(t, []string{"my-wf/outputs.tgz"}, s3cli.putKeys)
+
+		cleanup()
+		assert.NoFileExists(t, uploadPath)
+	})
+
+	t.Run("Archive is buffered in TempDir", func(t *testing.T) {
+		tempDir := t.TempDir()
+		driver := &ArtifactDriver{Archive: ArchiveTar, TempDir: tempDir}
+		uploadPath, cleanup, err := driver.archiveForSave(ctx, dir)
+		require.NoError(t, err)
+		assert.Equal(t, tempDir, filepath.Dir(uploadPath))
+
+		cleanup()
+		entries, err := os.ReadDir(tempDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("Directory is left as is without archiving", func(t *testing.T) {
+		uploadPath, cleanup, err := (&ArtifactDriver{}).archiveForSave(ctx, dir)
+		require.NoError(t, err)
+		defer cleanup()
+		assert.Equal(t, dir, uploadPath)
+	})
+
+	t.Run("File is never archived", func(t *testing.T) {
+		filePath := filepath.Join(dir, "a.txt")
+		uploadPath, cleanup, err := (&ArtifactDriver{Archive: ArchiveTar}).archiveForSave(ctx, filePath)
+		require.NoError(t, err)
+		defer cleanup()
+		assert.Equal(t, filePath, uploadPath)
+	})
+}
@@ -0,0 +1,101 @@
+package s3
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/minio/minio-go/v7"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+)
+
+// BucketCheckResult classifies the outcome of CheckBucket
+type BucketCheckResult string
+
+const (
+	// BucketReachable means the bucket exists and the credentials may access it
+	BucketReachable BucketCheckResult = "Reachable"
+	// BucketDNSFailure means the endpoint's host name could not be resolved
+	BucketDNSFailure BucketCheckResult = "DNSFailure"
+	// BucketTLSFailure means the TLS handshake failed, usually because the endpoint's certificate isn't trusted
+	BucketTLSFailure BucketCheckResult = "TLSFailure"
+	// BucketAuthFailure means the credentials were rejected, or aren't allowed to access the bucket
+	BucketAuthFailure BucketCheckResult = "AuthFailure"
+	// BucketNotFound means the endpoint was reached but the bucket doesn't exist
+	BucketNotFound BucketCheckResult = "BucketNotFound"
+	// BucketUnreachable means the endpoint could not be connected to
+	BucketUnreachable BucketCheckResult = "Unreachable"
+	// BucketCheckFailed is any other failure
+	BucketCheckFailed BucketCheckResult = "Failed"
+)
+
+// BucketCheck is the outcome of CheckBucket, with the error behind any failure
+type BucketCheck struct {
+	Result  BucketCheckResult
+	Message string
+}
+
+// CheckBucket resolves the client and sends a HeadBucket request for the artifact's bucket, without reading or
+// writing any object, to tell apart the ways a configuration can fail to reach it
+func (s3Driver *ArtifactDriver) CheckBucket(ctx context.Context, artifact *wfv1.Artifact) BucketCheck {
+	logging.RequireLoggerFromContext(ctx).WithFields(logging.Fields{"endpoint": s3Driver.Endpoint, "bucket": artifact.S3.Bucket}).Info(ctx, "S3 CheckBucket")
+	s3cli, err := s3Driver.newS3Client(ctx)
+	if err != nil {
+		return BucketCheck{Result: classifyBucketError(err), Message: fmt.Sprintf("failed to create new S3 client: %v", err)}
+	}
+	return checkBucket(s3cli, artifact.S3.Bucket)
+}
+
+// checkBucket checks the bucket exists and is accessible
+func checkBucket(s3cli S3Client, bucket string) BucketCheck {
+	exists, err := s3cli.BucketExists(bucket)
+	switch {
+	case err != nil:
+		return BucketCheck{Result: classifyBucketError(err), Message: err.Error()}
+	case !exists:
+		return BucketCheck{Result: BucketNotFound, Message: fmt.Sprintf("bucket %s does not exist", bucket)}
+	}
+	return BucketCheck{Result: BucketReachable, Message: fmt.Sprintf("bucket %s is reachable", bucket)}
+}
+
+// classifyBucketError returns the BucketCheckResult for an error reaching the bucket
+func classifyBucketError(err error) BucketCheckResult {
+	var dnsErr *net.DNSError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certificateInvalidErr x509.CertificateInvalidError
+	var verificationErr *tls.CertificateVerificationError
+	var recordHeaderErr tls.RecordHeaderError
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &dnsErr):
+		return BucketDNSFailure
+	case errors.As(err, &unknownAuthorityErr), errors.As(err, &hostnameErr), errors.As(err, &certificateInvalidErr),
+		errors.As(err, &verificationErr), errors.As(err, &recordHeaderErr):
+		return BucketTLSFailure
+	case IsS3ErrCode(err, "NoSuchBucket"):
+		return BucketNotFound
+	case isS3AuthError(err):
+		return BucketAuthFailure
+	case errors.As(err, &opErr):
+		return BucketUnreachable
+	}
+	return BucketCheckFailed
+}
+
+// isS3AuthError reports whether S3 rejected the request's credentials or denied it access
+func isS3AuthError(err error) bool {
+	for _, code := range []string{"AccessDenied", "AllAccessDisabled", "InvalidAccessKeyId", "SignatureDoesNotMatch", "ExpiredToken", "InvalidToken"} {
+		if IsS3ErrCode(err, code) {
+			return true
+		}
+	}
+	var minioErr minio.ErrorResponse
+	return errors.As(err, &minioErr) && (minioErr.StatusCode == http.StatusUnauthorized || minioErr.StatusCode == http.StatusForbidden)
+}
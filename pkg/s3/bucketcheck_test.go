@@ -0,0 +1,53 @@
+package s3
+
+import (
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCheckBucket verifies each way of failing to reach the bucket is told apart
+func TestCheckBucket(t *testing.T) {
+	dial := func(err error) error {
+		return &url.Error{Op: "Head", URL: "https://s3.example.com/my-bucket/", Err: &net.OpError{Op: "dial", Net: "tcp", Err: err}}
+	}
+
+	for name, tc := range map[string]struct {
+		files    map[string][]string
+		err      error
+		expected BucketCheckResult
+	}{
+		"Reachable":          {files: map[string][]string{"my-bucket": {}}, expected: BucketReachable},
+		"Missing bucket":     {expected: BucketNotFound},
+		"Missing bucket err": {err: minio.ErrorResponse{Code: "NoSuchBucket", StatusCode: http.StatusNotFound}, expected: BucketNotFound},
+		"DNS failure":        {err: dial(&net.DNSError{Err: "no such host", Name: "s3.example.com", IsNotFound: true}), expected: BucketDNSFailure},
+		"Untrusted certificate": {
+			err:      &url.Error{Op: "Head", URL: "https://s3.example.com/my-bucket/", Err: x509.UnknownAuthorityError{}},
+			expected: BucketTLSFailure,
+		},
+		"Wrong host name": {
+			err:      &url.Error{Op: "Head", URL: "https://s3.example.com/my-bucket/", Err: x509.HostnameError{Host: "s3.example.com"}},
+			expected: BucketTLSFailure,
+		},
+		"Access denied":       {err: minio.ErrorResponse{Code: "AccessDenied", StatusCode: http.StatusForbidden}, expected: BucketAuthFailure},
+		"Invalid access key":  {err: minio.ErrorResponse{Code: "InvalidAccessKeyId", StatusCode: http.StatusForbidden}, expected: BucketAuthFailure},
+		"Forbidden HEAD":      {err: minio.ErrorResponse{StatusCode: http.StatusForbidden}, expected: BucketAuthFailure},
+		"Connection refused":  {err: dial(errors.New("connection refused")), expected: BucketUnreachable},
+		"Server error":        {err: minio.ErrorResponse{Code: "InternalError", StatusCode: http.StatusInternalServerError}, expected: BucketCheckFailed},
+		"Unclassified errors": {err: errors.New("something else"), expected: BucketCheckFailed},
+	} {
+		t.Run(name, func(t *testing.T) {
+			check := checkBucket(newMockS3Client(tc.files, map[string]error{"BucketExists": tc.err}), "my-bucket")
+			assert.Equal(t, tc.expected, check.Result)
+			if tc.err != nil {
+				assert.Equal(t, tc.err.Error(), check.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/file"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+)
+
+const (
+	// ChecksumSHA256 verifies downloads against the object's x-amz-checksum-sha256
+	ChecksumSHA256 = "sha256"
+	// ChecksumCRC32C verifies downloads against the object's x-amz-checksum-crc32c
+	ChecksumCRC32C = "crc32c"
+	// checksumMD5 verifies downloads against a single part object's ETag
+	checksumMD5 = "md5"
+)
+
+// minioPartSize is the part size minio uploads with when none is set. Files of at least the part size are
+// uploaded in parts, unless multipart is disabled.
+const minioPartSize = 16 * 1024 * 1024
+
+// verifyS3Artifact compares the checksum of the downloaded file with the one S3 holds for the object.
+// The algorithm's checksum is preferred, falling back to the ETag when it is a plain MD5. Directories and
+// objects with neither aren't verified.
+// returns true if the file matches or can't be retried (non-transient error)
+// returns false if it can be retried (mismatch or transient error)
+func verifyS3Artifact(ctx context.Context, s3cli S3Client, inputArtifact *wfv1.Artifact, path, algorithm string) (bool, error) {
+	log := logging.RequireLoggerFromContext(ctx).WithFields(logging.Fields{"key": inputArtifact.S3.Key, "path": path})
+	isDir, err := file.IsDirectory(path)
+	if err != nil {
+		return true, fmt.Errorf("failed to test if %s is a directory: %w", path, err)
+	}
+	if isDir {
+		log.Debug(ctx, "Not verifying the checksum of a directory")
+		return true, nil
+	}
+
+	info, err := s3cli.StatObject(inputArtifact.S3.Bucket, inputArtifact.S3.Key)
+	if err != nil {
+		return !isTransientS3Err(ctx, err), fmt.Errorf("failed to stat %s: %w", inputArtifact.S3.Key, err)
+	}
+	kind, expected := expectedChecksum(info, algorithm)
+	if kind == "" {
+		log.Warn(ctx, "Object has no checksum to verify against, skipping verification")
+		return true, nil
+	}
+	actual, err := fileChecksum(path, kind)
+	if err != nil {
+		return true, fmt.Errorf("failed to compute the %s checksum of %s: %v", kind, path, err)
+	}
+	if actual != expected {
+		return false, fmt.Errorf("%s checksum mismatch for %s: expected %s, downloaded file has %s", kind, inputArtifact.S3.Key, expected, actual)
+	}
+	log.WithField("checksum", kind).Debug(ctx, "Checksum verified")
+	return true, nil
+}
+
+// isUnchanged reports whether the object at the artifact's key already holds the file at path, by comparing their
+// sizes and then checksums as verifyS3Artifact does. A directory, a missing object, or an object without a checksum
+// to compare is never unchanged.
+func isUnchanged(ctx context.Context, s3cli S3Client, outputArtifact *wfv1.Artifact, path, algorithm string) (bool, error) {
+	log := logging.RequireLoggerFromContext(ctx).WithFields(logging.Fields{"key": outputArtifact.S3.Key, "path": path})
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if fileInfo.IsDir() {
+		return false, nil
+	}
+
+	info, err := s3cli.StatObject(outputArtifact.S3.Bucket, outputArtifact.S3.Key)
+	if IsS3ErrCode(err, "NoSuchKey") {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to stat %s: %w", outputArtifact.S3.Key, err)
+	}
+	if info.Size != fileInfo.Size() {
+		return false, nil
+	}
+	kind, expected := expectedChecksum(info, algorithm)
+	if kind == "" {
+		log.Debug(ctx, "Object has no checksum to compare with, uploading it")
+		return false, nil
+	}
+	actual, err := fileChecksum(path, kind)
+	if err != nil {
+		return false, fmt.Errorf("failed to compute the %s checksum of %s: %v", kind, path, err)
+	}
+	return actual == expected, nil
+}
+
+// expectedChecksum returns the kind and value of the checksum to verify the object against, or an empty kind.
+// Composite checksums and ETags of multipart uploads don't cover the whole object, and the ETags of KMS or
+// customer key encrypted objects aren't their MD5, so none of these can be used.
+func expectedChecksum(info minio.ObjectInfo, algorithm string) (string, string) {
+	value := info.ChecksumSHA256
+	if algorithm == ChecksumCRC32C {
+		value = info.ChecksumCRC32C
+	}
+	if value != "" && !strings.Contains(value, "-") {
+		return algorithm, value
+	}
+
+	etag := strings.Trim(info.ETag, `"`)
+	encrypted := info.Metadata.Get("X-Amz-Server-Side-Encryption") == SSEAlgorithmKMS ||
+		info.Metadata.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != ""
+	if etag != "" && !strings.Contains(etag, "-") && !encrypted {
+		return checksumMD5, etag
+	}
+	return "", ""
+}
+
+// fileChecksum returns the checksum of the file encoded the way S3 reports it:
+// base64 for sha256 and crc32c, hex for the md5 ETag
+func fileChecksum(path, kind string) (string, error) {
+	h, err := newChecksumHash(kind)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return encodeChecksum(kind, h), nil
+}
+
+// newChecksumHash returns the hash computing a checksum of kind
+func newChecksumHash(kind string) (hash.Hash, error) {
+	switch kind {
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	case ChecksumCRC32C:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli)), nil
+	case checksumMD5:
+		return md5.New(), nil
+	}
+	return nil, fmt.Errorf("unknown checksum algorithm %s", kind)
+}
+
+// encodeChecksum returns the checksum h has computed encoded the way S3 reports it
+func encodeChecksum(kind string, h hash.Hash) string {
+	if kind == checksumMD5 {
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	if crc, ok := h.(hash.Hash32); ok {
+		return base64.StdEncoding.EncodeToString(binary.BigEndian.AppendUint32(nil, crc.Sum32()))
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// writeS3Artifact copies the artifact's stream into w. With an algorithm, the bytes written are compared with the
+// checksum S3 holds for the object once they all have been, as verifyS3Artifact compares a downloaded file.
+func writeS3Artifact(ctx context.Context, s3cli S3Client, inputArtifact *wfv1.Artifact, stream io.Reader, w io.Writer, algorithm string) error {
+	kind, expected := "", ""
+	if algorithm != "" {
+		info, err := s3cli.StatObject(inputArtifact.S3.Bucket, inputArtifact.S3.Key)
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", inputArtifact.S3.Key, err)
+		}
+		if kind, expected = expectedChecksum(info, algorithm); kind == "" {
+			logging.RequireLoggerFromContext(ctx).WithField("key", inputArtifact.S3.Key).Warn(ctx, "Object has no checksum to verify against, skipping verification")
+		}
+	}
+	if kind == "" {
+		if _, err := io.Copy(w, stream); err != nil {
+			return fmt.Errorf("failed to write %s: %w", inputArtifact.S3.Key, err)
+		}
+		return nil
+	}
+
+	h, err := newChecksumHash(kind)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.MultiWriter(w, h), stream); err != nil {
+		return fmt.Errorf("failed to write %s: %w", inputArtifact.S3.Key, err)
+	}
+	if actual := encodeChecksum(kind, h); actual != expected {
+		return fmt.Errorf("%s checksum mismatch for %s: expected %s, written data has %s", kind, inputArtifact.S3.Key, expected, actual)
+	}
+	return nil
+}
+
+// uploadChecksum adds a checksum of the file to the upload options for S3 to validate. A file uploaded in a single
+// request carries the checksum of the whole file, larger files are uploaded in parts each sent with its own checksum.
+func uploadChecksum(path, algorithm string, putOpts *minio.PutObjectOptions) error {
+	checksumType := uploadChecksumType(algorithm)
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	partSize := int64(putOpts.PartSize)
+	if partSize == 0 {
+		partSize = minioPartSize
+	}
+	if !putOpts.DisableMultipart && info.Size() >= partSize {
+		putOpts.Checksum = checksumType
+		return nil
+	}
+	sum, err := fileChecksum(path, algorithm)
+	if err != nil {
+		return err
+	}
+	if putOpts.UserMetadata == nil {
+		putOpts.UserMetadata = map[string]string{}
+	}
+	putOpts.UserMetadata[checksumType.Key()] = sum
+	return nil
+}
+
+// bytesChecksum adds the checksum of data, which is uploaded in a single request, to the upload options
+func bytesChecksum(data []byte, algorithm string, putOpts *minio.PutObjectOptions) {
+	checksumType := uploadChecksumType(algorithm)
+	if putOpts.UserMetadata == nil {
+		putOpts.UserMetadata = map[string]string{}
+	}
+	putOpts.UserMetadata[checksumType.Key()] = checksumType.ChecksumBytes(data).Encoded()
+}
+
+// uploadChecksumType returns the minio checksum type for a checksumAlgorithm
+func uploadChecksumType(algorithm string) minio.ChecksumType {
+	if algorithm == ChecksumCRC32C {
+		return minio.ChecksumCRC32C
+	}
+	return minio.ChecksumSHA256
+}
@@ -0,0 +1,174 @@
+package s3
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+)
+
+func TestVerifyS3Artifact(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	content := []byte("hello artifact")
+	sha := sha256.Sum256(content)
+	md := md5.Sum(content)
+	path := filepath.Join(t.TempDir(), "hello-art.txt")
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+		S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+		Key:      "my-wf/hello-art.txt",
+	}}}
+
+	tests := map[string]struct {
+		info      minio.ObjectInfo
+		algorithm string
+		done      bool
+		errMsg    string
+	}{
+		"SHA256 match": {
+			info:      minio.ObjectInfo{ChecksumSHA256: base64.StdEncoding.EncodeToString(sha[:])},
+			algorithm: ChecksumSHA256,
+			done:      true,
+		},
+		"SHA256 mismatch": {
+			info:      minio.ObjectInfo{ChecksumSHA256: base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))},
+			algorithm: ChecksumSHA256,
+			errMsg:    "sha256 checksum mismatch for my-wf/hello-art.txt",
+		},
+		"CRC32C match": {
+			info:      minio.ObjectInfo{ChecksumCRC32C: "Umqp5Q=="},
+			algorithm: ChecksumCRC32C,
+			done:      true,
+		},
+		"CRC32C mismatch": {
+			info:      minio.ObjectInfo{ChecksumCRC32C: "AAAAAA=="},
+			algorithm: ChecksumCRC32C,
+			errMsg:    "crc32c checksum mismatch",
+		},
+		"ETag match": {
+			info:      minio.ObjectInfo{ETag: `"` + hex.EncodeToString(md[:]) + `"`},
+			algorithm: ChecksumSHA256,
+			done:      true,
+		},
+		"ETag mismatch": {
+			info:      minio.ObjectInfo{ETag: "d41d8cd98f00b204e9800998ecf8427e"},
+			algorithm: ChecksumSHA256,
+			errMsg:    "md5 checksum mismatch",
+		},
+		"Multipart ETag is skipped": {
+			info:      minio.ObjectInfo{ETag: "d41d8cd98f00b204e9800998ecf8427e-3"},
+			algorithm: ChecksumSHA256,
+			done:      true,
+		},
+		"Composite checksum is skipped": {
+			info:      minio.ObjectInfo{ChecksumSHA256: "AAAA-3"},
+			algorithm: ChecksumSHA256,
+			done:      true,
+		},
+		"KMS encrypted ETag is skipped": {
+			info: minio.ObjectInfo{
+				ETag:     "d41d8cd98f00b204e9800998ecf8427e",
+				Metadata: http.Header{"X-Amz-Server-Side-Encryption": {SSEAlgorithmKMS}},
+			},
+			algorithm: ChecksumSHA256,
+			done:      true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s3cli := &mockS3Client{objectInfos: map[string]minio.ObjectInfo{artifact.S3.Key: tc.info}}
+			done, err := verifyS3Artifact(ctx, s3cli, artifact, path, tc.algorithm)
+			assert.Equal(t, tc.done, done)
+			if tc.errMsg == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tc.errMsg)
+			}
+		})
+	}
+
+	t.Run("Directory is skipped", func(t *testing.T) {
+		done, err := verifyS3Artifact(ctx, &mockS3Client{}, artifact, t.TempDir(), ChecksumSHA256)
+		require.NoError(t, err)
+		assert.True(t, done)
+	})
+}
+
+func TestIsUnchanged(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	content := []byte("hello artifact")
+	md := md5.Sum(content)
+	size := int64(len(content))
+	path := filepath.Join(t.TempDir(), "hello-art.txt")
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+		S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+		Key:      "my-wf/hello-art.txt",
+	}}}
+
+	for name, tc := range map[string]struct {
+		info      *minio.ObjectInfo
+		unchanged bool
+	}{
+		"Same MD5 ETag":      {info: &minio.ObjectInfo{Size: size, ETag: hex.EncodeToString(md[:])}, unchanged: true},
+		"Different ETag":     {info: &minio.ObjectInfo{Size: size, ETag: hex.EncodeToString(make([]byte, md5.Size))}},
+		"Different size":     {info: &minio.ObjectInfo{Size: size + 1, ETag: hex.EncodeToString(md[:])}},
+		"No usable checksum": {info: &minio.ObjectInfo{Size: size, ETag: "abc-2"}},
+		"Missing object":     {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s3cli := &mockS3Client{}
+			if tc.info != nil {
+				s3cli.objectInfos = map[string]minio.ObjectInfo{"my-wf/hello-art.txt": *tc.info}
+			}
+			unchanged, err := isUnchanged(ctx, s3cli, artifact, path, ChecksumSHA256)
+			require.NoError(t, err)
+			assert.Equal(t, tc.unchanged, unchanged)
+		})
+	}
+}
+
+func TestUploadChecksum(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hello-art.txt")
+	require.NoError(t, os.WriteFile(path, []byte("hello artifact"), 0o600))
+
+	t.Run("SHA256", func(t *testing.T) {
+		var putOpts minio.PutObjectOptions
+		require.NoError(t, uploadChecksum(path, ChecksumSHA256, &putOpts))
+		sha := sha256.Sum256([]byte("hello artifact"))
+		assert.Equal(t, base64.StdEncoding.EncodeToString(sha[:]), putOpts.Header().Get("X-Amz-Checksum-Sha256"))
+		assert.False(t, putOpts.Checksum.IsSet())
+	})
+
+	t.Run("CRC32C", func(t *testing.T) {
+		var putOpts minio.PutObjectOptions
+		require.NoError(t, uploadChecksum(path, ChecksumCRC32C, &putOpts))
+		assert.Equal(t, "Umqp5Q==", putOpts.Header().Get("X-Amz-Checksum-Crc32c"))
+	})
+
+	t.Run("Multipart file uses per-part checksums", func(t *testing.T) {
+		large := filepath.Join(dir, "large.bin")
+		require.NoError(t, os.WriteFile(large, make([]byte, minioPartSize), 0o600))
+		var putOpts minio.PutObjectOptions
+		require.NoError(t, uploadChecksum(large, ChecksumSHA256, &putOpts))
+		assert.Equal(t, minio.ChecksumSHA256, putOpts.Checksum)
+		assert.Empty(t, putOpts.Header().Get("X-Amz-Checksum-Sha256"))
+
+		putOpts = minio.PutObjectOptions{DisableMultipart: true}
+		require.NoError(t, uploadChecksum(large, ChecksumSHA256, &putOpts))
+		assert.False(t, putOpts.Checksum.IsSet())
+		assert.NotEmpty(t, putOpts.Header().Get("X-Amz-Checksum-Sha256"))
+	})
+}
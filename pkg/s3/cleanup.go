@@ -0,0 +1,97 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+
+	argoerrs "github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+)
+
+// DeleteOlderThan deletes the objects under the artifact's key prefix which were last modified more than maxAge
+// ago, in batches of up to 1000 keys per request, and returns the keys deleted. With dryRun, or the driver's
+// DryRun, nothing is deleted and the keys which would have been are returned.
+func (s3Driver *ArtifactDriver) DeleteOlderThan(ctx context.Context, artifact *wfv1.Artifact, maxAge time.Duration, dryRun bool) (keys []string, err error) {
+	ctx, span := startSpan(ctx, "S3 DeleteOlderThan", artifact)
+	defer func() { endSpan(span, err, "") }()
+
+	if maxAge <= 0 {
+		return nil, argoerrs.Errorf(argoerrs.CodeBadRequest, "max age must be positive, got %s", maxAge)
+	}
+	if err := s3Driver.checkKeyPrefix(artifact); err != nil {
+		return nil, err
+	}
+	dryRun = dryRun || s3Driver.DryRun
+	if !dryRun {
+		if err := s3Driver.checkWritable("DeleteOlderThan"); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	log := logging.RequireLoggerFromContext(ctx).WithFields(logging.Fields{"key": artifact.S3.Key, "maxAge": maxAge.String(), "dryRun": dryRun})
+	log.Info(ctx, "S3 DeleteOlderThan")
+	keys, err = retryDeleteOlderThan(ctx, s3Driver.retryBackoff(ctx), func() (S3Client, error) {
+		return s3Driver.newS3Client(ctx)
+	}, artifact, time.Now().Add(-maxAge), dryRun)
+	if dryRun {
+		log.WithFields(logging.Fields{"keys": keys, "count": len(keys)}).Info(ctx, "S3 DeleteOlderThan dry run, not deleting")
+	}
+	return keys, err
+}
+
+// retryDeleteOlderThan runs deleteOlderThan with a client from newClient, retrying transient errors with backoff.
+// Each attempt lists the prefix again, so its keys replace the last attempt's, but the keys a failed attempt did
+// delete are kept, as a retried listing no longer returns them.
+func retryDeleteOlderThan(ctx context.Context, backoff wait.Backoff, newClient func() (S3Client, error), artifact *wfv1.Artifact, cutoff time.Time, dryRun bool) ([]string, error) {
+	var keys, deletedBefore []string
+	err := retry.OnError(backoff, func(err error) bool {
+		return isTransientS3Err(ctx, err)
+	}, func() error {
+		s3cli, err := newClient()
+		if err != nil {
+			return fmt.Errorf("failed to create new S3 client: %w", err)
+		}
+		keys, err = deleteOlderThan(s3cli, artifact, cutoff, dryRun)
+		if err != nil && !dryRun {
+			deletedBefore = append(deletedBefore, keys...)
+		}
+		return err
+	})
+	listed := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		listed[key] = true
+	}
+	for _, key := range deletedBefore {
+		if !listed[key] {
+			listed[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys, err
+}
+
+// deleteOlderThan lists the objects under the artifact's key prefix and batch deletes those last modified before
+// cutoff, returning the keys deleted, or with dryRun the keys which would have been
+func deleteOlderThan(s3cli S3Client, artifact *wfv1.Artifact, cutoff time.Time, dryRun bool) ([]string, error) {
+	objects, err := s3cli.ListDirectoryInfo(artifact.S3.Bucket, artifact.S3.Key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list files in %s: %w", artifact.S3.Key, err)
+	}
+	var stale []string
+	for _, object := range objects {
+		if object.LastModified.Before(cutoff) {
+			stale = append(stale, object.Key)
+		}
+	}
+	if dryRun {
+		return stale, nil
+	}
+	return deleteKeys(s3cli, artifact.S3.Bucket, stale)
+}
@@ -0,0 +1,124 @@
+package s3
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	argoerrs "github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+)
+
+func TestDeleteOlderThan(t *testing.T) {
+	now := time.Now()
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: "runs/"}}}
+	cutoff := now.Add(-7 * 24 * time.Hour)
+	newMock := func(mockedErrs map[string]error) *mockS3Client {
+		return &mockS3Client{
+			files:      map[string][]string{"my-bucket": {"runs/a/out.log", "runs/b/out.log", "runs/c/out.log", "other/old.log"}},
+			mockedErrs: mockedErrs,
+			objectInfos: map[string]minio.ObjectInfo{
+				"runs/a/out.log": {Key: "runs/a/out.log", LastModified: now.Add(-30 * 24 * time.Hour)},
+				"runs/b/out.log": {Key: "runs/b/out.log", LastModified: now.Add(-time.Hour)},
+				"runs/c/out.log": {Key: "runs/c/out.log", LastModified: now.Add(-8 * 24 * time.Hour)},
+				"other/old.log":  {Key: "other/old.log", LastModified: now.Add(-365 * 24 * time.Hour)},
+			},
+		}
+	}
+
+	t.Run("Deletes stale objects", func(t *testing.T) {
+		s3cli := newMock(nil)
+		keys, err := deleteOlderThan(s3cli, artifact, cutoff, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"runs/a/out.log", "runs/c/out.log"}, keys)
+		assert.Equal(t, []string{"runs/a/out.log", "runs/c/out.log"}, s3cli.deletedKeys)
+	})
+
+	t.Run("Dry run", func(t *testing.T) {
+		s3cli := newMock(nil)
+		keys, err := deleteOlderThan(s3cli, artifact, cutoff, true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"runs/a/out.log", "runs/c/out.log"}, keys)
+		assert.Empty(t, s3cli.deletedKeys)
+	})
+
+	t.Run("Nothing stale", func(t *testing.T) {
+		s3cli := newMock(nil)
+		keys, err := deleteOlderThan(s3cli, artifact, now.Add(-365*24*time.Hour), false)
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+		assert.Empty(t, s3cli.deletedKeys)
+	})
+
+	t.Run("Partial failure", func(t *testing.T) {
+		deleteErr := &DeleteObjectsError{Total: 2, Failed: map[string]error{"runs/c/out.log": errors.New("access denied")}}
+		keys, err := deleteOlderThan(newMock(map[string]error{"DeleteObjects": deleteErr}), artifact, cutoff, false)
+		require.ErrorIs(t, err, deleteErr)
+		assert.Equal(t, []string{"runs/a/out.log"}, keys)
+	})
+
+	t.Run("List error", func(t *testing.T) {
+		_, err := deleteOlderThan(newMock(map[string]error{"ListDirectoryInfo": errors.New("list failed")}), artifact, cutoff, false)
+		require.ErrorContains(t, err, "unable to list files in runs/")
+	})
+}
+
+func TestDeleteOlderThan_Invalid(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: "runs/"}}}
+
+	_, err := (&ArtifactDriver{}).DeleteOlderThan(ctx, artifact, 0, false)
+	assert.True(t, argoerrs.IsCode(argoerrs.CodeBadRequest, err))
+
+	_, err = (&ArtifactDriver{KeyPrefix: "team-a/"}).DeleteOlderThan(ctx, artifact, time.Hour, false)
+	assert.True(t, argoerrs.IsCode(argoerrs.CodeForbidden, err))
+
+	_, err = (&ArtifactDriver{Anonymous: true}).DeleteOlderThan(ctx, artifact, time.Hour, false)
+	assert.True(t, argoerrs.IsCode(argoerrs.CodeForbidden, err))
+}
+
+// flakyDeleteS3Client fails the first DeleteObjects for the key with a transient error, leaving the listing as it was
+type flakyDeleteS3Client struct {
+	*mockS3Client
+	failKey string
+	failed  bool
+}
+
+func (s *flakyDeleteS3Client) DeleteObjects(bucket string, keys []string) error {
+	if err := s.mockS3Client.DeleteObjects(bucket, keys); err != nil || s.failed {
+		return err
+	}
+	s.failed = true
+	return &DeleteObjectsError{Total: len(keys), Failed: map[string]error{s.failKey: minio.ErrorResponse{Code: "ServiceUnavailable", StatusCode: 503}}}
+}
+
+// TestRetryDeleteOlderThan verifies a retried attempt's keys replace the last attempt's rather than adding to them
+func TestRetryDeleteOlderThan(t *testing.T) {
+	t.Setenv("EXECUTOR_RETRY_BACKOFF_DURATION", "1ms")
+	ctx := logging.TestContext(t.Context())
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: "runs/"}}}
+	s3cli := &flakyDeleteS3Client{
+		mockS3Client: &mockS3Client{files: map[string][]string{"my-bucket": {"runs/a/out.log", "runs/c/out.log"}}},
+		failKey:      "runs/c/out.log",
+	}
+	newClient := func() (S3Client, error) { return s3cli, nil }
+	backoff := (&ArtifactDriver{MaxRetryAttempts: DefaultMaxRetryAttempts}).retryBackoff(ctx)
+
+	keys, err := retryDeleteOlderThan(ctx, backoff, newClient, artifact, time.Now(), false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"runs/a/out.log", "runs/c/out.log"}, keys)
+	assert.Equal(t, []string{"runs/a/out.log", "runs/c/out.log", "runs/a/out.log", "runs/c/out.log"}, s3cli.deletedKeys)
+
+	t.Run("Dry run", func(t *testing.T) {
+		s3cli := &mockS3Client{files: map[string][]string{"my-bucket": {"runs/a/out.log"}}, mockedErrs: map[string]error{}}
+		keys, err := retryDeleteOlderThan(ctx, backoff, func() (S3Client, error) { return s3cli, nil }, artifact, time.Now(), true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"runs/a/out.log"}, keys)
+		assert.Empty(t, s3cli.deletedKeys)
+	})
+}
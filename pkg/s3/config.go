@@ -1,41 +1,1092 @@
 package s3
 
 import (
+	"cmp"
+	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"maps"
+	"mime"
+	"net"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
 	"github.com/argoproj/argo-workflows/v3/util/logging"
+	"github.com/argoproj/argo-workflows/v3/workflow/common"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/tags"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
 	"sigs.k8s.io/yaml"
 )
 
+const (
+	// envVarWebIdentityTokenFile is set by EKS IRSA to the path of the projected service account token
+	envVarWebIdentityTokenFile = "AWS_WEB_IDENTITY_TOKEN_FILE"
+	// envVarRoleARN is set by EKS IRSA to the role the service account is bound to
+	envVarRoleARN = "AWS_ROLE_ARN"
+	// envVarAccessKeyID, envVarSecretAccessKey and envVarSessionToken are the standard AWS static credential variables
+	envVarAccessKeyID     = "AWS_ACCESS_KEY_ID"
+	envVarSecretAccessKey = "AWS_SECRET_ACCESS_KEY"
+	envVarSessionToken    = "AWS_SESSION_TOKEN"
+	// envVarRegion, envVarDefaultRegion and envVarEndpointURLS3 are the standard AWS region and S3 endpoint variables,
+	// used with useSDKCreds when the configuration leaves the region or endpoint empty
+	envVarRegion        = "AWS_REGION"
+	envVarDefaultRegion = "AWS_DEFAULT_REGION"
+	envVarEndpointURLS3 = "AWS_ENDPOINT_URL_S3"
+	// envVarSecretNamespace is the namespace credential secrets are read from when secretNamespace isn't configured
+	envVarSecretNamespace = "SECRET_NAMESPACE"
+	// envVarPodNamespace is the pod's own namespace, commonly injected through the downward API, used in place of
+	// the service account's namespace when that isn't mounted
+	envVarPodNamespace = "POD_NAMESPACE"
+	// envVarConfigStrict set to false ignores plugin configuration fields this version doesn't recognise
+	envVarConfigStrict = "CONFIG_STRICT"
+	// envVarConfigExpandStrict set to true fails configurations referencing undefined environment variables
+	envVarConfigExpandStrict = "CONFIG_EXPAND_STRICT"
+	// envVarConfigExpandVars lists, comma separated, environment variables configurations may expand besides those
+	// named with configExpandPrefix
+	envVarConfigExpandVars = "CONFIG_EXPAND_VARS"
+	// configExpandPrefix names the environment variables configurations may expand, any other must be listed in
+	// CONFIG_EXPAND_VARS so that configurations can't read the plugin's credentials
+	configExpandPrefix = "ARTIFACT_VAR_"
+	// envVarPluginDefaultsFile is the path of a YAML plugin configuration every artifact's configuration is merged onto
+	envVarPluginDefaultsFile = "PLUGIN_DEFAULTS_FILE"
+	// envVarKeyPrefix scopes the plugin instance to the keys under it, such as a tenant's area of a shared bucket. It
+	// is set for the instance rather than in artifact configurations, which the tenant writes, so it can't be changed.
+	envVarKeyPrefix = "KEY_PREFIX"
+)
+
+const (
+	// DefaultStreamChunkSize is the OpenStream chunk size used when none is configured
+	DefaultStreamChunkSize = 1024 * 1024
+	minStreamChunkSize     = 64 * 1024
+	maxStreamChunkSize     = 64 * 1024 * 1024
+)
+
+const (
+	// DefaultMultipartThreshold and DefaultMultipartPartSize are used when multipartThresholdBytes and
+	// multipartPartSizeBytes aren't configured
+	DefaultMultipartThreshold = 64 * 1024 * 1024
+	DefaultMultipartPartSize  = 16 * 1024 * 1024
+	// minMultipartPartSize and maxMultipartPartSize are the S3 part size limits, maxMultipartPartSize is
+	// also the largest object S3 accepts in a single PUT
+	minMultipartPartSize = 5 * 1024 * 1024
+	maxMultipartPartSize = 5 * 1024 * 1024 * 1024
+	// DefaultMultipartConcurrency is used when multipartConcurrency isn't configured
+	DefaultMultipartConcurrency = 4
+	// maxMultipartConcurrency limits both multipartConcurrency and downloadConcurrency
+	maxMultipartConcurrency = 32
+)
+
+// DefaultMaxRetryAttempts is the number of attempts made on transient S3 errors when maxRetryAttempts isn't configured
+const DefaultMaxRetryAttempts = 3
+
+// DefaultOperationTimeout bounds each RPC's driver call when operationTimeoutSeconds isn't configured
+const DefaultOperationTimeout = 300 * time.Second
+
+// DefaultDialTimeout, DefaultTLSHandshakeTimeout, DefaultIdleConnTimeout, DefaultMaxIdleConnsPerHost and
+// DefaultMaxIdleConnections configure the S3 client's HTTP transport when the corresponding fields aren't configured.
+// The number of connections isn't limited unless maxConnections is configured.
+const (
+	DefaultDialTimeout         = 10 * time.Second
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+	DefaultIdleConnTimeout     = 30 * time.Second
+	DefaultMaxIdleConnsPerHost = 16
+	DefaultMaxIdleConnections  = 256
+)
+
+// maxKeyLength is the maximum length of an S3 object key in bytes
+const maxKeyLength = 1024
+
+// keyFormatPlaceholderRegex matches a {{name}} placeholder in keyFormat, capturing the name
+var keyFormatPlaceholderRegex = regexp.MustCompile(`{{\s*([^{}]*?)\s*}}`)
+
+// keyFormatPlaceholders maps the placeholders keyFormat supports, besides {{key}}, to the variables their values are read from
+var keyFormatPlaceholders = map[string]string{
+	"workflow.name": common.EnvVarWorkflowName,
+	"pod.name":      common.EnvVarPodName,
+}
+
+// profileNameRegex matches the names accepted for AWS shared config profiles
+var profileNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.+=,@-]+$`)
+
+var bucketNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]*[a-z0-9]$`)
+
+// defaultRoleSessionName is the STS session name used when assuming a role without an explicit roleSessionName
+const defaultRoleSessionName = "argo-artifact-plugin-s3"
+
+const (
+	// SSEAlgorithmS3 requests SSE-S3 server-side encryption with S3 managed keys
+	SSEAlgorithmS3 = "AES256"
+	// SSEAlgorithmKMS requests SSE-KMS server-side encryption with a KMS key
+	SSEAlgorithmKMS = "aws:kms"
+)
+
+const (
+	// ArchiveNone uploads each file in a directory as a separate object
+	ArchiveNone = "none"
+	// ArchiveTar uploads a directory as a single tar object
+	ArchiveTar = "tar"
+	// ArchiveTarGz uploads a directory as a single gzip compressed tar object
+	ArchiveTarGz = "tar.gz"
+)
+
+const (
+	// maxObjectTags, maxTagKeyLength and maxTagValueLength are the S3 object tagging limits
+	maxObjectTags     = 10
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+)
+
+// storageClasses are the S3 storage classes accepted by storageClass
+var storageClasses = []string{
+	"STANDARD",
+	"REDUCED_REDUNDANCY",
+	"STANDARD_IA",
+	"ONEZONE_IA",
+	"INTELLIGENT_TIERING",
+	"GLACIER",
+	"GLACIER_IR",
+	"DEEP_ARCHIVE",
+	"OUTPOSTS",
+	"EXPRESS_ONEZONE",
+	"SNOW",
+}
+
+// cannedACLs are the S3 canned ACLs accepted by acl
+var cannedACLs = []string{
+	"private",
+	"public-read",
+	"public-read-write",
+	"authenticated-read",
+	"aws-exec-read",
+	"bucket-owner-read",
+	"bucket-owner-full-control",
+}
+
+// ErrInvalidConfig is wrapped by errors caused by an invalid plugin configuration
+var ErrInvalidConfig = errors.New("invalid plugin configuration")
+
+// PluginConfig is the plugin configuration: an Argo S3 bucket plus plugin specific settings
+type PluginConfig struct {
+	wfv1.S3Bucket `json:",inline"`
+
+	// Anonymous accesses a public bucket without credentials, no credential secrets are read. The bucket is
+	// treated as read-only, so Save and Delete are rejected.
+	Anonymous bool `json:"anonymous,omitempty"`
+
+	// StreamChunkSizeBytes is the size of each chunk sent by OpenStream, defaults to 1MB
+	StreamChunkSizeBytes int `json:"streamChunkSizeBytes,omitempty"`
+
+	// StreamOffsetBytes and StreamLengthBytes make OpenStream return only that byte range of the object, from the
+	// offset to the end when the length is unset. The range must lie within the object. Load is unaffected.
+	StreamOffsetBytes int64 `json:"streamOffsetBytes,omitempty"`
+	StreamLengthBytes int64 `json:"streamLengthBytes,omitempty"`
+
+	// MaxInlineObjectBytes is the largest object ReadObject and WriteObject transfer in memory, defaults to 8MB
+	MaxInlineObjectBytes int64 `json:"maxInlineObjectBytes,omitempty"`
+
+	// SSEAlgorithm is the server-side encryption algorithm to request, either AES256 (SSE-S3) or aws:kms (SSE-KMS).
+	// Setting it enables encryption, the KMS key and context are taken from encryptionOptions.
+	SSEAlgorithm string `json:"sseAlgorithm,omitempty"`
+
+	// RoleExternalID is the external ID required by the role owner when assuming roleARN
+	RoleExternalID string `json:"roleExternalID,omitempty"`
+
+	// RoleSessionName is the STS session name used when assuming roleARN, defaults to argo-artifact-plugin-s3
+	RoleSessionName string `json:"roleSessionName,omitempty"`
+
+	// MaxRetryAttempts is the number of attempts made by Load, Save and Delete on transient S3 errors, defaults to 3
+	MaxRetryAttempts int `json:"maxRetryAttempts,omitempty"`
+
+	// RetryMode is the retry mode of the AWS SDK clients resolving credentials, standard or adaptive, which also rate
+	// limits requests client side while they are throttled. Defaults to the SDK's, which honours AWS_RETRY_MODE.
+	// minio, which sends the S3 requests, always retries in the standard way.
+	RetryMode string `json:"retryMode,omitempty"`
+
+	// RetryMaxAttempts is the number of attempts each S3 request, and each request resolving credentials, is
+	// retried up to, below the attempts maxRetryAttempts makes of a whole operation. Defaults to minio's and the SDK's.
+	RetryMaxAttempts int `json:"retryMaxAttempts,omitempty"`
+
+	// PathStyle forces path-style (true) or virtual-hosted-style (false) bucket addressing.
+	// Defaults to virtual-hosted-style for AWS endpoints and path-style for any other S3 compatible store.
+	PathStyle *bool `json:"pathStyle,omitempty"`
+
+	// RequesterPays charges the requester rather than the bucket owner for every request, which requester-pays
+	// buckets refuse otherwise
+	RequesterPays bool `json:"requesterPays,omitempty"`
+
+	// DryRun makes Delete log the keys it would remove and succeed without deleting anything
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// OperationTimeoutSeconds bounds how long each RPC waits for S3, defaults to 300
+	OperationTimeoutSeconds int `json:"operationTimeoutSeconds,omitempty"`
+
+	// DialTimeoutSeconds bounds how long connecting to the endpoint may take, defaults to 10
+	DialTimeoutSeconds int `json:"dialTimeoutSeconds,omitempty"`
+
+	// TLSHandshakeTimeoutSeconds bounds how long the TLS handshake with the endpoint may take, defaults to 10
+	TLSHandshakeTimeoutSeconds int `json:"tlsHandshakeTimeoutSeconds,omitempty"`
+
+	// IdleConnTimeoutSeconds is how long an unused connection to the endpoint is kept open for reuse, defaults to 30
+	IdleConnTimeoutSeconds int `json:"idleConnTimeoutSeconds,omitempty"`
+
+	// MaxIdleConnsPerHost is how many unused connections to the endpoint are kept open for reuse, defaults to 16
+	MaxIdleConnsPerHost int `json:"maxIdleConnsPerHost,omitempty"`
+
+	// MaxConnections limits how many connections, in use or idle, are open to the endpoint at once. Requests beyond
+	// it wait for a connection to free up. Unlimited by default.
+	MaxConnections int `json:"maxConnections,omitempty"`
+
+	// MaxIdleConnections is how many unused connections are kept open for reuse across all hosts, defaults to 256.
+	// It can't exceed maxConnections.
+	MaxIdleConnections int `json:"maxIdleConnections,omitempty"`
+
+	// ClientCertSecret and ClientKeySecret hold the PEM client certificate and key presented to the endpoint for mutual TLS.
+	// They must be set together.
+	ClientCertSecret *corev1.SecretKeySelector `json:"clientCertSecret,omitempty"`
+	ClientKeySecret  *corev1.SecretKeySelector `json:"clientKeySecret,omitempty"`
+
+	// ProgressIntervalSeconds is how often Save logs the upload progress of each file, progress isn't logged when zero
+	ProgressIntervalSeconds int `json:"progressIntervalSeconds,omitempty"`
+
+	// ListPattern filters the keys returned by ListObjects with path.Match semantics. It is matched against
+	// the key suffix after the artifact's key prefix, so * doesn't cross a /. Empty returns every key.
+	ListPattern string `json:"listPattern,omitempty"`
+
+	// ListRecursive lists every key under the artifact's key prefix when true, the default. When false only the keys
+	// directly under it are listed, and each sub-directory appears once as its common prefix ending in /, such as
+	// my-wf/data/nested/. listPattern matches a common prefix without its trailing /.
+	ListRecursive *bool `json:"listRecursive,omitempty"`
+
+	// Overwrite lets Load replace a file or directory already at the destination path when true, the default.
+	// When false Load fails with ErrDestinationExists instead, so a retried step can't replace what it loaded before.
+	Overwrite *bool `json:"overwrite,omitempty"`
+
+	// MultipartThresholdBytes is the file size from which Save uploads in parts rather than a single PUT, defaults to 64MB
+	MultipartThresholdBytes int64 `json:"multipartThresholdBytes,omitempty"`
+
+	// MultipartPartSizeBytes is the size of each part of a multipart upload, between 5MB and 5GB, defaults to 16MB.
+	// Each concurrent part is buffered in memory.
+	MultipartPartSizeBytes int64 `json:"multipartPartSizeBytes,omitempty"`
+
+	// MultipartConcurrency is how many parts of a multipart upload are sent in parallel, at most 32, defaults to 4
+	MultipartConcurrency int `json:"multipartConcurrency,omitempty"`
+
+	// DownloadConcurrency is how many byte ranges of an object Load downloads in parallel, at most 32. When above 1,
+	// objects of at least multipartThresholdBytes are downloaded as ranges of multipartPartSizeBytes. Smaller objects,
+	// and objects from stores which don't support range requests, are downloaded in a single stream, as is everything
+	// when it is unset.
+	DownloadConcurrency int `json:"downloadConcurrency,omitempty"`
+
+	// Archive controls how Save uploads a directory: none (one object per file, the default), tar or tar.gz
+	Archive string `json:"archive,omitempty"`
+
+	// ArchiveCompressionLevel is the gzip level (0-9) used with the tar.gz archive, defaults to gzip's default level
+	ArchiveCompressionLevel *int `json:"archiveCompressionLevel,omitempty"`
+
+	// TempDir is the absolute path of the directory Save buffers archives in, defaults to the system's temporary
+	// directory. It must be writable.
+	TempDir string `json:"tempDir,omitempty"`
+
+	// StorageClass is the S3 storage class Save writes objects with, such as STANDARD_IA. Unset uses the bucket default.
+	StorageClass string `json:"storageClass,omitempty"`
+
+	// ACL is the canned ACL Save and WriteObject apply to every object they upload, such as bucket-owner-full-control
+	// for the bucket's owner to be able to read objects written from another account. Unset leaves access to the
+	// bucket's policy.
+	ACL string `json:"acl,omitempty"`
+
+	// ObjectTags are the tags Save applies to every object it uploads, at most 10
+	ObjectTags map[string]string `json:"objectTags,omitempty"`
+
+	// ObjectLockMode is the object lock retention mode, GOVERNANCE or COMPLIANCE, Save and WriteObject apply to
+	// every object they upload. The bucket must have object lock enabled. It must be set with objectLockRetainUntil.
+	ObjectLockMode string `json:"objectLockMode,omitempty"`
+
+	// ObjectLockRetainUntil is how long uploaded objects are retained: a Go duration such as 8760h, counted from each
+	// upload, or an RFC3339 date
+	ObjectLockRetainUntil string `json:"objectLockRetainUntil,omitempty"`
+
+	// IfMatch makes Save and WriteObject only overwrite an object whose ETag matches, "*" requires the object to exist
+	IfMatch string `json:"ifMatch,omitempty"`
+
+	// IfNoneMatch makes Save and WriteObject fail rather than overwrite an object, "*" refuses to replace any object
+	IfNoneMatch string `json:"ifNoneMatch,omitempty"`
+
+	// ContentType is the Content-Type Save sets on every object it uploads.
+	// Unset detects it from each file's extension, or its content when the extension is unknown.
+	ContentType string `json:"contentType,omitempty"`
+
+	// CacheControl is the Cache-Control header Save and WriteObject set on every object they upload, such as max-age=3600
+	CacheControl string `json:"cacheControl,omitempty"`
+
+	// ContentDisposition is the Content-Disposition header Save and WriteObject set on every object they upload,
+	// such as attachment; filename="report.csv"
+	ContentDisposition string `json:"contentDisposition,omitempty"`
+
+	// UserAgentSuffix is appended to the argo-artifact-plugin-s3/<version> User-Agent sent with every S3 request,
+	// to tell apart the requests of different workflows or teams in CloudTrail and server access logs
+	UserAgentSuffix string `json:"userAgentSuffix,omitempty"`
+
+	// SecretNamespace is the namespace the referenced secrets are read from.
+	// Defaults to SECRET_NAMESPACE, then the namespace the plugin runs in.
+	SecretNamespace string `json:"secretNamespace,omitempty"`
+
+	// AccessKeyFile, SecretKeyFile and SessionTokenFile are absolute paths of files mounted into the plugin
+	// holding the credentials, as an alternative to the credential secrets. Trailing whitespace is ignored.
+	AccessKeyFile    string `json:"accessKeyFile,omitempty"`
+	SecretKeyFile    string `json:"secretKeyFile,omitempty"`
+	SessionTokenFile string `json:"sessionTokenFile,omitempty"`
+
+	// Profile is the AWS shared config and credentials file profile used with useSDKCreds, defaults to the SDK's default profile
+	Profile string `json:"profile,omitempty"`
+
+	// KeyFormat is expanded to give the object key, with {{key}} replaced by the artifact key and {{workflow.name}} and
+	// {{pod.name}} by the workflow and pod the plugin runs for. Unset uses the artifact key unchanged.
+	// Placeholders must expand the same wherever the artifact is later read, so {{pod.name}} only suits write-once keys.
+	KeyFormat string `json:"keyFormat,omitempty"`
+
+	// VerifyChecksum makes Load compare each downloaded file with the checksum S3 holds for the object, failing on a mismatch
+	VerifyChecksum bool `json:"verifyChecksum,omitempty"`
+
+	// UploadChecksum makes Save send a checksum of each file for S3 to validate, rejecting corrupted uploads
+	UploadChecksum bool `json:"uploadChecksum,omitempty"`
+
+	// SkipIfUnchanged makes Save skip uploading a file when the object at the key already has the file's checksum.
+	// Directories are always uploaded.
+	SkipIfUnchanged bool `json:"skipIfUnchanged,omitempty"`
+
+	// ChecksumAlgorithm is the checksum verifyChecksum compares and uploadChecksum sends, sha256 (the default) or crc32c.
+	// Objects without one are compared with their ETag when it is the object's MD5.
+	ChecksumAlgorithm string `json:"checksumAlgorithm,omitempty"`
+}
+
+// addressingStyle returns the bucket addressing style for the configuration, honouring pathStyle when it is set
+func addressingStyle(config *PluginConfig) AddressingStyle {
+	pathStyle := !isAWSEndpoint(config.Endpoint)
+	if config.PathStyle != nil {
+		pathStyle = *config.PathStyle
+	}
+	if pathStyle {
+		return PathStyle
+	}
+	return VirtualHostedStyle
+}
+
+// withAWSEnvDefaults returns a copy of config with an empty region and endpoint filled from the standard AWS
+// environment variables, as AWS tooling does. The endpoint URL's scheme is then applied by normalizeEndpoint.
+func withAWSEnvDefaults(config *PluginConfig) (*PluginConfig, error) {
+	filled := *config
+	if filled.Region == "" {
+		filled.Region = cmp.Or(os.Getenv(envVarRegion), os.Getenv(envVarDefaultRegion))
+	}
+	if rawURL := os.Getenv(envVarEndpointURLS3); filled.Endpoint == "" && rawURL != "" {
+		endpointURL, err := url.Parse(rawURL)
+		if err != nil || endpointURL.Host == "" {
+			return nil, fmt.Errorf("%w: %s %q must be a URL such as https://s3.example.com", ErrInvalidConfig, envVarEndpointURLS3, rawURL)
+		}
+		filled.Endpoint = rawURL
+	}
+	return &filled, nil
+}
+
+// normalizeEndpoint returns a copy of config with any http:// or https:// scheme stripped from the endpoint, which
+// minio expects as a bare host. The scheme sets insecure when it is unset, and must agree with it when it is set.
+func normalizeEndpoint(config *PluginConfig) (*PluginConfig, error) {
+	if !strings.Contains(config.Endpoint, "://") {
+		return config, nil
+	}
+	endpointURL, err := url.Parse(config.Endpoint)
+	if err != nil || endpointURL.Host == "" || strings.Trim(endpointURL.Path, "/") != "" || endpointURL.RawQuery != "" {
+		return nil, fmt.Errorf("%w: endpoint %q must be a host such as s3.example.com, or a URL without a path such as https://s3.example.com", ErrInvalidConfig, config.Endpoint)
+	}
+	var insecure bool
+	switch endpointURL.Scheme {
+	case "https":
+	case "http":
+		insecure = true
+	default:
+		return nil, fmt.Errorf("%w: endpoint %q must use http or https, got %s", ErrInvalidConfig, config.Endpoint, endpointURL.Scheme)
+	}
+	if config.Insecure != nil && *config.Insecure != insecure {
+		return nil, fmt.Errorf("%w: endpoint %q is %s but insecure is %t", ErrInvalidConfig, config.Endpoint, endpointURL.Scheme, *config.Insecure)
+	}
+	normalized := *config
+	normalized.Endpoint = endpointURL.Host
+	normalized.Insecure = &insecure
+	return &normalized, nil
+}
+
+// isAWSEndpoint reports whether the endpoint is AWS S3, an empty endpoint means the AWS default
+func isAWSEndpoint(endpoint string) bool {
+	return endpoint == "" || strings.HasSuffix(endpoint, ".amazonaws.com") || strings.HasSuffix(endpoint, ".amazonaws.com.cn")
+}
+
 // parsePluginConfiguration parses YAML configuration from Plugin.Configuration string
-func parsePluginConfiguration(ctx context.Context, configYAML string) (*wfv1.S3Bucket, error) {
-	var config wfv1.S3Bucket
+func parsePluginConfiguration(ctx context.Context, configYAML string) (*PluginConfig, error) {
+	var config PluginConfig
+
+	expandedYAML, err := expandConfig(configYAML)
+	if err != nil {
+		return nil, err
+	}
 
 	// Use Kubernetes SIGS YAML which is more compatible with Kubernetes API types
-	err := yaml.UnmarshalStrict([]byte(configYAML), &config)
+	err = yaml.UnmarshalStrict([]byte(expandedYAML), &config)
+	if err != nil && !configStrict() {
+		config = PluginConfig{}
+		if lenientErr := yaml.Unmarshal([]byte(expandedYAML), &config); lenientErr == nil {
+			logging.RequireLoggerFromContext(ctx).WithField("keys", unknownConfigKeys(expandedYAML)).
+				Warn(ctx, "Ignoring unrecognised plugin configuration fields")
+			err = nil
+		}
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse plugin configuration: %w", err)
+		return nil, fmt.Errorf("%w: failed to parse plugin configuration: %w", ErrInvalidConfig, err)
 	}
 
 	logging.RequireLoggerFromContext(ctx).WithFields(logging.Fields{
-		"input":  configYAML,
+		"input":  RedactConfiguration(configYAML),
 		"output": config,
 	}).Debug(ctx, "Parsed plugin configuration")
 
 	return &config, nil
 }
 
+// expandConfig replaces ${VAR} and $VAR in the configuration with the plugin's environment variables, $$ being a
+// literal $. Only variables exposed to configurations by configExpandPrefix or CONFIG_EXPAND_VARS may be referenced.
+// Undefined variables expand to nothing, or are an error when CONFIG_EXPAND_STRICT is true.
+func expandConfig(configYAML string) (string, error) {
+	var undefined, unexposed []string
+	expanded := os.Expand(configYAML, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		if !configExpandable(name) {
+			if !slices.Contains(unexposed, name) {
+				unexposed = append(unexposed, name)
+			}
+			return ""
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok && !slices.Contains(undefined, name) {
+			undefined = append(undefined, name)
+		}
+		return value
+	})
+	if len(unexposed) > 0 {
+		return "", fmt.Errorf("%w: plugin configuration references environment variables %s, only those named %s* or listed in %s may be expanded",
+			ErrInvalidConfig, strings.Join(unexposed, ", "), configExpandPrefix, envVarConfigExpandVars)
+	}
+	if len(undefined) > 0 {
+		if strict, _ := strconv.ParseBool(os.Getenv(envVarConfigExpandStrict)); strict {
+			return "", fmt.Errorf("%w: plugin configuration references undefined environment variables %s", ErrInvalidConfig, strings.Join(undefined, ", "))
+		}
+	}
+	return expanded, nil
+}
+
+// configExpandable reports whether configurations may expand the environment variable
+func configExpandable(name string) bool {
+	if strings.HasPrefix(name, configExpandPrefix) {
+		return true
+	}
+	for _, allowed := range strings.Split(os.Getenv(envVarConfigExpandVars), ",") {
+		if strings.TrimSpace(allowed) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeConfiguration returns configYAML base64-decoded when it is base64-encoded YAML, as some controllers hand
+// it over, and otherwise unchanged. It is only decoded when it isn't a YAML mapping as it is but is once decoded.
+func decodeConfiguration(ctx context.Context, configYAML string) string {
+	logger := logging.RequireLoggerFromContext(ctx)
+	if isYAMLMapping(configYAML) {
+		logger.Debug(ctx, "Plugin configuration is YAML")
+		return configYAML
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(configYAML), ""))
+	if err != nil || !utf8.Valid(decoded) || !isYAMLMapping(string(decoded)) {
+		logger.Debug(ctx, "Plugin configuration is neither a YAML mapping nor base64-encoded YAML, parsing it as it is")
+		return configYAML
+	}
+	logger.Info(ctx, "Plugin configuration is base64-encoded YAML, decoding it")
+	return string(decoded)
+}
+
+// isYAMLMapping reports whether s parses as a non-empty YAML mapping
+func isYAMLMapping(s string) bool {
+	var mapping map[string]any
+	return yaml.Unmarshal([]byte(s), &mapping) == nil && len(mapping) > 0
+}
+
+// applyConfigDefaults merges configYAML onto the defaults file named by PLUGIN_DEFAULTS_FILE, when one is set,
+// with the fields configYAML sets taking precedence
+func applyConfigDefaults(configYAML string) (string, error) {
+	path := os.Getenv(envVarPluginDefaultsFile)
+	if path == "" {
+		return configYAML, nil
+	}
+	defaultsYAML, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read plugin defaults: %w", err)
+	}
+	var defaults, config map[string]any
+	if err := yaml.Unmarshal(defaultsYAML, &defaults); err != nil {
+		return "", fmt.Errorf("failed to parse plugin defaults %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal([]byte(configYAML), &config); err != nil {
+		return "", fmt.Errorf("%w: failed to parse plugin configuration: %w", ErrInvalidConfig, err)
+	}
+	merged, err := yaml.Marshal(mergeConfig(defaults, config))
+	if err != nil {
+		return "", fmt.Errorf("failed to merge plugin defaults: %w", err)
+	}
+	return string(merged), nil
+}
+
+// mergeConfig returns defaults with config's fields applied over them, merging nested objects field by field
+func mergeConfig(defaults, config map[string]any) map[string]any {
+	merged := make(map[string]any, len(defaults)+len(config))
+	maps.Copy(merged, defaults)
+	for key, value := range config {
+		nested, isMap := value.(map[string]any)
+		defaultNested, defaultIsMap := merged[key].(map[string]any)
+		if isMap && defaultIsMap {
+			merged[key] = mergeConfig(defaultNested, nested)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// configStrict reports whether unknown configuration fields are rejected, which they are unless CONFIG_STRICT is false
+func configStrict() bool {
+	strict, err := strconv.ParseBool(os.Getenv(envVarConfigStrict))
+	return err != nil || strict
+}
+
+// unknownConfigKeys returns the top level keys of configYAML which strict parsing rejects, either because
+// they aren't fields of PluginConfig or because they contain fields which aren't
+func unknownConfigKeys(configYAML string) []string {
+	var raw map[string]any
+	if err := yaml.Unmarshal([]byte(configYAML), &raw); err != nil {
+		return nil
+	}
+	var keys []string
+	for key, value := range raw {
+		field, err := yaml.Marshal(map[string]any{key: value})
+		if err != nil {
+			continue
+		}
+		if yaml.UnmarshalStrict(field, &PluginConfig{}) != nil {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// validatePluginConfig checks the plugin specific settings are within their allowed ranges
+func validatePluginConfig(config *PluginConfig) error {
+	if config.StreamChunkSizeBytes != 0 && (config.StreamChunkSizeBytes < minStreamChunkSize || config.StreamChunkSizeBytes > maxStreamChunkSize) {
+		return fmt.Errorf("%w: streamChunkSizeBytes must be between %d and %d, got %d", ErrInvalidConfig, minStreamChunkSize, maxStreamChunkSize, config.StreamChunkSizeBytes)
+	}
+	if config.StreamOffsetBytes < 0 || config.StreamLengthBytes < 0 {
+		return fmt.Errorf("%w: streamOffsetBytes and streamLengthBytes must not be negative, got %d and %d", ErrInvalidConfig, config.StreamOffsetBytes, config.StreamLengthBytes)
+	}
+	if config.MaxInlineObjectBytes < 0 {
+		return fmt.Errorf("%w: maxInlineObjectBytes must not be negative, got %d", ErrInvalidConfig, config.MaxInlineObjectBytes)
+	}
+	if (config.ClientCertSecret == nil) != (config.ClientKeySecret == nil) {
+		return fmt.Errorf("%w: clientCertSecret and clientKeySecret must be set together", ErrInvalidConfig)
+	}
+	if _, err := path.Match(config.ListPattern, ""); err != nil {
+		return fmt.Errorf("%w: listPattern %q: %v", ErrInvalidConfig, config.ListPattern, err)
+	}
+	if config.StorageClass != "" && !slices.Contains(storageClasses, config.StorageClass) {
+		return fmt.Errorf("%w: storageClass must be one of %s, got %q", ErrInvalidConfig, strings.Join(storageClasses, ", "), config.StorageClass)
+	}
+	if config.ACL != "" && !slices.Contains(cannedACLs, config.ACL) {
+		return fmt.Errorf("%w: acl must be one of %s, got %q", ErrInvalidConfig, strings.Join(cannedACLs, ", "), config.ACL)
+	}
+	if err := validateAnonymous(config); err != nil {
+		return err
+	}
+	if err := validateCredentialFiles(config); err != nil {
+		return err
+	}
+	if config.Profile != "" {
+		if !config.UseSDKCreds {
+			return fmt.Errorf("%w: profile requires useSDKCreds", ErrInvalidConfig)
+		}
+		if !profileNameRegex.MatchString(config.Profile) {
+			return fmt.Errorf("%w: profile %q must only contain letters, digits and _.+=,@-", ErrInvalidConfig, config.Profile)
+		}
+	}
+	if config.SecretNamespace != "" {
+		if errs := validation.IsDNS1123Label(config.SecretNamespace); len(errs) > 0 {
+			return fmt.Errorf("%w: secretNamespace %q: %s", ErrInvalidConfig, config.SecretNamespace, strings.Join(errs, ", "))
+		}
+	}
+	if config.ContentType != "" {
+		if _, _, err := mime.ParseMediaType(config.ContentType); err != nil {
+			return fmt.Errorf("%w: contentType %q: %v", ErrInvalidConfig, config.ContentType, err)
+		}
+	}
+	if config.ContentDisposition != "" {
+		if _, _, err := mime.ParseMediaType(config.ContentDisposition); err != nil {
+			return fmt.Errorf("%w: contentDisposition %q: %v", ErrInvalidConfig, config.ContentDisposition, err)
+		}
+	}
+	if strings.ContainsFunc(config.UserAgentSuffix, unicode.IsControl) {
+		return fmt.Errorf("%w: userAgentSuffix %q must not contain control characters", ErrInvalidConfig, config.UserAgentSuffix)
+	}
+	switch config.ChecksumAlgorithm {
+	case "", ChecksumSHA256, ChecksumCRC32C:
+	default:
+		return fmt.Errorf("%w: checksumAlgorithm must be %s or %s, got %q", ErrInvalidConfig, ChecksumSHA256, ChecksumCRC32C, config.ChecksumAlgorithm)
+	}
+	if err := validateKeyFormat(config.KeyFormat); err != nil {
+		return err
+	}
+	if err := validateObjectTags(config.ObjectTags); err != nil {
+		return err
+	}
+	if err := validateArchive(config); err != nil {
+		return err
+	}
+	if err := validateTempDir(config.TempDir); err != nil {
+		return err
+	}
+	if err := validateObjectLock(config); err != nil {
+		return err
+	}
+	if err := validateMultipart(config); err != nil {
+		return err
+	}
+	if config.ProgressIntervalSeconds < 0 {
+		return fmt.Errorf("%w: progressIntervalSeconds must not be negative, got %d", ErrInvalidConfig, config.ProgressIntervalSeconds)
+	}
+	if config.OperationTimeoutSeconds < 0 {
+		return fmt.Errorf("%w: operationTimeoutSeconds must not be negative, got %d", ErrInvalidConfig, config.OperationTimeoutSeconds)
+	}
+	if config.MaxRetryAttempts < 0 {
+		return fmt.Errorf("%w: maxRetryAttempts must not be negative, got %d", ErrInvalidConfig, config.MaxRetryAttempts)
+	}
+	if err := validateRetry(config); err != nil {
+		return err
+	}
+	if err := validateTransport(config); err != nil {
+		return err
+	}
+	return validateEncryption(config)
+}
+
+// validateAnonymous checks anonymous access isn't combined with any way of providing credentials
+func validateAnonymous(config *PluginConfig) error {
+	if !config.Anonymous {
+		return nil
+	}
+	var conflicts []string
+	if config.UseSDKCreds {
+		conflicts = append(conflicts, "useSDKCreds")
+	}
+	if config.RoleARN != "" {
+		conflicts = append(conflicts, "roleARN")
+	}
+	if config.AccessKeySecret != nil {
+		conflicts = append(conflicts, "accessKeySecret")
+	}
+	if config.SecretKeySecret != nil {
+		conflicts = append(conflicts, "secretKeySecret")
+	}
+	if config.SessionTokenSecret != nil {
+		conflicts = append(conflicts, "sessionTokenSecret")
+	}
+	if config.AccessKeyFile != "" || config.SecretKeyFile != "" || config.SessionTokenFile != "" {
+		conflicts = append(conflicts, "credential files")
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%w: anonymous can't be combined with %s", ErrInvalidConfig, strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// validateCredentialFiles checks the credential files are absolute paths, and each replaces its secret rather
+// than being combined with it
+func validateCredentialFiles(config *PluginConfig) error {
+	if (config.AccessKeyFile == "") != (config.SecretKeyFile == "") {
+		return fmt.Errorf("%w: accessKeyFile and secretKeyFile must be set together", ErrInvalidConfig)
+	}
+	for _, file := range []struct {
+		field, path string
+		secret      *corev1.SecretKeySelector
+	}{
+		{"accessKeyFile", config.AccessKeyFile, config.AccessKeySecret},
+		{"secretKeyFile", config.SecretKeyFile, config.SecretKeySecret},
+		{"sessionTokenFile", config.SessionTokenFile, config.SessionTokenSecret},
+	} {
+		if file.path == "" {
+			continue
+		}
+		if !filepath.IsAbs(file.path) {
+			return fmt.Errorf("%w: %s must be an absolute path, got %q", ErrInvalidConfig, file.field, file.path)
+		}
+		if file.secret != nil {
+			return fmt.Errorf("%w: %s can't be combined with %sSecret", ErrInvalidConfig, file.field, strings.TrimSuffix(file.field, "File"))
+		}
+	}
+	return nil
+}
+
+// validateKeyFormat checks keyFormat only uses supported placeholders
+func validateKeyFormat(keyFormat string) error {
+	for _, match := range keyFormatPlaceholderRegex.FindAllStringSubmatch(keyFormat, -1) {
+		if _, ok := keyFormatPlaceholders[match[1]]; !ok && match[1] != "key" {
+			return fmt.Errorf("%w: keyFormat placeholder %s is not supported", ErrInvalidConfig, match[0])
+		}
+	}
+	return nil
+}
+
+// instanceKeyPrefix returns the KEY_PREFIX the plugin instance is scoped to, normalized, or an empty string when it
+// isn't scoped
+func instanceKeyPrefix() (string, error) {
+	keyPrefix := os.Getenv(envVarKeyPrefix)
+	if hasParentSegment(keyPrefix) {
+		return "", fmt.Errorf("%w: %s %q must not contain ..", ErrInvalidConfig, envVarKeyPrefix, keyPrefix)
+	}
+	return normalizeKeyPrefix(keyPrefix), nil
+}
+
+// ValidateKeyPrefix checks KEY_PREFIX, when it is set, can't be left with .., so a broken prefix is reported at
+// startup rather than by every request
+func ValidateKeyPrefix() error {
+	_, err := instanceKeyPrefix()
+	return err
+}
+
+// applyKeyPrefix returns key under the normalized keyPrefix, rejecting a key which could leave it with ..
+func applyKeyPrefix(keyPrefix, key string) (string, error) {
+	if keyPrefix == "" {
+		return key, nil
+	}
+	if hasParentSegment(key) {
+		return "", fmt.Errorf("%w: key %q must not contain .. as keys are scoped to %s %q", ErrInvalidConfig, key, envVarKeyPrefix, keyPrefix)
+	}
+	return keyPrefix + key, nil
+}
+
+// normalizeKeyPrefix returns keyPrefix without leading or repeated slashes and ending in a single slash, so it only
+// matches whole path segments. An unset prefix stays empty.
+func normalizeKeyPrefix(keyPrefix string) string {
+	for strings.Contains(keyPrefix, "//") {
+		keyPrefix = strings.ReplaceAll(keyPrefix, "//", "/")
+	}
+	keyPrefix = strings.Trim(keyPrefix, "/")
+	if keyPrefix == "" {
+		return ""
+	}
+	return keyPrefix + "/"
+}
+
+// hasParentSegment reports whether any / separated segment of key is ..
+func hasParentSegment(key string) bool {
+	return slices.Contains(strings.Split(key, "/"), "..")
+}
+
+// expandKeyFormat returns keyFormat with its placeholders replaced by the artifact key and the values of their variables
+func expandKeyFormat(keyFormat, key string) (string, error) {
+	var err error
+	expanded := keyFormatPlaceholderRegex.ReplaceAllStringFunc(keyFormat, func(placeholder string) string {
+		name := keyFormatPlaceholderRegex.FindStringSubmatch(placeholder)[1]
+		if name == "key" {
+			return key
+		}
+		value := os.Getenv(keyFormatPlaceholders[name])
+		if value == "" && err == nil {
+			err = fmt.Errorf("keyFormat placeholder %s can't be expanded, %s is not set", placeholder, keyFormatPlaceholders[name])
+		}
+		return value
+	})
+	return expanded, err
+}
+
+// validateObjectTags checks the tags are within the S3 object tagging limits and only use the allowed characters
+func validateObjectTags(objectTags map[string]string) error {
+	if len(objectTags) > maxObjectTags {
+		return fmt.Errorf("%w: objectTags must have at most %d tags, got %d", ErrInvalidConfig, maxObjectTags, len(objectTags))
+	}
+	for key, value := range objectTags {
+		if key == "" || utf8.RuneCountInString(key) > maxTagKeyLength {
+			return fmt.Errorf("%w: objectTags key %q must be between 1 and %d characters", ErrInvalidConfig, key, maxTagKeyLength)
+		}
+		if utf8.RuneCountInString(value) > maxTagValueLength {
+			return fmt.Errorf("%w: objectTags value for %q must be at most %d characters", ErrInvalidConfig, key, maxTagValueLength)
+		}
+	}
+	// minio silently drops tags it can't encode, so reject them here
+	if _, err := tags.NewTags(objectTags, true); err != nil {
+		return fmt.Errorf("%w: objectTags: %v", ErrInvalidConfig, err)
+	}
+	return nil
+}
+
+// validateArchive checks the archive format is known and the compression level is only set, within gzip's range, for tar.gz
+func validateArchive(config *PluginConfig) error {
+	switch config.Archive {
+	case "", ArchiveNone, ArchiveTar, ArchiveTarGz:
+	default:
+		return fmt.Errorf("%w: archive must be one of %s, %s or %s, got %q", ErrInvalidConfig, ArchiveNone, ArchiveTar, ArchiveTarGz, config.Archive)
+	}
+	if config.ArchiveCompressionLevel == nil {
+		return nil
+	}
+	if config.Archive != ArchiveTarGz {
+		return fmt.Errorf("%w: archiveCompressionLevel requires archive %s", ErrInvalidConfig, ArchiveTarGz)
+	}
+	if level := *config.ArchiveCompressionLevel; level < gzip.NoCompression || level > gzip.BestCompression {
+		return fmt.Errorf("%w: archiveCompressionLevel must be between %d and %d, got %d", ErrInvalidConfig, gzip.NoCompression, gzip.BestCompression, level)
+	}
+	return nil
+}
+
+// validateTempDir checks tempDir, when it is set, is an absolute path to a directory temporary files can be created in
+func validateTempDir(tempDir string) error {
+	if tempDir == "" {
+		return nil
+	}
+	if !filepath.IsAbs(tempDir) {
+		return fmt.Errorf("%w: tempDir must be an absolute path, got %q", ErrInvalidConfig, tempDir)
+	}
+	probe, err := os.CreateTemp(tempDir, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("%w: tempDir %s is not writable: %v", ErrInvalidConfig, tempDir, err)
+	}
+	_ = probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// ValidateDefaults checks the defaults file named by PLUGIN_DEFAULTS_FILE, when one is set, parses and has a
+// writable tempDir, so a broken file is reported at startup rather than by every request
+func ValidateDefaults(ctx context.Context) error {
+	path := os.Getenv(envVarPluginDefaultsFile)
+	if path == "" {
+		return nil
+	}
+	defaultsYAML, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read plugin defaults: %w", err)
+	}
+	config, err := parsePluginConfiguration(ctx, string(defaultsYAML))
+	if err != nil {
+		return fmt.Errorf("plugin defaults %s: %w", path, err)
+	}
+	return validateTempDir(config.TempDir)
+}
+
+func validateObjectLock(config *PluginConfig) error {
+	if config.ObjectLockMode == "" && config.ObjectLockRetainUntil == "" {
+		return nil
+	}
+	if !minio.RetentionMode(config.ObjectLockMode).IsValid() {
+		return fmt.Errorf("%w: objectLockMode must be %s or %s, got %q", ErrInvalidConfig, minio.Governance, minio.Compliance, config.ObjectLockMode)
+	}
+	if config.ObjectLockRetainUntil == "" {
+		return fmt.Errorf("%w: objectLockRetainUntil is required with objectLockMode", ErrInvalidConfig)
+	}
+	retention, retainUntil, err := parseRetainUntil(config.ObjectLockRetainUntil)
+	switch {
+	case err != nil:
+		return err
+	case retainUntil.IsZero() && retention <= 0:
+		return fmt.Errorf("%w: objectLockRetainUntil must be a positive duration, got %s", ErrInvalidConfig, config.ObjectLockRetainUntil)
+	case !retainUntil.IsZero() && !retainUntil.After(time.Now()):
+		return fmt.Errorf("%w: objectLockRetainUntil must be in the future, got %s", ErrInvalidConfig, config.ObjectLockRetainUntil)
+	}
+	return nil
+}
+
+// parseRetainUntil parses objectLockRetainUntil as either a retention period or a date, returning whichever it is
+func parseRetainUntil(value string) (time.Duration, time.Time, error) {
+	if retention, err := time.ParseDuration(value); err == nil {
+		return retention, time.Time{}, nil
+	}
+	retainUntil, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("%w: objectLockRetainUntil must be a duration such as 8760h or an RFC3339 date, got %q", ErrInvalidConfig, value)
+	}
+	return 0, retainUntil, nil
+}
+
+// validateRetry checks the retry mode is one the AWS SDK knows and the request attempts aren't negative
+func validateRetry(config *PluginConfig) error {
+	if config.RetryMode != "" {
+		if _, err := aws.ParseRetryMode(config.RetryMode); err != nil {
+			return fmt.Errorf("%w: retryMode must be %s or %s, got %q", ErrInvalidConfig, aws.RetryModeStandard, aws.RetryModeAdaptive, config.RetryMode)
+		}
+	}
+	if config.RetryMaxAttempts < 0 {
+		return fmt.Errorf("%w: retryMaxAttempts must be at least 1, got %d", ErrInvalidConfig, config.RetryMaxAttempts)
+	}
+	return nil
+}
+
+func validateTransport(config *PluginConfig) error {
+	for field, value := range map[string]int{
+		"dialTimeoutSeconds":         config.DialTimeoutSeconds,
+		"tlsHandshakeTimeoutSeconds": config.TLSHandshakeTimeoutSeconds,
+		"idleConnTimeoutSeconds":     config.IdleConnTimeoutSeconds,
+		"maxIdleConnsPerHost":        config.MaxIdleConnsPerHost,
+	} {
+		if value < 0 {
+			return fmt.Errorf("%w: %s must not be negative, got %d", ErrInvalidConfig, field, value)
+		}
+	}
+	for field, value := range map[string]int{
+		"maxConnections":     config.MaxConnections,
+		"maxIdleConnections": config.MaxIdleConnections,
+	} {
+		if value < 0 {
+			return fmt.Errorf("%w: %s must be positive, got %d", ErrInvalidConfig, field, value)
+		}
+	}
+	if config.MaxConnections > 0 && config.MaxIdleConnections > config.MaxConnections {
+		return fmt.Errorf("%w: maxIdleConnections (%d) must not exceed maxConnections (%d)", ErrInvalidConfig, config.MaxIdleConnections, config.MaxConnections)
+	}
+	return nil
+}
+
+// validateMultipart checks the part size is within the S3 limits, the threshold is no smaller than a part,
+// nor larger than a single PUT can upload, and the concurrency is within its limit
+func validateMultipart(config *PluginConfig) error {
+	partSize := config.MultipartPartSizeBytes
+	if partSize == 0 {
+		partSize = DefaultMultipartPartSize
+	} else if partSize < minMultipartPartSize || partSize > maxMultipartPartSize {
+		return fmt.Errorf("%w: multipartPartSizeBytes must be between %d and %d, got %d", ErrInvalidConfig, minMultipartPartSize, maxMultipartPartSize, partSize)
+	}
+	if threshold := config.MultipartThresholdBytes; threshold != 0 && (threshold < partSize || threshold > maxMultipartPartSize) {
+		return fmt.Errorf("%w: multipartThresholdBytes must be between the part size %d and %d, got %d", ErrInvalidConfig, partSize, maxMultipartPartSize, threshold)
+	}
+	if config.MultipartConcurrency < 0 || config.MultipartConcurrency > maxMultipartConcurrency {
+		return fmt.Errorf("%w: multipartConcurrency must be between 1 and %d, got %d", ErrInvalidConfig, maxMultipartConcurrency, config.MultipartConcurrency)
+	}
+	if config.DownloadConcurrency < 0 || config.DownloadConcurrency > maxMultipartConcurrency {
+		return fmt.Errorf("%w: downloadConcurrency must be between 1 and %d, got %d", ErrInvalidConfig, maxMultipartConcurrency, config.DownloadConcurrency)
+	}
+	return nil
+}
+
+// validateEncryption checks the requested server-side encryption algorithm is consistent with the encryption options
+func validateEncryption(config *PluginConfig) error {
+	var kmsKeyID string
+	if config.EncryptionOptions != nil {
+		kmsKeyID = config.EncryptionOptions.KmsKeyId
+	}
+	switch config.SSEAlgorithm {
+	case "":
+	case SSEAlgorithmS3:
+		if kmsKeyID != "" {
+			return fmt.Errorf("%w: sseAlgorithm %s cannot be used with encryptionOptions.kmsKeyId", ErrInvalidConfig, SSEAlgorithmS3)
+		}
+	case SSEAlgorithmKMS:
+		if kmsKeyID == "" {
+			return fmt.Errorf("%w: sseAlgorithm %s requires encryptionOptions.kmsKeyId", ErrInvalidConfig, SSEAlgorithmKMS)
+		}
+	default:
+		return fmt.Errorf("%w: sseAlgorithm must be %s or %s, got %q", ErrInvalidConfig, SSEAlgorithmS3, SSEAlgorithmKMS, config.SSEAlgorithm)
+	}
+	return nil
+}
+
+// validateS3Config checks the bucket name against the S3 naming rules and the key against the S3 key limits,
+// returning the key with any accidental repeated slashes collapsed
+func validateS3Config(bucket, key string) (string, error) {
+	if err := validateBucketName(bucket); err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(key, "/") {
+		return "", fmt.Errorf("%w: key %q must not start with a slash", ErrInvalidConfig, key)
+	}
+	for strings.Contains(key, "//") {
+		key = strings.ReplaceAll(key, "//", "/")
+	}
+	if len(key) > maxKeyLength {
+		return "", fmt.Errorf("%w: key must be at most %d bytes, got %d", ErrInvalidConfig, maxKeyLength, len(key))
+	}
+	return key, nil
+}
+
+// validateBucketName checks a bucket name against the S3 general purpose bucket naming rules
+func validateBucketName(bucket string) error {
+	switch {
+	case bucket == "":
+		return fmt.Errorf("%w: bucket is required", ErrInvalidConfig)
+	case len(bucket) < 3 || len(bucket) > 63:
+		return fmt.Errorf("%w: bucket %q must be between 3 and 63 characters long", ErrInvalidConfig, bucket)
+	case !bucketNameRegex.MatchString(bucket):
+		return fmt.Errorf("%w: bucket %q must consist of lowercase letters, numbers, dots and hyphens, and begin and end with a letter or number", ErrInvalidConfig, bucket)
+	case strings.Contains(bucket, ".."):
+		return fmt.Errorf("%w: bucket %q must not contain two adjacent periods", ErrInvalidConfig, bucket)
+	case net.ParseIP(bucket) != nil:
+		return fmt.Errorf("%w: bucket %q must not be formatted as an IP address", ErrInvalidConfig, bucket)
+	}
+	return nil
+}
+
 func DriverAndArtifactFromConfig(ctx context.Context, configYaml string, key string) (*ArtifactDriver, *wfv1.Artifact, error) {
+	configYaml, err := applyConfigDefaults(decodeConfiguration(ctx, configYaml))
+	if err != nil {
+		return nil, nil, err
+	}
 	pluginConfig, err := parsePluginConfiguration(ctx, configYaml)
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := validatePluginConfig(pluginConfig); err != nil {
+		return nil, nil, err
+	}
+	if pluginConfig.KeyFormat != "" {
+		key, err = expandKeyFormat(pluginConfig.KeyFormat, key)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	keyPrefix, err := instanceKeyPrefix()
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err = applyKeyPrefix(keyPrefix, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err = validateS3Config(pluginConfig.Bucket, key)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	artifact := createArgoArtifactFromConfig(pluginConfig, key)
 	driver, err := getArtifactDriver(ctx, pluginConfig)
@@ -43,46 +1094,177 @@ func DriverAndArtifactFromConfig(ctx context.Context, configYaml string, key str
 	return driver, artifact, err
 }
 
-func createArgoArtifactFromConfig(pluginConfig *wfv1.S3Bucket, key string) *wfv1.Artifact {
+func createArgoArtifactFromConfig(pluginConfig *PluginConfig, key string) *wfv1.Artifact {
 	return &wfv1.Artifact{
 		ArtifactLocation: wfv1.ArtifactLocation{
 			S3: &wfv1.S3Artifact{
-				S3Bucket: *pluginConfig,
+				S3Bucket: pluginConfig.S3Bucket,
 				Key:      key,
 			},
 		},
 	}
 }
 
-func getArtifactDriver(ctx context.Context, pluginConfig *wfv1.S3Bucket) (*ArtifactDriver, error) {
+func getArtifactDriver(ctx context.Context, pluginConfig *PluginConfig) (*ArtifactDriver, error) {
+	var err error
+	if pluginConfig.UseSDKCreds {
+		if pluginConfig, err = withAWSEnvDefaults(pluginConfig); err != nil {
+			return nil, err
+		}
+	}
+	if pluginConfig, err = normalizeEndpoint(pluginConfig); err != nil {
+		return nil, err
+	}
+
 	// Create base ArtifactDriver from plugin config
 	driver := &ArtifactDriver{
-		Endpoint:    pluginConfig.Endpoint,
-		Region:      pluginConfig.Region,
-		Secure:      pluginConfig.Insecure == nil || !*pluginConfig.Insecure, // Insecure is inverted to Secure
-		RoleARN:     pluginConfig.RoleARN,
-		UseSDKCreds: pluginConfig.UseSDKCreds,
+		Endpoint:            pluginConfig.Endpoint,
+		Region:              pluginConfig.Region,
+		Secure:              pluginConfig.Insecure == nil || !*pluginConfig.Insecure, // Insecure is inverted to Secure
+		RoleARN:             pluginConfig.RoleARN,
+		UseSDKCreds:         pluginConfig.UseSDKCreds,
+		Anonymous:           pluginConfig.Anonymous,
+		Profile:             pluginConfig.Profile,
+		StreamChunkSize:     pluginConfig.StreamChunkSizeBytes,
+		StreamOffset:        pluginConfig.StreamOffsetBytes,
+		StreamLength:        pluginConfig.StreamLengthBytes,
+		MaxInlineObjectSize: pluginConfig.MaxInlineObjectBytes,
+		RoleExternalID:      pluginConfig.RoleExternalID,
+		RoleSessionName:     pluginConfig.RoleSessionName,
+		MaxRetryAttempts:    pluginConfig.MaxRetryAttempts,
+		RetryMode:           pluginConfig.RetryMode,
+		RetryMaxAttempts:    pluginConfig.RetryMaxAttempts,
+		RequesterPays:       pluginConfig.RequesterPays,
+		DryRun:              pluginConfig.DryRun,
+		OperationTimeout:    time.Duration(pluginConfig.OperationTimeoutSeconds) * time.Second,
+		ProgressInterval:    time.Duration(pluginConfig.ProgressIntervalSeconds) * time.Second,
+		ListPattern:         pluginConfig.ListPattern,
+		ListNonRecursive:    pluginConfig.ListRecursive != nil && !*pluginConfig.ListRecursive,
+		NoOverwrite:         pluginConfig.Overwrite != nil && !*pluginConfig.Overwrite,
+		Archive:             pluginConfig.Archive,
+		TempDir:             cmp.Or(pluginConfig.TempDir, os.TempDir()),
+		StorageClass:        pluginConfig.StorageClass,
+		ACL:                 pluginConfig.ACL,
+		ObjectTags:          pluginConfig.ObjectTags,
+		ObjectLockMode:      pluginConfig.ObjectLockMode,
+		IfMatch:             pluginConfig.IfMatch,
+		IfNoneMatch:         pluginConfig.IfNoneMatch,
+		ContentType:         pluginConfig.ContentType,
+		CacheControl:        pluginConfig.CacheControl,
+		ContentDisposition:  pluginConfig.ContentDisposition,
+		UserAgentSuffix:     pluginConfig.UserAgentSuffix,
+		VerifyChecksum:      pluginConfig.VerifyChecksum,
+		UploadChecksum:      pluginConfig.UploadChecksum,
+		SkipIfUnchanged:     pluginConfig.SkipIfUnchanged,
+		DownloadConcurrency: pluginConfig.DownloadConcurrency,
+	}
+	driver.ChecksumAlgorithm = ChecksumSHA256
+	if pluginConfig.ChecksumAlgorithm != "" {
+		driver.ChecksumAlgorithm = pluginConfig.ChecksumAlgorithm
+	}
+	driver.CompressionLevel = gzip.DefaultCompression
+	if pluginConfig.ArchiveCompressionLevel != nil {
+		driver.CompressionLevel = *pluginConfig.ArchiveCompressionLevel
+	}
+	driver.MultipartThreshold = cmp.Or(pluginConfig.MultipartThresholdBytes, DefaultMultipartThreshold)
+	driver.MultipartPartSize = cmp.Or(pluginConfig.MultipartPartSizeBytes, DefaultMultipartPartSize)
+	driver.MultipartConcurrency = cmp.Or(pluginConfig.MultipartConcurrency, DefaultMultipartConcurrency)
+	if driver.StreamChunkSize == 0 {
+		driver.StreamChunkSize = DefaultStreamChunkSize
+	}
+	if driver.OperationTimeout == 0 {
+		driver.OperationTimeout = DefaultOperationTimeout
+	}
+	if driver.MaxRetryAttempts == 0 {
+		driver.MaxRetryAttempts = DefaultMaxRetryAttempts
+	}
+	if pluginConfig.ObjectLockRetainUntil != "" {
+		if driver.ObjectLockRetention, driver.ObjectLockRetainUntil, err = parseRetainUntil(pluginConfig.ObjectLockRetainUntil); err != nil {
+			return nil, err
+		}
+	}
+	driver.DialTimeout = cmp.Or(time.Duration(pluginConfig.DialTimeoutSeconds)*time.Second, DefaultDialTimeout)
+	driver.TLSHandshakeTimeout = cmp.Or(time.Duration(pluginConfig.TLSHandshakeTimeoutSeconds)*time.Second, DefaultTLSHandshakeTimeout)
+	driver.IdleConnTimeout = cmp.Or(time.Duration(pluginConfig.IdleConnTimeoutSeconds)*time.Second, DefaultIdleConnTimeout)
+	driver.MaxIdleConnsPerHost = cmp.Or(pluginConfig.MaxIdleConnsPerHost, DefaultMaxIdleConnsPerHost)
+	driver.MaxConnections = pluginConfig.MaxConnections
+	driver.MaxIdleConnections = cmp.Or(pluginConfig.MaxIdleConnections, DefaultMaxIdleConnections)
+	driver.AddressingStyle = addressingStyle(pluginConfig)
+	if driver.KeyPrefix, err = instanceKeyPrefix(); err != nil {
+		return nil, err
+	}
+	// Without a region the SDK may guess wrong for AWS, so ask S3 where the bucket is
+	if driver.Region == "" && isAWSEndpoint(pluginConfig.Endpoint) && pluginConfig.Bucket != "" {
+		driver.Region = detectBucketRegion(ctx, bucketRegionURL(pluginConfig.Endpoint, driver.Secure, pluginConfig.Bucket), driver.OperationTimeout)
+	}
+	if driver.RoleSessionName == "" {
+		driver.RoleSessionName = defaultRoleSessionName
+	}
+	if driver.RoleExternalID != "" && driver.RoleARN == "" {
+		logging.RequireLoggerFromContext(ctx).Warn(ctx, "roleExternalID is only used when assuming a role, ignoring it as roleARN is not set")
+	}
+	if pluginConfig.EncryptionOptions != nil {
+		driver.EnableEncryption = pluginConfig.EncryptionOptions.EnableEncryption
+		driver.KmsKeyID = pluginConfig.EncryptionOptions.KmsKeyId
+		driver.KmsEncryptionContext = pluginConfig.EncryptionOptions.KmsEncryptionContext
+	}
+	if pluginConfig.SSEAlgorithm != "" {
+		driver.EnableEncryption = true
+	}
+
+	// The Kubernetes client is only needed, and created, when a secret is selected
+	secrets := &secretResolver{namespace: pluginConfig.SecretNamespace}
+
+	// Resolve the CA bundle trusted for the endpoint's certificate (optional), whichever credentials are used
+	if pluginConfig.CASecret != nil {
+		if err := resolveTrustedCA(ctx, driver, secrets, pluginConfig.CASecret); err != nil {
+			return nil, err
+		}
+	}
+
+	// Resolve the client certificate for mutual TLS (optional)
+	if pluginConfig.ClientCertSecret != nil && pluginConfig.ClientKeySecret != nil {
+		if err := resolveClientCertificate(ctx, driver, secrets, pluginConfig.ClientCertSecret, pluginConfig.ClientKeySecret); err != nil {
+			return nil, err
+		}
+	}
+
+	// Resolve SSE-C customer key (optional), whichever credentials are used
+	if pluginConfig.EncryptionOptions != nil && pluginConfig.EncryptionOptions.ServerSideCustomerKeySecret != nil {
+		customerKeySecret := pluginConfig.EncryptionOptions.ServerSideCustomerKeySecret
+		customerKey, err := secrets.value(ctx, customerKeySecret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve server-side customer key: %w", err)
+		}
+		driver.ServerSideCustomerKey = customerKey
+	}
+
+	// Anonymous access has no credentials to resolve
+	if pluginConfig.Anonymous {
+		return driver, nil
 	}
 
-	// If UseSDKCreds is true, we don't need to resolve any secrets
+	// If UseSDKCreds is true, we don't need to resolve any credential secrets
 	if pluginConfig.UseSDKCreds {
+		resolveWebIdentity(ctx, driver)
 		return driver, nil
 	}
 
-	// Create Kubernetes client
-	k8sConfig, err := rest.InClusterConfig()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get in-cluster config: %w", err)
+	// Without credential secrets or files, fall back to credentials from the environment
+	if pluginConfig.AccessKeySecret == nil && pluginConfig.SecretKeySecret == nil && pluginConfig.AccessKeyFile == "" {
+		if err := resolveEnvCredentials(driver); err != nil {
+			return nil, err
+		}
 	}
 
-	clientset, err := kubernetes.NewForConfig(k8sConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	// Resolve credentials mounted as files (optional)
+	if err := resolveCredentialFiles(driver, pluginConfig); err != nil {
+		return nil, err
 	}
 
 	// Resolve access key
 	if pluginConfig.AccessKeySecret != nil {
-		accessKey, err := getSecretValue(ctx, clientset, pluginConfig.AccessKeySecret.Name, pluginConfig.AccessKeySecret.Key)
+		accessKey, err := secrets.value(ctx, pluginConfig.AccessKeySecret)
 		if err != nil {
 			return nil, fmt.Errorf("failed to resolve access key: %w", err)
 		}
@@ -91,7 +1273,7 @@ func getArtifactDriver(ctx context.Context, pluginConfig *wfv1.S3Bucket) (*Artif
 
 	// Resolve secret key
 	if pluginConfig.SecretKeySecret != nil {
-		secretKey, err := getSecretValue(ctx, clientset, pluginConfig.SecretKeySecret.Name, pluginConfig.SecretKeySecret.Key)
+		secretKey, err := secrets.value(ctx, pluginConfig.SecretKeySecret)
 		if err != nil {
 			return nil, fmt.Errorf("failed to resolve secret key: %w", err)
 		}
@@ -100,7 +1282,7 @@ func getArtifactDriver(ctx context.Context, pluginConfig *wfv1.S3Bucket) (*Artif
 
 	// Resolve session token (optional)
 	if pluginConfig.SessionTokenSecret != nil {
-		sessionToken, err := getSecretValue(ctx, clientset, pluginConfig.SessionTokenSecret.Name, pluginConfig.SessionTokenSecret.Key)
+		sessionToken, err := secrets.value(ctx, pluginConfig.SessionTokenSecret)
 		if err != nil {
 			return nil, fmt.Errorf("failed to resolve session token: %w", err)
 		}
@@ -112,33 +1294,182 @@ func getArtifactDriver(ctx context.Context, pluginConfig *wfv1.S3Bucket) (*Artif
 	return driver, nil
 }
 
-// getSecretValue retrieves a value from a Kubernetes secret
-func getSecretValue(ctx context.Context, clientset *kubernetes.Clientset, secretName, secretKey string) (string, error) {
-	// Get namespace from service account token
-	namespace, err := getNamespace()
+// resolveTrustedCA resolves the PEM CA bundle from the secret into the driver, failing if it holds no certificates
+func resolveTrustedCA(ctx context.Context, driver *ArtifactDriver, secrets *secretResolver, caSecret *corev1.SecretKeySelector) error {
+	caCert, err := secrets.value(ctx, caSecret)
+	if err != nil {
+		return fmt.Errorf("failed to resolve CA certificate: %w", err)
+	}
+	if caCert == "" && isOptional(caSecret) {
+		return nil
+	}
+	if !x509.NewCertPool().AppendCertsFromPEM([]byte(caCert)) {
+		return fmt.Errorf("%w: caSecret %s/%s does not contain a PEM encoded certificate", ErrInvalidConfig, caSecret.Name, caSecret.Key)
+	}
+	driver.TrustedCA = caCert
+	return nil
+}
+
+// resolveClientCertificate resolves the PEM client certificate and key from their secrets into the driver,
+// failing if they don't form a valid key pair
+func resolveClientCertificate(ctx context.Context, driver *ArtifactDriver, secrets *secretResolver, certSecret, keySecret *corev1.SecretKeySelector) error {
+	clientCert, err := secrets.value(ctx, certSecret)
+	if err != nil {
+		return fmt.Errorf("failed to resolve client certificate: %w", err)
+	}
+	clientKey, err := secrets.value(ctx, keySecret)
+	if err != nil {
+		return fmt.Errorf("failed to resolve client key: %w", err)
+	}
+	if (clientCert == "" && isOptional(certSecret)) || (clientKey == "" && isOptional(keySecret)) {
+		return nil
+	}
+	if _, err := tls.X509KeyPair([]byte(clientCert), []byte(clientKey)); err != nil {
+		return fmt.Errorf("%w: clientCertSecret and clientKeySecret are not a valid key pair: %v", ErrInvalidConfig, err)
+	}
+	driver.ClientCert = clientCert
+	driver.ClientKey = clientKey
+	return nil
+}
+
+// resolveWebIdentity wires up IRSA web identity credentials when the token file is present in the environment.
+// An explicitly configured RoleARN takes precedence over the one injected by IRSA.
+func resolveWebIdentity(ctx context.Context, driver *ArtifactDriver) {
+	tokenFile := os.Getenv(envVarWebIdentityTokenFile)
+	if tokenFile == "" {
+		return
+	}
+	if driver.RoleARN == "" {
+		driver.RoleARN = os.Getenv(envVarRoleARN)
+	}
+	if driver.RoleARN == "" {
+		logging.RequireLoggerFromContext(ctx).WithField("tokenFile", tokenFile).Warn(ctx, "Web identity token file found but no role ARN set, falling back to SDK credentials")
+		return
+	}
+	driver.WebIdentityTokenFile = tokenFile
+	logging.RequireLoggerFromContext(ctx).WithFields(logging.Fields{
+		"tokenFile": tokenFile,
+		"roleArn":   driver.RoleARN,
+	}).Debug(ctx, "Using web identity credentials")
+}
+
+// resolveCredentialFiles reads the configured credential files into the driver
+func resolveCredentialFiles(driver *ArtifactDriver, pluginConfig *PluginConfig) error {
+	for _, file := range []struct {
+		field, path string
+		value       *string
+	}{
+		{"accessKeyFile", pluginConfig.AccessKeyFile, &driver.AccessKey},
+		{"secretKeyFile", pluginConfig.SecretKeyFile, &driver.SecretKey},
+		{"sessionTokenFile", pluginConfig.SessionTokenFile, &driver.SessionToken},
+	} {
+		if file.path == "" {
+			continue
+		}
+		value, err := readCredentialFile(file.path)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrInvalidConfig, file.field, err)
+		}
+		*file.value = value
+	}
+	return nil
+}
+
+// readCredentialFile returns the contents of a credential file without trailing whitespace, failing if that leaves nothing
+func readCredentialFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimRightFunc(string(data), unicode.IsSpace)
+	if value == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return value, nil
+}
+
+// resolveEnvCredentials populates static credentials from the standard AWS environment variables.
+// A role ARN on its own is enough to authenticate, otherwise the variables are required.
+func resolveEnvCredentials(driver *ArtifactDriver) error {
+	accessKey := os.Getenv(envVarAccessKeyID)
+	secretKey := os.Getenv(envVarSecretAccessKey)
+	if accessKey == "" || secretKey == "" {
+		if driver.RoleARN != "" {
+			return nil
+		}
+		return fmt.Errorf("%w: no credentials configured, set accessKeySecret and secretKeySecret, useSDKCreds, roleARN or the %s and %s environment variables",
+			ErrInvalidConfig, envVarAccessKeyID, envVarSecretAccessKey)
+	}
+	driver.AccessKey = accessKey
+	driver.SecretKey = secretKey
+	driver.SessionToken = os.Getenv(envVarSessionToken)
+	return nil
+}
+
+// getSelectedSecretValue retrieves the value the selector refers to. A missing secret or key resolves to an empty
+// value rather than an error when the selector is optional.
+func getSelectedSecretValue(ctx context.Context, clientset kubernetes.Interface, secretNamespace string, selector *corev1.SecretKeySelector) (string, error) {
+	value, err := getSecretValue(ctx, clientset, secretNamespace, selector.Name, selector.Key)
+	var secretErr *SecretResolutionError
+	if isOptional(selector) && errors.As(err, &secretErr) && (secretErr.Category == SecretNotFound || secretErr.Category == SecretKeyNotFound) {
+		logging.RequireLoggerFromContext(ctx).WithError(err).Info(ctx, "Skipping optional secret")
+		return "", nil
+	}
+	return value, err
+}
+
+// isOptional reports whether the selector is marked optional
+func isOptional(selector *corev1.SecretKeySelector) bool {
+	return selector.Optional != nil && *selector.Optional
+}
+
+// getSecretValue retrieves a value from a Kubernetes secret, served from the secret cache when fresh.
+// The secret is read from secretNamespace when set, see getNamespace otherwise.
+func getSecretValue(ctx context.Context, clientset kubernetes.Interface, secretNamespace, secretName, secretKey string) (string, error) {
+	namespace, err := getNamespace(secretNamespace)
 	if err != nil {
 		return "", fmt.Errorf("failed to get namespace: %w", err)
 	}
 
+	return secretValues.get(ctx, clientset, namespace, secretName, secretKey)
+}
+
+// fetchSecretValue reads a value from a Kubernetes secret via the API server, failing with a SecretResolutionError
+func fetchSecretValue(ctx context.Context, clientset kubernetes.Interface, namespace, secretName, secretKey string) (string, error) {
 	secret, err := clientset.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
 	if err != nil {
-		return "", fmt.Errorf("failed to get secret %s: %w", secretName, err)
+		return "", &SecretResolutionError{Namespace: namespace, Name: secretName, Key: secretKey, Category: secretErrorCategory(err), Err: err}
 	}
 
 	value, exists := secret.Data[secretKey]
 	if !exists {
-		return "", fmt.Errorf("secret key %s not found in secret %s", secretKey, secretName)
+		return "", &SecretResolutionError{Namespace: namespace, Name: secretName, Key: secretKey, Category: SecretKeyNotFound}
 	}
 
 	return string(value), nil
 }
 
-// getNamespace reads the namespace from the service account token
-func getNamespace() (string, error) {
-	// Read namespace from the mounted service account token
-	namespaceBytes, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+// namespaceFile holds the namespace of the mounted service account, a variable so tests can point it elsewhere
+var namespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// getNamespace returns the namespace secrets are read from: the configured one when set, then SECRET_NAMESPACE,
+// then POD_NAMESPACE, then the namespace of the mounted service account
+func getNamespace(configured string) (string, error) {
+	if configured != "" {
+		return configured, nil
+	}
+	for _, envVar := range []string{envVarSecretNamespace, envVarPodNamespace} {
+		if namespace := os.Getenv(envVar); namespace != "" {
+			if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+				return "", fmt.Errorf("%w: %s %q: %s", ErrInvalidConfig, envVar, namespace, strings.Join(errs, ", "))
+			}
+			return namespace, nil
+		}
+	}
+	// Read namespace from the mounted service account token, which hardened pods may not mount
+	namespaceBytes, err := os.ReadFile(namespaceFile)
 	if err != nil {
-		return "", fmt.Errorf("failed to read namespace: %w", err)
+		return "", fmt.Errorf("failed to read namespace, set %s or mount the service account: %w", envVarPodNamespace, err)
 	}
 	return string(namespaceBytes), nil
 }
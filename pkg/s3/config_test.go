@@ -1,13 +1,38 @@
 package s3
 
 import (
+	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	argoerrs "github.com/argoproj/argo-workflows/v3/errors"
 	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
 	"github.com/argoproj/argo-workflows/v3/util/logging"
+	"github.com/argoproj/argo-workflows/v3/workflow/common"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestParsePluginConfiguration(t *testing.T) {
@@ -17,7 +42,7 @@ func TestParsePluginConfiguration(t *testing.T) {
 		name        string
 		configYAML  string
 		expectError bool
-		validate    func(t *testing.T, config *wfv1.S3Bucket)
+		validate    func(t *testing.T, config *PluginConfig)
 	}{
 		{
 			name: "basic configuration",
@@ -29,7 +54,7 @@ insecure: true
 useSDKCreds: false
 `,
 			expectError: false,
-			validate: func(t *testing.T, config *wfv1.S3Bucket) {
+			validate: func(t *testing.T, config *PluginConfig) {
 				assert.Equal(t, "my-bucket", config.Bucket)
 				assert.Equal(t, "minio:9000", config.Endpoint)
 				assert.Equal(t, "us-east-1", config.Region)
@@ -52,7 +77,7 @@ secretKeySecret:
   key: secretkey
 `,
 			expectError: false,
-			validate: func(t *testing.T, config *wfv1.S3Bucket) {
+			validate: func(t *testing.T, config *PluginConfig) {
 				assert.Equal(t, "my-bucket", config.Bucket)
 				assert.Equal(t, "minio:9000", config.Endpoint)
 
@@ -83,7 +108,7 @@ sessionTokenSecret:
   key: sessiontoken
 `,
 			expectError: false,
-			validate: func(t *testing.T, config *wfv1.S3Bucket) {
+			validate: func(t *testing.T, config *PluginConfig) {
 				assert.Equal(t, "my-bucket", config.Bucket)
 
 				// Check all three secrets
@@ -111,7 +136,7 @@ accessKeySecret:
   optional: true
 `,
 			expectError: false,
-			validate: func(t *testing.T, config *wfv1.S3Bucket) {
+			validate: func(t *testing.T, config *PluginConfig) {
 				require.NotNil(t, config.AccessKeySecret)
 				assert.Equal(t, "my-minio-cred", config.AccessKeySecret.Name)
 				assert.Equal(t, "accesskey", config.AccessKeySecret.Key)
@@ -137,7 +162,7 @@ endpoint: minio:9000
 useSDKCreds: true
 `,
 			expectError: false,
-			validate: func(t *testing.T, config *wfv1.S3Bucket) {
+			validate: func(t *testing.T, config *PluginConfig) {
 				assert.Equal(t, "my-bucket", config.Bucket)
 				assert.Equal(t, "minio:9000", config.Endpoint)
 				assert.True(t, config.UseSDKCreds)
@@ -215,6 +240,96 @@ accessKeySecret: "invalid-string-instead-of-object"
 	}
 }
 
+// TestParsePluginConfiguration_Strict verifies unknown fields are rejected unless CONFIG_STRICT is false
+func TestParsePluginConfiguration_Strict(t *testing.T) {
+	configYAML := `
+bucket: my-bucket
+region: us-east-1
+futureOption: true
+accessKeySecret:
+  name: my-minio-cred
+  key: accesskey
+  futureSubOption: 1
+`
+
+	for _, value := range []string{"", "true", "not-a-bool"} {
+		t.Run("CONFIG_STRICT="+value, func(t *testing.T) {
+			t.Setenv(envVarConfigStrict, value)
+			_, err := parsePluginConfiguration(logging.TestContext(t.Context()), configYAML)
+			require.ErrorIs(t, err, ErrInvalidConfig)
+			assert.ErrorContains(t, err, "unknown field")
+		})
+	}
+
+	t.Run("CONFIG_STRICT=false", func(t *testing.T) {
+		t.Setenv(envVarConfigStrict, "false")
+		var buf bytes.Buffer
+		ctx := logging.WithLogger(t.Context(), logging.NewSlogLoggerCustom(logging.Info, logging.JSON, &buf))
+
+		config, err := parsePluginConfiguration(ctx, configYAML)
+		require.NoError(t, err)
+		assert.Equal(t, "my-bucket", config.Bucket)
+		assert.Equal(t, "us-east-1", config.Region)
+		require.NotNil(t, config.AccessKeySecret)
+		assert.Equal(t, "my-minio-cred", config.AccessKeySecret.Name)
+		assert.Contains(t, buf.String(), "Ignoring unrecognised plugin configuration fields")
+		assert.Contains(t, buf.String(), `["accessKeySecret","futureOption"]`)
+
+		_, err = parsePluginConfiguration(ctx, "bucket: [")
+		require.ErrorIs(t, err, ErrInvalidConfig, "invalid YAML is still an error")
+	})
+}
+
+// TestParsePluginConfiguration_EnvExpansion verifies the environment variables exposed to configurations are expanded
+// into them, and no others
+func TestParsePluginConfiguration_EnvExpansion(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	t.Setenv("ARTIFACT_VAR_ENV", "staging")
+	t.Setenv("ARTIFACT_VAR_REGION", "eu-west-1")
+
+	t.Run("defined variables", func(t *testing.T) {
+		config, err := parsePluginConfiguration(ctx, "bucket: artifacts-${ARTIFACT_VAR_ENV}\nregion: $ARTIFACT_VAR_REGION\n")
+		require.NoError(t, err)
+		assert.Equal(t, "artifacts-staging", config.Bucket)
+		assert.Equal(t, "eu-west-1", config.Region)
+	})
+
+	t.Run("escaped dollar", func(t *testing.T) {
+		config, err := parsePluginConfiguration(ctx, "bucket: my-bucket\nkeyFormat: prices-$$ARTIFACT_VAR_ENV/{{key}}\n")
+		require.NoError(t, err)
+		assert.Equal(t, "prices-$ARTIFACT_VAR_ENV/{{key}}", config.KeyFormat)
+	})
+
+	t.Run("undefined variable", func(t *testing.T) {
+		config, err := parsePluginConfiguration(ctx, "bucket: artifacts-${ARTIFACT_VAR_UNDEFINED}\n")
+		require.NoError(t, err)
+		assert.Equal(t, "artifacts-", config.Bucket)
+	})
+
+	t.Run("undefined variable with CONFIG_EXPAND_STRICT", func(t *testing.T) {
+		t.Setenv(envVarConfigExpandStrict, "true")
+		_, err := parsePluginConfiguration(ctx, "bucket: artifacts-${ARTIFACT_VAR_UNDEFINED}\nregion: $ARTIFACT_VAR_REGION\n")
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		assert.ErrorContains(t, err, "undefined environment variables ARTIFACT_VAR_UNDEFINED")
+	})
+
+	t.Run("secret variable is not expanded", func(t *testing.T) {
+		t.Setenv(envVarSecretAccessKey, "wJalrXUtnFEMIEXAMPLEKEY")
+		_, err := parsePluginConfiguration(ctx, "bucket: my-bucket\nuserAgentSuffix: ${AWS_SECRET_ACCESS_KEY}\n")
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		assert.ErrorContains(t, err, "references environment variables AWS_SECRET_ACCESS_KEY")
+		assert.NotContains(t, err.Error(), "wJalrXUtnFEMIEXAMPLEKEY")
+	})
+
+	t.Run("variable listed in CONFIG_EXPAND_VARS", func(t *testing.T) {
+		t.Setenv(envVarConfigExpandVars, "CLUSTER_NAME, TEAM")
+		t.Setenv("CLUSTER_NAME", "prod-eu")
+		config, err := parsePluginConfiguration(ctx, "bucket: artifacts-${CLUSTER_NAME}\n")
+		require.NoError(t, err)
+		assert.Equal(t, "artifacts-prod-eu", config.Bucket)
+	})
+}
+
 // TestSecretKeySelector_FieldMapping verifies the YAML field mapping works correctly
 func TestSecretKeySelector_FieldMapping(t *testing.T) {
 	ctx := logging.WithLogger(context.Background(), logging.NewSlogLogger(logging.Debug, logging.JSON))
@@ -256,3 +371,1643 @@ secretKeySecret:
 		t.Error("SecretKeySecret is nil")
 	}
 }
+
+// TestCreateArgoArtifactFromConfig verifies every bucket setting in the plugin configuration reaches the artifact
+func TestCreateArgoArtifactFromConfig(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+
+	config, err := parsePluginConfiguration(ctx, `bucket: my-bucket
+endpoint: minio:9000
+region: eu-west-1
+insecure: true
+roleARN: arn:aws:iam::123456789012:role/artifacts
+useSDKCreds: true
+caSecret:
+  name: my-ca
+  key: ca.crt
+accessKeySecret:
+  name: my-minio-cred
+  key: accesskey
+secretKeySecret:
+  name: my-minio-cred
+  key: secretkey
+sessionTokenSecret:
+  name: my-minio-cred
+  key: sessiontoken
+encryptionOptions:
+  enableEncryption: true
+  kmsKeyId: my-key
+`)
+	require.NoError(t, err)
+
+	artifact := createArgoArtifactFromConfig(config, "my-wf/hello-art.tar.gz")
+	require.NotNil(t, artifact.S3)
+	assert.Equal(t, "my-wf/hello-art.tar.gz", artifact.S3.Key)
+	assert.Equal(t, config.S3Bucket, artifact.S3.S3Bucket)
+	assert.Equal(t, "minio:9000", artifact.S3.Endpoint)
+	assert.Equal(t, "eu-west-1", artifact.S3.Region)
+	require.NotNil(t, artifact.S3.Insecure)
+	assert.True(t, *artifact.S3.Insecure)
+	assert.Equal(t, "arn:aws:iam::123456789012:role/artifacts", artifact.S3.RoleARN)
+	assert.True(t, artifact.S3.UseSDKCreds)
+	assert.Equal(t, "my-ca", artifact.S3.CASecret.Name)
+	assert.Equal(t, "accesskey", artifact.S3.AccessKeySecret.Key)
+	assert.Equal(t, "secretkey", artifact.S3.SecretKeySecret.Key)
+	assert.Equal(t, "sessiontoken", artifact.S3.SessionTokenSecret.Key)
+	assert.Equal(t, "my-key", artifact.S3.EncryptionOptions.KmsKeyId)
+}
+
+// TestGetArtifactDriver_WebIdentity verifies IRSA web identity detection when using SDK credentials
+func TestGetArtifactDriver_WebIdentity(t *testing.T) {
+	t.Run("token file present", func(t *testing.T) {
+		t.Setenv(envVarWebIdentityTokenFile, "/var/run/secrets/eks.amazonaws.com/serviceaccount/token")
+		t.Setenv(envVarRoleARN, "arn:aws:iam::123456789012:role/argo-artifacts")
+		ctx := logging.TestContext(t.Context())
+
+		driver, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{UseSDKCreds: true}})
+		require.NoError(t, err)
+		assert.Equal(t, "/var/run/secrets/eks.amazonaws.com/serviceaccount/token", driver.WebIdentityTokenFile)
+		assert.Equal(t, "arn:aws:iam::123456789012:role/argo-artifacts", driver.RoleARN)
+	})
+
+	t.Run("explicit role ARN wins", func(t *testing.T) {
+		t.Setenv(envVarWebIdentityTokenFile, "/var/run/secrets/eks.amazonaws.com/serviceaccount/token")
+		t.Setenv(envVarRoleARN, "arn:aws:iam::123456789012:role/argo-artifacts")
+		ctx := logging.TestContext(t.Context())
+
+		driver, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{UseSDKCreds: true, RoleARN: "arn:aws:iam::123456789012:role/other"}})
+		require.NoError(t, err)
+		assert.Equal(t, "/var/run/secrets/eks.amazonaws.com/serviceaccount/token", driver.WebIdentityTokenFile)
+		assert.Equal(t, "arn:aws:iam::123456789012:role/other", driver.RoleARN)
+	})
+
+	t.Run("token file absent", func(t *testing.T) {
+		t.Setenv(envVarWebIdentityTokenFile, "")
+		t.Setenv(envVarRoleARN, "arn:aws:iam::123456789012:role/argo-artifacts")
+		ctx := logging.TestContext(t.Context())
+
+		driver, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{UseSDKCreds: true}})
+		require.NoError(t, err)
+		assert.Empty(t, driver.WebIdentityTokenFile)
+		assert.Empty(t, driver.RoleARN)
+	})
+}
+
+// TestValidatePluginConfig_StreamChunkSize verifies the OpenStream chunk size bounds
+func TestValidateS3Config(t *testing.T) {
+	tests := []struct {
+		name        string
+		bucket      string
+		key         string
+		expectedKey string
+		errMsg      string
+	}{
+		{name: "valid", bucket: "my-bucket", key: "my-wf/my-pod/art.tgz", expectedKey: "my-wf/my-pod/art.tgz"},
+		{name: "valid with dots", bucket: "my.bucket.1", key: "art.tgz", expectedKey: "art.tgz"},
+		{name: "empty key", bucket: "my-bucket", key: "", expectedKey: ""},
+		{name: "double slashes", bucket: "my-bucket", key: "my-wf//my-pod///art.tgz", expectedKey: "my-wf/my-pod/art.tgz"},
+		{name: "directory key", bucket: "my-bucket", key: "my-wf//", expectedKey: "my-wf/"},
+		{name: "empty bucket", bucket: "", key: "art.tgz", errMsg: "invalid plugin configuration: bucket is required"},
+		{name: "short bucket", bucket: "ab", key: "art.tgz", errMsg: `invalid plugin configuration: bucket "ab" must be between 3 and 63 characters long`},
+		{name: "long bucket", bucket: strings.Repeat("a", 64), key: "art.tgz", errMsg: "must be between 3 and 63 characters long"},
+		{name: "uppercase bucket", bucket: "My-Bucket", key: "art.tgz", errMsg: "must consist of lowercase letters, numbers, dots and hyphens"},
+		{name: "underscore bucket", bucket: "my_bucket", key: "art.tgz", errMsg: "must consist of lowercase letters, numbers, dots and hyphens"},
+		{name: "bucket ending with hyphen", bucket: "my-bucket-", key: "art.tgz", errMsg: "begin and end with a letter or number"},
+		{name: "adjacent periods", bucket: "my..bucket", key: "art.tgz", errMsg: "must not contain two adjacent periods"},
+		{name: "IP address bucket", bucket: "192.168.5.4", key: "art.tgz", errMsg: "must not be formatted as an IP address"},
+		{name: "leading slash", bucket: "my-bucket", key: "/art.tgz", errMsg: `invalid plugin configuration: key "/art.tgz" must not start with a slash`},
+		{name: "key too long", bucket: "my-bucket", key: strings.Repeat("a", 1025), errMsg: "invalid plugin configuration: key must be at most 1024 bytes, got 1025"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := validateS3Config(tt.bucket, tt.key)
+			if tt.errMsg != "" {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, ErrInvalidConfig)
+				assert.Contains(t, err.Error(), tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedKey, key)
+		})
+	}
+}
+
+func TestValidatePluginConfig_StreamChunkSize(t *testing.T) {
+	tests := []struct {
+		name        string
+		chunkSize   int
+		expectError bool
+	}{
+		{name: "unset", chunkSize: 0},
+		{name: "minimum", chunkSize: 64 * 1024},
+		{name: "maximum", chunkSize: 64 * 1024 * 1024},
+		{name: "too small", chunkSize: 1024, expectError: true},
+		{name: "too large", chunkSize: 128 * 1024 * 1024, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePluginConfig(&PluginConfig{StreamChunkSizeBytes: tt.chunkSize})
+			if tt.expectError {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, ErrInvalidConfig)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// TestGetArtifactDriver_MaxRetryAttempts verifies the retry attempts are passed to the driver with a default of 3
+func TestGetArtifactDriver_MaxRetryAttempts(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+
+	driver, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{UseSDKCreds: true}})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultMaxRetryAttempts, driver.MaxRetryAttempts)
+
+	config, err := parsePluginConfiguration(ctx, "useSDKCreds: true\nmaxRetryAttempts: 5\n")
+	require.NoError(t, err)
+	driver, err = getArtifactDriver(ctx, config)
+	require.NoError(t, err)
+	assert.Equal(t, 5, driver.MaxRetryAttempts)
+
+	err = validatePluginConfig(&PluginConfig{MaxRetryAttempts: -1})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+// TestGetArtifactDriver_PathStyle verifies the addressing style defaults by endpoint and honours pathStyle
+func TestGetArtifactDriver_PathStyle(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	enabled, disabled := true, false
+
+	tests := []struct {
+		name     string
+		endpoint string
+		style    *bool
+		expected AddressingStyle
+	}{
+		{name: "empty endpoint is AWS", expected: VirtualHostedStyle},
+		{name: "AWS endpoint", endpoint: "s3.us-east-1.amazonaws.com", expected: VirtualHostedStyle},
+		{name: "AWS China endpoint", endpoint: "s3.cn-north-1.amazonaws.com.cn", expected: VirtualHostedStyle},
+		{name: "MinIO endpoint", endpoint: "minio:9000", expected: PathStyle},
+		{name: "AWS forced path style", endpoint: "s3.us-east-1.amazonaws.com", style: &enabled, expected: PathStyle},
+		{name: "empty endpoint forced path style", style: &enabled, expected: PathStyle},
+		{name: "MinIO forced virtual hosted style", endpoint: "minio:9000", style: &disabled, expected: VirtualHostedStyle},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver, err := getArtifactDriver(ctx, &PluginConfig{
+				S3Bucket:  wfv1.S3Bucket{Endpoint: tt.endpoint, UseSDKCreds: true},
+				PathStyle: tt.style,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, driver.AddressingStyle)
+		})
+	}
+}
+
+// TestGetArtifactDriver_OperationTimeout verifies the operation timeout is passed to the driver with a 300s default
+func TestGetArtifactDriver_OperationTimeout(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+
+	driver, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{UseSDKCreds: true}})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultOperationTimeout, driver.OperationTimeout)
+
+	config, err := parsePluginConfiguration(ctx, "useSDKCreds: true\noperationTimeoutSeconds: 30\n")
+	require.NoError(t, err)
+	driver, err = getArtifactDriver(ctx, config)
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, driver.OperationTimeout)
+
+	err = validatePluginConfig(&PluginConfig{OperationTimeoutSeconds: -1})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestValidatePluginConfig_ListPattern(t *testing.T) {
+	require.NoError(t, validatePluginConfig(&PluginConfig{}))
+	require.NoError(t, validatePluginConfig(&PluginConfig{ListPattern: "*.parquet"}))
+
+	err := validatePluginConfig(&PluginConfig{ListPattern: "[a-"})
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	assert.Contains(t, err.Error(), `listPattern "[a-"`)
+}
+
+func TestValidatePluginConfig_Archive(t *testing.T) {
+	level := func(l int) *int { return &l }
+	require.NoError(t, validatePluginConfig(&PluginConfig{Archive: ArchiveNone}))
+	require.NoError(t, validatePluginConfig(&PluginConfig{Archive: ArchiveTar}))
+	require.NoError(t, validatePluginConfig(&PluginConfig{Archive: ArchiveTarGz, ArchiveCompressionLevel: level(0)}))
+	require.NoError(t, validatePluginConfig(&PluginConfig{Archive: ArchiveTarGz, ArchiveCompressionLevel: level(9)}))
+
+	for name, config := range map[string]*PluginConfig{
+		"unknown archive":    {Archive: "zip"},
+		"level without gzip": {Archive: ArchiveTar, ArchiveCompressionLevel: level(5)},
+		"level too low":      {Archive: ArchiveTarGz, ArchiveCompressionLevel: level(-1)},
+		"level too high":     {Archive: ArchiveTarGz, ArchiveCompressionLevel: level(10)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.ErrorIs(t, validatePluginConfig(config), ErrInvalidConfig)
+		})
+	}
+}
+
+// TestGetArtifactDriver_StorageClass verifies the storage class is validated and reaches the upload options
+func TestGetArtifactDriver_StorageClass(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+
+	t.Run("Valid", func(t *testing.T) {
+		config, err := parsePluginConfiguration(ctx, "useSDKCreds: true\nstorageClass: STANDARD_IA\n")
+		require.NoError(t, err)
+		require.NoError(t, validatePluginConfig(config))
+		driver, err := getArtifactDriver(ctx, config)
+		require.NoError(t, err)
+		assert.Equal(t, "STANDARD_IA", driver.StorageClass)
+
+		putOpts, err := (&s3client{S3ClientOpts: S3ClientOpts{StorageClass: driver.StorageClass}}).putObjectOptions("my-bucket", "my-key")
+		require.NoError(t, err)
+		assert.Equal(t, "STANDARD_IA", putOpts.StorageClass)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		err := validatePluginConfig(&PluginConfig{StorageClass: "standard-ia"})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		assert.Contains(t, err.Error(), `got "standard-ia"`)
+	})
+
+	t.Run("Unset", func(t *testing.T) {
+		driver, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{UseSDKCreds: true}})
+		require.NoError(t, err)
+		assert.Empty(t, driver.StorageClass)
+
+		putOpts, err := (&s3client{}).putObjectOptions("my-bucket", "my-key")
+		require.NoError(t, err)
+		assert.Empty(t, putOpts.StorageClass)
+	})
+}
+
+// TestGetArtifactDriver_ACL verifies the canned ACL is validated and sent with uploads
+func TestGetArtifactDriver_ACL(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+
+	t.Run("Valid", func(t *testing.T) {
+		config, err := parsePluginConfiguration(ctx, "useSDKCreds: true\nacl: bucket-owner-full-control\n")
+		require.NoError(t, err)
+		require.NoError(t, validatePluginConfig(config))
+		driver, err := getArtifactDriver(ctx, config)
+		require.NoError(t, err)
+		assert.Equal(t, "bucket-owner-full-control", driver.ACL)
+
+		f := newFakeObjectStore(t)
+		saver := f.driver()
+		saver.ACL = driver.ACL
+		path := filepath.Join(t.TempDir(), "report.csv")
+		require.NoError(t, os.WriteFile(path, []byte("a,b\n"), 0o600))
+		artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: "report.csv"}}}
+		require.NoError(t, saver.Save(ctx, path, artifact))
+		assert.Equal(t, "bucket-owner-full-control", f.acls["/my-bucket/report.csv"])
+		assert.Equal(t, "a,b\n", string(f.objects["/my-bucket/report.csv"]))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		err := validatePluginConfig(&PluginConfig{ACL: "owner-only"})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		assert.Contains(t, err.Error(), `got "owner-only"`)
+	})
+
+	t.Run("Unset", func(t *testing.T) {
+		putOpts, err := (&s3client{}).putObjectOptions("my-bucket", "my-key")
+		require.NoError(t, err)
+		assert.NotContains(t, putOpts.UserMetadata, headerACL)
+	})
+}
+
+// TestGetArtifactDriver_ObjectLock verifies the retention mode and retain-until date are validated and sent
+func TestGetArtifactDriver_ObjectLock(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	retainUntil := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+
+	for name, tc := range map[string]struct {
+		configYAML string
+		mode       string
+		expected   func(uploaded time.Time) time.Time
+	}{
+		"Governance for a duration": {
+			configYAML: "objectLockMode: GOVERNANCE\nobjectLockRetainUntil: 720h\n",
+			mode:       "GOVERNANCE",
+			expected:   func(uploaded time.Time) time.Time { return uploaded.Add(720 * time.Hour) },
+		},
+		"Compliance until a date": {
+			configYAML: "objectLockMode: COMPLIANCE\nobjectLockRetainUntil: " + retainUntil.Format(time.RFC3339) + "\n",
+			mode:       "COMPLIANCE",
+			expected:   func(time.Time) time.Time { return retainUntil },
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config, err := parsePluginConfiguration(ctx, "useSDKCreds: true\n"+tc.configYAML)
+			require.NoError(t, err)
+			require.NoError(t, validatePluginConfig(config))
+			driver, err := getArtifactDriver(ctx, config)
+			require.NoError(t, err)
+
+			uploaded := time.Now()
+			putOpts, err := (&s3client{S3ClientOpts: S3ClientOpts{
+				ObjectLockMode:        driver.ObjectLockMode,
+				ObjectLockRetention:   driver.ObjectLockRetention,
+				ObjectLockRetainUntil: driver.ObjectLockRetainUntil,
+			}}).putObjectOptions("my-bucket", "my-key")
+			require.NoError(t, err)
+			assert.Equal(t, tc.mode, putOpts.Header().Get("X-Amz-Object-Lock-Mode"))
+			sent, err := time.Parse(time.RFC3339, putOpts.Header().Get("X-Amz-Object-Lock-Retain-Until-Date"))
+			require.NoError(t, err)
+			assert.WithinDuration(t, tc.expected(uploaded), sent, 2*time.Second)
+		})
+	}
+
+	t.Run("Unset", func(t *testing.T) {
+		putOpts, err := (&s3client{}).putObjectOptions("my-bucket", "my-key")
+		require.NoError(t, err)
+		assert.Empty(t, putOpts.Header().Get("X-Amz-Object-Lock-Mode"))
+	})
+
+	t.Run("Unparseable without validation", func(t *testing.T) {
+		config, err := parsePluginConfiguration(ctx, "useSDKCreds: true\nregion: us-east-1\nobjectLockMode: GOVERNANCE\nobjectLockRetainUntil: next year\n")
+		require.NoError(t, err)
+		_, err = getArtifactDriver(ctx, config)
+		require.ErrorIs(t, err, ErrInvalidConfig)
+	})
+
+	for name, tc := range map[string]struct {
+		config   PluginConfig
+		expected string
+	}{
+		"unknown mode":         {config: PluginConfig{ObjectLockMode: "LEGAL_HOLD", ObjectLockRetainUntil: "24h"}, expected: `objectLockMode must be GOVERNANCE or COMPLIANCE, got "LEGAL_HOLD"`},
+		"mode without a date":  {config: PluginConfig{ObjectLockMode: "GOVERNANCE"}, expected: "objectLockRetainUntil is required"},
+		"date without a mode":  {config: PluginConfig{ObjectLockRetainUntil: "24h"}, expected: `objectLockMode must be GOVERNANCE or COMPLIANCE, got ""`},
+		"invalid retain-until": {config: PluginConfig{ObjectLockMode: "GOVERNANCE", ObjectLockRetainUntil: "next year"}, expected: `must be a duration such as 8760h or an RFC3339 date, got "next year"`},
+		"negative duration":    {config: PluginConfig{ObjectLockMode: "GOVERNANCE", ObjectLockRetainUntil: "-24h"}, expected: "must be a positive duration"},
+		"date in the past":     {config: PluginConfig{ObjectLockMode: "COMPLIANCE", ObjectLockRetainUntil: "2020-01-01T00:00:00Z"}, expected: "must be in the future"},
+	} {
+		t.Run("Invalid "+name, func(t *testing.T) {
+			err := validatePluginConfig(&tc.config)
+			require.ErrorIs(t, err, ErrInvalidConfig)
+			assert.ErrorContains(t, err, tc.expected)
+		})
+	}
+}
+
+// TestGetArtifactDriver_CacheControlAndContentDisposition verifies both headers are sent when configured and omitted otherwise
+func TestGetArtifactDriver_CacheControlAndContentDisposition(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+
+	t.Run("Configured", func(t *testing.T) {
+		config, err := parsePluginConfiguration(ctx, "useSDKCreds: true\ncacheControl: public, max-age=3600\ncontentDisposition: 'attachment; filename=\"report.csv\"'\n")
+		require.NoError(t, err)
+		require.NoError(t, validatePluginConfig(config))
+		driver, err := getArtifactDriver(ctx, config)
+		require.NoError(t, err)
+
+		putOpts, err := (&s3client{S3ClientOpts: S3ClientOpts{CacheControl: driver.CacheControl, ContentDisposition: driver.ContentDisposition}}).putObjectOptions("my-bucket", "my-key")
+		require.NoError(t, err)
+		assert.Equal(t, "public, max-age=3600", putOpts.Header().Get("Cache-Control"))
+		assert.Equal(t, `attachment; filename="report.csv"`, putOpts.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("Unset", func(t *testing.T) {
+		putOpts, err := (&s3client{}).putObjectOptions("my-bucket", "my-key")
+		require.NoError(t, err)
+		assert.NotContains(t, putOpts.Header(), "Cache-Control")
+		assert.NotContains(t, putOpts.Header(), "Content-Disposition")
+	})
+
+	t.Run("Invalid content disposition", func(t *testing.T) {
+		err := validatePluginConfig(&PluginConfig{ContentDisposition: "attachment; filename"})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		assert.ErrorContains(t, err, "contentDisposition")
+	})
+}
+
+// TestGetArtifactDriver_ObjectTags verifies the tags are validated and sent URL-encoded in the tagging header
+func TestGetArtifactDriver_ObjectTags(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+
+	config, err := parsePluginConfiguration(ctx, "useSDKCreds: true\nobjectTags:\n  team: ml\n  owner: data ml/platform\n")
+	require.NoError(t, err)
+	require.NoError(t, validatePluginConfig(config))
+	driver, err := getArtifactDriver(ctx, config)
+	require.NoError(t, err)
+
+	putOpts, err := (&s3client{S3ClientOpts: S3ClientOpts{ObjectTags: driver.ObjectTags}}).putObjectOptions("my-bucket", "my-key")
+	require.NoError(t, err)
+	assert.Equal(t, "owner=data+ml%2Fplatform&team=ml", putOpts.Header().Get("X-Amz-Tagging"))
+
+	tooMany := map[string]string{}
+	for i := range maxObjectTags + 1 {
+		tooMany[fmt.Sprintf("tag%d", i)] = "value"
+	}
+	for name, tags := range map[string]map[string]string{
+		"too many tags":  tooMany,
+		"empty key":      {"": "value"},
+		"key too long":   {strings.Repeat("k", maxTagKeyLength+1): "value"},
+		"value too long": {"team": strings.Repeat("v", maxTagValueLength+1)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.ErrorIs(t, validatePluginConfig(&PluginConfig{ObjectTags: tags}), ErrInvalidConfig)
+		})
+	}
+}
+
+func TestValidatePluginConfig_ContentType(t *testing.T) {
+	require.NoError(t, validatePluginConfig(&PluginConfig{ContentType: "application/json; charset=utf-8"}))
+
+	err := validatePluginConfig(&PluginConfig{ContentType: "application/"})
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	assert.Contains(t, err.Error(), `contentType "application/"`)
+}
+
+// TestGetSecretValue_Namespace verifies secrets are read from the configured namespace, then SECRET_NAMESPACE,
+// then the service account's namespace
+func TestGetSecretValue_Namespace(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	setNamespace(t, "argo")
+	clientset := fake.NewClientset(
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "ns-creds", Namespace: "argo"}, Data: map[string][]byte{"accesskey": []byte("from-argo")}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "ns-creds", Namespace: "creds"}, Data: map[string][]byte{"accesskey": []byte("from-creds")}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "ns-creds", Namespace: "shared"}, Data: map[string][]byte{"accesskey": []byte("from-shared")}},
+	)
+
+	t.Run("Explicit namespace", func(t *testing.T) {
+		t.Setenv(envVarSecretNamespace, "shared")
+		value, err := getSecretValue(ctx, clientset, "creds", "ns-creds", "accesskey")
+		require.NoError(t, err)
+		assert.Equal(t, "from-creds", value)
+	})
+
+	t.Run("Environment variable", func(t *testing.T) {
+		t.Setenv(envVarSecretNamespace, "shared")
+		value, err := getSecretValue(ctx, clientset, "", "ns-creds", "accesskey")
+		require.NoError(t, err)
+		assert.Equal(t, "from-shared", value)
+	})
+
+	t.Run("Service account file", func(t *testing.T) {
+		t.Setenv(envVarSecretNamespace, "")
+		t.Setenv(envVarPodNamespace, "")
+		value, err := getSecretValue(ctx, clientset, "", "ns-creds", "accesskey")
+		require.NoError(t, err)
+		assert.Equal(t, "from-argo", value)
+	})
+
+	t.Run("Invalid environment variable", func(t *testing.T) {
+		t.Setenv(envVarSecretNamespace, "Not_A_Namespace")
+		_, err := getSecretValue(ctx, clientset, "", "ns-creds", "accesskey")
+		require.ErrorIs(t, err, ErrInvalidConfig)
+	})
+}
+
+// TestGetNamespace verifies POD_NAMESPACE is used before the service account file, which may not be mounted
+func TestGetNamespace(t *testing.T) {
+	t.Setenv(envVarSecretNamespace, "")
+
+	t.Run("Environment variable", func(t *testing.T) {
+		setNamespace(t, "argo")
+		t.Setenv(envVarPodNamespace, "workflows")
+		namespace, err := getNamespace("")
+		require.NoError(t, err)
+		assert.Equal(t, "workflows", namespace)
+	})
+
+	t.Run("File only", func(t *testing.T) {
+		setNamespace(t, "argo")
+		t.Setenv(envVarPodNamespace, "")
+		namespace, err := getNamespace("")
+		require.NoError(t, err)
+		assert.Equal(t, "argo", namespace)
+	})
+
+	t.Run("Neither", func(t *testing.T) {
+		original := namespaceFile
+		namespaceFile = filepath.Join(t.TempDir(), "missing")
+		t.Cleanup(func() { namespaceFile = original })
+		t.Setenv(envVarPodNamespace, "")
+		_, err := getNamespace("")
+		require.ErrorContains(t, err, "set POD_NAMESPACE or mount the service account")
+	})
+
+	t.Run("Invalid environment variable", func(t *testing.T) {
+		t.Setenv(envVarPodNamespace, "Not_A_Namespace")
+		_, err := getNamespace("")
+		require.ErrorIs(t, err, ErrInvalidConfig)
+	})
+}
+
+func TestValidatePluginConfig_SecretNamespace(t *testing.T) {
+	require.NoError(t, validatePluginConfig(&PluginConfig{SecretNamespace: "creds"}))
+
+	err := validatePluginConfig(&PluginConfig{SecretNamespace: "Creds.Namespace"})
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	assert.Contains(t, err.Error(), `secretNamespace "Creds.Namespace"`)
+}
+
+func TestValidatePluginConfig_Profile(t *testing.T) {
+	require.NoError(t, validatePluginConfig(&PluginConfig{S3Bucket: wfv1.S3Bucket{UseSDKCreds: true}, Profile: "team-ml.prod"}))
+
+	err := validatePluginConfig(&PluginConfig{S3Bucket: wfv1.S3Bucket{UseSDKCreds: true}, Profile: "team ml"})
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	assert.Contains(t, err.Error(), `profile "team ml"`)
+
+	err = validatePluginConfig(&PluginConfig{Profile: "backup"})
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	assert.Contains(t, err.Error(), "profile requires useSDKCreds")
+}
+
+// TestGetArtifactDriver_StreamChunkSize verifies the chunk size is passed to the driver with a 1MB default
+func TestGetArtifactDriver_StreamChunkSize(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+
+	driver, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{UseSDKCreds: true}})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultStreamChunkSize, driver.StreamChunkSize)
+
+	config, err := parsePluginConfiguration(ctx, "useSDKCreds: true\nstreamChunkSizeBytes: 131072\n")
+	require.NoError(t, err)
+	driver, err = getArtifactDriver(ctx, config)
+	require.NoError(t, err)
+	assert.Equal(t, 131072, driver.StreamChunkSize)
+}
+
+// TestGetArtifactDriver_StreamRange verifies the stream range reaches the driver and can't be negative
+func TestGetArtifactDriver_StreamRange(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+
+	config, err := parsePluginConfiguration(ctx, "useSDKCreds: true\nstreamOffsetBytes: 1024\nstreamLengthBytes: 4096\n")
+	require.NoError(t, err)
+	require.NoError(t, validatePluginConfig(config))
+	driver, err := getArtifactDriver(ctx, config)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1024), driver.StreamOffset)
+	assert.Equal(t, int64(4096), driver.StreamLength)
+
+	require.ErrorIs(t, validatePluginConfig(&PluginConfig{StreamOffsetBytes: -1}), ErrInvalidConfig)
+	require.ErrorIs(t, validatePluginConfig(&PluginConfig{StreamLengthBytes: -1}), ErrInvalidConfig)
+}
+
+// TestGetArtifactDriver_MaxInlineObjectSize verifies the inline size limit reaches the driver and can't be negative
+func TestGetArtifactDriver_MaxInlineObjectSize(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+
+	config, err := parsePluginConfiguration(ctx, "useSDKCreds: true\nmaxInlineObjectBytes: 1048576\n")
+	require.NoError(t, err)
+	require.NoError(t, validatePluginConfig(config))
+	driver, err := getArtifactDriver(ctx, config)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1048576), driver.MaxInlineObjectSize)
+
+	require.ErrorIs(t, validatePluginConfig(&PluginConfig{MaxInlineObjectBytes: -1}), ErrInvalidConfig)
+}
+
+// TestGetArtifactDriver_Encryption verifies server-side encryption options reach the driver
+func TestGetArtifactDriver_Encryption(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+
+	t.Run("SSE-S3", func(t *testing.T) {
+		config, err := parsePluginConfiguration(ctx, "useSDKCreds: true\nsseAlgorithm: AES256\n")
+		require.NoError(t, err)
+		require.NoError(t, validatePluginConfig(config))
+
+		driver, err := getArtifactDriver(ctx, config)
+		require.NoError(t, err)
+		assert.True(t, driver.EnableEncryption)
+		assert.Empty(t, driver.KmsKeyID)
+
+		sse, err := (&EncryptOpts{Enabled: driver.EnableEncryption, KmsKeyID: driver.KmsKeyID}).buildServerSideEnc("my-bucket", "my-key")
+		require.NoError(t, err)
+		assert.Equal(t, encrypt.S3, sse.Type())
+	})
+
+	t.Run("SSE-KMS", func(t *testing.T) {
+		config, err := parsePluginConfiguration(ctx, `
+useSDKCreds: true
+sseAlgorithm: aws:kms
+encryptionOptions:
+  kmsKeyId: arn:aws:kms:us-east-1:123456789012:key/my-key
+  kmsEncryptionContext: '{"team":"ml"}'
+`)
+		require.NoError(t, err)
+		require.NoError(t, validatePluginConfig(config))
+
+		driver, err := getArtifactDriver(ctx, config)
+		require.NoError(t, err)
+		assert.True(t, driver.EnableEncryption)
+		assert.Equal(t, "arn:aws:kms:us-east-1:123456789012:key/my-key", driver.KmsKeyID)
+		assert.JSONEq(t, `{"team":"ml"}`, driver.KmsEncryptionContext)
+
+		sse, err := (&EncryptOpts{Enabled: driver.EnableEncryption, KmsKeyID: driver.KmsKeyID, KmsEncryptionContext: driver.KmsEncryptionContext}).buildServerSideEnc("my-bucket", "my-key")
+		require.NoError(t, err)
+		assert.Equal(t, encrypt.KMS, sse.Type())
+	})
+
+	t.Run("SSE-KMS without key", func(t *testing.T) {
+		config, err := parsePluginConfiguration(ctx, "useSDKCreds: true\nsseAlgorithm: aws:kms\n")
+		require.NoError(t, err)
+		err = validatePluginConfig(config)
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		assert.Contains(t, err.Error(), "requires encryptionOptions.kmsKeyId")
+	})
+
+	t.Run("unknown algorithm", func(t *testing.T) {
+		config, err := parsePluginConfiguration(ctx, "useSDKCreds: true\nsseAlgorithm: rot13\n")
+		require.NoError(t, err)
+		assert.ErrorIs(t, validatePluginConfig(config), ErrInvalidConfig)
+	})
+}
+
+// TestGetArtifactDriver_EnvCredentials verifies credentials fall back to the AWS environment variables
+func TestGetArtifactDriver_EnvCredentials(t *testing.T) {
+	t.Run("env vars present", func(t *testing.T) {
+		setClientsetConstructor(t, func() (kubernetes.Interface, error) {
+			return newFakeSecretClientset(), nil
+		})
+		t.Setenv(envVarAccessKeyID, "env-access-key")
+		t.Setenv(envVarSecretAccessKey, "env-secret-key")
+		t.Setenv(envVarSessionToken, "env-session-token")
+		ctx := logging.TestContext(t.Context())
+
+		driver, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}})
+		require.NoError(t, err)
+		assert.Equal(t, "env-access-key", driver.AccessKey)
+		assert.Equal(t, "env-secret-key", driver.SecretKey)
+		assert.Equal(t, "env-session-token", driver.SessionToken)
+	})
+
+	t.Run("env vars absent", func(t *testing.T) {
+		setClientsetConstructor(t, func() (kubernetes.Interface, error) {
+			return newFakeSecretClientset(), nil
+		})
+		t.Setenv(envVarAccessKeyID, "")
+		t.Setenv(envVarSecretAccessKey, "")
+		ctx := logging.TestContext(t.Context())
+
+		_, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		assert.Contains(t, err.Error(), "no credentials configured")
+	})
+
+	t.Run("role ARN without env vars", func(t *testing.T) {
+		setClientsetConstructor(t, func() (kubernetes.Interface, error) {
+			return newFakeSecretClientset(), nil
+		})
+		t.Setenv(envVarAccessKeyID, "")
+		t.Setenv(envVarSecretAccessKey, "")
+		ctx := logging.TestContext(t.Context())
+
+		driver, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{RoleARN: "arn:aws:iam::123456789012:role/argo-artifacts"}})
+		require.NoError(t, err)
+		assert.Empty(t, driver.AccessKey)
+	})
+}
+
+// TestGetArtifactDriver_OutOfCluster verifies credentials from the environment or files need no Kubernetes client,
+// using the real in-cluster constructor, which fails outside a cluster
+func TestGetArtifactDriver_OutOfCluster(t *testing.T) {
+	setClientsetConstructor(t, newInClusterClientset)
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv(envVarAccessKeyID, "env-access-key")
+	t.Setenv(envVarSecretAccessKey, "env-secret-key")
+	ctx := logging.TestContext(t.Context())
+	config := func() *PluginConfig {
+		return &PluginConfig{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket", Endpoint: "minio:9000", Region: "us-east-1"}}
+	}
+
+	driver, err := getArtifactDriver(ctx, config())
+	require.NoError(t, err)
+	assert.Equal(t, "env-access-key", driver.AccessKey)
+
+	t.Run("Credential files", func(t *testing.T) {
+		dir := t.TempDir()
+		accessKeyFile, secretKeyFile := filepath.Join(dir, "accesskey"), filepath.Join(dir, "secretkey")
+		require.NoError(t, os.WriteFile(accessKeyFile, []byte("file-access-key"), 0o600))
+		require.NoError(t, os.WriteFile(secretKeyFile, []byte("file-secret-key"), 0o600))
+		pluginConfig := config()
+		pluginConfig.AccessKeyFile, pluginConfig.SecretKeyFile = accessKeyFile, secretKeyFile
+		driver, err := getArtifactDriver(ctx, pluginConfig)
+		require.NoError(t, err)
+		assert.Equal(t, "file-access-key", driver.AccessKey)
+	})
+
+	t.Run("Secret selected", func(t *testing.T) {
+		pluginConfig := config()
+		pluginConfig.AccessKeySecret = &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "my-minio-cred"}, Key: "accesskey"}
+		_, err := getArtifactDriver(ctx, pluginConfig)
+		require.ErrorContains(t, err, "failed to get in-cluster config")
+	})
+}
+
+// TestGetArtifactDriver_SSECustomerKey verifies the SSE-C customer key is resolved whichever credentials are used
+func TestGetArtifactDriver_SSECustomerKey(t *testing.T) {
+	setClientsetConstructor(t, func() (kubernetes.Interface, error) {
+		return fake.NewClientset(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "sse-c", Namespace: "argo"},
+			Data:       map[string][]byte{"key": []byte("0123456789abcdef0123456789abcdef")},
+		}), nil
+	})
+	t.Setenv(envVarAccessKeyID, "env-access-key")
+	t.Setenv(envVarSecretAccessKey, "env-secret-key")
+	ctx := logging.TestContext(t.Context())
+
+	for name, config := range map[string]PluginConfig{
+		"Secret credentials": {},
+		"SDK credentials":    {S3Bucket: wfv1.S3Bucket{UseSDKCreds: true}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config.Bucket, config.Endpoint, config.Region = "my-bucket", "minio:9000", "us-east-1"
+			config.SecretNamespace = "argo"
+			config.EncryptionOptions = &wfv1.S3EncryptionOptions{
+				EnableEncryption:            true,
+				ServerSideCustomerKeySecret: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "sse-c"}, Key: "key"},
+			}
+			driver, err := getArtifactDriver(ctx, &config)
+			require.NoError(t, err)
+			assert.Equal(t, "0123456789abcdef0123456789abcdef", driver.ServerSideCustomerKey)
+		})
+	}
+}
+
+// TestGetArtifactDriver_AWSEnvDefaults verifies useSDKCreds fills an empty region and endpoint from the AWS
+// environment variables, and an explicit configuration takes precedence
+func TestGetArtifactDriver_AWSEnvDefaults(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	setEnv := func(t *testing.T, region, defaultRegion, endpointURL string) {
+		t.Setenv(envVarRegion, region)
+		t.Setenv(envVarDefaultRegion, defaultRegion)
+		t.Setenv(envVarEndpointURLS3, endpointURL)
+	}
+
+	t.Run("empty config filled from env", func(t *testing.T) {
+		setEnv(t, "eu-west-2", "us-east-2", "http://minio.local:9000")
+		driver, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{UseSDKCreds: true}})
+		require.NoError(t, err)
+		assert.Equal(t, "eu-west-2", driver.Region)
+		assert.Equal(t, "minio.local:9000", driver.Endpoint)
+		assert.False(t, driver.Secure)
+		assert.Equal(t, PathStyle, driver.AddressingStyle)
+	})
+
+	t.Run("default region", func(t *testing.T) {
+		setEnv(t, "", "us-east-2", "https://s3.example.com")
+		driver, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{UseSDKCreds: true}})
+		require.NoError(t, err)
+		assert.Equal(t, "us-east-2", driver.Region)
+		assert.Equal(t, "s3.example.com", driver.Endpoint)
+		assert.True(t, driver.Secure)
+	})
+
+	t.Run("explicit config wins", func(t *testing.T) {
+		setEnv(t, "eu-west-2", "us-east-2", "http://minio.local:9000")
+		driver, err := getArtifactDriver(ctx, &PluginConfig{
+			S3Bucket: wfv1.S3Bucket{Endpoint: "s3.example.com", Region: "ap-south-1", UseSDKCreds: true},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "ap-south-1", driver.Region)
+		assert.Equal(t, "s3.example.com", driver.Endpoint)
+		assert.True(t, driver.Secure)
+	})
+
+	t.Run("only with useSDKCreds", func(t *testing.T) {
+		setEnv(t, "eu-west-2", "", "http://minio.local:9000")
+		driver, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{Endpoint: "s3.example.com", Region: "ap-south-1"}, Anonymous: true})
+		require.NoError(t, err)
+		assert.Equal(t, "ap-south-1", driver.Region)
+		driver, err = getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{Endpoint: "s3.example.com"}, Anonymous: true})
+		require.NoError(t, err)
+		assert.Empty(t, driver.Region)
+	})
+
+	t.Run("endpoint URL agrees with insecure", func(t *testing.T) {
+		setEnv(t, "", "", "http://minio.local:9000")
+		_, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{UseSDKCreds: true, Insecure: new(bool)}})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+	})
+
+	t.Run("invalid endpoint URL", func(t *testing.T) {
+		setEnv(t, "", "", "minio.local:9000")
+		_, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{UseSDKCreds: true}})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		assert.Contains(t, err.Error(), envVarEndpointURLS3)
+	})
+}
+
+// TestGetArtifactDriver_EndpointScheme verifies an endpoint's scheme is stripped and sets, or must agree with, insecure
+func TestGetArtifactDriver_EndpointScheme(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	yes, no := true, false
+
+	for name, tc := range map[string]struct {
+		endpoint string
+		insecure *bool
+		expected string
+		secure   bool
+		err      string
+	}{
+		"No scheme":                {endpoint: "minio:9000", expected: "minio:9000", secure: true},
+		"No scheme, insecure":      {endpoint: "minio:9000", insecure: &yes, expected: "minio:9000"},
+		"https":                    {endpoint: "https://minio:9000", expected: "minio:9000", secure: true},
+		"https, trailing slash":    {endpoint: "https://minio:9000/", expected: "minio:9000", secure: true},
+		"https, secure":            {endpoint: "https://minio:9000", insecure: &no, expected: "minio:9000", secure: true},
+		"http":                     {endpoint: "http://minio:9000", expected: "minio:9000"},
+		"http, insecure":           {endpoint: "http://minio:9000", insecure: &yes, expected: "minio:9000"},
+		"https, insecure conflict": {endpoint: "https://minio:9000", insecure: &yes, err: "is https but insecure is true"},
+		"http, secure conflict":    {endpoint: "http://minio:9000", insecure: &no, err: "is http but insecure is false"},
+		"Unknown scheme":           {endpoint: "ftp://minio:9000", err: "must use http or https"},
+		"Path":                     {endpoint: "https://minio:9000/bucket", err: "without a path"},
+		"No host":                  {endpoint: "https://", err: "without a path"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			driver, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{Endpoint: tc.endpoint, Insecure: tc.insecure}, Anonymous: true})
+			if tc.err != "" {
+				require.ErrorIs(t, err, ErrInvalidConfig)
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, driver.Endpoint)
+			assert.Equal(t, tc.secure, driver.Secure)
+		})
+	}
+}
+
+// TestGetArtifactDriver_OptionalSecrets verifies a missing secret or key is skipped only when its selector is optional
+func TestGetArtifactDriver_OptionalSecrets(t *testing.T) {
+	setNamespace(t, "argo")
+	setClientsetConstructor(t, func() (kubernetes.Interface, error) {
+		return fake.NewClientset(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "optional-test-cred", Namespace: "argo"},
+			Data: map[string][]byte{
+				"accesskey": []byte("my-access-key"),
+				"secretkey": []byte("my-secret-key"),
+			},
+		}), nil
+	})
+	ctx := logging.TestContext(t.Context())
+	optional, required := true, false
+	selector := func(name, key string, optional *bool) *corev1.SecretKeySelector {
+		return &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: name}, Key: key, Optional: optional}
+	}
+	config := func(sessionToken *corev1.SecretKeySelector) *PluginConfig {
+		return &PluginConfig{S3Bucket: wfv1.S3Bucket{
+			Bucket:             "my-bucket",
+			AccessKeySecret:    selector("optional-test-cred", "accesskey", nil),
+			SecretKeySecret:    selector("optional-test-cred", "secretkey", nil),
+			SessionTokenSecret: sessionToken,
+		}}
+	}
+
+	for name, sessionToken := range map[string]*corev1.SecretKeySelector{
+		"optional missing key":    selector("optional-test-cred", "sessiontoken", &optional),
+		"optional missing secret": selector("missing-cred", "sessiontoken", &optional),
+	} {
+		t.Run(name, func(t *testing.T) {
+			driver, err := getArtifactDriver(ctx, config(sessionToken))
+			require.NoError(t, err)
+			assert.Equal(t, "my-access-key", driver.AccessKey)
+			assert.Equal(t, "my-secret-key", driver.SecretKey)
+			assert.Empty(t, driver.SessionToken)
+		})
+	}
+
+	for name, sessionToken := range map[string]*corev1.SecretKeySelector{
+		"required missing key":    selector("optional-test-cred", "sessiontoken", nil),
+		"explicitly required key": selector("optional-test-cred", "sessiontoken", &required),
+		"required missing secret": selector("missing-cred", "sessiontoken", nil),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := getArtifactDriver(ctx, config(sessionToken))
+			var secretErr *SecretResolutionError
+			require.ErrorAs(t, err, &secretErr)
+			assert.ErrorContains(t, err, "failed to resolve session token")
+		})
+	}
+}
+
+// TestGetArtifactDriver_CredentialFiles verifies credentials are read from mounted files
+func TestGetArtifactDriver_CredentialFiles(t *testing.T) {
+	setClientsetConstructor(t, func() (kubernetes.Interface, error) {
+		return newFakeSecretClientset(), nil
+	})
+	t.Setenv(envVarAccessKeyID, "env-access-key")
+	t.Setenv(envVarSecretAccessKey, "env-secret-key")
+	ctx := logging.TestContext(t.Context())
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+	accessKeyFile := writeFile("access-key", "file-access-key\n")
+	secretKeyFile := writeFile("secret-key", "file-secret-key \r\n")
+	sessionTokenFile := writeFile("session-token", "file-session-token")
+	emptyFile := writeFile("empty", " \n")
+
+	t.Run("all components", func(t *testing.T) {
+		config := &PluginConfig{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, AccessKeyFile: accessKeyFile, SecretKeyFile: secretKeyFile, SessionTokenFile: sessionTokenFile}
+		require.NoError(t, validatePluginConfig(config))
+		driver, err := getArtifactDriver(ctx, config)
+		require.NoError(t, err)
+		assert.Equal(t, "file-access-key", driver.AccessKey)
+		assert.Equal(t, "file-secret-key", driver.SecretKey)
+		assert.Equal(t, "file-session-token", driver.SessionToken)
+	})
+
+	for name, tc := range map[string]struct {
+		config   *PluginConfig
+		contains string
+	}{
+		"missing access key file": {
+			config:   &PluginConfig{AccessKeyFile: filepath.Join(dir, "missing"), SecretKeyFile: secretKeyFile},
+			contains: "accessKeyFile",
+		},
+		"empty secret key file": {
+			config:   &PluginConfig{AccessKeyFile: accessKeyFile, SecretKeyFile: emptyFile},
+			contains: "secretKeyFile: " + emptyFile + " is empty",
+		},
+		"empty session token file": {
+			config:   &PluginConfig{AccessKeyFile: accessKeyFile, SecretKeyFile: secretKeyFile, SessionTokenFile: emptyFile},
+			contains: "sessionTokenFile: " + emptyFile + " is empty",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := getArtifactDriver(ctx, tc.config)
+			require.ErrorIs(t, err, ErrInvalidConfig)
+			assert.ErrorContains(t, err, tc.contains)
+		})
+	}
+
+	for name, config := range map[string]*PluginConfig{
+		"access key file alone":  {AccessKeyFile: accessKeyFile},
+		"relative path":          {AccessKeyFile: "access-key", SecretKeyFile: secretKeyFile},
+		"file and secret":        {AccessKeyFile: accessKeyFile, SecretKeyFile: secretKeyFile, S3Bucket: wfv1.S3Bucket{SecretKeySecret: &corev1.SecretKeySelector{Key: "secretkey"}}},
+		"anonymous with files":   {AccessKeyFile: accessKeyFile, SecretKeyFile: secretKeyFile, Anonymous: true},
+		"relative session token": {AccessKeyFile: accessKeyFile, SecretKeyFile: secretKeyFile, SessionTokenFile: "token"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.ErrorIs(t, validatePluginConfig(config), ErrInvalidConfig)
+		})
+	}
+}
+
+// TestGetArtifactDriver_AssumeRole verifies the external ID and session name reach the driver
+func TestGetArtifactDriver_AssumeRole(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+
+	config, err := parsePluginConfiguration(ctx, `
+useSDKCreds: true
+roleARN: arn:aws:iam::123456789012:role/bucket-owner
+roleExternalID: my-external-id
+roleSessionName: my-session
+`)
+	require.NoError(t, err)
+	driver, err := getArtifactDriver(ctx, config)
+	require.NoError(t, err)
+	assert.Equal(t, "my-external-id", driver.RoleExternalID)
+	assert.Equal(t, "my-session", driver.RoleSessionName)
+
+	driver, err = getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{UseSDKCreds: true}})
+	require.NoError(t, err)
+	assert.Equal(t, "argo-artifact-plugin-s3", driver.RoleSessionName)
+}
+
+// generateCACertPEM returns a self-signed PEM encoded CA certificate
+func generateCACertPEM(t *testing.T) []byte {
+	t.Helper()
+	cert, _ := generateCertPEM(t)
+	return cert
+}
+
+// generateCertPEM returns a self-signed PEM encoded certificate and its private key
+func generateCertPEM(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// TestGetArtifactDriver_CASecret verifies the CA bundle is resolved from caSecret and trusted by the transport
+func TestGetArtifactDriver_CASecret(t *testing.T) {
+	caCert := generateCACertPEM(t)
+	setNamespace(t, "argo")
+	setClientsetConstructor(t, func() (kubernetes.Interface, error) {
+		return fake.NewClientset(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-minio-ca", Namespace: "argo"},
+			Data: map[string][]byte{
+				"ca.crt":  caCert,
+				"invalid": []byte("not a certificate"),
+			},
+		}), nil
+	})
+	ctx := logging.TestContext(t.Context())
+
+	t.Run("resolved", func(t *testing.T) {
+		driver, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{
+			UseSDKCreds: true,
+			CASecret: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "my-minio-ca"},
+				Key:                  "ca.crt",
+			},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, string(caCert), driver.TrustedCA)
+
+		tr, err := driver.newTransport(S3ClientOpts{Secure: true})
+		require.NoError(t, err)
+		expected := x509.NewCertPool()
+		require.True(t, expected.AppendCertsFromPEM(caCert))
+		require.NotNil(t, tr.TLSClientConfig.RootCAs)
+		assert.True(t, expected.Equal(tr.TLSClientConfig.RootCAs))
+	})
+
+	t.Run("not a certificate", func(t *testing.T) {
+		_, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{
+			UseSDKCreds: true,
+			CASecret: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "my-minio-ca"},
+				Key:                  "invalid",
+			},
+		}})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+	})
+
+	t.Run("absent", func(t *testing.T) {
+		driver, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{UseSDKCreds: true}})
+		require.NoError(t, err)
+		assert.Empty(t, driver.TrustedCA)
+
+		tr, err := driver.newTransport(S3ClientOpts{Secure: true})
+		require.NoError(t, err)
+		untrusted := x509.NewCertPool()
+		require.True(t, untrusted.AppendCertsFromPEM(caCert))
+		assert.False(t, untrusted.Equal(tr.TLSClientConfig.RootCAs))
+	})
+}
+
+// TestGetArtifactDriver_Transport verifies the transport's timeouts and connection limits follow the configuration
+func TestGetArtifactDriver_Transport(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+
+	t.Run("Configured", func(t *testing.T) {
+		config, err := parsePluginConfiguration(ctx, "useSDKCreds: true\ndialTimeoutSeconds: 3\ntlsHandshakeTimeoutSeconds: 4\nidleConnTimeoutSeconds: 15\nmaxIdleConnsPerHost: 8\nmaxConnections: 64\nmaxIdleConnections: 32\n")
+		require.NoError(t, err)
+		require.NoError(t, validatePluginConfig(config))
+		driver, err := getArtifactDriver(ctx, config)
+		require.NoError(t, err)
+
+		tr, err := driver.newTransport(S3ClientOpts{Secure: true})
+		require.NoError(t, err)
+		assert.Equal(t, 3*time.Second, driver.dialer().Timeout)
+		assert.Equal(t, 4*time.Second, tr.TLSHandshakeTimeout)
+		assert.Equal(t, 15*time.Second, tr.IdleConnTimeout)
+		assert.Equal(t, 8, tr.MaxIdleConnsPerHost)
+		assert.Equal(t, 64, tr.MaxConnsPerHost)
+		assert.Equal(t, 32, tr.MaxIdleConns)
+	})
+
+	t.Run("Max connections below idle per host", func(t *testing.T) {
+		driver, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{UseSDKCreds: true}, MaxConnections: 4})
+		require.NoError(t, err)
+
+		tr, err := driver.newTransport(S3ClientOpts{Secure: true})
+		require.NoError(t, err)
+		assert.Equal(t, 4, tr.MaxConnsPerHost)
+		assert.Equal(t, 4, tr.MaxIdleConnsPerHost)
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		driver, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{UseSDKCreds: true}})
+		require.NoError(t, err)
+
+		tr, err := driver.newTransport(S3ClientOpts{Secure: true})
+		require.NoError(t, err)
+		assert.Equal(t, DefaultDialTimeout, driver.dialer().Timeout)
+		assert.Equal(t, DefaultTLSHandshakeTimeout, tr.TLSHandshakeTimeout)
+		assert.Equal(t, DefaultIdleConnTimeout, tr.IdleConnTimeout)
+		assert.Equal(t, DefaultMaxIdleConnsPerHost, tr.MaxIdleConnsPerHost)
+		assert.Equal(t, DefaultMaxIdleConnections, tr.MaxIdleConns)
+		assert.Zero(t, tr.MaxConnsPerHost)
+	})
+
+	t.Run("Negative", func(t *testing.T) {
+		err := validatePluginConfig(&PluginConfig{IdleConnTimeoutSeconds: -1})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		assert.ErrorContains(t, err, "idleConnTimeoutSeconds must not be negative")
+
+		err = validatePluginConfig(&PluginConfig{MaxConnections: -1})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		assert.ErrorContains(t, err, "maxConnections must be positive")
+	})
+
+	t.Run("Idle above max", func(t *testing.T) {
+		err := validatePluginConfig(&PluginConfig{MaxConnections: 8, MaxIdleConnections: 16})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		assert.ErrorContains(t, err, "maxIdleConnections (16) must not exceed maxConnections (8)")
+	})
+}
+
+// TestGetArtifactDriver_Retry verifies the retry mode and attempts configure the retryer of the SDK clients
+// resolving credentials, while the S3 requests minio sends only take the attempts
+func TestGetArtifactDriver_Retry(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	clientOptions := func(t *testing.T, configYAML string) sts.Options {
+		t.Helper()
+		config, err := parsePluginConfiguration(ctx, configYAML)
+		require.NoError(t, err)
+		require.NoError(t, validatePluginConfig(config))
+		driver, err := getArtifactDriver(ctx, config)
+		require.NoError(t, err)
+		cfg, err := awsconfig.LoadDefaultConfig(ctx, awsRetryOptions(S3ClientOpts{RetryMode: driver.RetryMode, RetryMaxAttempts: driver.RetryMaxAttempts})...)
+		require.NoError(t, err)
+		return sts.NewFromConfig(cfg).Options()
+	}
+
+	t.Run("Standard", func(t *testing.T) {
+		opts := clientOptions(t, "useSDKCreds: true\nretryMode: standard\nretryMaxAttempts: 5\n")
+		assert.Equal(t, aws.RetryModeStandard, opts.RetryMode)
+		assert.Equal(t, 5, opts.Retryer.MaxAttempts())
+	})
+
+	t.Run("Adaptive", func(t *testing.T) {
+		opts := clientOptions(t, "useSDKCreds: true\nretryMode: adaptive\nretryMaxAttempts: 7\n")
+		assert.Equal(t, aws.RetryModeAdaptive, opts.RetryMode)
+		assert.Equal(t, 7, opts.Retryer.MaxAttempts())
+	})
+
+	t.Run("Unset", func(t *testing.T) {
+		t.Setenv("AWS_RETRY_MODE", "")
+		t.Setenv("AWS_MAX_ATTEMPTS", "")
+		opts := clientOptions(t, "useSDKCreds: true\n")
+		assert.Equal(t, aws.RetryModeStandard, opts.RetryMode)
+		assert.Equal(t, retry.DefaultMaxAttempts, opts.Retryer.MaxAttempts())
+	})
+
+	t.Run("S3 requests", func(t *testing.T) {
+		for _, mode := range []string{"standard", "adaptive"} {
+			f := newFakeObjectStore(t)
+			f.slowDown = true
+			driver := f.driver()
+			driver.RetryMode = mode
+			driver.RetryMaxAttempts = 2
+			s3cli, err := driver.newS3Client(ctx)
+			require.NoError(t, err)
+			_, err = s3cli.StatObject("my-bucket", "key")
+			require.Error(t, err)
+			assert.Equal(t, 2, f.requests, mode)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		err := validatePluginConfig(&PluginConfig{RetryMode: "aggressive"})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		assert.ErrorContains(t, err, "retryMode must be standard or adaptive")
+		err = validatePluginConfig(&PluginConfig{RetryMaxAttempts: -1})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		assert.ErrorContains(t, err, "retryMaxAttempts must be at least 1")
+	})
+}
+
+// TestGetArtifactDriver_ClientCertificate verifies the mutual TLS client certificate is resolved and presented by the transport
+func TestGetArtifactDriver_ClientCertificate(t *testing.T) {
+	clientCert, clientKey := generateCertPEM(t)
+	otherCert, _ := generateCertPEM(t)
+	setNamespace(t, "argo")
+	setClientsetConstructor(t, func() (kubernetes.Interface, error) {
+		return fake.NewClientset(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-minio-client", Namespace: "argo"},
+			Data: map[string][]byte{
+				"tls.crt":   clientCert,
+				"tls.key":   clientKey,
+				"other.crt": otherCert,
+			},
+		}), nil
+	})
+	ctx := logging.TestContext(t.Context())
+	selector := func(key string) *corev1.SecretKeySelector {
+		return &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "my-minio-client"},
+			Key:                  key,
+		}
+	}
+
+	t.Run("resolved", func(t *testing.T) {
+		driver, err := getArtifactDriver(ctx, &PluginConfig{
+			S3Bucket:         wfv1.S3Bucket{UseSDKCreds: true},
+			ClientCertSecret: selector("tls.crt"),
+			ClientKeySecret:  selector("tls.key"),
+		})
+		require.NoError(t, err)
+
+		tr, err := driver.newTransport(S3ClientOpts{Secure: true})
+		require.NoError(t, err)
+		require.Len(t, tr.TLSClientConfig.Certificates, 1)
+		block, _ := pem.Decode(clientCert)
+		assert.Equal(t, block.Bytes, tr.TLSClientConfig.Certificates[0].Certificate[0])
+	})
+
+	t.Run("mismatched key pair", func(t *testing.T) {
+		_, err := getArtifactDriver(ctx, &PluginConfig{
+			S3Bucket:         wfv1.S3Bucket{UseSDKCreds: true},
+			ClientCertSecret: selector("other.crt"),
+			ClientKeySecret:  selector("tls.key"),
+		})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		assert.Contains(t, err.Error(), "not a valid key pair")
+	})
+
+	t.Run("only one provided", func(t *testing.T) {
+		err := validatePluginConfig(&PluginConfig{ClientCertSecret: selector("tls.crt")})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		assert.Contains(t, err.Error(), "clientCertSecret and clientKeySecret must be set together")
+
+		err = validatePluginConfig(&PluginConfig{ClientKeySecret: selector("tls.key")})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+	})
+}
+
+// TestGetArtifactDriver_VerifyChecksum verifies the checksum algorithm is validated and defaults to sha256
+func TestGetArtifactDriver_VerifyChecksum(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+
+	config, err := parsePluginConfiguration(ctx, "useSDKCreds: true\nverifyChecksum: true\n")
+	require.NoError(t, err)
+	require.NoError(t, validatePluginConfig(config))
+	driver, err := getArtifactDriver(ctx, config)
+	require.NoError(t, err)
+	assert.True(t, driver.VerifyChecksum)
+	assert.Equal(t, ChecksumSHA256, driver.ChecksumAlgorithm)
+
+	config, err = parsePluginConfiguration(ctx, "useSDKCreds: true\nuploadChecksum: true\nchecksumAlgorithm: crc32c\n")
+	require.NoError(t, err)
+	require.NoError(t, validatePluginConfig(config))
+	driver, err = getArtifactDriver(ctx, config)
+	require.NoError(t, err)
+	assert.True(t, driver.UploadChecksum)
+	assert.Equal(t, ChecksumCRC32C, driver.ChecksumAlgorithm)
+
+	err = validatePluginConfig(&PluginConfig{VerifyChecksum: true, ChecksumAlgorithm: "md5"})
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	assert.Contains(t, err.Error(), `got "md5"`)
+}
+
+// TestExpandKeyFormat verifies the keyFormat placeholders are validated and expanded from the artifact key and environment
+func TestExpandKeyFormat(t *testing.T) {
+	t.Setenv(common.EnvVarWorkflowName, "my-wf")
+	t.Setenv(common.EnvVarPodName, "my-wf-main-123")
+
+	for keyFormat, expected := range map[string]string{
+		"{{workflow.name}}/{{pod.name}}/{{key}}": "my-wf/my-wf-main-123/hello-art.tar.gz",
+		"archive/{{ workflow.name }}/{{key}}":    "archive/my-wf/hello-art.tar.gz",
+		"artifacts/{{workflow.name}}-output.tgz": "artifacts/my-wf-output.tgz",
+		"artifacts/fixed/hello-art.tar.gz":       "artifacts/fixed/hello-art.tar.gz",
+	} {
+		t.Run(keyFormat, func(t *testing.T) {
+			require.NoError(t, validateKeyFormat(keyFormat))
+			key, err := expandKeyFormat(keyFormat, "hello-art.tar.gz")
+			require.NoError(t, err)
+			assert.Equal(t, expected, key)
+		})
+	}
+
+	t.Run("Through the plugin configuration", func(t *testing.T) {
+		ctx := logging.TestContext(t.Context())
+		_, artifact, err := DriverAndArtifactFromConfig(ctx, "bucket: my-bucket\nuseSDKCreds: true\nkeyFormat: \"{{workflow.name}}/{{key}}\"\n", "hello-art.tar.gz")
+		require.NoError(t, err)
+		assert.Equal(t, "my-wf/hello-art.tar.gz", artifact.S3.Key)
+	})
+
+	t.Run("Unsupported placeholder", func(t *testing.T) {
+		err := validatePluginConfig(&PluginConfig{KeyFormat: "{{workflow.uid}}/{{key}}"})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		assert.Contains(t, err.Error(), "{{workflow.uid}}")
+	})
+
+	t.Run("Unset variable", func(t *testing.T) {
+		t.Setenv(common.EnvVarPodName, "")
+		_, err := expandKeyFormat("{{pod.name}}/{{key}}", "hello-art.tar.gz")
+		require.ErrorContains(t, err, common.EnvVarPodName+" is not set")
+	})
+}
+
+// TestApplyConfigDefaults verifies the artifact's configuration wins over the defaults file field by field
+func TestApplyConfigDefaults(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	defaultsFile := filepath.Join(t.TempDir(), "defaults.yaml")
+	require.NoError(t, os.WriteFile(defaultsFile, []byte(`
+endpoint: s3.eu-west-1.amazonaws.com
+region: eu-west-1
+storageClass: STANDARD_IA
+accessKeySecret:
+  name: shared-cred
+  key: accesskey
+secretKeySecret:
+  name: shared-cred
+  key: secretkey
+`), 0o600))
+	t.Setenv(envVarPluginDefaultsFile, defaultsFile)
+
+	parse := func(t *testing.T, configYAML string) *PluginConfig {
+		t.Helper()
+		merged, err := applyConfigDefaults(configYAML)
+		require.NoError(t, err)
+		config, err := parsePluginConfiguration(ctx, merged)
+		require.NoError(t, err)
+		return config
+	}
+
+	t.Run("Scalar fields", func(t *testing.T) {
+		config := parse(t, "bucket: team-a\nregion: us-east-1\n")
+		assert.Equal(t, "team-a", config.Bucket)
+		assert.Equal(t, "us-east-1", config.Region)
+		assert.Equal(t, "s3.eu-west-1.amazonaws.com", config.Endpoint)
+		assert.Equal(t, "STANDARD_IA", config.StorageClass)
+	})
+
+	t.Run("Nested secret selector", func(t *testing.T) {
+		config := parse(t, "bucket: team-a\naccessKeySecret:\n  name: team-a-cred\n")
+		require.NotNil(t, config.AccessKeySecret)
+		assert.Equal(t, "team-a-cred", config.AccessKeySecret.Name)
+		assert.Equal(t, "accesskey", config.AccessKeySecret.Key)
+		require.NotNil(t, config.SecretKeySecret)
+		assert.Equal(t, "shared-cred", config.SecretKeySecret.Name)
+	})
+
+	t.Run("Empty configuration", func(t *testing.T) {
+		config := parse(t, "")
+		assert.Equal(t, "eu-west-1", config.Region)
+	})
+
+	t.Run("Through the plugin configuration", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(defaultsFile, []byte("region: eu-west-1\nuseSDKCreds: true\n"), 0o600))
+		driver, artifact, err := DriverAndArtifactFromConfig(ctx, "bucket: team-a\n", "hello-art.tar.gz")
+		require.NoError(t, err)
+		assert.Equal(t, "eu-west-1", driver.Region)
+		assert.Equal(t, "team-a", artifact.S3.Bucket)
+	})
+
+	t.Run("Missing defaults file", func(t *testing.T) {
+		t.Setenv(envVarPluginDefaultsFile, filepath.Join(t.TempDir(), "missing.yaml"))
+		_, err := applyConfigDefaults("bucket: team-a\n")
+		require.ErrorContains(t, err, "failed to read plugin defaults")
+	})
+
+	t.Run("Unset", func(t *testing.T) {
+		t.Setenv(envVarPluginDefaultsFile, "")
+		merged, err := applyConfigDefaults("bucket: team-a\n")
+		require.NoError(t, err)
+		assert.Equal(t, "bucket: team-a\n", merged)
+	})
+}
+
+func TestValidateTempDir(t *testing.T) {
+	require.NoError(t, validateTempDir(""))
+	dir := t.TempDir()
+	require.NoError(t, validateTempDir(dir))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the write check's file is removed")
+
+	err = validateTempDir("tmp")
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	assert.ErrorContains(t, err, "absolute path")
+	err = validateTempDir(filepath.Join(dir, "missing"))
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	assert.ErrorContains(t, err, "not writable")
+}
+
+func TestValidateDefaults(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	defaultsFile := filepath.Join(t.TempDir(), "defaults.yaml")
+	t.Setenv(envVarPluginDefaultsFile, defaultsFile)
+
+	require.NoError(t, os.WriteFile(defaultsFile, []byte("tempDir: "+t.TempDir()+"\n"), 0o600))
+	require.NoError(t, ValidateDefaults(ctx))
+
+	require.NoError(t, os.WriteFile(defaultsFile, []byte("tempDir: /nonexistent/tmp\n"), 0o600))
+	require.ErrorIs(t, ValidateDefaults(ctx), ErrInvalidConfig)
+
+	t.Setenv(envVarPluginDefaultsFile, "")
+	require.NoError(t, ValidateDefaults(ctx))
+}
+
+// TestDecodeConfiguration verifies base64-encoded YAML is decoded and anything else is left to the parser
+func TestDecodeConfiguration(t *testing.T) {
+	configYAML := "bucket: my-bucket\nuseSDKCreds: true\n"
+	decode := func(t *testing.T, configYAML string) (string, string) {
+		t.Helper()
+		var buf bytes.Buffer
+		ctx := logging.WithLogger(t.Context(), logging.NewSlogLoggerCustom(logging.Debug, logging.JSON, &buf))
+		return decodeConfiguration(ctx, configYAML), buf.String()
+	}
+
+	t.Run("Raw YAML", func(t *testing.T) {
+		decoded, logs := decode(t, configYAML)
+		assert.Equal(t, configYAML, decoded)
+		assert.Contains(t, logs, "Plugin configuration is YAML")
+	})
+
+	t.Run("Base64-encoded YAML", func(t *testing.T) {
+		decoded, logs := decode(t, base64.StdEncoding.EncodeToString([]byte(configYAML))+"\n")
+		assert.Equal(t, configYAML, decoded)
+		assert.Contains(t, logs, "Plugin configuration is base64-encoded YAML, decoding it")
+
+		ctx := logging.TestContext(t.Context())
+		driver, artifact, err := DriverAndArtifactFromConfig(ctx, base64.StdEncoding.EncodeToString([]byte(configYAML)), "hello-art.tar.gz")
+		require.NoError(t, err)
+		assert.True(t, driver.UseSDKCreds)
+		assert.Equal(t, "my-bucket", artifact.S3.Bucket)
+	})
+
+	t.Run("Invalid input", func(t *testing.T) {
+		for _, input := range []string{
+			"bucket: [unterminated",
+			base64.StdEncoding.EncodeToString([]byte("just a string")),
+			base64.StdEncoding.EncodeToString([]byte{0xff, 0xfe, 0x00}),
+		} {
+			decoded, logs := decode(t, input)
+			assert.Equal(t, input, decoded)
+			assert.Contains(t, logs, "neither a YAML mapping nor base64-encoded YAML")
+		}
+
+		ctx := logging.TestContext(t.Context())
+		_, _, err := DriverAndArtifactFromConfig(ctx, "bucket: [unterminated", "hello-art.tar.gz")
+		require.ErrorIs(t, err, ErrInvalidConfig)
+	})
+}
+
+// TestKeyPrefix verifies keys are scoped to KEY_PREFIX and can't leave it, nor can a configuration change it
+func TestKeyPrefix(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	fromConfig := func(configYAML, key string) (*ArtifactDriver, *wfv1.Artifact, error) {
+		return DriverAndArtifactFromConfig(ctx, "bucket: shared\nuseSDKCreds: true\nregion: us-east-1\n"+configYAML, key)
+	}
+
+	for name, tc := range map[string]struct {
+		keyPrefix string
+		key       string
+		expected  string
+	}{
+		"file":               {keyPrefix: "tenant-a", key: "out/a.txt", expected: "tenant-a/out/a.txt"},
+		"directory":          {keyPrefix: "tenant-a/", key: "out/", expected: "tenant-a/out/"},
+		"extra slashes":      {keyPrefix: "/tenant-a//team/", key: "out/a.txt", expected: "tenant-a/team/out/a.txt"},
+		"empty key":          {keyPrefix: "tenant-a", key: "", expected: "tenant-a/"},
+		"leading slash":      {keyPrefix: "tenant-a", key: "/out/a.txt", expected: "tenant-a/out/a.txt"},
+		"dots in a filename": {keyPrefix: "tenant-a", key: "out/a..txt", expected: "tenant-a/out/a..txt"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(envVarKeyPrefix, tc.keyPrefix)
+			driver, artifact, err := fromConfig("", tc.key)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, artifact.S3.Key)
+			require.NoError(t, driver.checkKeyPrefix(artifact))
+		})
+	}
+
+	t.Setenv(envVarKeyPrefix, "tenant-a")
+	for name, key := range map[string]string{
+		"parent":          "../tenant-b/secret.txt",
+		"nested parent":   "out/../../tenant-b/secret.txt",
+		"trailing parent": "out/..",
+	} {
+		t.Run("rejects "+name, func(t *testing.T) {
+			_, _, err := fromConfig("", key)
+			require.ErrorIs(t, err, ErrInvalidConfig)
+			assert.ErrorContains(t, err, "must not contain ..")
+		})
+	}
+
+	t.Run("Configuration can't change the prefix", func(t *testing.T) {
+		_, _, err := fromConfig("keyPrefix: tenant-b\n", "out/a.txt")
+		require.ErrorIs(t, err, ErrInvalidConfig)
+
+		t.Setenv(envVarConfigStrict, "false")
+		_, artifact, err := fromConfig("keyPrefix: \"\"\n", "out/a.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "tenant-a/out/a.txt", artifact.S3.Key)
+	})
+
+	t.Run("Invalid prefix", func(t *testing.T) {
+		t.Setenv(envVarKeyPrefix, "tenant-a/../tenant-b")
+		require.ErrorIs(t, ValidateKeyPrefix(), ErrInvalidConfig)
+		_, _, err := fromConfig("", "out/a.txt")
+		require.ErrorIs(t, err, ErrInvalidConfig)
+	})
+
+	t.Run("Unset", func(t *testing.T) {
+		t.Setenv(envVarKeyPrefix, "")
+		require.NoError(t, ValidateKeyPrefix())
+		_, artifact, err := fromConfig("", "../out/a.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "../out/a.txt", artifact.S3.Key)
+	})
+
+	t.Run("List and delete outside the prefix", func(t *testing.T) {
+		driver, _, err := fromConfig("", "out/")
+		require.NoError(t, err)
+		for _, key := range []string{"tenant-ab/out/", "tenant-a/../tenant-b/out/"} {
+			outside := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+				S3Bucket: wfv1.S3Bucket{Bucket: "shared"},
+				Key:      key,
+			}}}
+
+			_, err = driver.ListObjects(ctx, outside)
+			assert.True(t, argoerrs.IsCode(argoerrs.CodeForbidden, err), key)
+			_, _, err = driver.ListObjectsPage(ctx, outside, 10, "")
+			assert.True(t, argoerrs.IsCode(argoerrs.CodeForbidden, err), key)
+			err = driver.Delete(ctx, outside)
+			assert.True(t, argoerrs.IsCode(argoerrs.CodeForbidden, err), key)
+		}
+	})
+}
+
+// TestGetArtifactDriver_Anonymous verifies anonymous access needs no credentials and can't be combined with them
+func TestGetArtifactDriver_Anonymous(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	t.Setenv(envVarAccessKeyID, "")
+	t.Setenv(envVarSecretAccessKey, "")
+
+	config, err := parsePluginConfiguration(ctx, "bucket: public-bucket\nanonymous: true\n")
+	require.NoError(t, err)
+	require.NoError(t, validatePluginConfig(config))
+	driver, err := getArtifactDriver(ctx, config)
+	require.NoError(t, err)
+	assert.True(t, driver.Anonymous)
+	assert.Empty(t, driver.AccessKey)
+
+	creds, err := GetCredentials(ctx, S3ClientOpts{Anonymous: true})
+	require.NoError(t, err)
+	value, err := creds.Get()
+	require.NoError(t, err)
+	assert.True(t, value.SignerType.IsAnonymous())
+
+	err = validatePluginConfig(&PluginConfig{Anonymous: true, S3Bucket: wfv1.S3Bucket{
+		UseSDKCreds:     true,
+		AccessKeySecret: &corev1.SecretKeySelector{Key: "accesskey"},
+	}})
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	assert.Contains(t, err.Error(), "anonymous can't be combined with useSDKCreds, accessKeySecret")
+}
+
+// TestGetArtifactDriver_Multipart verifies the multipart defaults and that the part size and threshold are validated
+func TestGetArtifactDriver_Multipart(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+
+	driver, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{Region: "us-east-1", UseSDKCreds: true}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(DefaultMultipartThreshold), driver.MultipartThreshold)
+	assert.Equal(t, int64(DefaultMultipartPartSize), driver.MultipartPartSize)
+	assert.Equal(t, DefaultMultipartConcurrency, driver.MultipartConcurrency)
+
+	config, err := parsePluginConfiguration(ctx, "useSDKCreds: true\nregion: us-east-1\nmultipartThresholdBytes: 134217728\nmultipartPartSizeBytes: 33554432\nmultipartConcurrency: 16\n")
+	require.NoError(t, err)
+	require.NoError(t, validatePluginConfig(config))
+	driver, err = getArtifactDriver(ctx, config)
+	require.NoError(t, err)
+	assert.Equal(t, int64(128*1024*1024), driver.MultipartThreshold)
+	assert.Equal(t, int64(32*1024*1024), driver.MultipartPartSize)
+	assert.Equal(t, 16, driver.MultipartConcurrency)
+
+	config, err = parsePluginConfiguration(ctx, "useSDKCreds: true\nregion: us-east-1\ndownloadConcurrency: 8\n")
+	require.NoError(t, err)
+	require.NoError(t, validatePluginConfig(config))
+	driver, err = getArtifactDriver(ctx, config)
+	require.NoError(t, err)
+	assert.Equal(t, 8, driver.DownloadConcurrency)
+
+	for name, config := range map[string]*PluginConfig{
+		"part size below 5MB":           {MultipartPartSizeBytes: minMultipartPartSize - 1},
+		"part size above 5GB":           {MultipartPartSizeBytes: maxMultipartPartSize + 1},
+		"threshold below part size":     {MultipartThresholdBytes: DefaultMultipartPartSize - 1},
+		"threshold above single PUT":    {MultipartThresholdBytes: maxMultipartPartSize + 1},
+		"concurrency above 32":          {MultipartConcurrency: maxMultipartConcurrency + 1},
+		"negative concurrency":          {MultipartConcurrency: -1},
+		"download concurrency above 32": {DownloadConcurrency: maxMultipartConcurrency + 1},
+		"negative download concurrency": {DownloadConcurrency: -1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.ErrorIs(t, validatePluginConfig(config), ErrInvalidConfig)
+		})
+	}
+}
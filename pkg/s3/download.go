@@ -0,0 +1,113 @@
+package s3
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+)
+
+// errRangesUnsupported reports the store returned the whole object when asked for its first range
+var errRangesUnsupported = errors.New("range requests are not supported")
+
+// getFileRanged downloads an object of at least MultipartThreshold bytes into f as ranges of MultipartPartSize
+// bytes, DownloadConcurrency at a time, each written into place in the file. Smaller objects are downloaded in a
+// single stream, as are objects from stores which ignore the range and return the whole object.
+func (s *s3client) getFileRanged(bucket, key string, f *os.File, sse encrypt.ServerSide) error {
+	info, err := s.minioClient.StatObject(s.ctx, bucket, key, s.getObjectOptions(sse))
+	if err != nil {
+		return err
+	}
+	if info.Size < s.MultipartThreshold || info.Size <= s.MultipartPartSize {
+		return s.getFileStream(bucket, key, f, sse)
+	}
+
+	err = s.downloadRanges(bucket, key, info, sse, f)
+	if errors.Is(err, errRangesUnsupported) {
+		logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"bucket": bucket, "key": key}).Info(s.ctx, "Store doesn't support range requests, downloaded in a single stream")
+		return nil
+	}
+	return err
+}
+
+// getFileStream downloads an object into f in a single stream
+func (s *s3client) getFileStream(bucket, key string, f *os.File, sse encrypt.ServerSide) error {
+	object, err := s.minioClient.GetObject(s.ctx, bucket, key, s.getObjectOptions(sse))
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+	_, err = io.Copy(f, object)
+	return err
+}
+
+// downloadRanges writes the object to f. The first range is downloaded alone, if the store returns the whole
+// object instead it is written and errRangesUnsupported returned.
+func (s *s3client) downloadRanges(bucket, key string, info minio.ObjectInfo, sse encrypt.ServerSide, f *os.File) error {
+	core := minio.Core{Client: s.minioClient}
+	getRange := func(start int64) error {
+		end := min(start+s.MultipartPartSize, info.Size) - 1
+		opts := s.getObjectOptions(sse)
+		// Every range must come from the same version of the object
+		if err := opts.SetMatchETag(info.ETag); err != nil {
+			return err
+		}
+		if err := opts.SetRange(start, end); err != nil {
+			return err
+		}
+		body, _, header, err := core.GetObject(s.ctx, bucket, key, opts)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		if header.Get("Content-Range") == "" {
+			if _, err := io.Copy(io.NewOffsetWriter(f, 0), body); err != nil {
+				return err
+			}
+			return errRangesUnsupported
+		}
+		n, err := io.Copy(io.NewOffsetWriter(f, start), body)
+		if err == nil && n != end-start+1 {
+			err = fmt.Errorf("range %d-%d of %s returned %d bytes", start, end, key, n)
+		}
+		return err
+	}
+
+	if err := getRange(0); err != nil {
+		return err
+	}
+
+	sem := make(chan struct{}, s.DownloadConcurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	for start := s.MultipartPartSize; start < info.Size; start += s.MultipartPartSize {
+		sem <- struct{}{}
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := getRange(start); err != nil {
+				mu.Lock()
+				firstErr = cmp.Or(firstErr, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
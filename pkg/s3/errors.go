@@ -2,6 +2,9 @@ package s3
 
 import (
 	"context"
+	"net/http"
+
+	"github.com/minio/minio-go/v7"
 
 	"github.com/argoproj/argo-workflows/v3/util/errors"
 	"github.com/argoproj/argo-workflows/v3/util/logging"
@@ -33,5 +36,16 @@ func isTransientS3Err(ctx context.Context, err error) bool {
 			return true
 		}
 	}
+	if isRetryableStatusCode(err) {
+		log.WithError(err).Error(ctx, "Transient S3 error")
+		return true
+	}
 	return errors.IsTransientErr(ctx, err)
 }
+
+// isRetryableStatusCode checks if an minio.ErrorResponse carries a server error or throttling HTTP status,
+// which covers S3 compatible stores that don't return one of the well known transient error codes
+func isRetryableStatusCode(err error) bool {
+	statusCode := minio.ToErrorResponse(err).StatusCode
+	return statusCode >= http.StatusInternalServerError || statusCode == http.StatusTooManyRequests
+}
@@ -24,6 +24,15 @@ func TestIsTransientS3Err(t *testing.T) {
 
 	nonTransientErr = minio.ErrorResponse{Code: "AccessDenied"}
 	assert.False(t, isTransientS3Err(ctx, nonTransientErr))
+
+	err = minio.ErrorResponse{Code: "BadGateway", StatusCode: 502}
+	assert.True(t, isTransientS3Err(ctx, err))
+
+	err = minio.ErrorResponse{Code: "TooManyRequests", StatusCode: 429}
+	assert.True(t, isTransientS3Err(ctx, err))
+
+	nonTransientErr = minio.ErrorResponse{Code: "AccessDenied", StatusCode: 403}
+	assert.False(t, isTransientS3Err(ctx, nonTransientErr))
 }
 
 func TestIsTransientOSSErr(t *testing.T) {
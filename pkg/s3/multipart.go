@@ -0,0 +1,167 @@
+package s3
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"k8s.io/client-go/util/retry"
+
+	argoerrs "github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+)
+
+// maxMultipartParts is the most parts a multipart upload can have, numbered from 1
+const maxMultipartParts = 10000
+
+// MultipartPart is a part uploaded with UploadPart, which CompleteMultipart needs to assemble the object
+type MultipartPart struct {
+	PartNumber int
+	ETag       string
+}
+
+// InitMultipart starts a multipart upload to the artifact's key and returns its upload ID. The object only exists
+// once CompleteMultipart has assembled the parts, an upload which won't be completed should be aborted with
+// AbortMultipart so S3 stops storing its parts.
+func (s3Driver *ArtifactDriver) InitMultipart(ctx context.Context, artifact *wfv1.Artifact) (uploadID string, err error) {
+	ctx, span := startSpan(ctx, "S3 InitMultipart", artifact)
+	defer func() { endSpan(span, err, "") }()
+
+	if err := s3Driver.checkWritable("InitMultipart"); err != nil {
+		return "", err
+	}
+	err = s3Driver.retryMultipart(ctx, "S3 InitMultipart", artifact, func(s3cli S3Client) error {
+		if err := createBucketIfNotPresent(ctx, s3cli, artifact); err != nil {
+			return err
+		}
+		uploadID, err = s3cli.NewMultipartUpload(artifact.S3.Bucket, artifact.S3.Key)
+		return err
+	})
+	return uploadID, err
+}
+
+// UploadPart uploads data as part partNumber, from 1 to 10000, of the multipart upload. Every part but the last
+// must be at least 5MiB. Uploading a part number again replaces the part.
+func (s3Driver *ArtifactDriver) UploadPart(ctx context.Context, artifact *wfv1.Artifact, uploadID string, partNumber int, data []byte) (part MultipartPart, err error) {
+	ctx, span := startSpan(ctx, "S3 UploadPart", artifact)
+	defer func() { endSpan(span, err, "") }()
+
+	if partNumber < 1 || partNumber > maxMultipartParts {
+		return MultipartPart{}, argoerrs.Errorf(argoerrs.CodeBadRequest, "part number %d is outside of 1 to %d", partNumber, maxMultipartParts)
+	}
+	err = s3Driver.retryMultipart(ctx, "S3 UploadPart", artifact, func(s3cli S3Client) error {
+		etag, err := s3cli.PutObjectPart(artifact.S3.Bucket, artifact.S3.Key, uploadID, partNumber, data)
+		part = MultipartPart{PartNumber: partNumber, ETag: etag}
+		return err
+	})
+	return part, err
+}
+
+// CompleteMultipart assembles the uploaded parts, in part number order, into the artifact's object
+func (s3Driver *ArtifactDriver) CompleteMultipart(ctx context.Context, artifact *wfv1.Artifact, uploadID string, parts []MultipartPart) (err error) {
+	ctx, span := startSpan(ctx, "S3 CompleteMultipart", artifact)
+	defer func() { endSpan(span, err, "") }()
+
+	if len(parts) == 0 {
+		return argoerrs.New(argoerrs.CodeBadRequest, "a multipart upload needs at least one part to complete")
+	}
+	parts = slices.SortedFunc(slices.Values(parts), func(a, b MultipartPart) int { return cmp.Compare(a.PartNumber, b.PartNumber) })
+	return s3Driver.retryMultipart(ctx, "S3 CompleteMultipart", artifact, func(s3cli S3Client) error {
+		return s3cli.CompleteMultipartUpload(artifact.S3.Bucket, artifact.S3.Key, uploadID, parts)
+	})
+}
+
+// AbortMultipart abandons the multipart upload, deleting the parts uploaded so far
+func (s3Driver *ArtifactDriver) AbortMultipart(ctx context.Context, artifact *wfv1.Artifact, uploadID string) (err error) {
+	ctx, span := startSpan(ctx, "S3 AbortMultipart", artifact)
+	defer func() { endSpan(span, err, "") }()
+
+	return s3Driver.retryMultipart(ctx, "S3 AbortMultipart", artifact, func(s3cli S3Client) error {
+		return s3cli.AbortMultipartUpload(artifact.S3.Bucket, artifact.S3.Key, uploadID)
+	})
+}
+
+// retryMultipart runs a multipart upload request with a new client, retrying it on transient errors
+func (s3Driver *ArtifactDriver) retryMultipart(ctx context.Context, operation string, artifact *wfv1.Artifact, request func(S3Client) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	log := logging.RequireLoggerFromContext(ctx)
+	return retry.OnError(s3Driver.retryBackoff(ctx), func(err error) bool {
+		return isTransientS3Err(ctx, err)
+	}, func() error {
+		log.WithField("key", artifact.S3.Key).Info(ctx, operation)
+		s3cli, err := s3Driver.newS3Client(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to create new S3 client: %w", err)
+		}
+		return request(s3cli)
+	})
+}
+
+// NewMultipartUpload starts a multipart upload to the key with the storage class, encryption, tags and content type
+// PutFile uploads with, returning its upload ID. CreateMultipartUpload doesn't take If-Match or If-None-Match, so
+// unlike PutFile no conditions are sent.
+func (s *s3client) NewMultipartUpload(bucket, key string) (string, error) {
+	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "key": key}).Info(s.ctx, "Starting multipart upload to s3")
+
+	encOpts, err := s.EncryptOpts.buildServerSideEnc(bucket, key)
+	if err != nil {
+		return "", err
+	}
+	return minio.Core{Client: s.minioClient}.NewMultipartUpload(s.ctx, bucket, key, minio.PutObjectOptions{
+		StorageClass:         s.StorageClass,
+		ServerSideEncryption: encOpts,
+		UserTags:             s.ObjectTags,
+		ContentType:          s.ContentType,
+	})
+}
+
+// PutObjectPart uploads data as a part of the multipart upload, returning the part's ETag
+func (s *s3client) PutObjectPart(bucket, key, uploadID string, partNumber int, data []byte) (string, error) {
+	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "key": key, "part": partNumber, "size": len(data)}).Info(s.ctx, "Uploading part to s3")
+
+	sse, err := s.customerKey(bucket, key)
+	if err != nil {
+		return "", err
+	}
+	part, err := minio.Core{Client: s.minioClient}.PutObjectPart(s.ctx, bucket, key, uploadID, partNumber, bytes.NewReader(data), int64(len(data)), minio.PutObjectPartOptions{SSE: sse, DisableContentSha256: s.RequesterPays})
+	return part.ETag, err
+}
+
+// CompleteMultipartUpload assembles the parts, which must be in part number order, into the object
+func (s *s3client) CompleteMultipartUpload(bucket, key, uploadID string, parts []MultipartPart) error {
+	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "key": key, "parts": len(parts)}).Info(s.ctx, "Completing multipart upload to s3")
+
+	sse, err := s.customerKey(bucket, key)
+	if err != nil {
+		return err
+	}
+	completeParts := make([]minio.CompletePart, 0, len(parts))
+	for _, part := range parts {
+		completeParts = append(completeParts, minio.CompletePart{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+	_, err = minio.Core{Client: s.minioClient}.CompleteMultipartUpload(s.ctx, bucket, key, uploadID, completeParts, minio.PutObjectOptions{ServerSideEncryption: sse})
+	return err
+}
+
+// AbortMultipartUpload abandons the multipart upload, deleting its parts
+func (s *s3client) AbortMultipartUpload(bucket, key, uploadID string) error {
+	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "key": key}).Info(s.ctx, "Aborting multipart upload to s3")
+
+	return minio.Core{Client: s.minioClient}.AbortMultipartUpload(s.ctx, bucket, key, uploadID)
+}
+
+// customerKey returns the SSE-C key for the key, which unlike S3 or KMS managed keys has to be sent with every
+// part of a multipart upload, or nil when SSE-C isn't used
+func (s *s3client) customerKey(bucket, key string) (encrypt.ServerSide, error) {
+	sse, err := s.EncryptOpts.buildServerSideEnc(bucket, key)
+	if err != nil || sse == nil || sse.Type() != encrypt.SSEC {
+		return nil, err
+	}
+	return sse, nil
+}
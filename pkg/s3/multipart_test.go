@@ -0,0 +1,84 @@
+package s3
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	argoerrs "github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+)
+
+// TestMultipart uploads parts through a multipart upload, and verifies completing it assembles them in part
+// number order while aborting it leaves no object
+func TestMultipart(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	f := newFakeObjectStore(t)
+	driver := f.driver()
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: "logs/main.log"}}}
+
+	t.Run("Complete", func(t *testing.T) {
+		uploadID, err := driver.InitMultipart(ctx, artifact)
+		require.NoError(t, err)
+		second, err := driver.UploadPart(ctx, artifact, uploadID, 2, []byte("second chunk"))
+		require.NoError(t, err)
+		first, err := driver.UploadPart(ctx, artifact, uploadID, 1, []byte("first chunk, "))
+		require.NoError(t, err)
+		assert.Equal(t, MultipartPart{PartNumber: 1, ETag: "etag-1"}, first)
+
+		require.NoError(t, driver.CompleteMultipart(ctx, artifact, uploadID, []MultipartPart{second, first}))
+		var buf bytes.Buffer
+		require.NoError(t, driver.LoadToWriter(ctx, artifact, &buf))
+		assert.Equal(t, "first chunk, second chunk", buf.String())
+		assert.Empty(t, f.uploads)
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		aborted := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: "logs/aborted.log"}}}
+		uploadID, err := driver.InitMultipart(ctx, aborted)
+		require.NoError(t, err)
+		_, err = driver.UploadPart(ctx, aborted, uploadID, 1, []byte("never completed"))
+		require.NoError(t, err)
+
+		require.NoError(t, driver.AbortMultipart(ctx, aborted, uploadID))
+		assert.Empty(t, f.uploads)
+		assert.NotContains(t, f.objects, "/my-bucket/logs/aborted.log")
+		_, err = driver.UploadPart(ctx, aborted, uploadID, 2, []byte("too late"))
+		assert.True(t, IsS3ErrCode(err, "NoSuchUpload"))
+	})
+
+	t.Run("Conditional", func(t *testing.T) {
+		driver := f.driver()
+		driver.IfNoneMatch = "*"
+		uploadID, err := driver.InitMultipart(ctx, artifact)
+		require.NoError(t, err)
+		require.NoError(t, driver.AbortMultipart(ctx, artifact, uploadID))
+	})
+
+	t.Run("Create bucket", func(t *testing.T) {
+		created := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "new-bucket", CreateBucketIfNotPresent: &wfv1.CreateS3BucketOptions{}}, Key: "logs/main.log"}}}
+		uploadID, err := driver.InitMultipart(ctx, created)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"new-bucket"}, f.buckets)
+		require.NoError(t, driver.AbortMultipart(ctx, created, uploadID))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := driver.UploadPart(ctx, artifact, "upload-id", 0, []byte("data"))
+		assert.True(t, argoerrs.IsCode(argoerrs.CodeBadRequest, err))
+		_, err = driver.UploadPart(ctx, artifact, "upload-id", maxMultipartParts+1, []byte("data"))
+		assert.True(t, argoerrs.IsCode(argoerrs.CodeBadRequest, err))
+		err = driver.CompleteMultipart(ctx, artifact, "upload-id", nil)
+		assert.True(t, argoerrs.IsCode(argoerrs.CodeBadRequest, err))
+	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		driver := f.driver()
+		driver.Anonymous = true
+		_, err := driver.InitMultipart(ctx, artifact)
+		assert.True(t, argoerrs.IsCode(argoerrs.CodeForbidden, err))
+	})
+}
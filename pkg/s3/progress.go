@@ -0,0 +1,65 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+)
+
+// countingReader counts the bytes read through it and, when interval is positive,
+// logs the upload progress at most once per interval
+type countingReader struct {
+	reader   io.Reader
+	total    int64
+	size     int64
+	key      string
+	interval time.Duration
+	start    time.Time
+	lastLog  time.Time
+	// nolint: containedctx
+	ctx context.Context
+}
+
+func newCountingReader(ctx context.Context, reader io.Reader, key string, size int64, interval time.Duration) *countingReader {
+	now := time.Now()
+	return &countingReader{
+		reader:   reader,
+		size:     size,
+		key:      key,
+		interval: interval,
+		start:    now,
+		lastLog:  now,
+		ctx:      ctx,
+	}
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.total += int64(n)
+	if r.interval > 0 && time.Since(r.lastLog) >= r.interval {
+		r.logProgress()
+	}
+	return n, err
+}
+
+// Total returns the number of bytes read so far
+func (r *countingReader) Total() int64 {
+	return r.total
+}
+
+func (r *countingReader) logProgress() {
+	r.lastLog = time.Now()
+	elapsed := r.lastLog.Sub(r.start).Seconds()
+	var rate float64
+	if elapsed > 0 {
+		rate = float64(r.total) / elapsed
+	}
+	logging.RequireLoggerFromContext(r.ctx).WithFields(logging.Fields{
+		"key":            r.key,
+		"bytesUploaded":  r.total,
+		"totalBytes":     r.size,
+		"bytesPerSecond": int64(rate),
+	}).Info(r.ctx, "Upload progress")
+}
@@ -0,0 +1,63 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+)
+
+func TestCountingReader(t *testing.T) {
+	data := bytes.Repeat([]byte("a"), 3*1024*1024+17)
+
+	for name, interval := range map[string]time.Duration{
+		"Progress disabled": 0,
+		"Progress enabled":  time.Nanosecond,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			ctx := logging.WithLogger(t.Context(), logging.NewSlogLoggerCustom(logging.Info, logging.JSON, &buf))
+			reader := newCountingReader(ctx, bytes.NewReader(data), "my-key", int64(len(data)), interval)
+			n, err := io.Copy(io.Discard, reader)
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(data)), n)
+			assert.Equal(t, int64(len(data)), reader.Total())
+
+			progress := progressEntries(t, &buf)
+			if interval == 0 {
+				assert.Empty(t, progress)
+				return
+			}
+			require.NotEmpty(t, progress, "a progress line should be logged")
+			for _, entry := range progress {
+				assert.Equal(t, "my-key", entry["key"])
+				assert.Positive(t, entry["bytesUploaded"])
+				assert.LessOrEqual(t, entry["bytesUploaded"], float64(len(data)))
+				assert.InDelta(t, float64(len(data)), entry["totalBytes"], 0)
+			}
+		})
+	}
+}
+
+// progressEntries returns the upload progress entries logged as JSON to buf
+func progressEntries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		if entry["msg"] == "Upload progress" {
+			entries = append(entries, entry)
+		}
+	}
+	require.NoError(t, scanner.Err())
+	return entries
+}
@@ -0,0 +1,79 @@
+package s3
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// redactedValue replaces secret values in logs
+const redactedValue = "<redacted>"
+
+// sensitiveKeyParts mark a configuration key as holding a secret value when its name contains one of them
+var sensitiveKeyParts = []string{"secret", "token", "password", "privatekey", "accesskey"}
+
+// LogValue implements slog.LogValuer so logging the driver never writes its credentials
+func (s3Driver *ArtifactDriver) LogValue() slog.Value {
+	return slog.AnyValue(s3Driver.redacted())
+}
+
+// String formats the driver with its credentials masked
+func (s3Driver *ArtifactDriver) String() string {
+	return fmt.Sprintf("%+v", s3Driver.redacted())
+}
+
+// redacted returns a copy of the driver with every credential masked
+func (s3Driver *ArtifactDriver) redacted() ArtifactDriver {
+	driver := *s3Driver
+	for _, field := range []*string{&driver.AccessKey, &driver.SecretKey, &driver.SessionToken, &driver.ServerSideCustomerKey, &driver.ClientKey} {
+		if *field != "" {
+			*field = redactedValue
+		}
+	}
+	return driver
+}
+
+// RedactConfiguration masks the string values of secret looking keys in a plugin configuration so it can be logged.
+// Secret references are kept as they only name the Kubernetes secret. A configuration that isn't valid YAML
+// is redacted entirely.
+func RedactConfiguration(configYAML string) string {
+	var config any
+	if err := yaml.Unmarshal([]byte(configYAML), &config); err != nil {
+		return redactedValue
+	}
+	out, err := yaml.Marshal(redactValues(config))
+	if err != nil {
+		return redactedValue
+	}
+	return string(out)
+}
+
+func redactValues(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if _, isString := child.(string); isString && isSensitiveKey(key) {
+				v[key] = redactedValue
+				continue
+			}
+			v[key] = redactValues(child)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = redactValues(child)
+		}
+	}
+	return value
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
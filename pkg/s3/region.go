@@ -0,0 +1,99 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+)
+
+// defaultRegion is used when a bucket's region can't be detected
+const defaultRegion = "us-east-1"
+
+// defaultAWSEndpoint is probed for a bucket's region when no endpoint is configured
+const defaultAWSEndpoint = "s3.amazonaws.com"
+
+// regionProbeTimeout bounds the HeadBucket request made to detect a bucket's region
+const regionProbeTimeout = 10 * time.Second
+
+// regionFailureTTL is how long defaultRegion is used for a bucket whose probe failed before it is probed again, so an
+// unreachable endpoint doesn't cost every request a probe
+const regionFailureTTL = time.Minute
+
+// bucketRegions caches the bucketRegion detected for each bucket, keyed by the probe URL
+var bucketRegions sync.Map
+
+// bucketRegion is a cached probe result. A detected region never expires, the fallback for a failed probe does.
+type bucketRegion struct {
+	region  string
+	expires time.Time
+}
+
+// probeBucketRegion asks for the region of the bucket at bucketURL, a variable so tests can stub it
+var probeBucketRegion = headBucketRegion
+
+// regionProbeClient doesn't follow redirects, S3 answers a request to the wrong region with one
+// which already carries the region header
+var regionProbeClient = &http.Client{
+	Timeout: regionProbeTimeout,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// bucketRegionURL returns the path-style URL of the bucket on the endpoint, AWS's global endpoint when it is empty
+func bucketRegionURL(endpoint string, secure bool, bucket string) string {
+	if endpoint == "" {
+		endpoint = defaultAWSEndpoint
+	}
+	scheme := "https"
+	if !secure {
+		scheme = "http"
+	}
+	return (&url.URL{Scheme: scheme, Host: endpoint, Path: "/" + bucket}).String()
+}
+
+// detectBucketRegion returns the region of the bucket at bucketURL, probing for it within timeout when it isn't
+// cached. defaultRegion is returned, and cached for regionFailureTTL, when the probe fails.
+func detectBucketRegion(ctx context.Context, bucketURL string, timeout time.Duration) string {
+	if cached, ok := bucketRegions.Load(bucketURL); ok {
+		if cached := cached.(bucketRegion); cached.expires.IsZero() || time.Now().Before(cached.expires) {
+			return cached.region
+		}
+	}
+	log := logging.RequireLoggerFromContext(ctx).WithField("bucket", bucketURL)
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	region, err := probeBucketRegion(probeCtx, bucketURL)
+	if err != nil {
+		log.WithError(err).Warn(ctx, "Failed to detect the bucket region, using "+defaultRegion)
+		bucketRegions.Store(bucketURL, bucketRegion{region: defaultRegion, expires: time.Now().Add(regionFailureTTL)})
+		return defaultRegion
+	}
+	log.WithField("region", region).Info(ctx, "Detected bucket region")
+	bucketRegions.Store(bucketURL, bucketRegion{region: region})
+	return region
+}
+
+// headBucketRegion returns the region of the bucket at bucketURL from the x-amz-bucket-region header of a
+// HeadBucket request, which S3 sends whether or not the request is authorized
+func headBucketRegion(ctx context.Context, bucketURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, bucketURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := regionProbeClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	region := resp.Header.Get("X-Amz-Bucket-Region")
+	if region == "" {
+		return "", fmt.Errorf("bucket region not reported, status %d", resp.StatusCode)
+	}
+	return region, nil
+}
@@ -0,0 +1,135 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+)
+
+// TestMain stubs the region probe, so that tests of drivers without a region or endpoint don't reach out to AWS.
+// The tests of the probe itself restore it.
+func TestMain(m *testing.M) {
+	probeBucketRegion = func(context.Context, string) (string, error) {
+		return "", errors.New("region probes are stubbed in tests")
+	}
+	os.Exit(m.Run())
+}
+
+// setProbeBucketRegion replaces the region probe for the duration of the test
+func setProbeBucketRegion(t *testing.T, probe func(context.Context, string) (string, error)) {
+	original := probeBucketRegion
+	probeBucketRegion = probe
+	t.Cleanup(func() { probeBucketRegion = original })
+}
+
+func TestDetectBucketRegion(t *testing.T) {
+	setProbeBucketRegion(t, headBucketRegion)
+	ctx := logging.TestContext(t.Context())
+	var probes int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes++
+		assert.Equal(t, http.MethodHead, r.Method)
+		switch r.URL.Path {
+		case "/eu-bucket":
+			// S3 reports the region even when the request isn't authorized
+			w.Header().Set("X-Amz-Bucket-Region", "eu-west-2")
+			w.WriteHeader(http.StatusForbidden)
+		case "/moved-bucket":
+			w.Header().Set("X-Amz-Bucket-Region", "ap-southeast-1")
+			w.Header().Set("Location", "/elsewhere")
+			w.WriteHeader(http.StatusMovedPermanently)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+
+	t.Run("Region header", func(t *testing.T) {
+		probes = 0
+		bucketURL := bucketRegionURL(endpoint, false, "eu-bucket")
+		assert.Equal(t, "eu-west-2", detectBucketRegion(ctx, bucketURL, time.Second))
+		assert.Equal(t, "eu-west-2", detectBucketRegion(ctx, bucketURL, time.Second))
+		assert.Equal(t, 1, probes, "the detected region should be cached")
+	})
+
+	t.Run("Redirect is not followed", func(t *testing.T) {
+		assert.Equal(t, "ap-southeast-1", detectBucketRegion(ctx, bucketRegionURL(endpoint, false, "moved-bucket"), time.Second))
+	})
+
+	t.Run("Missing header falls back", func(t *testing.T) {
+		probes = 0
+		bucketURL := bucketRegionURL(endpoint, false, "unknown-bucket")
+		assert.Equal(t, defaultRegion, detectBucketRegion(ctx, bucketURL, time.Second))
+		assert.Equal(t, defaultRegion, detectBucketRegion(ctx, bucketURL, time.Second))
+		assert.Equal(t, 1, probes, "a failed probe should be cached")
+
+		// Once the failure expires the bucket is probed again
+		bucketRegions.Store(bucketURL, bucketRegion{region: defaultRegion, expires: time.Now().Add(-time.Second)})
+		assert.Equal(t, defaultRegion, detectBucketRegion(ctx, bucketURL, time.Second))
+		assert.Equal(t, 2, probes)
+	})
+
+	t.Run("Unreachable endpoint falls back", func(t *testing.T) {
+		assert.Equal(t, defaultRegion, detectBucketRegion(ctx, bucketRegionURL("127.0.0.1:1", false, "eu-bucket"), time.Second))
+	})
+
+	t.Run("Probe is bounded by the timeout", func(t *testing.T) {
+		stalled := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+		t.Cleanup(stalled.Close)
+		start := time.Now()
+		bucketURL := bucketRegionURL(strings.TrimPrefix(stalled.URL, "http://"), false, "stalled-bucket")
+		assert.Equal(t, defaultRegion, detectBucketRegion(ctx, bucketURL, 50*time.Millisecond))
+		assert.Less(t, time.Since(start), regionProbeTimeout)
+	})
+}
+
+// TestGetArtifactDriver_RegionDetection verifies the driver uses the detected region only when none is configured
+func TestGetArtifactDriver_RegionDetection(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	bucketURL := bucketRegionURL("", true, "detected-region-bucket")
+	assert.Equal(t, "https://s3.amazonaws.com/detected-region-bucket", bucketURL)
+	bucketRegions.Store(bucketURL, bucketRegion{region: "eu-central-1"})
+	t.Cleanup(func() { bucketRegions.Delete(bucketURL) })
+
+	driver, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{Bucket: "detected-region-bucket", UseSDKCreds: true}})
+	require.NoError(t, err)
+	assert.Equal(t, "eu-central-1", driver.Region)
+
+	driver, err = getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{Bucket: "detected-region-bucket", Region: "us-west-2", UseSDKCreds: true}})
+	require.NoError(t, err)
+	assert.Equal(t, "us-west-2", driver.Region)
+
+	driver, err = getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{Bucket: "detected-region-bucket", Endpoint: "minio:9000", UseSDKCreds: true}})
+	require.NoError(t, err)
+	assert.Empty(t, driver.Region, "custom endpoints aren't probed")
+}
+
+// TestGetArtifactDriver_RegionProbeTimeout verifies the region probe is bounded by the operation timeout
+func TestGetArtifactDriver_RegionProbeTimeout(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	var deadline time.Time
+	setProbeBucketRegion(t, func(ctx context.Context, _ string) (string, error) {
+		deadline, _ = ctx.Deadline()
+		return "ap-south-1", nil
+	})
+	t.Cleanup(func() { bucketRegions.Delete(bucketRegionURL("", true, "probed-bucket")) })
+
+	driver, err := getArtifactDriver(ctx, &PluginConfig{S3Bucket: wfv1.S3Bucket{Bucket: "probed-bucket", UseSDKCreds: true}, OperationTimeoutSeconds: 2})
+	require.NoError(t, err)
+	assert.Equal(t, "ap-south-1", driver.Region)
+	assert.WithinDuration(t, time.Now().Add(2*time.Second), deadline, time.Second)
+}
@@ -0,0 +1,68 @@
+package s3
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/signer"
+)
+
+const (
+	// signV4Algorithm starts the Authorization header of a request signed with signature version 4
+	signV4Algorithm = "AWS4-HMAC-SHA256"
+	// streamingPayloadPrefix starts the X-Amz-Content-Sha256 header of a request whose body is signed chunk by chunk
+	streamingPayloadPrefix = "STREAMING-AWS4-HMAC-SHA256"
+)
+
+// requesterPaysTransport asks for the requester to be charged for every request. minio only sends custom headers
+// with downloads and listings, so the transport adds x-amz-request-payer to the other requests, such as uploads,
+// copies and deletes, and signs them again, as S3 rejects requests with unsigned x-amz- headers.
+type requesterPaysTransport struct {
+	base http.RoundTripper
+	// getCreds returns the credentials the request was signed with, set once the minio client is created
+	getCreds func() (credentials.Value, error)
+}
+
+func (t *requesterPaysTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get(headerRequestPayer) != "" {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set(headerRequestPayer, requestPayerRequester)
+
+	auth := req.Header.Get("Authorization")
+	if auth == "" {
+		// Anonymous requests aren't signed
+		return t.base.RoundTrip(req)
+	}
+	region := signedRegion(auth)
+	if !strings.HasPrefix(auth, signV4Algorithm) || region == "" {
+		return nil, errors.New("requester pays is only supported with signature version 4")
+	}
+	// A streaming signature chains each chunk's signature from the request's, so it can't be replaced
+	if strings.HasPrefix(req.Header.Get("X-Amz-Content-Sha256"), streamingPayloadPrefix) {
+		return nil, errors.New("requester pays can't be used with a streaming signature")
+	}
+	creds, err := t.getCreds()
+	if err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(signer.SignV4(*req, creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken, region))
+}
+
+// signedRegion returns the region in the credential scope of a signature version 4 Authorization header,
+// Credential=<access key>/<date>/<region>/s3/aws4_request, or "" when it has none
+func signedRegion(auth string) string {
+	_, credential, ok := strings.Cut(auth, "Credential=")
+	if !ok {
+		return ""
+	}
+	credential, _, _ = strings.Cut(credential, ",")
+	scope := strings.Split(credential, "/")
+	if len(scope) != 5 {
+		return ""
+	}
+	return scope[2]
+}
@@ -0,0 +1,71 @@
+package s3
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRequesterPaysTransport verifies requests are signed again to cover the requester-pays header, and refused
+// when they can't be
+func TestRequesterPaysTransport(t *testing.T) {
+	var sent *http.Request
+	transport := &requesterPaysTransport{
+		base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			sent = req
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}),
+		getCreds: func() (credentials.Value, error) {
+			return credentials.Value{AccessKeyID: "key", SecretAccessKey: "secret"}, nil
+		},
+	}
+	signedRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodDelete, "https://s3.amazonaws.com/my-bucket/key", nil)
+		req.Header.Set("Authorization", signV4Algorithm+" Credential=key/20250101/eu-west-1/s3/aws4_request, SignedHeaders=host;x-amz-date, Signature=abc")
+		return req
+	}
+
+	t.Run("Signed", func(t *testing.T) {
+		_, err := transport.RoundTrip(signedRequest())
+		require.NoError(t, err)
+		assert.Equal(t, requestPayerRequester, sent.Header.Get(headerRequestPayer))
+		auth := sent.Header.Get("Authorization")
+		assert.Contains(t, auth, "/eu-west-1/s3/aws4_request")
+		assert.Contains(t, auth, headerRequestPayer)
+		assert.NotContains(t, auth, "Signature=abc")
+	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		req := signedRequest()
+		req.Header.Del("Authorization")
+		_, err := transport.RoundTrip(req)
+		require.NoError(t, err)
+		assert.Equal(t, requestPayerRequester, sent.Header.Get(headerRequestPayer))
+		assert.Empty(t, sent.Header.Get("Authorization"))
+	})
+
+	t.Run("Signature version 2", func(t *testing.T) {
+		req := signedRequest()
+		req.Header.Set("Authorization", "AWS key:abc")
+		_, err := transport.RoundTrip(req)
+		require.ErrorContains(t, err, "signature version 4")
+	})
+
+	t.Run("Streaming signature", func(t *testing.T) {
+		req := signedRequest()
+		req.Header.Set("X-Amz-Content-Sha256", streamingPayloadPrefix+"-PAYLOAD")
+		_, err := transport.RoundTrip(req)
+		require.ErrorContains(t, err, "streaming signature")
+	})
+}
+
+// roundTripFunc adapts a function to an http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
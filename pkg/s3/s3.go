@@ -1,19 +1,31 @@
 package s3
 
 import (
+	"bufio"
+	"bytes"
+	"cmp"
 	"context"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
@@ -22,13 +34,17 @@ import (
 	"github.com/minio/minio-go/v7/pkg/sse"
 
 	"github.com/minio/minio-go/v7"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/util/retry"
 
 	argoerrs "github.com/argoproj/argo-workflows/v3/errors"
 	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
 	"github.com/argoproj/argo-workflows/v3/util/file"
 	"github.com/argoproj/argo-workflows/v3/util/logging"
-	waitutil "github.com/argoproj/argo-workflows/v3/util/wait"
 	artifactscommon "github.com/argoproj/argo-workflows/v3/workflow/artifacts/common"
 	"github.com/argoproj/argo-workflows/v3/workflow/common"
 	executorretry "github.com/argoproj/argo-workflows/v3/workflow/executor/retry"
@@ -36,32 +52,112 @@ import (
 
 const nullIAMEndpoint = ""
 
+// MaxPresignedURLExpiry is the longest validity S3 accepts for a presigned URL
+const MaxPresignedURLExpiry = 7 * 24 * time.Hour
+
+// DefaultMaxInlineObjectSize is the largest object ReadObject and WriteObject transfer when none is configured
+const DefaultMaxInlineObjectSize = 8 * 1024 * 1024
+
+// ErrObjectTooLarge is wrapped by the error ReadObject returns for an object above the inline size limit
+var ErrObjectTooLarge = errors.New("object too large to read inline")
+
+// ErrDestinationExists is wrapped by the error Load returns when overwrite is disabled and the destination exists
+var ErrDestinationExists = errors.New("destination already exists")
+
+// maxCopyObjectSize is the largest object a single CopyObject request can copy, larger objects use a multipart copy
+const maxCopyObjectSize = 5 * 1024 * 1024 * 1024
+
+// headerRequestPayer set to requestPayerRequester charges the requester for a request to a requester-pays bucket
+const (
+	headerRequestPayer    = "x-amz-request-payer"
+	requestPayerRequester = "requester"
+)
+
+// headerACL is the canned ACL an upload applies to the object
+const headerACL = "x-amz-acl"
+
+var tracer = otel.Tracer("github.com/pipekit/artifact-plugin-s3/pkg/s3")
+
 type S3Client interface {
 	// PutFile puts a single file to a bucket at the specified key
 	PutFile(bucket, key, path string) error
 
+	// PutBytes puts data to a bucket at the specified key in a single request
+	PutBytes(bucket, key string, data []byte, contentType string) error
+
+	// PutReader puts size bytes read from r to a bucket at the specified key, a negative size uploads in parts
+	// until r is exhausted
+	PutReader(bucket, key string, r io.Reader, size int64, contentType string) error
+
 	// PutDirectory puts a complete directory into a bucket key prefix, with each file in the directory
 	// a separate key in the bucket.
 	PutDirectory(bucket, key, path string) error
 
-	// GetFile downloads a file to a local file path
-	GetFile(bucket, key, path string) error
+	// GetFile downloads an object into f, which must be an empty regular file
+	GetFile(bucket, key string, f *os.File) error
 
 	// OpenFile opens a file for much lower disk and memory usage that GetFile
 	OpenFile(bucket, key string) (io.ReadCloser, error)
 
+	// OpenFileRange opens length bytes of a file from offset, or the rest of the file when length is 0
+	OpenFileRange(bucket, key string, offset, length int64) (io.ReadCloser, error)
+
 	// KeyExists checks if object exists (and if we have permission to access)
 	KeyExists(bucket, key string) (bool, error)
 
+	// StatObject returns the metadata of an object without downloading it
+	StatObject(bucket, key string) (minio.ObjectInfo, error)
+
 	// Delete deletes the key from the bucket
 	Delete(bucket, key string) error
 
+	// DeleteObjects deletes the keys from the bucket using multi-object delete requests
+	DeleteObjects(bucket string, keys []string) error
+
+	// CopyObject copies the src object to dstKey within the bucket server-side, for objects up to 5GB
+	CopyObject(bucket string, src minio.ObjectInfo, dstKey string) error
+
+	// ComposeObject copies the src object to dstKey within the bucket server-side using a multipart copy
+	ComposeObject(bucket string, src minio.ObjectInfo, dstKey string) error
+
+	// NewMultipartUpload starts a multipart upload to the key, returning its upload ID
+	NewMultipartUpload(bucket, key string) (string, error)
+
+	// PutObjectPart uploads data as a part of the multipart upload, returning the part's ETag
+	PutObjectPart(bucket, key, uploadID string, partNumber int, data []byte) (string, error)
+
+	// CompleteMultipartUpload assembles the parts, which must be in part number order, into the object
+	CompleteMultipartUpload(bucket, key, uploadID string, parts []MultipartPart) error
+
+	// AbortMultipartUpload abandons the multipart upload, deleting its parts
+	AbortMultipartUpload(bucket, key, uploadID string) error
+
+	// SelectObjectContent runs an S3 Select request over the key, returning a reader of the selected records
+	SelectObjectContent(bucket, key string, opts minio.SelectObjectOptions) (io.ReadCloser, error)
+
+	// PresignedURL returns a URL signed for the HTTP method (GET or PUT) on the key, valid for expiry
+	PresignedURL(method, bucket, key string, expiry time.Duration) (*url.URL, error)
+
+	// ListBuckets lists the buckets the credentials can see
+	ListBuckets() ([]minio.BucketInfo, error)
+
 	// GetDirectory downloads a directory to a local file path
 	GetDirectory(bucket, key, path string) error
 
 	// ListDirectory list the contents of a directory/bucket
 	ListDirectory(bucket, keyPrefix string) ([]string, error)
 
+	// ListDirectoryInfo lists the objects of a directory/bucket like ListDirectory, with their metadata
+	ListDirectoryInfo(bucket, keyPrefix string) ([]minio.ObjectInfo, error)
+
+	// ListDirectoryLevel lists the keys directly inside a directory/bucket, with each sub-directory
+	// returned once as its common prefix, ending in /
+	ListDirectoryLevel(bucket, keyPrefix string) ([]string, error)
+
+	// ListDirectoryPage lists at most pageSize keys of a directory/bucket, starting from continuationToken.
+	// It returns the token for the next page, which is empty once the listing is complete
+	ListDirectoryPage(bucket, keyPrefix string, pageSize int, continuationToken string) ([]string, string, error)
+
 	// IsDirectory tests if the key is acting like an s3 directory
 	IsDirectory(bucket, key string) (bool, error)
 
@@ -89,20 +185,52 @@ const (
 )
 
 type S3ClientOpts struct {
-	Endpoint        string
-	AddressingStyle AddressingStyle
-	Region          string
-	Secure          bool
-	Transport       http.RoundTripper
-	AccessKey       string
-	SecretKey       string
-	SessionToken    string
-	Trace           bool
-	RoleARN         string
-	RoleSessionName string
-	UseSDKCreds     bool
-	EncryptOpts     EncryptOpts
-	SendContentMd5  bool
+	Endpoint             string
+	AddressingStyle      AddressingStyle
+	Region               string
+	Secure               bool
+	Transport            http.RoundTripper
+	AccessKey            string
+	SecretKey            string
+	SessionToken         string
+	Trace                bool
+	RoleARN              string
+	RoleSessionName      string
+	UseSDKCreds          bool
+	Profile              string
+	Anonymous            bool
+	EncryptOpts          EncryptOpts
+	SendContentMd5       bool
+	WebIdentityTokenFile string
+	ExternalID           string
+	RetryMode            string
+	RetryMaxAttempts     int
+	RequesterPays        bool
+	ProgressInterval     time.Duration
+	StorageClass         string
+	ACL                  string
+	ObjectTags           map[string]string
+	ContentType          string
+	CacheControl         string
+	ContentDisposition   string
+	// ObjectLockMode is the retention mode PutFile and PutBytes apply, until ObjectLockRetainUntil when it is set
+	// or else for ObjectLockRetention from each upload. No retention is applied when empty.
+	ObjectLockMode        string
+	ObjectLockRetention   time.Duration
+	ObjectLockRetainUntil time.Time
+	// IfMatch and IfNoneMatch are the ETag conditions PutFile and PutBytes send, none when empty
+	IfMatch     string
+	IfNoneMatch string
+	// UploadChecksum is the checksum algorithm PutFile sends for S3 to validate, none when empty
+	UploadChecksum string
+	// MultipartThreshold is the file size from which PutFile uploads in parts of MultipartPartSize bytes
+	MultipartThreshold int64
+	MultipartPartSize  int64
+	// MultipartConcurrency is how many parts of a multipart upload are sent at once
+	MultipartConcurrency int
+	// DownloadConcurrency is how many ranges of an object GetFile downloads at once, objects are downloaded in a
+	// single stream unless it is above 1. Ranges use the multipart threshold and part size.
+	DownloadConcurrency int
 }
 
 type s3client struct {
@@ -125,10 +253,62 @@ type ArtifactDriver struct {
 	SessionToken          string
 	RoleARN               string
 	UseSDKCreds           bool
+	Profile               string
+	Anonymous             bool
 	KmsKeyID              string
 	KmsEncryptionContext  string
 	EnableEncryption      bool
 	ServerSideCustomerKey string
+	WebIdentityTokenFile  string
+	StreamChunkSize       int
+	RoleExternalID        string
+	RoleSessionName       string
+	MaxRetryAttempts      int
+	RetryMode             string
+	RetryMaxAttempts      int
+	RequesterPays         bool
+	AddressingStyle       AddressingStyle
+	DryRun                bool
+	OperationTimeout      time.Duration
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	IdleConnTimeout       time.Duration
+	MaxIdleConnsPerHost   int
+	MaxConnections        int
+	MaxIdleConnections    int
+	ClientCert            string
+	ClientKey             string
+	ProgressInterval      time.Duration
+	ListPattern           string
+	ListNonRecursive      bool
+	NoOverwrite           bool
+	Archive               string
+	CompressionLevel      int
+	TempDir               string
+	StorageClass          string
+	ACL                   string
+	ObjectTags            map[string]string
+	KeyPrefix             string
+	ObjectLockMode        string
+	ObjectLockRetention   time.Duration
+	ObjectLockRetainUntil time.Time
+	IfMatch               string
+	IfNoneMatch           string
+	ContentType           string
+	CacheControl          string
+	ContentDisposition    string
+	UserAgentSuffix       string
+	VerifyChecksum        bool
+	UploadChecksum        bool
+	SkipIfUnchanged       bool
+	ChecksumAlgorithm     string
+	MultipartThreshold    int64
+	MultipartPartSize     int64
+	MultipartConcurrency  int
+	DownloadConcurrency   int
+	StreamOffset          int64
+	StreamLength          int64
+	MaxInlineObjectSize   int64
 }
 
 var _ artifactscommon.ArtifactDriver = &ArtifactDriver{}
@@ -136,140 +316,688 @@ var _ artifactscommon.ArtifactDriver = &ArtifactDriver{}
 // newS3Client instantiates a new S3 client object.
 func (s3Driver *ArtifactDriver) newS3Client(ctx context.Context) (S3Client, error) {
 	opts := S3ClientOpts{
-		Endpoint:     s3Driver.Endpoint,
-		Region:       s3Driver.Region,
-		Secure:       s3Driver.Secure,
-		AccessKey:    s3Driver.AccessKey,
-		SecretKey:    s3Driver.SecretKey,
-		SessionToken: s3Driver.SessionToken,
-		RoleARN:      s3Driver.RoleARN,
-		Trace:        os.Getenv(common.EnvVarArgoTrace) == "1",
-		UseSDKCreds:  s3Driver.UseSDKCreds,
+		Endpoint:        s3Driver.Endpoint,
+		AddressingStyle: s3Driver.AddressingStyle,
+		Region:          s3Driver.Region,
+		Secure:          s3Driver.Secure,
+		AccessKey:       s3Driver.AccessKey,
+		SecretKey:       s3Driver.SecretKey,
+		SessionToken:    s3Driver.SessionToken,
+		RoleARN:         s3Driver.RoleARN,
+		RoleSessionName: s3Driver.RoleSessionName,
+		ExternalID:      s3Driver.RoleExternalID,
+		Trace:           os.Getenv(common.EnvVarArgoTrace) == "1",
+		UseSDKCreds:     s3Driver.UseSDKCreds,
+		Profile:         s3Driver.Profile,
+		Anonymous:       s3Driver.Anonymous,
 		EncryptOpts: EncryptOpts{
 			KmsKeyID:              s3Driver.KmsKeyID,
 			KmsEncryptionContext:  s3Driver.KmsEncryptionContext,
 			Enabled:               s3Driver.EnableEncryption,
 			ServerSideCustomerKey: s3Driver.ServerSideCustomerKey,
 		},
-		SendContentMd5: true,
+		SendContentMd5:        true,
+		WebIdentityTokenFile:  s3Driver.WebIdentityTokenFile,
+		RetryMode:             s3Driver.RetryMode,
+		RetryMaxAttempts:      s3Driver.RetryMaxAttempts,
+		RequesterPays:         s3Driver.RequesterPays,
+		ProgressInterval:      s3Driver.ProgressInterval,
+		StorageClass:          s3Driver.StorageClass,
+		ACL:                   s3Driver.ACL,
+		ObjectTags:            s3Driver.ObjectTags,
+		ObjectLockMode:        s3Driver.ObjectLockMode,
+		ObjectLockRetention:   s3Driver.ObjectLockRetention,
+		ObjectLockRetainUntil: s3Driver.ObjectLockRetainUntil,
+		IfMatch:               s3Driver.IfMatch,
+		IfNoneMatch:           s3Driver.IfNoneMatch,
+		ContentType:           s3Driver.ContentType,
+		CacheControl:          s3Driver.CacheControl,
+		ContentDisposition:    s3Driver.ContentDisposition,
+		MultipartThreshold:    s3Driver.MultipartThreshold,
+		MultipartPartSize:     s3Driver.MultipartPartSize,
+		MultipartConcurrency:  s3Driver.MultipartConcurrency,
+		DownloadConcurrency:   s3Driver.DownloadConcurrency,
+	}
+	if s3Driver.UploadChecksum {
+		opts.UploadChecksum = s3Driver.ChecksumAlgorithm
 	}
 
-	if tr, err := GetDefaultTransport(opts); err == nil {
-		if s3Driver.Secure && s3Driver.TrustedCA != "" {
-			// Trust only the provided root CA
-			pool := x509.NewCertPool()
-			pool.AppendCertsFromPEM([]byte(s3Driver.TrustedCA))
-			tr.TLSClientConfig.RootCAs = pool
-		}
-		opts.Transport = tr
+	tr, err := s3Driver.newTransport(opts)
+	if err != nil {
+		return nil, err
 	}
+	opts.Transport = &userAgentTransport{base: tr, userAgent: userAgent(s3Driver.UserAgentSuffix)}
 
 	return NewS3Client(ctx, opts)
 }
 
-// Load downloads artifacts from S3 compliant storage
-func (s3Driver *ArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, path string) error {
+// newTransport returns the HTTP transport for the S3 client, trusting only TrustedCA when it is set
+// and presenting the client certificate when one is configured. minio's defaults are kept for any unset timeout or limit.
+func (s3Driver *ArtifactDriver) newTransport(opts S3ClientOpts) (*http.Transport, error) {
+	tr, err := GetDefaultTransport(opts)
+	if err != nil {
+		return nil, err
+	}
+	if dialer := s3Driver.dialer(); dialer != nil {
+		tr.DialContext = dialer.DialContext
+	}
+	if s3Driver.TLSHandshakeTimeout > 0 {
+		tr.TLSHandshakeTimeout = s3Driver.TLSHandshakeTimeout
+	}
+	if s3Driver.IdleConnTimeout > 0 {
+		tr.IdleConnTimeout = s3Driver.IdleConnTimeout
+	}
+	if s3Driver.MaxIdleConnsPerHost > 0 {
+		tr.MaxIdleConnsPerHost = s3Driver.MaxIdleConnsPerHost
+	}
+	if s3Driver.MaxConnections > 0 {
+		tr.MaxConnsPerHost = s3Driver.MaxConnections
+		// More idle connections per host than may be open at all would never be kept
+		tr.MaxIdleConnsPerHost = min(tr.MaxIdleConnsPerHost, s3Driver.MaxConnections)
+	}
+	if s3Driver.MaxIdleConnections > 0 {
+		tr.MaxIdleConns = s3Driver.MaxIdleConnections
+	}
+	if s3Driver.Secure && s3Driver.TrustedCA != "" {
+		// Trust only the provided root CA
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM([]byte(s3Driver.TrustedCA))
+		tr.TLSClientConfig.RootCAs = pool
+	}
+	if s3Driver.Secure && s3Driver.ClientCert != "" && s3Driver.ClientKey != "" {
+		// Present the client certificate for mutual TLS
+		cert, err := tls.X509KeyPair([]byte(s3Driver.ClientCert), []byte(s3Driver.ClientKey))
+		if err != nil {
+			return nil, err
+		}
+		tr.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tr, nil
+}
+
+// dialer returns the dialer connecting to the endpoint within DialTimeout, or nil to keep minio's when it isn't set
+func (s3Driver *ArtifactDriver) dialer() *net.Dialer {
+	if s3Driver.DialTimeout <= 0 {
+		return nil
+	}
+	return &net.Dialer{Timeout: s3Driver.DialTimeout, KeepAlive: 30 * time.Second}
+}
+
+// startSpan starts a child span for a driver operation, recording the artifact's bucket and key
+func startSpan(ctx context.Context, name string, artifact *wfv1.Artifact) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("s3.bucket", artifact.S3.Bucket),
+		attribute.String("s3.key", artifact.S3.Key),
+	))
+}
+
+// endSpan records the outcome of a driver operation and ends its span. When path is set
+// and the operation succeeded, the bytes transferred to or from path are recorded.
+func endSpan(span trace.Span, err error, path string) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	} else if path != "" {
+		span.SetAttributes(attribute.Int64("s3.bytes", LocalPathSize(path)))
+	}
+	span.End()
+}
+
+// LocalPathSize returns the total size of the regular files at path, which may be a file or a directory
+func LocalPathSize(path string) int64 {
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// retryBackoff returns the exponential backoff, with jitter, used to retry transient S3 errors.
+// The executor retry settings are honoured, but the number of attempts is capped at MaxRetryAttempts.
+func (s3Driver *ArtifactDriver) retryBackoff(ctx context.Context) wait.Backoff {
+	backoff := executorretry.ExecutorRetry(ctx)
+	if s3Driver.MaxRetryAttempts > 0 {
+		backoff.Steps = s3Driver.MaxRetryAttempts
+	}
+	return backoff
+}
+
+// backoff calls f with backoff until it is done, like waitutil.Backoff, but wraps the last error rather than
+// formatting it so callers can still inspect it once the attempts are exhausted
+func backoff(b wait.Backoff, f func() (bool, error)) error {
+	var err error
+	waitErr := wait.ExponentialBackoff(b, func() (bool, error) {
+		var done bool
+		done, err = f()
+		return done, nil
+	})
+	if waitErr != nil && err != nil {
+		return fmt.Errorf("%v: %w", waitErr, err)
+	}
+	if waitErr != nil {
+		return waitErr
+	}
+	return err
+}
+
+// checkWritable rejects an operation which writes to the bucket when the driver has read-only anonymous access,
+// rather than letting it fail with an S3 access denied error
+func (s3Driver *ArtifactDriver) checkWritable(operation string) error {
+	if s3Driver.Anonymous {
+		return argoerrs.Errorf(argoerrs.CodeForbidden, "%s is not allowed, the bucket is configured for read-only anonymous access", operation)
+	}
+	return nil
+}
+
+// checkKeyPrefix rejects an artifact whose key isn't under KeyPrefix, or could leave it with .., so listings and
+// deletes stay within it
+func (s3Driver *ArtifactDriver) checkKeyPrefix(artifact *wfv1.Artifact) error {
+	if s3Driver.KeyPrefix != "" && (!strings.HasPrefix(artifact.S3.Key, s3Driver.KeyPrefix) || hasParentSegment(artifact.S3.Key)) {
+		return argoerrs.Errorf(argoerrs.CodeForbidden, "key %q is outside the key prefix %q", artifact.S3.Key, s3Driver.KeyPrefix)
+	}
+	return nil
+}
+
+// Load downloads artifacts from S3 compliant storage. A file is downloaded through LoadToWriter, a directory,
+// which can't be written to a writer, is downloaded object by object under path.
+func (s3Driver *ArtifactDriver) Load(ctx context.Context, inputArtifact *wfv1.Artifact, path string) (err error) {
+	ctx, span := startSpan(ctx, "S3 Load", inputArtifact)
+	defer func() { endSpan(span, err, path) }()
+
+	// Checked once up front, so retrying a failed attempt below may still replace what it partly downloaded
+	if s3Driver.NoOverwrite {
+		if _, err := os.Lstat(path); err == nil {
+			return fmt.Errorf("%w: %s", ErrDestinationExists, path)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to check destination %s: %w", path, err)
+		}
+	}
+
+	logging.RequireLoggerFromContext(ctx).WithFields(logging.Fields{"path": path, "key": inputArtifact.S3.Key}).Info(ctx, "S3 Load")
+	err = s3Driver.loadFile(ctx, inputArtifact, path)
+	if !argoerrs.IsCode(argoerrs.CodeNotImplemented, err) {
+		return err
+	}
+	return s3Driver.loadDirectory(ctx, inputArtifact, path)
+}
+
+// loadFile downloads the artifact through LoadToWriter into a temporary file beside path, which replaces path once
+// the download is complete
+func (s3Driver *ArtifactDriver) loadFile(ctx context.Context, inputArtifact *wfv1.Artifact, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.part")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	err = s3Driver.LoadToWriter(ctx, inputArtifact, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// loadDirectory downloads each object under the artifact's key to the file at its relative path under path
+func (s3Driver *ArtifactDriver) loadDirectory(ctx context.Context, inputArtifact *wfv1.Artifact, path string) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	log := logging.RequireLoggerFromContext(ctx)
-	err := waitutil.Backoff(executorretry.ExecutorRetry(ctx),
+	return backoff(s3Driver.retryBackoff(ctx),
 		func() (bool, error) {
-			log.WithFields(logging.Fields{"path": path, "key": inputArtifact.S3.Key}).Info(ctx, "S3 Load")
 			s3cli, err := s3Driver.newS3Client(ctx)
 			if err != nil {
-				return !isTransientS3Err(ctx, err), fmt.Errorf("failed to create new S3 client: %v", err)
+				return !isTransientS3Err(ctx, err), fmt.Errorf("failed to create new S3 client: %w", err)
 			}
-			return loadS3Artifact(ctx, s3cli, inputArtifact, path)
+			return loadS3Directory(ctx, s3cli, inputArtifact, path)
 		})
-
-	return err
 }
 
-// loadS3Artifact downloads artifacts from an S3 compliant storage
-// returns true if the download is completed or can't be retried (non-transient error)
+// loadS3Artifact writes the artifact's object into w. An empty regular file is downloaded into directly, with
+// ranges DownloadConcurrency at a time, any other writer from a single stream. A key which is a directory fails
+// with CodeNotImplemented, as a directory can't be written to w.
+// returns true if the download is completed or can't be retried (non-transient error, or w has been written to)
 // returns false if it can be retried (transient error)
-func loadS3Artifact(ctx context.Context, s3cli S3Client, inputArtifact *wfv1.Artifact, path string) (bool, error) {
-	origErr := s3cli.GetFile(inputArtifact.S3.Bucket, inputArtifact.S3.Key, path)
-	if origErr == nil {
-		return true, nil
-	}
-	if !IsS3ErrCode(origErr, "NoSuchKey") {
-		return !isTransientS3Err(ctx, origErr), fmt.Errorf("failed to get file: %v", origErr)
+func loadS3Artifact(ctx context.Context, s3cli S3Client, inputArtifact *wfv1.Artifact, w io.Writer, algorithm string) (bool, error) {
+	if f := emptyFile(w); f != nil {
+		if err := s3cli.GetFile(inputArtifact.S3.Bucket, inputArtifact.S3.Key, f); err != nil {
+			if IsS3ErrCode(err, "NoSuchKey") {
+				err = missingObjectError(s3cli, inputArtifact, err)
+			} else {
+				err = fmt.Errorf("failed to get file: %w", err)
+			}
+			return !isTransientS3Err(ctx, err), err
+		}
+		if algorithm == "" {
+			return true, nil
+		}
+		return verifyS3Artifact(ctx, s3cli, inputArtifact, f.Name(), algorithm)
 	}
-	// If we get here, the error was a NoSuchKey. The key might be an s3 "directory"
-	isDir, err := s3cli.IsDirectory(inputArtifact.S3.Bucket, inputArtifact.S3.Key)
+
+	stream, err := streamS3Artifact(ctx, s3cli, inputArtifact, 0, 0)
 	if err != nil {
-		return !isTransientS3Err(ctx, err), fmt.Errorf("failed to test if %s is a directory: %v", inputArtifact.S3.Key, err)
+		return !isTransientS3Err(ctx, err), err
 	}
-	if !isDir {
-		// It's neither a file, nor a directory. Return the original NoSuchKey error
-		return true, argoerrs.New(argoerrs.CodeNotFound, origErr.Error())
+	defer stream.Close()
+	return true, writeS3Artifact(ctx, s3cli, inputArtifact, stream, w, algorithm)
+}
+
+// emptyFile returns w when it is an empty regular file, which can be written at any offset and truncated to
+// download the object again, and otherwise nil
+func emptyFile(w io.Writer) *os.File {
+	f, ok := w.(*os.File)
+	if !ok {
+		return nil
 	}
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() || info.Size() != 0 {
+		return nil
+	}
+	return f
+}
 
-	if err = s3cli.GetDirectory(inputArtifact.S3.Bucket, inputArtifact.S3.Key, path); err != nil {
-		return !isTransientS3Err(ctx, err), fmt.Errorf("failed to get directory: %v", err)
+// loadS3Directory downloads the objects under the artifact's key into the directory at path
+// returns true if the download is completed or can't be retried (non-transient error)
+// returns false if it can be retried (transient error)
+func loadS3Directory(ctx context.Context, s3cli S3Client, inputArtifact *wfv1.Artifact, path string) (bool, error) {
+	if err := s3cli.GetDirectory(inputArtifact.S3.Bucket, inputArtifact.S3.Key, path); err != nil {
+		return !isTransientS3Err(ctx, err), fmt.Errorf("failed to get directory: %w", err)
 	}
 	return true, nil
 }
 
-// OpenStream opens a stream reader for an artifact from S3 compliant storage
+// OpenStream opens a stream reader for an artifact from S3 compliant storage. The reader keeps using ctx,
+// so ctx must stay live until reading is done, and cancelling it aborts the transfer.
 func (s3Driver *ArtifactDriver) OpenStream(ctx context.Context, inputArtifact *wfv1.Artifact) (io.ReadCloser, error) {
 	log := logging.RequireLoggerFromContext(ctx)
 	log.WithField("key", inputArtifact.S3.Key).Info(ctx, "S3 OpenStream")
-	// nolint:contextcheck
-	s3cli, err := s3Driver.newS3Client(log.NewBackgroundContext())
+	s3cli, err := s3Driver.newS3Client(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create new S3 client: %v", err)
+		return nil, fmt.Errorf("failed to create new S3 client: %w", err)
 	}
 
-	return streamS3Artifact(ctx, s3cli, inputArtifact)
+	return streamS3Artifact(ctx, s3cli, inputArtifact, s3Driver.StreamOffset, s3Driver.StreamLength)
 }
 
-func streamS3Artifact(_ context.Context, s3cli S3Client, inputArtifact *wfv1.Artifact) (io.ReadCloser, error) {
-	stream, origErr := s3cli.OpenFile(inputArtifact.S3.Bucket, inputArtifact.S3.Key)
+// streamS3Artifact opens the artifact for streaming, only length bytes from offset when either is set
+func streamS3Artifact(_ context.Context, s3cli S3Client, inputArtifact *wfv1.Artifact, offset, length int64) (io.ReadCloser, error) {
+	var stream io.ReadCloser
+	var origErr error
+	if offset == 0 && length == 0 {
+		stream, origErr = s3cli.OpenFile(inputArtifact.S3.Bucket, inputArtifact.S3.Key)
+	} else {
+		stream, origErr = s3cli.OpenFileRange(inputArtifact.S3.Bucket, inputArtifact.S3.Key, offset, length)
+	}
 	if origErr == nil {
 		return stream, nil
 	}
 	if !IsS3ErrCode(origErr, "NoSuchKey") {
-		return nil, fmt.Errorf("failed to get file: %v", origErr)
+		return nil, fmt.Errorf("failed to get file: %w", origErr)
 	}
-	// If we get here, the error was a NoSuchKey. The key might be an s3 "directory"
+	return nil, missingObjectError(s3cli, inputArtifact, origErr)
+}
+
+// missingObjectError returns the error for the artifact's key not being an object: the original NoSuchKey error
+// as not found when it isn't a directory either, or not implemented when it is
+func missingObjectError(s3cli S3Client, inputArtifact *wfv1.Artifact, origErr error) error {
+	// The key might be an s3 "directory"
 	isDir, err := s3cli.IsDirectory(inputArtifact.S3.Bucket, inputArtifact.S3.Key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to test if %s is a directory: %v", inputArtifact.S3.Key, err)
+		return fmt.Errorf("failed to test if %s is a directory: %w", inputArtifact.S3.Key, err)
 	}
 	if !isDir {
 		// It's neither a file, nor a directory. Return the original NoSuchKey error
-		return nil, argoerrs.New(argoerrs.CodeNotFound, origErr.Error())
+		return argoerrs.New(argoerrs.CodeNotFound, origErr.Error())
 	}
 	// directory case:
 	// todo: make a .tgz file which can be streamed to user
-	return nil, argoerrs.New(argoerrs.CodeNotImplemented, "Directory Stream capability currently unimplemented for S3")
+	return argoerrs.New(argoerrs.CodeNotImplemented, "Directory Stream capability currently unimplemented for S3")
 }
 
 // Save saves an artifact to S3 compliant storage
 func (s3Driver *ArtifactDriver) Save(ctx context.Context, path string, outputArtifact *wfv1.Artifact) error {
+	_, err := s3Driver.SaveWithStats(ctx, path, outputArtifact)
+	return err
+}
+
+// SaveStats describes the objects a Save uploaded
+type SaveStats struct {
+	// ObjectCount is the number of objects written, 1 for a file or an archived directory
+	ObjectCount int
+	// TotalBytes is the combined size of the objects written
+	TotalBytes int64
+	// Skipped is true when nothing was written as SkipIfUnchanged found the object already holds the file
+	Skipped bool
+}
+
+// SaveWithStats saves an artifact like Save, and returns the number and combined size of the objects it uploaded
+func (s3Driver *ArtifactDriver) SaveWithStats(ctx context.Context, path string, outputArtifact *wfv1.Artifact) (stats SaveStats, err error) {
+	ctx, span := startSpan(ctx, "S3 Save", outputArtifact)
+	defer func() { endSpan(span, err, path) }()
+
+	if err := s3Driver.checkWritable("Save"); err != nil {
+		return SaveStats{}, err
+	}
+
+	uploadPath, cleanup, err := s3Driver.archiveForSave(ctx, path)
+	if err != nil {
+		return SaveStats{}, err
+	}
+	defer cleanup()
+
+	log := logging.RequireLoggerFromContext(ctx)
+	log.WithFields(logging.Fields{"path": path, "key": outputArtifact.S3.Key}).Info(ctx, "S3 Save")
+	if s3Driver.SkipIfUnchanged {
+		skipped, err := s3Driver.isUnchanged(ctx, outputArtifact, uploadPath)
+		if err != nil {
+			return SaveStats{}, err
+		}
+		if skipped {
+			log.WithField("key", outputArtifact.S3.Key).Info(ctx, "Object is unchanged, skipped uploading it")
+			return SaveStats{Skipped: true}, nil
+		}
+	}
+	isDir, err := file.IsDirectory(uploadPath)
+	if err != nil {
+		return SaveStats{}, fmt.Errorf("failed to test if %s is a directory: %w", uploadPath, err)
+	}
+	if isDir {
+		err = s3Driver.saveDirectory(ctx, uploadPath, outputArtifact)
+	} else {
+		err = s3Driver.saveFile(ctx, uploadPath, outputArtifact)
+	}
+	if err != nil {
+		return SaveStats{}, err
+	}
+	return uploadStats(uploadPath), nil
+}
+
+// isUnchanged reports whether the object at the artifact's key already holds the file at path
+func (s3Driver *ArtifactDriver) isUnchanged(ctx context.Context, outputArtifact *wfv1.Artifact, path string) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var unchanged bool
+	err := backoff(s3Driver.retryBackoff(ctx),
+		func() (bool, error) {
+			s3cli, err := s3Driver.newS3Client(ctx)
+			if err != nil {
+				return !isTransientS3Err(ctx, err), fmt.Errorf("failed to create new S3 client: %w", err)
+			}
+			unchanged, err = isUnchanged(ctx, s3cli, outputArtifact, path, s3Driver.ChecksumAlgorithm)
+			return !isTransientS3Err(ctx, err), err
+		})
+	return unchanged, err
+}
+
+// saveFile uploads the file at path through SaveFromReader
+func (s3Driver *ArtifactDriver) saveFile(ctx context.Context, path string, outputArtifact *wfv1.Artifact) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s3Driver.SaveFromReader(ctx, f, outputArtifact)
+}
+
+// saveDirectory uploads each file under path to the key of its relative path under the artifact's key
+func (s3Driver *ArtifactDriver) saveDirectory(ctx context.Context, path string, outputArtifact *wfv1.Artifact) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	return backoff(s3Driver.retryBackoff(ctx),
+		func() (bool, error) {
+			s3cli, err := s3Driver.newS3Client(ctx)
+			if err != nil {
+				return !isTransientS3Err(ctx, err), fmt.Errorf("failed to create new S3 client: %w", err)
+			}
+			return saveS3Directory(ctx, s3cli, path, outputArtifact)
+		})
+}
+
+// uploadStats counts the objects saveS3Artifact uploads for path: the file itself, or each regular file under the
+// directory, as PutDirectory skips symlinks
+func uploadStats(path string) SaveStats {
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return SaveStats{ObjectCount: 1, TotalBytes: info.Size()}
+	}
+	var stats SaveStats
+	_ = filepath.WalkDir(filepath.Clean(path)+string(os.PathSeparator), func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			stats.ObjectCount++
+			stats.TotalBytes += info.Size()
+		}
+		return nil
+	})
+	return stats
+}
+
+// SaveFromReader saves the data read from r as the artifact, without writing it to the filesystem first. The
+// length is taken from readers which report it, such as a strings.Reader or an os.File, otherwise the object is
+// uploaded in parts, each buffered in memory, until r is exhausted. A reader which can seek is rewound to retry a
+// failed upload, any other is uploaded once.
+func (s3Driver *ArtifactDriver) SaveFromReader(ctx context.Context, r io.Reader, outputArtifact *wfv1.Artifact) (err error) {
+	ctx, span := startSpan(ctx, "S3 SaveFromReader", outputArtifact)
+	defer func() { endSpan(span, err, "") }()
+
+	if err := s3Driver.checkWritable("SaveFromReader"); err != nil {
+		return err
+	}
+	size, err := readerSize(r)
+	if err != nil {
+		return err
+	}
+	seeker, _ := r.(io.Seeker)
+	var start int64
+	if seeker != nil {
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			seeker = nil
+		}
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	log := logging.RequireLoggerFromContext(ctx)
-	err := waitutil.Backoff(executorretry.ExecutorRetry(ctx),
+	attempted := false
+	return backoff(s3Driver.retryBackoff(ctx),
 		func() (bool, error) {
-			log.WithFields(logging.Fields{"path": path, "key": outputArtifact.S3.Key}).Info(ctx, "S3 Save")
+			log.WithFields(logging.Fields{"key": outputArtifact.S3.Key, "size": size}).Info(ctx, "S3 SaveFromReader")
 			s3cli, err := s3Driver.newS3Client(ctx)
 			if err != nil {
-				return !isTransientS3Err(ctx, err), fmt.Errorf("failed to create new S3 client: %v", err)
+				return !isTransientS3Err(ctx, err), fmt.Errorf("failed to create new S3 client: %w", err)
+			}
+			if attempted {
+				if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+					return true, fmt.Errorf("failed to rewind the reader to retry: %w", err)
+				}
 			}
-			return saveS3Artifact(ctx, s3cli, path, outputArtifact)
+			attempted = true
+			done, err := saveS3Artifact(ctx, s3cli, r, size, outputArtifact)
+			return done || seeker == nil, err
 		})
+}
+
+// readerSize returns the number of bytes left to read from r, or -1 when r doesn't report it
+func readerSize(r io.Reader) (int64, error) {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len()), nil
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil {
+			return 0, err
+		}
+		if !info.Mode().IsRegular() {
+			return -1, nil
+		}
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, err
+		}
+		return info.Size() - offset, nil
+	}
+	return -1, nil
+}
+
+// WriteObject saves data held in memory as the artifact, without writing it to the filesystem first. An empty
+// contentType uses the configured content type, or the one detected from data.
+func (s3Driver *ArtifactDriver) WriteObject(ctx context.Context, artifact *wfv1.Artifact, data []byte, contentType string) (err error) {
+	ctx, span := startSpan(ctx, "S3 WriteObject", artifact)
+	defer func() { endSpan(span, err, "") }()
+
+	if err := s3Driver.checkWritable("WriteObject"); err != nil {
+		return err
+	}
+	if int64(len(data)) > s3Driver.maxInlineObjectSize() {
+		return argoerrs.Errorf(argoerrs.CodeBadRequest, "inline object is %d bytes, at most %d bytes can be written inline", len(data), s3Driver.maxInlineObjectSize())
+	}
+
+	log := logging.RequireLoggerFromContext(ctx)
+	return backoff(s3Driver.retryBackoff(ctx),
+		func() (bool, error) {
+			log.WithFields(logging.Fields{"key": artifact.S3.Key, "size": len(data)}).Info(ctx, "S3 WriteObject")
+			s3cli, err := s3Driver.newS3Client(ctx)
+			if err != nil {
+				return !isTransientS3Err(ctx, err), fmt.Errorf("failed to create new S3 client: %w", err)
+			}
+			if err := s3cli.PutBytes(artifact.S3.Bucket, artifact.S3.Key, data, contentType); err != nil {
+				return !isTransientS3Err(ctx, err), fmt.Errorf("failed to put object: %w", err)
+			}
+			return true, nil
+		})
+}
+
+// ReadObject returns the contents of a small artifact in memory. An object above the inline size limit is
+// rejected with ErrObjectTooLarge, it should be read with OpenStream instead.
+func (s3Driver *ArtifactDriver) ReadObject(ctx context.Context, artifact *wfv1.Artifact) (data []byte, err error) {
+	ctx, span := startSpan(ctx, "S3 ReadObject", artifact)
+	defer func() { endSpan(span, err, "") }()
+
+	logging.RequireLoggerFromContext(ctx).WithField("key", artifact.S3.Key).Info(ctx, "S3 ReadObject")
+	s3cli, err := s3Driver.newS3Client(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new S3 client: %w", err)
+	}
+	return readS3Artifact(s3cli, artifact, s3Driver.maxInlineObjectSize())
+}
+
+// LoadToWriter downloads the artifact into w rather than to a path, for callers embedding the driver. A download into an
+// empty regular file is retried on transient errors from the start, any other writer only until writing has begun.
+// A directory can't be written to w, so fails as it does with OpenStream. With VerifyChecksum, the bytes written
+// are compared with the object's checksum once they all have been.
+func (s3Driver *ArtifactDriver) LoadToWriter(ctx context.Context, inputArtifact *wfv1.Artifact, w io.Writer) (err error) {
+	ctx, span := startSpan(ctx, "S3 LoadToWriter", inputArtifact)
+	defer func() { endSpan(span, err, "") }()
+
+	algorithm := ""
+	if s3Driver.VerifyChecksum {
+		algorithm = s3Driver.ChecksumAlgorithm
+	}
+	f := emptyFile(w)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	log := logging.RequireLoggerFromContext(ctx)
+	attempted := false
+	return backoff(s3Driver.retryBackoff(ctx),
+		func() (bool, error) {
+			log.WithField("key", inputArtifact.S3.Key).Info(ctx, "S3 LoadToWriter")
+			s3cli, err := s3Driver.newS3Client(ctx)
+			if err != nil {
+				return !isTransientS3Err(ctx, err), fmt.Errorf("failed to create new S3 client: %w", err)
+			}
+			if attempted && f != nil {
+				if err := rewindFile(f); err != nil {
+					return true, fmt.Errorf("failed to rewind %s to retry: %w", f.Name(), err)
+				}
+			}
+			attempted = true
+			return loadS3Artifact(ctx, s3cli, inputArtifact, w, algorithm)
+		})
+}
+
+// rewindFile empties f to download the object into it again
+func rewindFile(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.Seek(0, io.SeekStart)
 	return err
 }
 
+// readS3Artifact reads the whole artifact, as long as it is no larger than limit bytes
+func readS3Artifact(s3cli S3Client, artifact *wfv1.Artifact, limit int64) ([]byte, error) {
+	info, err := s3cli.StatObject(artifact.S3.Bucket, artifact.S3.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", artifact.S3.Key, err)
+	}
+	tooLarge := func(size int64) error {
+		return fmt.Errorf("%w: %s is %d bytes, at most %d bytes can be read inline, use OpenStream instead", ErrObjectTooLarge, artifact.S3.Key, size, limit)
+	}
+	if info.Size > limit {
+		return nil, tooLarge(info.Size)
+	}
+	stream, err := s3cli.OpenFile(artifact.S3.Bucket, artifact.S3.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+	defer stream.Close()
+	// The object may have been replaced by a larger one since it was stat'ed
+	data, err := io.ReadAll(io.LimitReader(stream, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", artifact.S3.Key, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, tooLarge(int64(len(data)))
+	}
+	return data, nil
+}
+
+// maxInlineObjectSize returns the configured inline size limit, or the default when none is configured
+func (s3Driver *ArtifactDriver) maxInlineObjectSize() int64 {
+	return cmp.Or(s3Driver.MaxInlineObjectSize, DefaultMaxInlineObjectSize)
+}
+
 // Delete deletes an artifact from an S3 compliant storage
 func (s3Driver *ArtifactDriver) Delete(ctx context.Context, artifact *wfv1.Artifact) error {
+	_, err := s3Driver.DeleteWithKeys(ctx, artifact)
+	return err
+}
+
+// DeleteWithKeys deletes an artifact like Delete, and returns the keys it deleted, or with DryRun would have.
+// A single key deleted is returned whether or not its object existed, as S3 doesn't tell.
+func (s3Driver *ArtifactDriver) DeleteWithKeys(ctx context.Context, artifact *wfv1.Artifact) (keys []string, err error) {
+	ctx, span := startSpan(ctx, "S3 Delete", artifact)
+	defer func() { endSpan(span, err, "") }()
+
+	if err := s3Driver.checkKeyPrefix(artifact); err != nil {
+		return nil, err
+	}
+	if s3Driver.DryRun {
+		return s3Driver.deleteDryRun(ctx, artifact)
+	}
+	if err := s3Driver.checkWritable("Delete"); err != nil {
+		return nil, err
+	}
+
+	// check suffix instead of s3cli.IsDirectory as it requires another request for file delete (most scenarios)
+	if strings.HasSuffix(artifact.S3.Key, "/") {
+		return s3Driver.deleteObjectsWithKeys(ctx, artifact)
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	log := logging.RequireLoggerFromContext(ctx)
-	err := retry.OnError(retry.DefaultBackoff, func(err error) bool {
+	err = retry.OnError(s3Driver.retryBackoff(ctx), func(err error) bool {
 		return isTransientS3Err(ctx, err)
 	}, func() error {
 		log.WithField("key", artifact.S3.Key).Info(ctx, "S3 Delete")
@@ -277,66 +1005,183 @@ func (s3Driver *ArtifactDriver) Delete(ctx context.Context, artifact *wfv1.Artif
 		if err != nil {
 			return err
 		}
+		return s3cli.Delete(artifact.S3.Bucket, artifact.S3.Key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []string{artifact.S3.Key}, nil
+}
 
-		// check suffix instead of s3cli.IsDirectory as it requires another request for file delete (most scenarios)
-		if !strings.HasSuffix(artifact.S3.Key, "/") {
-			return s3cli.Delete(artifact.S3.Bucket, artifact.S3.Key)
-		}
+// DeleteDryRun logs the keys Delete would remove for the artifact, without deleting anything,
+// and returns how many objects would have been deleted
+func (s3Driver *ArtifactDriver) DeleteDryRun(ctx context.Context, artifact *wfv1.Artifact) (int, error) {
+	keys, err := s3Driver.deleteDryRun(ctx, artifact)
+	return len(keys), err
+}
 
+// deleteDryRun logs and returns the keys Delete would remove for the artifact, without deleting anything
+func (s3Driver *ArtifactDriver) deleteDryRun(ctx context.Context, artifact *wfv1.Artifact) ([]string, error) {
+	log := logging.RequireLoggerFromContext(ctx)
+	s3cli, err := s3Driver.newS3Client(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new S3 client: %w", err)
+	}
+	keys, err := deleteDryRunKeys(s3cli, artifact)
+	if err != nil {
+		return nil, err
+	}
+	log.WithFields(logging.Fields{"bucket": artifact.S3.Bucket, "key": artifact.S3.Key, "keys": keys, "count": len(keys)}).Info(ctx, "S3 Delete dry run, not deleting")
+	return keys, nil
+}
+
+// deleteDryRunKeys returns the keys Delete would remove for the artifact
+func deleteDryRunKeys(s3cli S3Client, artifact *wfv1.Artifact) ([]string, error) {
+	if strings.HasSuffix(artifact.S3.Key, "/") {
 		keys, err := s3cli.ListDirectory(artifact.S3.Bucket, artifact.S3.Key)
 		if err != nil {
-			return fmt.Errorf("unable to list files in %s: %s", artifact.S3.Key, err)
+			return nil, fmt.Errorf("unable to list files in %s: %s", artifact.S3.Key, err)
 		}
-		for _, objKey := range keys {
-			err = s3cli.Delete(artifact.S3.Bucket, objKey)
-			if err != nil {
-				return err
-			}
+		return keys, nil
+	}
+	exists, err := s3cli.KeyExists(artifact.S3.Bucket, artifact.S3.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if key %s exists from bucket %s: %w", artifact.S3.Key, artifact.S3.Bucket, err)
+	}
+	if !exists {
+		return nil, nil
+	}
+	return []string{artifact.S3.Key}, nil
+}
+
+// DeleteObjects deletes every object under the artifact's key prefix, in batches of up to 1000 keys per request
+func (s3Driver *ArtifactDriver) DeleteObjects(ctx context.Context, artifact *wfv1.Artifact) error {
+	_, err := s3Driver.deleteObjectsWithKeys(ctx, artifact)
+	return err
+}
+
+// deleteObjectsWithKeys deletes every object under the artifact's key prefix like DeleteObjects, and returns the keys
+// deleted, even when some could not be
+func (s3Driver *ArtifactDriver) deleteObjectsWithKeys(ctx context.Context, artifact *wfv1.Artifact) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	log := logging.RequireLoggerFromContext(ctx)
+	var keys []string
+	err := retry.OnError(s3Driver.retryBackoff(ctx), func(err error) bool {
+		return isTransientS3Err(ctx, err)
+	}, func() error {
+		log.WithField("key", artifact.S3.Key).Info(ctx, "S3 DeleteObjects")
+		s3cli, err := s3Driver.newS3Client(ctx)
+		if err != nil {
+			return err
 		}
-		return nil
+		deleted, err := deleteObjects(s3cli, artifact)
+		keys = append(keys, deleted...)
+		return err
 	})
+	return keys, err
+}
 
-	return err
+// deleteObjects lists the objects under the artifact's key prefix and batch deletes them, returning the keys
+// deleted. When only some could be, those which were are returned with the DeleteObjectsError.
+func deleteObjects(s3cli S3Client, artifact *wfv1.Artifact) ([]string, error) {
+	keys, err := s3cli.ListDirectory(artifact.S3.Bucket, artifact.S3.Key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list files in %s: %s", artifact.S3.Key, err)
+	}
+	return deleteKeys(s3cli, artifact.S3.Bucket, keys)
+}
+
+// deleteKeys batch deletes the keys, returning those deleted. When only some could be, those which were are
+// returned with the DeleteObjectsError.
+func deleteKeys(s3cli S3Client, bucket string, keys []string) ([]string, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	err := s3cli.DeleteObjects(bucket, keys)
+	var deleteErr *DeleteObjectsError
+	if errors.As(err, &deleteErr) {
+		return slices.DeleteFunc(keys, func(key string) bool { _, failed := deleteErr.Failed[key]; return failed }), err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
 }
 
-// saveS3Artifact uploads artifacts to an S3 compliant storage
+// saveS3Artifact uploads the data read from r, size bytes or until it is exhausted when size is negative, as the
+// artifact's object. A regular file read from its start is uploaded from its path, so gets the content type of its
+// extension, and the checksum of the whole file when one is sent.
 // returns true if the upload is completed or can't be retried (non-transient error)
 // returns false if it can be retried (transient error)
-func saveS3Artifact(ctx context.Context, s3cli S3Client, path string, outputArtifact *wfv1.Artifact) (bool, error) {
-	isDir, err := file.IsDirectory(path)
-	if err != nil {
-		return true, fmt.Errorf("failed to test if %s is a directory: %v", path, err)
+func saveS3Artifact(ctx context.Context, s3cli S3Client, r io.Reader, size int64, outputArtifact *wfv1.Artifact) (bool, error) {
+	if err := createBucketIfNotPresent(ctx, s3cli, outputArtifact); err != nil {
+		return !isTransientS3Err(ctx, err), err
 	}
-	log := logging.RequireLoggerFromContext(ctx)
-	createBucketIfNotPresent := outputArtifact.S3.CreateBucketIfNotPresent
-	if createBucketIfNotPresent != nil {
-		log.WithField("bucket", outputArtifact.S3.Bucket).Info(ctx, "creating bucket")
-		err := s3cli.MakeBucket(outputArtifact.S3.Bucket, minio.MakeBucketOptions{
-			Region:        outputArtifact.S3.Region,
-			ObjectLocking: outputArtifact.S3.CreateBucketIfNotPresent.ObjectLocking,
-		})
-		alreadyExists := bucketAlreadyExistsErr(err)
-		log.WithField("bucket", outputArtifact.S3.Bucket).
-			WithField("alreadyExists", alreadyExists).
-			WithError(err).
-			Info(ctx, "create bucket failed")
-		if err != nil && !alreadyExists {
-			return !isTransientS3Err(ctx, err), fmt.Errorf("failed to create bucket %s: %v", outputArtifact.S3.Bucket, err)
+	if f := fileAtStart(r); f != nil {
+		if err := s3cli.PutFile(outputArtifact.S3.Bucket, outputArtifact.S3.Key, f.Name()); err != nil {
+			return !isTransientS3Err(ctx, err), fmt.Errorf("failed to put file: %w", err)
 		}
+		return true, nil
 	}
+	if err := s3cli.PutReader(outputArtifact.S3.Bucket, outputArtifact.S3.Key, r, size, ""); err != nil {
+		return !isTransientS3Err(ctx, err), fmt.Errorf("failed to put object: %w", err)
+	}
+	return true, nil
+}
 
-	if isDir {
-		if err = s3cli.PutDirectory(outputArtifact.S3.Bucket, outputArtifact.S3.Key, path); err != nil {
-			return !isTransientS3Err(ctx, err), fmt.Errorf("failed to put directory: %v", err)
-		}
-	} else {
-		if err = s3cli.PutFile(outputArtifact.S3.Bucket, outputArtifact.S3.Key, path); err != nil {
-			return !isTransientS3Err(ctx, err), fmt.Errorf("failed to put file: %v", err)
-		}
+// fileAtStart returns r when it is a regular file which hasn't been read from, and otherwise nil
+func fileAtStart(r io.Reader) *os.File {
+	f, ok := r.(*os.File)
+	if !ok {
+		return nil
+	}
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	if offset, err := f.Seek(0, io.SeekCurrent); err != nil || offset != 0 {
+		return nil
+	}
+	return f
+}
+
+// saveS3Directory uploads each file under path to the key of its relative path under the artifact's key
+// returns true if the upload is completed or can't be retried (non-transient error)
+// returns false if it can be retried (transient error)
+func saveS3Directory(ctx context.Context, s3cli S3Client, path string, outputArtifact *wfv1.Artifact) (bool, error) {
+	if err := createBucketIfNotPresent(ctx, s3cli, outputArtifact); err != nil {
+		return !isTransientS3Err(ctx, err), err
+	}
+	if err := s3cli.PutDirectory(outputArtifact.S3.Bucket, outputArtifact.S3.Key, path); err != nil {
+		return !isTransientS3Err(ctx, err), fmt.Errorf("failed to put directory: %w", err)
 	}
 	return true, nil
 }
 
+// createBucketIfNotPresent creates the artifact's bucket when CreateBucketIfNotPresent is set, a bucket which
+// already exists is left as it is
+func createBucketIfNotPresent(ctx context.Context, s3cli S3Client, outputArtifact *wfv1.Artifact) error {
+	if outputArtifact.S3.CreateBucketIfNotPresent == nil {
+		return nil
+	}
+	log := logging.RequireLoggerFromContext(ctx)
+	log.WithField("bucket", outputArtifact.S3.Bucket).Info(ctx, "creating bucket")
+	err := s3cli.MakeBucket(outputArtifact.S3.Bucket, minio.MakeBucketOptions{
+		Region:        outputArtifact.S3.Region,
+		ObjectLocking: outputArtifact.S3.CreateBucketIfNotPresent.ObjectLocking,
+	})
+	alreadyExists := bucketAlreadyExistsErr(err)
+	log.WithField("bucket", outputArtifact.S3.Bucket).
+		WithField("alreadyExists", alreadyExists).
+		WithError(err).
+		Info(ctx, "create bucket failed")
+	if err != nil && !alreadyExists {
+		return fmt.Errorf("failed to create bucket %s: %w", outputArtifact.S3.Bucket, err)
+	}
+	return nil
+}
+
 func bucketAlreadyExistsErr(err error) bool {
 	resp := &minio.ErrorResponse{}
 	// https://docs.aws.amazon.com/AmazonS3/latest/API/ErrorResponses.html
@@ -346,46 +1191,316 @@ func bucketAlreadyExistsErr(err error) bool {
 
 // ListObjects returns the files inside the directory represented by the Artifact
 func (s3Driver *ArtifactDriver) ListObjects(ctx context.Context, artifact *wfv1.Artifact) ([]string, error) {
+	if err := s3Driver.checkKeyPrefix(artifact); err != nil {
+		return nil, err
+	}
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	var files []string
 	var done bool
-	err := waitutil.Backoff(executorretry.ExecutorRetry(ctx),
+	err := backoff(s3Driver.retryBackoff(ctx),
 		func() (bool, error) {
 			s3cli, err := s3Driver.newS3Client(ctx)
 			if err != nil {
-				return !isTransientS3Err(ctx, err), fmt.Errorf("failed to create new S3 client: %v", err)
+				return !isTransientS3Err(ctx, err), fmt.Errorf("failed to create new S3 client: %w", err)
 			}
-			done, files, err = listObjects(ctx, s3cli, artifact)
+			done, files, err = listObjects(ctx, s3cli, artifact, !s3Driver.ListNonRecursive)
 			return done, err
 		})
+	if err != nil || s3Driver.ListPattern == "" {
+		return files, err
+	}
+
+	return filterKeys(files, artifact.S3.Key, s3Driver.ListPattern)
+}
 
-	return files, err
+// filterKeys returns the keys whose suffix after prefix matches pattern, using path.Match semantics.
+// A common prefix is matched without its trailing /, so a pattern can select sub-directories by name.
+func filterKeys(keys []string, prefix, pattern string) ([]string, error) {
+	var matched []string
+	for _, key := range keys {
+		relative := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(key, prefix), "/"), "/")
+		ok, err := path.Match(pattern, relative)
+		if err != nil {
+			return nil, fmt.Errorf("invalid list pattern %q: %w", pattern, err)
+		}
+		if ok {
+			matched = append(matched, key)
+		}
+	}
+	return matched, nil
 }
 
-// listObjects returns the files inside the directory represented by the Artifact
+// listObjects returns the files inside the directory represented by the Artifact. Unless recursive, only the
+// files directly inside it are returned, along with the common prefix of each sub-directory.
 // returns true if success or can't be retried (non-transient error)
 // returns false if it can be retried (transient error)
-func listObjects(ctx context.Context, s3cli S3Client, artifact *wfv1.Artifact) (bool, []string, error) {
+func listObjects(ctx context.Context, s3cli S3Client, artifact *wfv1.Artifact, recursive bool) (bool, []string, error) {
+	listDirectory := s3cli.ListDirectory
+	if !recursive {
+		listDirectory = s3cli.ListDirectoryLevel
+	}
+	files, err := listDirectory(artifact.S3.Bucket, artifact.S3.Key)
+	if err != nil {
+		return !isTransientS3Err(ctx, err), files, fmt.Errorf("failed to list directory: %w", err)
+	}
+	log := logging.RequireLoggerFromContext(ctx)
+	log.WithFields(logging.Fields{"bucket": artifact.S3.Bucket, "key": artifact.S3.Key, "files": files}).Debug(ctx, "successfully listing S3 directory")
+
+	if len(files) == 0 {
+		directoryExists, err := s3cli.KeyExists(artifact.S3.Bucket, artifact.S3.Key)
+		if err != nil {
+			return !isTransientS3Err(ctx, err), files, fmt.Errorf("failed to check if key %s exists from bucket %s: %w", artifact.S3.Key, artifact.S3.Bucket, err)
+		}
+		if !directoryExists {
+			return true, files, argoerrs.New(argoerrs.CodeNotFound, fmt.Sprintf("no key found of name %s", artifact.S3.Key))
+		}
+	}
+	return true, files, nil
+}
+
+// ListObjectsPage returns one page of the files inside the directory represented by the Artifact,
+// along with the continuation token for the next page. An empty token means there are no more pages.
+// A pageSize of zero or less lists everything in a single page, like ListObjects.
+func (s3Driver *ArtifactDriver) ListObjectsPage(ctx context.Context, artifact *wfv1.Artifact, pageSize int, continuationToken string) ([]string, string, error) {
+	if pageSize <= 0 {
+		files, err := s3Driver.ListObjects(ctx, artifact)
+		return files, "", err
+	}
+	if err := s3Driver.checkKeyPrefix(artifact); err != nil {
+		return nil, "", err
+	}
+
 	var files []string
-	files, err := s3cli.ListDirectory(artifact.S3.Bucket, artifact.S3.Key)
+	var nextToken string
+	err := backoff(s3Driver.retryBackoff(ctx),
+		func() (bool, error) {
+			s3cli, err := s3Driver.newS3Client(ctx)
+			if err != nil {
+				return !isTransientS3Err(ctx, err), fmt.Errorf("failed to create new S3 client: %w", err)
+			}
+			var done bool
+			done, files, nextToken, err = listObjectsPage(ctx, s3cli, artifact, pageSize, continuationToken)
+			return done, err
+		})
+
+	return files, nextToken, err
+}
+
+// listObjectsPage returns one page of the files inside the directory represented by the Artifact
+// returns true if success or can't be retried (non-transient error)
+// returns false if it can be retried (transient error)
+func listObjectsPage(ctx context.Context, s3cli S3Client, artifact *wfv1.Artifact, pageSize int, continuationToken string) (bool, []string, string, error) {
+	files, nextToken, err := s3cli.ListDirectoryPage(artifact.S3.Bucket, artifact.S3.Key, pageSize, continuationToken)
+	if err != nil {
+		return !isTransientS3Err(ctx, err), nil, "", fmt.Errorf("failed to list directory: %w", err)
+	}
+	return true, files, nextToken, nil
+}
+
+// Exists reports whether the artifact exists without downloading it. A key ending in / is a
+// directory, which exists if at least one object shares the prefix.
+func (s3Driver *ArtifactDriver) Exists(ctx context.Context, artifact *wfv1.Artifact) (bool, error) {
+	log := logging.RequireLoggerFromContext(ctx)
+	log.WithField("key", artifact.S3.Key).Info(ctx, "S3 Exists")
+	s3cli, err := s3Driver.newS3Client(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to create new S3 client: %w", err)
+	}
+	return artifactExists(s3cli, artifact)
+}
+
+func artifactExists(s3cli S3Client, artifact *wfv1.Artifact) (bool, error) {
+	if strings.HasSuffix(artifact.S3.Key, "/") {
+		exists, err := s3cli.IsDirectory(artifact.S3.Bucket, artifact.S3.Key)
+		if err != nil {
+			return false, fmt.Errorf("failed to test if %s is a directory: %w", artifact.S3.Key, err)
+		}
+		return exists, nil
+	}
+	exists, err := s3cli.KeyExists(artifact.S3.Bucket, artifact.S3.Key)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if key %s exists from bucket %s: %w", artifact.S3.Key, artifact.S3.Bucket, err)
+	}
+	return exists, nil
+}
+
+// ObjectStat is the metadata of a single object
+type ObjectStat struct {
+	Size         int64
+	ETag         string
+	ContentType  string
+	LastModified time.Time
+}
+
+// Stat returns the metadata of the artifact's object without downloading it.
+// A missing object is reported as an argo CodeNotFound error.
+func (s3Driver *ArtifactDriver) Stat(ctx context.Context, artifact *wfv1.Artifact) (*ObjectStat, error) {
+	log := logging.RequireLoggerFromContext(ctx)
+	log.WithField("key", artifact.S3.Key).Info(ctx, "S3 Stat")
+	s3cli, err := s3Driver.newS3Client(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new S3 client: %w", err)
+	}
+	return statS3Artifact(s3cli, artifact)
+}
+
+func statS3Artifact(s3cli S3Client, artifact *wfv1.Artifact) (*ObjectStat, error) {
+	info, err := s3cli.StatObject(artifact.S3.Bucket, artifact.S3.Key)
+	if IsS3ErrCode(err, "NoSuchKey") {
+		return nil, argoerrs.New(argoerrs.CodeNotFound, err.Error())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", artifact.S3.Key, err)
+	}
+	return &ObjectStat{
+		Size:         info.Size,
+		ETag:         info.ETag,
+		ContentType:  info.ContentType,
+		LastModified: info.LastModified,
+	}, nil
+}
+
+// Copy copies the src artifact to dst server-side, without transferring the object through the plugin.
+// Both artifacts must be in the same bucket. The metadata is preserved and the destination is written
+// with StorageClass when one is configured.
+func (s3Driver *ArtifactDriver) Copy(ctx context.Context, src, dst *wfv1.Artifact) error {
+	if err := s3Driver.checkWritable("Copy"); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	log := logging.RequireLoggerFromContext(ctx)
+	return retry.OnError(s3Driver.retryBackoff(ctx), func(err error) bool {
+		return isTransientS3Err(ctx, err)
+	}, func() error {
+		log.WithFields(logging.Fields{"src": src.S3.Key, "dst": dst.S3.Key}).Info(ctx, "S3 Copy")
+		s3cli, err := s3Driver.newS3Client(ctx)
+		if err != nil {
+			return err
+		}
+		return copyS3Artifact(s3cli, src, dst)
+	})
+}
+
+func copyS3Artifact(s3cli S3Client, src, dst *wfv1.Artifact) error {
+	if src.S3.Bucket != dst.S3.Bucket {
+		return argoerrs.Errorf(argoerrs.CodeBadRequest, "cannot copy between buckets %s and %s", src.S3.Bucket, dst.S3.Bucket)
+	}
+	info, err := s3cli.StatObject(src.S3.Bucket, src.S3.Key)
+	if IsS3ErrCode(err, "NoSuchKey") {
+		return argoerrs.New(argoerrs.CodeNotFound, err.Error())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", src.S3.Key, err)
+	}
+	if info.Size > maxCopyObjectSize {
+		return s3cli.ComposeObject(src.S3.Bucket, info, dst.S3.Key)
+	}
+	return s3cli.CopyObject(src.S3.Bucket, info, dst.S3.Key)
+}
+
+// Move moves the src artifact to dst within a bucket. The object is copied server-side as Copy does, and src is
+// deleted only once a HEAD of dst confirms the copy, so a failure at any point leaves src in place. The copy and
+// the delete are each retried on transient errors, but confirming the copy isn't.
+func (s3Driver *ArtifactDriver) Move(ctx context.Context, src, dst *wfv1.Artifact) error {
+	if err := s3Driver.checkWritable("Move"); err != nil {
+		return err
+	}
+	log := logging.RequireLoggerFromContext(ctx)
+	log.WithFields(logging.Fields{"src": src.S3.Key, "dst": dst.S3.Key}).Info(ctx, "S3 Move")
+	s3cli, err := s3Driver.newS3Client(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create new S3 client: %w", err)
+	}
+	return moveS3Artifact(ctx, s3cli, src, dst, s3Driver.retryBackoff(ctx))
+}
+
+func moveS3Artifact(ctx context.Context, s3cli S3Client, src, dst *wfv1.Artifact, b wait.Backoff) error {
+	if src.S3.Bucket == dst.S3.Bucket && src.S3.Key == dst.S3.Key {
+		return argoerrs.Errorf(argoerrs.CodeBadRequest, "cannot move %s onto itself", src.S3.Key)
+	}
+	isTransient := func(err error) bool { return isTransientS3Err(ctx, err) }
+	err := retry.OnError(b, isTransient, func() error {
+		return copyS3Artifact(s3cli, src, dst)
+	})
+	if err != nil {
+		return err
+	}
+	srcInfo, err := s3cli.StatObject(src.S3.Bucket, src.S3.Key)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s after copying it: %w", src.S3.Key, err)
+	}
+	// A multipart copy has a different ETag, so the copy is confirmed by its size
+	dstInfo, err := s3cli.StatObject(dst.S3.Bucket, dst.S3.Key)
+	if err != nil {
+		return fmt.Errorf("failed to confirm the copy of %s to %s, not deleting %s: %w", src.S3.Key, dst.S3.Key, src.S3.Key, err)
+	}
+	if dstInfo.Size != srcInfo.Size {
+		return fmt.Errorf("copy of %s to %s is %d bytes rather than %d, not deleting %s", src.S3.Key, dst.S3.Key, dstInfo.Size, srcInfo.Size, src.S3.Key)
+	}
+	err = retry.OnError(b, isTransient, func() error {
+		err := s3cli.Delete(src.S3.Bucket, src.S3.Key)
+		// An earlier attempt may have deleted src before its response was lost, and the copy is confirmed
+		if IsS3ErrCode(err, "NoSuchKey") {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s after copying it to %s: %w", src.S3.Key, dst.S3.Key, err)
+	}
+	return nil
+}
+
+// PresignedURL returns a URL an external tool can use to GET or PUT the artifact directly, valid for expiry
+func (s3Driver *ArtifactDriver) PresignedURL(ctx context.Context, artifact *wfv1.Artifact, method string, expiry time.Duration) (string, error) {
+	if method != http.MethodGet && method != http.MethodPut {
+		return "", argoerrs.Errorf(argoerrs.CodeBadRequest, "presigned URL method must be %s or %s, got %q", http.MethodGet, http.MethodPut, method)
+	}
+	if expiry <= 0 || expiry > MaxPresignedURLExpiry {
+		return "", argoerrs.Errorf(argoerrs.CodeBadRequest, "presigned URL expiry must be positive and at most %s, got %s", MaxPresignedURLExpiry, expiry)
+	}
+	if method == http.MethodPut {
+		if err := s3Driver.checkWritable("PresignedURL"); err != nil {
+			return "", err
+		}
+	}
+	log := logging.RequireLoggerFromContext(ctx)
+	log.WithFields(logging.Fields{"key": artifact.S3.Key, "method": method, "expiry": expiry}).Info(ctx, "S3 PresignedURL")
+	s3cli, err := s3Driver.newS3Client(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create new S3 client: %w", err)
+	}
+	u, err := s3cli.PresignedURL(method, artifact.S3.Bucket, artifact.S3.Key, expiry)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign %s %s: %w", method, artifact.S3.Key, err)
+	}
+	return u.String(), nil
+}
+
+// ListBuckets returns the name and creation date of every bucket the resolved credentials can see, to tell
+// credential problems apart from a mistyped bucket name
+func (s3Driver *ArtifactDriver) ListBuckets(ctx context.Context) ([]minio.BucketInfo, error) {
+	logging.RequireLoggerFromContext(ctx).WithField("endpoint", s3Driver.Endpoint).Info(ctx, "S3 ListBuckets")
+	s3cli, err := s3Driver.newS3Client(ctx)
 	if err != nil {
-		return !isTransientS3Err(ctx, err), files, fmt.Errorf("failed to list directory: %v", err)
+		return nil, fmt.Errorf("failed to create new S3 client: %w", err)
 	}
-	log := logging.RequireLoggerFromContext(ctx)
-	log.WithFields(logging.Fields{"bucket": artifact.S3.Bucket, "key": artifact.S3.Key, "files": files}).Debug(ctx, "successfully listing S3 directory")
+	return listBuckets(s3cli)
+}
 
-	if len(files) == 0 {
-		directoryExists, err := s3cli.KeyExists(artifact.S3.Bucket, artifact.S3.Key)
-		if err != nil {
-			return !isTransientS3Err(ctx, err), files, fmt.Errorf("failed to check if key %s exists from bucket %s: %v", artifact.S3.Key, artifact.S3.Bucket, err)
-		}
-		if !directoryExists {
-			return true, files, argoerrs.New(argoerrs.CodeNotFound, fmt.Sprintf("no key found of name %s", artifact.S3.Key))
-		}
+// listBuckets lists the buckets, reporting a permission error when the credentials may not list them
+func listBuckets(s3cli S3Client) ([]minio.BucketInfo, error) {
+	buckets, err := s3cli.ListBuckets()
+	if IsS3ErrCode(err, "AccessDenied") {
+		return nil, argoerrs.Errorf(argoerrs.CodeForbidden, "credentials are not allowed to list buckets, s3:ListAllMyBuckets is required: %v", err)
 	}
-	return true, files, nil
+	if err != nil {
+		return nil, fmt.Errorf("failed to list buckets: %w", err)
+	}
+	return buckets, nil
 }
 
 func (s3Driver *ArtifactDriver) IsDirectory(ctx context.Context, artifact *wfv1.Artifact) (bool, error) {
@@ -398,7 +1513,15 @@ func (s3Driver *ArtifactDriver) IsDirectory(ctx context.Context, artifact *wfv1.
 
 // Get AWS credentials based on default order from aws SDK
 func getAWSCredentials(ctx context.Context, opts S3ClientOpts) (*credentials.Credentials, error) {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(opts.Region))
+	loadOpts := append([]func(*config.LoadOptions) error{config.WithRegion(opts.Region)}, awsRetryOptions(opts)...)
+	if opts.Profile != "" {
+		loadOpts = append(loadOpts, config.WithSharedConfigProfile(opts.Profile))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, loadOpts...)
+	var notExist config.SharedConfigProfileNotExistError
+	if errors.As(err, &notExist) {
+		return nil, fmt.Errorf("AWS profile %q not found in the shared config or credentials files: %w", opts.Profile, err)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -412,7 +1535,7 @@ func getAWSCredentials(ctx context.Context, opts S3ClientOpts) (*credentials.Cre
 
 // GetAssumeRoleCredentials gets Assumed role credentials
 func getAssumeRoleCredentials(ctx context.Context, opts S3ClientOpts) (*credentials.Credentials, error) {
-	cfg, err := config.LoadDefaultConfig(ctx)
+	cfg, err := config.LoadDefaultConfig(ctx, awsRetryOptions(opts)...)
 	if err != nil {
 		return nil, err
 	}
@@ -421,7 +1544,25 @@ func getAssumeRoleCredentials(ctx context.Context, opts S3ClientOpts) (*credenti
 	// Create the credentials from AssumeRoleProvider to assume the role
 	// referenced by the "myRoleARN" ARN. Prompt for MFA token from stdin.
 
-	creds := stscreds.NewAssumeRoleProvider(client, opts.RoleARN)
+	creds := stscreds.NewAssumeRoleProvider(client, opts.RoleARN, assumeRoleOptions(opts))
+	value, err := creds.Retrieve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewStaticV4(value.AccessKeyID, value.SecretAccessKey, value.SessionToken), nil
+}
+
+// getWebIdentityCredentials gets credentials by assuming a role with a web identity token (e.g. IRSA)
+func getWebIdentityCredentials(ctx context.Context, opts S3ClientOpts) (*credentials.Credentials, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, append([]func(*config.LoadOptions) error{config.WithRegion(opts.Region)}, awsRetryOptions(opts)...)...)
+	if err != nil {
+		return nil, err
+	}
+	client := sts.NewFromConfig(cfg)
+
+	creds := stscreds.NewWebIdentityRoleProvider(client, opts.RoleARN, stscreds.IdentityTokenFile(opts.WebIdentityTokenFile), func(o *stscreds.WebIdentityRoleOptions) {
+		o.RoleSessionName = opts.RoleSessionName
+	})
 	value, err := creds.Retrieve(ctx)
 	if err != nil {
 		return nil, err
@@ -429,8 +1570,36 @@ func getAssumeRoleCredentials(ctx context.Context, opts S3ClientOpts) (*credenti
 	return credentials.NewStaticV4(value.AccessKeyID, value.SecretAccessKey, value.SessionToken), nil
 }
 
+// awsRetryOptions configures the retryer of the AWS SDK clients resolving credentials with the retry mode and
+// maximum attempts, leaving the SDK's defaults for those unset
+func awsRetryOptions(opts S3ClientOpts) []func(*config.LoadOptions) error {
+	var loadOpts []func(*config.LoadOptions) error
+	if opts.RetryMode != "" {
+		loadOpts = append(loadOpts, config.WithRetryMode(aws.RetryMode(opts.RetryMode)))
+	}
+	if opts.RetryMaxAttempts > 0 {
+		loadOpts = append(loadOpts, config.WithRetryMaxAttempts(opts.RetryMaxAttempts))
+	}
+	return loadOpts
+}
+
+// assumeRoleOptions applies the session name and cross-account external ID to an assume-role request
+func assumeRoleOptions(opts S3ClientOpts) func(*stscreds.AssumeRoleOptions) {
+	return func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = opts.RoleSessionName
+		if opts.ExternalID != "" {
+			externalID := opts.ExternalID
+			o.ExternalID = &externalID
+		}
+	}
+}
+
 func GetCredentials(ctx context.Context, opts S3ClientOpts) (*credentials.Credentials, error) {
 	log := logging.RequireLoggerFromContext(ctx)
+	if opts.Anonymous {
+		log.WithField("endpoint", opts.Endpoint).Info(ctx, "Creating minio client using anonymous access")
+		return credentials.NewStatic("", "", "", credentials.SignatureAnonymous), nil
+	}
 	if opts.AccessKey != "" && opts.SecretKey != "" {
 		if opts.SessionToken != "" {
 			log.WithField("endpoint", opts.Endpoint).Info(ctx, "Creating minio client using ephemeral credentials")
@@ -439,11 +1608,14 @@ func GetCredentials(ctx context.Context, opts S3ClientOpts) (*credentials.Creden
 			log.WithField("endpoint", opts.Endpoint).Info(ctx, "Creating minio client using static credentials")
 			return credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, ""), nil
 		}
+	} else if opts.WebIdentityTokenFile != "" && opts.RoleARN != "" {
+		log.WithField("roleArn", opts.RoleARN).Info(ctx, "Creating minio client using web identity credentials")
+		return getWebIdentityCredentials(ctx, opts)
 	} else if opts.RoleARN != "" {
 		log.WithField("roleArn", opts.RoleARN).Info(ctx, "Creating minio client using assumed-role credentials")
 		return getAssumeRoleCredentials(ctx, opts)
 	} else if opts.UseSDKCreds {
-		log.Info(ctx, "Creating minio client using AWS SDK credentials")
+		log.WithField("profile", opts.Profile).Info(ctx, "Creating minio client using AWS SDK credentials")
 		return getAWSCredentials(ctx, opts)
 	} else {
 		log.Info(ctx, "Creating minio client using IAM role")
@@ -481,11 +1653,30 @@ func NewS3Client(ctx context.Context, opts S3ClientOpts) (S3Client, error) {
 	default:
 		bucketLookupType = minio.BucketLookupAuto
 	}
-	minioOpts := &minio.Options{Creds: credentials, Secure: s3cli.Secure, Transport: opts.Transport, Region: s3cli.Region, BucketLookup: bucketLookupType}
+	minioOpts := &minio.Options{
+		Creds: credentials, Secure: s3cli.Secure, Transport: opts.Transport, Region: s3cli.Region, BucketLookup: bucketLookupType,
+		MaxRetries: opts.RetryMaxAttempts,
+	}
+	// minio only sends x-amz-checksum-* headers to servers declared to support trailing headers
+	minioOpts.TrailingHeaders = opts.UploadChecksum != ""
+	var requesterPays *requesterPaysTransport
+	if opts.RequesterPays {
+		base := opts.Transport
+		if base == nil {
+			if base, err = minio.DefaultTransport(s3cli.Secure); err != nil {
+				return nil, err
+			}
+		}
+		requesterPays = &requesterPaysTransport{base: base}
+		minioOpts.Transport = requesterPays
+	}
 	minioClient, err = minio.New(s3cli.Endpoint, minioOpts)
 	if err != nil {
 		return nil, err
 	}
+	if requesterPays != nil {
+		requesterPays.getCreds = minioClient.GetCreds
+	}
 	if opts.Trace {
 		minioClient.TraceOn(os.Stderr)
 	}
@@ -507,17 +1698,188 @@ func NewS3Client(ctx context.Context, opts S3ClientOpts) (S3Client, error) {
 // PutFile puts a single file to a bucket at the specified key
 func (s *s3client) PutFile(bucket, key, path string) error {
 	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "key": key, "path": path}).Info(s.ctx, "Saving file to s3")
-	// NOTE: minio will detect proper mime-type based on file extension
 
+	putOpts, err := s.putObjectOptions(bucket, key)
+	if err != nil {
+		return err
+	}
+	if putOpts.ContentType, err = s.contentType(path); err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	s.multipartOptions(info.Size(), &putOpts)
+	if s.UploadChecksum != "" {
+		if err := uploadChecksum(path, s.UploadChecksum, &putOpts); err != nil {
+			return fmt.Errorf("failed to compute the %s checksum of %s: %v", s.UploadChecksum, path, err)
+		}
+	}
+	if s.ProgressInterval <= 0 {
+		_, err = s.minioClient.FPutObject(s.ctx, bucket, key, path, putOpts)
+	} else {
+		err = s.putFileWithProgress(bucket, key, path, putOpts)
+	}
+	return s.objectLockError(bucket, err)
+}
+
+// PutBytes puts data to a bucket at the specified key in a single request
+func (s *s3client) PutBytes(bucket, key string, data []byte, contentType string) error {
+	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "key": key, "size": len(data)}).Info(s.ctx, "Saving object to s3")
+
+	putOpts, err := s.putObjectOptions(bucket, key)
+	if err != nil {
+		return err
+	}
+	putOpts.ContentType = cmp.Or(contentType, s.ContentType, http.DetectContentType(data))
+	putOpts.DisableMultipart = true
+	if s.UploadChecksum != "" {
+		bytesChecksum(data, s.UploadChecksum, &putOpts)
+	}
+	_, err = s.minioClient.PutObject(s.ctx, bucket, key, bytes.NewReader(data), int64(len(data)), putOpts)
+	return s.objectLockError(bucket, err)
+}
+
+// PutReader puts size bytes read from r to a bucket at the specified key. An empty contentType uses the
+// configured content type, or the one detected from the first bytes of r. A negative size uploads in parts until r
+// is exhausted, as minio requires a length for a single PUT.
+func (s *s3client) PutReader(bucket, key string, r io.Reader, size int64, contentType string) error {
+	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "key": key, "size": size}).Info(s.ctx, "Saving stream to s3")
+
+	putOpts, err := s.putObjectOptions(bucket, key)
+	if err != nil {
+		return err
+	}
+	if putOpts.ContentType = cmp.Or(contentType, s.ContentType); putOpts.ContentType == "" {
+		buffered := bufio.NewReaderSize(r, 512)
+		head, err := buffered.Peek(512)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		putOpts.ContentType = http.DetectContentType(head)
+		r = buffered
+	}
+	if size < 0 {
+		putOpts.PartSize = uint64(s.MultipartPartSize)
+		putOpts.NumThreads = uint(s.MultipartConcurrency)
+	} else {
+		s.multipartOptions(size, &putOpts)
+	}
+	if s.UploadChecksum != "" {
+		putOpts.Checksum = uploadChecksumType(s.UploadChecksum)
+	}
+	_, err = s.minioClient.PutObject(s.ctx, bucket, key, r, size, putOpts)
+	return s.objectLockError(bucket, err)
+}
+
+// objectLockError explains S3 rejecting an upload's retention because the bucket doesn't have object lock enabled
+func (s *s3client) objectLockError(bucket string, err error) error {
+	if s.ObjectLockMode == "" || !IsS3ErrCode(err, "InvalidRequest") {
+		return err
+	}
+	// AWS reports "Bucket is missing Object Lock Configuration", MinIO "Bucket is missing ObjectLockConfiguration"
+	message := strings.ToLower(strings.ReplaceAll(minio.ToErrorResponse(err).Message, " ", ""))
+	if !strings.Contains(message, "objectlock") {
+		return err
+	}
+	return fmt.Errorf("%w: objectLockMode requires object lock to be enabled on bucket %s: %w", ErrInvalidConfig, bucket, err)
+}
+
+// multipartOptions uploads files of at least MultipartThreshold bytes in parts of MultipartPartSize bytes,
+// MultipartConcurrency at a time, and smaller files in a single PUT. minio's defaults are used for any unset.
+// minio completes the upload with the parts in order, and aborts it if any part fails.
+func (s *s3client) multipartOptions(size int64, putOpts *minio.PutObjectOptions) {
+	if s.MultipartThreshold > 0 && size < s.MultipartThreshold {
+		putOpts.DisableMultipart = true
+		return
+	}
+	putOpts.PartSize = uint64(s.MultipartPartSize)
+	putOpts.NumThreads = uint(s.MultipartConcurrency)
+}
+
+// contentType returns the configured ContentType, or the one detected for the file when none is configured
+func (s *s3client) contentType(path string) (string, error) {
+	if s.ContentType != "" {
+		return s.ContentType, nil
+	}
+	return detectContentType(path)
+}
+
+// detectContentType returns the content type for the file's extension, falling back to sniffing its first 512 bytes
+func detectContentType(path string) (string, error) {
+	if contentType := mime.TypeByExtension(filepath.Ext(path)); contentType != "" {
+		return contentType, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// putObjectOptions returns the upload options for the key, an empty StorageClass leaves the bucket default
+// and ObjectTags are sent in the x-amz-tagging header. CacheControl and ContentDisposition are only sent when set. IfMatch and IfNoneMatch make S3 reject the upload with
+// PreconditionFailed when the existing object doesn't meet them.
+func (s *s3client) putObjectOptions(bucket, key string) (minio.PutObjectOptions, error) {
 	encOpts, err := s.EncryptOpts.buildServerSideEnc(bucket, key)
+	if err != nil {
+		return minio.PutObjectOptions{}, err
+	}
+	putOpts := minio.PutObjectOptions{
+		SendContentMd5:       s.SendContentMd5,
+		ServerSideEncryption: encOpts,
+		StorageClass:         s.StorageClass,
+		UserTags:             s.ObjectTags,
+		CacheControl:         s.CacheControl,
+		ContentDisposition:   s.ContentDisposition,
+		// requesterPaysTransport can't sign a request again once its body is signed chunk by chunk
+		DisableContentSha256: s.RequesterPays,
+	}
+	if s.ObjectLockMode != "" {
+		putOpts.Mode = minio.RetentionMode(s.ObjectLockMode)
+		putOpts.RetainUntilDate = s.ObjectLockRetainUntil
+		if putOpts.RetainUntilDate.IsZero() {
+			putOpts.RetainUntilDate = time.Now().Add(s.ObjectLockRetention)
+		}
+	}
+	if s.ACL != "" {
+		// minio has no option for it, but sends user metadata which is an x-amz- header as it is
+		putOpts.UserMetadata = map[string]string{headerACL: s.ACL}
+	}
+	if s.IfMatch != "" {
+		putOpts.SetMatchETag(strings.Trim(s.IfMatch, `"`))
+	}
+	if s.IfNoneMatch != "" {
+		putOpts.SetMatchETagExcept(strings.Trim(s.IfNoneMatch, `"`))
+	}
+	return putOpts, nil
+}
+
+// putFileWithProgress uploads a file through a counting reader which periodically logs the upload progress
+func (s *s3client) putFileWithProgress(bucket, key, path string, putOpts minio.PutObjectOptions) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
 	if err != nil {
 		return err
 	}
 
-	_, err = s.minioClient.FPutObject(s.ctx, bucket, key, path, minio.PutObjectOptions{SendContentMd5: s.SendContentMd5, ServerSideEncryption: encOpts})
+	reader := newCountingReader(s.ctx, f, key, info.Size(), s.ProgressInterval)
+	_, err = s.minioClient.PutObject(s.ctx, bucket, key, reader, info.Size(), putOpts)
 	if err != nil {
 		return err
 	}
+	reader.logProgress()
 	return nil
 }
 
@@ -584,20 +1946,19 @@ func (s *s3client) PutDirectory(bucket, key, path string) error {
 	return nil
 }
 
-// GetFile downloads a file to a local file path
-func (s *s3client) GetFile(bucket, key, path string) error {
-	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "key": key, "path": path}).Info(s.ctx, "Getting file from s3")
+// GetFile downloads an object into f, which must be an empty regular file
+func (s *s3client) GetFile(bucket, key string, f *os.File) error {
+	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "key": key, "path": f.Name()}).Info(s.ctx, "Getting file from s3")
 
 	encOpts, err := s.EncryptOpts.buildServerSideEnc(bucket, key)
 	if err != nil {
 		return err
 	}
 
-	err = s.minioClient.FGetObject(s.ctx, bucket, key, path, minio.GetObjectOptions{ServerSideEncryption: encOpts})
-	if err != nil {
-		return err
+	if s.DownloadConcurrency > 1 {
+		return s.getFileRanged(bucket, key, f, encOpts)
 	}
-	return nil
+	return s.getFileStream(bucket, key, f, encOpts)
 }
 
 // OpenFile opens a file for reading
@@ -608,7 +1969,7 @@ func (s *s3client) OpenFile(bucket, key string) (io.ReadCloser, error) {
 	if err != nil {
 		return nil, err
 	}
-	f, err := s.minioClient.GetObject(s.ctx, bucket, key, minio.GetObjectOptions{ServerSideEncryption: encOpts})
+	f, err := s.minioClient.GetObject(s.ctx, bucket, key, s.getObjectOptions(encOpts))
 	if err != nil {
 		return nil, err
 	}
@@ -620,6 +1981,42 @@ func (s *s3client) OpenFile(bucket, key string) (io.ReadCloser, error) {
 	return f, nil
 }
 
+// OpenFileRange opens a byte range of a file for reading. The range must lie within the object.
+func (s *s3client) OpenFileRange(bucket, key string, offset, length int64) (io.ReadCloser, error) {
+	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "key": key, "offset": offset, "length": length}).Info(s.ctx, "Opening file range from s3")
+
+	encOpts, err := s.EncryptOpts.buildServerSideEnc(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.minioClient.StatObject(s.ctx, bucket, key, s.getObjectOptions(encOpts))
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 || length < 0 || offset >= info.Size || offset+length > info.Size {
+		return nil, fmt.Errorf("%w: range of %d bytes from offset %d is outside %s, which is %d bytes", ErrInvalidConfig, length, offset, key, info.Size)
+	}
+	opts := s.getObjectOptions(encOpts)
+	// The range must come from the object which was checked
+	if err := opts.SetMatchETag(info.ETag); err != nil {
+		return nil, err
+	}
+	end := offset + length - 1
+	if length == 0 {
+		end = info.Size - 1
+	}
+	if err := opts.SetRange(offset, end); err != nil {
+		return nil, err
+	}
+	return s.minioClient.GetObject(s.ctx, bucket, key, opts)
+}
+
+// ListBuckets lists the buckets the credentials can see
+func (s *s3client) ListBuckets() ([]minio.BucketInfo, error) {
+	logging.RequireLoggerFromContext(s.ctx).WithField("endpoint", s.Endpoint).Info(s.ctx, "Listing buckets from s3")
+	return s.minioClient.ListBuckets(s.ctx)
+}
+
 // checks if object exists (and if we have permission to access)
 func (s *s3client) KeyExists(bucket, key string) (bool, error) {
 	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "key": key}).Info(s.ctx, "Checking key exists from s3")
@@ -629,7 +2026,7 @@ func (s *s3client) KeyExists(bucket, key string) (bool, error) {
 		return false, err
 	}
 
-	_, err = s.minioClient.StatObject(s.ctx, bucket, key, minio.StatObjectOptions{ServerSideEncryption: encOpts})
+	_, err = s.minioClient.StatObject(s.ctx, bucket, key, s.getObjectOptions(encOpts))
 	if err == nil {
 		return true, nil
 	}
@@ -640,11 +2037,141 @@ func (s *s3client) KeyExists(bucket, key string) (bool, error) {
 	return false, err
 }
 
+// StatObject returns the metadata of an object without downloading it
+func (s *s3client) StatObject(bucket, key string) (minio.ObjectInfo, error) {
+	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "key": key}).Info(s.ctx, "Getting object metadata from s3")
+
+	encOpts, err := s.EncryptOpts.buildServerSideEnc(bucket, key)
+	if err != nil {
+		return minio.ObjectInfo{}, err
+	}
+
+	opts := s.getObjectOptions(encOpts)
+	// Checksum asks for the object's x-amz-checksum-* values, used to verify downloads
+	opts.Checksum = true
+	return s.minioClient.StatObject(s.ctx, bucket, key, opts)
+}
+
+// CopyObject copies the src object to dstKey within the bucket server-side, for objects up to 5GB
+func (s *s3client) CopyObject(bucket string, src minio.ObjectInfo, dstKey string) error {
+	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "src": src.Key, "dst": dstKey}).Info(s.ctx, "Copying object in s3")
+
+	srcOpts, dstOpts, err := s.copyOptions(bucket, src, dstKey)
+	if err != nil {
+		return err
+	}
+	_, err = s.minioClient.CopyObject(s.ctx, dstOpts, srcOpts)
+	return err
+}
+
+// ComposeObject copies the src object to dstKey within the bucket server-side using a multipart copy
+func (s *s3client) ComposeObject(bucket string, src minio.ObjectInfo, dstKey string) error {
+	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "src": src.Key, "dst": dstKey, "size": src.Size}).Info(s.ctx, "Copying object in s3 using a multipart copy")
+
+	srcOpts, dstOpts, err := s.copyOptions(bucket, src, dstKey)
+	if err != nil {
+		return err
+	}
+	_, err = s.minioClient.ComposeObject(s.ctx, dstOpts, srcOpts)
+	return err
+}
+
+// copyOptions returns the copy options for src, pinned to its ETag. Changing the storage class requires replacing
+// the metadata, so the source metadata is carried over explicitly in that case.
+func (s *s3client) copyOptions(bucket string, src minio.ObjectInfo, dstKey string) (minio.CopySrcOptions, minio.CopyDestOptions, error) {
+	srcEnc, err := s.EncryptOpts.buildServerSideEnc(bucket, src.Key)
+	if err != nil {
+		return minio.CopySrcOptions{}, minio.CopyDestOptions{}, err
+	}
+	dstEnc, err := s.EncryptOpts.buildServerSideEnc(bucket, dstKey)
+	if err != nil {
+		return minio.CopySrcOptions{}, minio.CopyDestOptions{}, err
+	}
+	srcOpts := minio.CopySrcOptions{Bucket: bucket, Object: src.Key, MatchETag: src.ETag}
+	if srcEnc != nil && srcEnc.Type() == encrypt.SSEC {
+		// Only a customer key has to be sent to read the source
+		srcOpts.Encryption = encrypt.SSECopy(srcEnc)
+	}
+	dstOpts := minio.CopyDestOptions{Bucket: bucket, Object: dstKey, Encryption: dstEnc}
+	if s.StorageClass != "" {
+		dstOpts.ReplaceMetadata = true
+		dstOpts.ContentType = src.ContentType
+		dstOpts.UserMetadata = map[string]string{"X-Amz-Storage-Class": s.StorageClass}
+		for key, value := range src.UserMetadata {
+			dstOpts.UserMetadata[key] = value
+		}
+	}
+	return srcOpts, dstOpts, nil
+}
+
+// PresignedURL returns a URL signed for the HTTP method (GET or PUT) on the key, valid for expiry
+func (s *s3client) PresignedURL(method, bucket, key string, expiry time.Duration) (*url.URL, error) {
+	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "key": key, "method": method}).Info(s.ctx, "Presigning s3 URL")
+
+	return s.minioClient.Presign(s.ctx, method, bucket, key, expiry, nil)
+}
+
 func (s *s3client) Delete(bucket, key string) error {
 	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "key": key}).Info(s.ctx, "Deleting object from s3")
 	return s.minioClient.RemoveObject(s.ctx, bucket, key, minio.RemoveObjectOptions{})
 }
 
+// DeleteObjectsError reports the keys a multi-object delete failed to remove
+type DeleteObjectsError struct {
+	// Total is the number of keys the delete was asked to remove
+	Total int
+	// Failed maps each key that could not be removed to its error
+	Failed map[string]error
+}
+
+func (e *DeleteObjectsError) Error() string {
+	keys := make([]string, 0, len(e.Failed))
+	for key := range e.Failed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	failures := make([]string, 0, len(keys))
+	for _, key := range keys {
+		failures = append(failures, fmt.Sprintf("%s: %v", key, e.Failed[key]))
+	}
+	return fmt.Sprintf("failed to delete %d of %d objects: %s", len(e.Failed), e.Total, strings.Join(failures, "; "))
+}
+
+// Unwrap exposes the individual failures so transient S3 errors can still be detected
+func (e *DeleteObjectsError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, err := range e.Failed {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// DeleteObjects deletes the keys from the bucket, minio batches them into multi-object delete requests of up to 1000 keys
+func (s *s3client) DeleteObjects(bucket string, keys []string) error {
+	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "count": len(keys)}).Info(s.ctx, "Deleting objects from s3")
+
+	objectsCh := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(objectsCh)
+		for _, key := range keys {
+			select {
+			case objectsCh <- minio.ObjectInfo{Key: key}:
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	failed := make(map[string]error)
+	for removeErr := range s.minioClient.RemoveObjects(s.ctx, bucket, objectsCh, minio.RemoveObjectsOptions{}) {
+		failed[removeErr.ObjectName] = removeErr.Err
+	}
+	if len(failed) > 0 {
+		return &DeleteObjectsError{Total: len(keys), Failed: failed}
+	}
+	return nil
+}
+
 // GetDirectory downloads a s3 directory to a local path
 func (s *s3client) GetDirectory(bucket, keyPrefix, path string) error {
 	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "key": keyPrefix, "path": path}).Info(s.ctx, "Getting directory from s3")
@@ -653,16 +2180,27 @@ func (s *s3client) GetDirectory(bucket, keyPrefix, path string) error {
 		return err
 	}
 
+	// Create the destination even when the directory is only an empty marker
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return err
+	}
+	keyPrefix = directoryPrefix(keyPrefix)
 	for _, objKey := range keys {
-		relKeyPath := strings.TrimPrefix(objKey, keyPrefix)
+		relKeyPath := filepath.FromSlash(strings.TrimPrefix(objKey, keyPrefix))
+		if !filepath.IsLocal(relKeyPath) {
+			return fmt.Errorf("refusing to download %s outside of %s", objKey, path)
+		}
 		localPath := filepath.Join(path, relKeyPath)
+		if err := os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
+			return err
+		}
 
 		encOpts, err := s.EncryptOpts.buildServerSideEnc(bucket, objKey)
 		if err != nil {
 			return err
 		}
 
-		err = s.minioClient.FGetObject(s.ctx, bucket, objKey, localPath, minio.GetObjectOptions{ServerSideEncryption: encOpts})
+		err = s.minioClient.FGetObject(s.ctx, bucket, objKey, localPath, s.getObjectOptions(encOpts))
 		if err != nil {
 			return err
 		}
@@ -670,28 +2208,38 @@ func (s *s3client) GetDirectory(bucket, keyPrefix, path string) error {
 	return nil
 }
 
+// directoryPrefix returns the key prefix of the objects in the keyPrefix directory, ending with a /
+func directoryPrefix(keyPrefix string) string {
+	if keyPrefix == "" {
+		return ""
+	}
+	keyPrefix = filepath.Clean(keyPrefix) + "/"
+	if os.PathSeparator == '\\' {
+		keyPrefix = strings.ReplaceAll(keyPrefix, "\\", "/")
+	}
+	return keyPrefix
+}
+
 // IsDirectory tests if the key is acting like a s3 directory. This just means it has at least one
-// object which is prefixed with the given key
+// object which is prefixed with the given key, or an empty directory marker
 func (s *s3client) IsDirectory(bucket, keyPrefix string) (bool, error) {
 	doneCh := make(chan struct{})
 	defer close(doneCh)
 
-	if keyPrefix != "" {
-		keyPrefix = filepath.Clean(keyPrefix) + "/"
-		if os.PathSeparator == '\\' {
-			keyPrefix = strings.ReplaceAll(keyPrefix, "\\", "/")
-		}
-	}
+	keyPrefix = directoryPrefix(keyPrefix)
 
-	listOpts := minio.ListObjectsOptions{
+	listOpts := s.listObjectsOptions(minio.ListObjectsOptions{
 		Prefix:    keyPrefix,
 		Recursive: false,
-	}
+	})
 	objCh := s.minioClient.ListObjects(s.ctx, bucket, listOpts)
 	for obj := range objCh {
 		if obj.Err != nil {
 			return false, obj.Err
-		} else {
+		}
+		// The directory's own key is listed too, as a zero-byte marker when it was created empty by a tool
+		// such as the AWS console. Any other object named after the directory is a file, not a marker.
+		if obj.Key != keyPrefix || obj.Size == 0 {
 			return true, nil
 		}
 	}
@@ -699,22 +2247,30 @@ func (s *s3client) IsDirectory(bucket, keyPrefix string) (bool, error) {
 }
 
 func (s *s3client) ListDirectory(bucket, keyPrefix string) ([]string, error) {
+	objects, err := s.ListDirectoryInfo(bucket, keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, obj := range objects {
+		out = append(out, obj.Key)
+	}
+	return out, nil
+}
+
+// ListDirectoryInfo lists the objects of a directory/bucket, skipping directory marker objects
+func (s *s3client) ListDirectoryInfo(bucket, keyPrefix string) ([]minio.ObjectInfo, error) {
 	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "key": keyPrefix}).Info(s.ctx, "Listing directory from s3")
 
-	if keyPrefix != "" {
-		keyPrefix = filepath.Clean(keyPrefix) + "/"
-		if os.PathSeparator == '\\' {
-			keyPrefix = strings.ReplaceAll(keyPrefix, "\\", "/")
-		}
-	}
+	keyPrefix = directoryPrefix(keyPrefix)
 
 	doneCh := make(chan struct{})
 	defer close(doneCh)
-	listOpts := minio.ListObjectsOptions{
+	listOpts := s.listObjectsOptions(minio.ListObjectsOptions{
 		Prefix:    keyPrefix,
 		Recursive: true,
-	}
-	var out []string
+	})
+	var out []minio.ObjectInfo
 	objCh := s.minioClient.ListObjects(s.ctx, bucket, listOpts)
 	for obj := range objCh {
 		if obj.Err != nil {
@@ -729,11 +2285,102 @@ func (s *s3client) ListDirectory(bucket, keyPrefix string) ([]string, error) {
 			// creates error when downloading the files under the dir.
 			continue
 		}
+		out = append(out, obj)
+	}
+	return out, nil
+}
+
+func (s *s3client) ListDirectoryLevel(bucket, keyPrefix string) ([]string, error) {
+	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "key": keyPrefix}).Info(s.ctx, "Listing directory level from s3")
+
+	keyPrefix = directoryPrefix(keyPrefix)
+
+	// Without Recursive minio lists with the / delimiter, returning each common prefix as an object whose key ends in /
+	var out []string
+	for obj := range s.minioClient.ListObjects(s.ctx, bucket, s.listObjectsOptions(minio.ListObjectsOptions{Prefix: keyPrefix})) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		// Skip the directory's own marker object, as ListDirectory does
+		if obj.Key == keyPrefix {
+			continue
+		}
 		out = append(out, obj.Key)
 	}
 	return out, nil
 }
 
+func (s *s3client) ListDirectoryPage(bucket, keyPrefix string, pageSize int, continuationToken string) ([]string, string, error) {
+	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "key": keyPrefix, "pageSize": pageSize}).Info(s.ctx, "Listing directory page from s3")
+
+	keyPrefix = directoryPrefix(keyPrefix)
+	if s.RequesterPays {
+		return s.listDirectoryPageAfter(bucket, keyPrefix, pageSize, continuationToken)
+	}
+
+	core := minio.Core{Client: s.minioClient}
+	result, err := core.ListObjectsV2(bucket, keyPrefix, "", continuationToken, "", pageSize)
+	if err != nil {
+		return nil, "", err
+	}
+	var out []string
+	for _, obj := range result.Contents {
+		// Skip directory marker objects, as ListDirectory does
+		if strings.HasSuffix(obj.Key, "/") {
+			continue
+		}
+		out = append(out, obj.Key)
+	}
+	if !result.IsTruncated {
+		return out, "", nil
+	}
+	return out, result.NextContinuationToken, nil
+}
+
+// listDirectoryPageAfter lists a page of the keys after startAfter. Unlike minio's Core.ListObjectsV2 its
+// ListObjects sends custom headers such as the requester-pays one, so the last key listed is the continuation token.
+func (s *s3client) listDirectoryPageAfter(bucket, keyPrefix string, pageSize int, startAfter string) ([]string, string, error) {
+	// Stops the listing once the page is full
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	listOpts := s.listObjectsOptions(minio.ListObjectsOptions{Prefix: keyPrefix, Recursive: true, StartAfter: startAfter, MaxKeys: pageSize})
+	var out []string
+	listed, lastKey := 0, ""
+	for obj := range s.minioClient.ListObjects(ctx, bucket, listOpts) {
+		if obj.Err != nil {
+			return nil, "", obj.Err
+		}
+		if listed == pageSize {
+			return out, lastKey, nil
+		}
+		listed, lastKey = listed+1, obj.Key
+		// Skip directory marker objects, as ListDirectory does
+		if strings.HasSuffix(obj.Key, "/") {
+			continue
+		}
+		out = append(out, obj.Key)
+	}
+	return out, "", nil
+}
+
+// getObjectOptions returns the options of a GET or HEAD of an object, asking for the requester to be charged for
+// it when RequesterPays is set
+func (s *s3client) getObjectOptions(sse encrypt.ServerSide) minio.GetObjectOptions {
+	opts := minio.GetObjectOptions{ServerSideEncryption: sse}
+	if s.RequesterPays {
+		opts.Set(headerRequestPayer, requestPayerRequester)
+	}
+	return opts
+}
+
+// listObjectsOptions returns opts, asking for the requester to be charged for the listing when RequesterPays is set
+func (s *s3client) listObjectsOptions(opts minio.ListObjectsOptions) minio.ListObjectsOptions {
+	if s.RequesterPays {
+		opts.Set(headerRequestPayer, requestPayerRequester)
+	}
+	return opts
+}
+
 // IsS3ErrCode returns if the supplied error is of a specific S3 error code
 func IsS3ErrCode(err error, code string) bool {
 	var minioErr minio.ErrorResponse
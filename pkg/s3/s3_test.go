@@ -2,17 +2,39 @@ package s3
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"maps"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/minio/minio-go/v7"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 
+	argoerrs "github.com/argoproj/argo-workflows/v3/errors"
 	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
 	"github.com/argoproj/argo-workflows/v3/util/logging"
 )
@@ -24,6 +46,22 @@ type mockS3Client struct {
 	files map[string][]string
 	// mockedErrs is a map where key is the function name and value is the mocked error of that function
 	mockedErrs map[string]error
+	// deletedKeys records the keys passed to Delete and DeleteObjects
+	deletedKeys []string
+	// putKeys records the keys passed to PutFile and PutDirectory
+	putKeys []string
+	// objectSizes overrides the size StatObject reports for a key
+	objectSizes map[string]int64
+	// objectInfos overrides the whole ObjectInfo StatObject reports for a key
+	objectInfos map[string]minio.ObjectInfo
+	// copies records the CopyObject and ComposeObject calls as "<method> <src> <dst>"
+	copies []string
+	// buckets is returned by ListBuckets
+	buckets []minio.BucketInfo
+	// selectRecords maps a key to the records SelectObjectContent returns from it
+	selectRecords map[string]string
+	// selectOpts records the options of the last SelectObjectContent call
+	selectOpts minio.SelectObjectOptions
 }
 
 func newMockS3Client(files map[string][]string, mockedErrs map[string]error) S3Client {
@@ -43,17 +81,29 @@ func (s *mockS3Client) getMockedErr(funcName string) error {
 
 // PutFile puts a single file to a bucket at the specified key
 func (s *mockS3Client) PutFile(bucket, key, path string) error {
+	s.putKeys = append(s.putKeys, key)
 	return s.getMockedErr("PutFile")
 }
 
+func (s *mockS3Client) PutBytes(bucket, key string, data []byte, contentType string) error {
+	s.putKeys = append(s.putKeys, key)
+	return s.getMockedErr("PutBytes")
+}
+
+func (s *mockS3Client) PutReader(bucket, key string, r io.Reader, size int64, contentType string) error {
+	s.putKeys = append(s.putKeys, key)
+	return s.getMockedErr("PutReader")
+}
+
 // PutDirectory puts a complete directory into a bucket key prefix, with each file in the directory
 // a separate key in the bucket.
 func (s *mockS3Client) PutDirectory(bucket, key, path string) error {
+	s.putKeys = append(s.putKeys, key)
 	return s.getMockedErr("PutDirectory")
 }
 
-// GetFile downloads a file to a local file path
-func (s *mockS3Client) GetFile(bucket, key, path string) error {
+// GetFile downloads an object into f
+func (s *mockS3Client) GetFile(bucket, key string, f *os.File) error {
 	return s.getMockedErr("GetFile")
 }
 
@@ -65,6 +115,21 @@ func (s *mockS3Client) OpenFile(bucket, key string) (io.ReadCloser, error) {
 	return nil, err
 }
 
+func (s *mockS3Client) OpenFileRange(bucket, key string, offset, length int64) (io.ReadCloser, error) {
+	err := s.getMockedErr("OpenFileRange")
+	if err == nil {
+		return io.NopCloser(&bytes.Buffer{}), nil
+	}
+	return nil, err
+}
+
+func (s *mockS3Client) ListBuckets() ([]minio.BucketInfo, error) {
+	if err := s.getMockedErr("ListBuckets"); err != nil {
+		return nil, err
+	}
+	return s.buckets, nil
+}
+
 func (s *mockS3Client) KeyExists(bucket, key string) (bool, error) {
 	err := s.getMockedErr("KeyExists")
 	if files, ok := s.files[bucket]; ok {
@@ -77,6 +142,26 @@ func (s *mockS3Client) KeyExists(bucket, key string) (bool, error) {
 	return false, err
 }
 
+// StatObject returns the metadata of an object
+func (s *mockS3Client) StatObject(bucket, key string) (minio.ObjectInfo, error) {
+	if err := s.getMockedErr("StatObject"); err != nil {
+		return minio.ObjectInfo{}, err
+	}
+	if info, ok := s.objectInfos[key]; ok {
+		return info, nil
+	}
+	for _, file := range s.files[bucket] {
+		if file == key {
+			size, ok := s.objectSizes[key]
+			if !ok {
+				size = int64(len(key))
+			}
+			return minio.ObjectInfo{Key: key, Size: size, ETag: "etag-" + key}, nil
+		}
+	}
+	return minio.ObjectInfo{}, minio.ErrorResponse{Code: "NoSuchKey"}
+}
+
 // GetDirectory downloads a directory to a local file path
 func (s *mockS3Client) GetDirectory(bucket, key, path string) error {
 	return s.getMockedErr("GetDirectory")
@@ -88,7 +173,7 @@ func (s *mockS3Client) ListDirectory(bucket, keyPrefix string) ([]string, error)
 	err := s.getMockedErr("ListDirectory")
 	if files, ok := s.files[bucket]; ok {
 		for _, file := range files {
-			if strings.HasPrefix(file, keyPrefix+"/") {
+			if strings.HasPrefix(file, strings.TrimSuffix(keyPrefix, "/")+"/") {
 				dirs = append(dirs, file)
 			}
 		}
@@ -96,6 +181,71 @@ func (s *mockS3Client) ListDirectory(bucket, keyPrefix string) ([]string, error)
 	return dirs, err
 }
 
+// ListDirectoryInfo lists the objects under keyPrefix, with the ObjectInfo of objectInfos when there is one
+func (s *mockS3Client) ListDirectoryInfo(bucket, keyPrefix string) ([]minio.ObjectInfo, error) {
+	keys, err := s.ListDirectory(bucket, keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.getMockedErr("ListDirectoryInfo"); err != nil {
+		return nil, err
+	}
+	objects := make([]minio.ObjectInfo, 0, len(keys))
+	for _, key := range keys {
+		info, ok := s.objectInfos[key]
+		if !ok {
+			info = minio.ObjectInfo{Key: key}
+		}
+		objects = append(objects, info)
+	}
+	return objects, nil
+}
+
+// ListDirectoryLevel lists the keys directly under keyPrefix, collapsing deeper keys into their common prefix
+func (s *mockS3Client) ListDirectoryLevel(bucket, keyPrefix string) ([]string, error) {
+	if err := s.getMockedErr("ListDirectoryLevel"); err != nil {
+		return nil, err
+	}
+	prefix := strings.TrimSuffix(keyPrefix, "/") + "/"
+	out := make([]string, 0)
+	for _, file := range s.files[bucket] {
+		relative, ok := strings.CutPrefix(file, prefix)
+		if !ok {
+			continue
+		}
+		if dir, _, nested := strings.Cut(relative, "/"); nested {
+			file = prefix + dir + "/"
+		}
+		if !slices.Contains(out, file) {
+			out = append(out, file)
+		}
+	}
+	return out, nil
+}
+
+// ListDirectoryPage pages through ListDirectory, using the offset of the next key as the continuation token
+func (s *mockS3Client) ListDirectoryPage(bucket, keyPrefix string, pageSize int, continuationToken string) ([]string, string, error) {
+	if err := s.getMockedErr("ListDirectoryPage"); err != nil {
+		return nil, "", err
+	}
+	files, err := s.ListDirectory(bucket, keyPrefix)
+	if err != nil {
+		return nil, "", err
+	}
+	start := 0
+	if continuationToken != "" {
+		start, err = strconv.Atoi(continuationToken)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	end := start + pageSize
+	if end >= len(files) {
+		return files[start:], "", nil
+	}
+	return files[start:end], strconv.Itoa(end), nil
+}
+
 // IsDirectory tests if the key is acting like a s3 directory
 func (s *mockS3Client) IsDirectory(bucket, key string) (bool, error) {
 	var isDir bool
@@ -231,7 +381,7 @@ func TestOpenStreamS3Artifact(t *testing.T) {
 						Key: tc.key,
 					},
 				},
-			})
+			}, 0, 0)
 			if tc.errMsg == "" {
 				require.NoError(t, err)
 				assert.NotNil(t, stream)
@@ -245,17 +395,87 @@ func TestOpenStreamS3Artifact(t *testing.T) {
 
 // Delete deletes an S3 artifact by artifact key
 func (s *mockS3Client) Delete(bucket, key string) error {
+	s.deletedKeys = append(s.deletedKeys, key)
 	return s.getMockedErr("Delete")
 }
 
+// CopyObject copies an S3 object within a bucket
+func (s *mockS3Client) CopyObject(bucket string, src minio.ObjectInfo, dstKey string) error {
+	s.copies = append(s.copies, "CopyObject "+src.Key+" "+dstKey)
+	if err := s.getMockedErr("CopyObject"); err != nil {
+		return err
+	}
+	s.copyFile(bucket, src, dstKey)
+	return nil
+}
+
+// copyFile adds dstKey to the bucket's files, the same size as src
+func (s *mockS3Client) copyFile(bucket string, src minio.ObjectInfo, dstKey string) {
+	if s.files == nil {
+		s.files = map[string][]string{}
+	}
+	if s.objectSizes == nil {
+		s.objectSizes = map[string]int64{}
+	}
+	s.files[bucket] = append(s.files[bucket], dstKey)
+	s.objectSizes[dstKey] = src.Size
+}
+
+// PresignedURL returns a fake presigned URL for the key
+func (s *mockS3Client) PresignedURL(method, bucket, key string, expiry time.Duration) (*url.URL, error) {
+	if err := s.getMockedErr("PresignedURL"); err != nil {
+		return nil, err
+	}
+	return &url.URL{Scheme: "https", Host: bucket + ".s3.amazonaws.com", Path: "/" + key}, nil
+}
+
+// ComposeObject copies an S3 object within a bucket using a multipart copy
+func (s *mockS3Client) NewMultipartUpload(bucket, key string) (string, error) {
+	return "upload-id", s.getMockedErr("NewMultipartUpload")
+}
+
+func (s *mockS3Client) PutObjectPart(bucket, key, uploadID string, partNumber int, data []byte) (string, error) {
+	return "etag-" + strconv.Itoa(partNumber), s.getMockedErr("PutObjectPart")
+}
+
+func (s *mockS3Client) CompleteMultipartUpload(bucket, key, uploadID string, parts []MultipartPart) error {
+	return s.getMockedErr("CompleteMultipartUpload")
+}
+
+func (s *mockS3Client) AbortMultipartUpload(bucket, key, uploadID string) error {
+	return s.getMockedErr("AbortMultipartUpload")
+}
+
+func (s *mockS3Client) SelectObjectContent(bucket, key string, opts minio.SelectObjectOptions) (io.ReadCloser, error) {
+	s.selectOpts = opts
+	if err := s.getMockedErr("SelectObjectContent"); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader(s.selectRecords[key])), nil
+}
+
+func (s *mockS3Client) ComposeObject(bucket string, src minio.ObjectInfo, dstKey string) error {
+	s.copies = append(s.copies, "ComposeObject "+src.Key+" "+dstKey)
+	if err := s.getMockedErr("ComposeObject"); err != nil {
+		return err
+	}
+	s.copyFile(bucket, src, dstKey)
+	return nil
+}
+
+// DeleteObjects deletes the S3 artifacts by artifact keys
+func (s *mockS3Client) DeleteObjects(bucket string, keys []string) error {
+	s.deletedKeys = append(s.deletedKeys, keys...)
+	return s.getMockedErr("DeleteObjects")
+}
+
 func TestLoadS3Artifact(t *testing.T) {
 	tests := map[string]struct {
-		s3client  S3Client
-		bucket    string
-		key       string
-		localPath string
-		done      bool
-		errMsg    string
+		s3client S3Client
+		bucket   string
+		key      string
+		done     bool
+		errMsg   string
 	}{
 		"Success": {
 			s3client: newMockS3Client(
@@ -265,11 +485,10 @@ func TestLoadS3Artifact(t *testing.T) {
 					},
 				},
 				map[string]error{}),
-			bucket:    "my-bucket",
-			key:       "/folder/hello-art.tar.gz",
-			localPath: "/tmp/hello-art.tar.gz",
-			done:      true,
-			errMsg:    "",
+			bucket: "my-bucket",
+			key:    "/folder/hello-art.tar.gz",
+			done:   true,
+			errMsg: "",
 		},
 		"No such bucket": {
 			s3client: newMockS3Client(
@@ -279,11 +498,10 @@ func TestLoadS3Artifact(t *testing.T) {
 						Code: "NoSuchBucket",
 					},
 				}),
-			bucket:    "my-bucket",
-			key:       "/folder/hello-art.tar.gz",
-			localPath: "/tmp/hello-art.tar.gz",
-			done:      true,
-			errMsg:    "failed to get file: The specified bucket does not exist.",
+			bucket: "my-bucket",
+			key:    "/folder/hello-art.tar.gz",
+			done:   true,
+			errMsg: "failed to get file: The specified bucket does not exist.",
 		},
 		"No such key": {
 			s3client: newMockS3Client(
@@ -297,11 +515,10 @@ func TestLoadS3Artifact(t *testing.T) {
 						Code: "NoSuchKey",
 					},
 				}),
-			bucket:    "my-bucket",
-			key:       "/folder/hello-art.tar.gz",
-			localPath: "/tmp/hello-art.tar.gz",
-			done:      true,
-			errMsg:    "The specified key does not exist.",
+			bucket: "my-bucket",
+			key:    "/folder/hello-art.tar.gz",
+			done:   true,
+			errMsg: "The specified key does not exist.",
 		},
 		"Is Directory": {
 			s3client: newMockS3Client(
@@ -315,11 +532,10 @@ func TestLoadS3Artifact(t *testing.T) {
 						Code: "NoSuchKey",
 					},
 				}),
-			bucket:    "my-bucket",
-			key:       "/folder/",
-			localPath: "/tmp/folder/",
-			done:      true,
-			errMsg:    "",
+			bucket: "my-bucket",
+			key:    "/folder/",
+			done:   true,
+			errMsg: "Directory Stream capability currently unimplemented for S3",
 		},
 		"Get File Other Transient Error": {
 			s3client: newMockS3Client(
@@ -333,11 +549,10 @@ func TestLoadS3Artifact(t *testing.T) {
 						Code: "this error is transient",
 					},
 				}),
-			bucket:    "my-bucket",
-			key:       "/folder/",
-			localPath: "/tmp/folder/",
-			done:      false,
-			errMsg:    "failed to get file: Error response code this error is transient.",
+			bucket: "my-bucket",
+			key:    "/folder/",
+			done:   false,
+			errMsg: "failed to get file: Error response code this error is transient.",
 		},
 		"Test Directory Failed": {
 			s3client: newMockS3Client(
@@ -354,32 +569,10 @@ func TestLoadS3Artifact(t *testing.T) {
 						Code: "InternalError",
 					},
 				}),
-			bucket:    "my-bucket",
-			key:       "/folder/",
-			localPath: "/tmp/folder/",
-			done:      false,
-			errMsg:    "failed to test if /folder/ is a directory: We encountered an internal error, please try again.",
-		},
-		"Get Directory Failed": {
-			s3client: newMockS3Client(
-				map[string][]string{
-					"my-bucket": {
-						"/folder/hello-art-2.tar.gz",
-					},
-				},
-				map[string]error{
-					"GetFile": minio.ErrorResponse{
-						Code: "NoSuchKey",
-					},
-					"GetDirectory": minio.ErrorResponse{
-						Code: "InternalError",
-					},
-				}),
-			bucket:    "my-bucket",
-			key:       "/folder/",
-			localPath: "/tmp/folder/",
-			done:      false,
-			errMsg:    "failed to get directory: We encountered an internal error, please try again.",
+			bucket: "my-bucket",
+			key:    "/folder/",
+			done:   false,
+			errMsg: "failed to test if /folder/ is a directory: We encountered an internal error, please try again.",
 		},
 	}
 
@@ -396,7 +589,7 @@ func TestLoadS3Artifact(t *testing.T) {
 						Key: tc.key,
 					},
 				},
-			}, tc.localPath)
+			}, emptyTempFile(t), "")
 			assert.Equal(t, tc.done, success)
 			if err != nil {
 				assert.Equal(t, tc.errMsg, err.Error())
@@ -407,6 +600,35 @@ func TestLoadS3Artifact(t *testing.T) {
 	}
 }
 
+// TestLoadS3Directory verifies a failure to download a directory's objects can be retried when it is transient
+func TestLoadS3Directory(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	t.Setenv(transientEnvVarKey, "this error is transient")
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+		S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+		Key:      "/folder/",
+	}}}
+
+	done, err := loadS3Directory(ctx, newMockS3Client(map[string][]string{}, map[string]error{}), artifact, t.TempDir())
+	require.NoError(t, err)
+	assert.True(t, done)
+
+	done, err = loadS3Directory(ctx, newMockS3Client(map[string][]string{}, map[string]error{
+		"GetDirectory": minio.ErrorResponse{Code: "this error is transient"},
+	}), artifact, t.TempDir())
+	assert.False(t, done)
+	require.EqualError(t, err, "failed to get directory: Error response code this error is transient.")
+}
+
+// emptyTempFile returns an empty file, which is closed once the test is done
+func emptyTempFile(t *testing.T) *os.File {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "download")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = f.Close() })
+	return f
+}
+
 func TestSaveS3Artifact(t *testing.T) {
 	ctx := logging.TestContext(t.Context())
 
@@ -516,23 +738,30 @@ func TestSaveS3Artifact(t *testing.T) {
 	for name, tc := range tests {
 		t.Setenv(transientEnvVarKey, "this error is transient")
 		t.Run(name, func(t *testing.T) {
-			success, err := saveS3Artifact(ctx,
-				tc.s3client,
-				tc.localPath,
-				&wfv1.Artifact{
-					ArtifactLocation: wfv1.ArtifactLocation{
-						S3: &wfv1.S3Artifact{
-							S3Bucket: wfv1.S3Bucket{
-								Bucket:                   tc.bucket,
-								CreateBucketIfNotPresent: &wfv1.CreateS3BucketOptions{},
-								EncryptionOptions: &wfv1.S3EncryptionOptions{
-									EnableEncryption: true,
-								},
+			artifact := &wfv1.Artifact{
+				ArtifactLocation: wfv1.ArtifactLocation{
+					S3: &wfv1.S3Artifact{
+						S3Bucket: wfv1.S3Bucket{
+							Bucket:                   tc.bucket,
+							CreateBucketIfNotPresent: &wfv1.CreateS3BucketOptions{},
+							EncryptionOptions: &wfv1.S3EncryptionOptions{
+								EnableEncryption: true,
 							},
-							Key: tc.key,
 						},
+						Key: tc.key,
 					},
-				})
+				},
+			}
+			var success bool
+			var err error
+			if tc.localPath == tempDir {
+				success, err = saveS3Directory(ctx, tc.s3client, tc.localPath, artifact)
+			} else {
+				f, openErr := os.Open(tc.localPath)
+				require.NoError(t, openErr)
+				defer f.Close()
+				success, err = saveS3Artifact(ctx, tc.s3client, f, -1, artifact)
+			}
 			assert.Equal(t, tc.done, success)
 			if err != nil {
 				assert.Equal(t, tc.errMsg, err.Error())
@@ -541,6 +770,20 @@ func TestSaveS3Artifact(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("Reader is streamed", func(t *testing.T) {
+		s3cli := newMockS3Client(map[string][]string{}, map[string]error{
+			"PutFile":   errors.New("a reader isn't uploaded from a path"),
+			"PutReader": minio.ErrorResponse{Code: "this error is transient"},
+		})
+		artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+			S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+			Key:      "/folder/streamed.txt",
+		}}}
+		success, err := saveS3Artifact(ctx, s3cli, strings.NewReader("streamed"), 8, artifact)
+		assert.False(t, success)
+		require.EqualError(t, err, "failed to put object: Error response code this error is transient.")
+	})
 }
 
 func TestListObjects(t *testing.T) {
@@ -611,7 +854,7 @@ func TestListObjects(t *testing.T) {
 							Key: tc.key,
 						},
 					},
-				})
+				}, true)
 			if tc.expectedSuccess {
 				require.NoError(t, err)
 				assert.Len(t, files, tc.expectedNumFiles)
@@ -740,3 +983,2032 @@ func TestDisallowedComboOptions(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestAssumeRoleOptions(t *testing.T) {
+	t.Run("with external ID", func(t *testing.T) {
+		var o stscreds.AssumeRoleOptions
+		assumeRoleOptions(S3ClientOpts{RoleSessionName: "my-session", ExternalID: "my-external-id"})(&o)
+		assert.Equal(t, "my-session", o.RoleSessionName)
+		require.NotNil(t, o.ExternalID)
+		assert.Equal(t, "my-external-id", *o.ExternalID)
+	})
+
+	t.Run("without external ID", func(t *testing.T) {
+		var o stscreds.AssumeRoleOptions
+		assumeRoleOptions(S3ClientOpts{RoleSessionName: "my-session"})(&o)
+		assert.Equal(t, "my-session", o.RoleSessionName)
+		assert.Nil(t, o.ExternalID)
+	})
+}
+
+func TestDeleteObjects(t *testing.T) {
+	artifact := &wfv1.Artifact{
+		ArtifactLocation: wfv1.ArtifactLocation{
+			S3: &wfv1.S3Artifact{
+				S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+				Key:      "/folder/",
+			},
+		},
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		mock := &mockS3Client{
+			files: map[string][]string{
+				"my-bucket": {"/folder/a.txt", "/folder/b.txt", "/other/c.txt"},
+			},
+		}
+		keys, err := deleteObjects(mock, artifact)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/folder/a.txt", "/folder/b.txt"}, mock.deletedKeys)
+		assert.Equal(t, []string{"/folder/a.txt", "/folder/b.txt"}, keys)
+	})
+
+	t.Run("Partial failure", func(t *testing.T) {
+		mock := &mockS3Client{
+			files: map[string][]string{
+				"my-bucket": {"/folder/a.txt", "/folder/b.txt"},
+			},
+			mockedErrs: map[string]error{
+				"DeleteObjects": &DeleteObjectsError{
+					Total:  2,
+					Failed: map[string]error{"/folder/b.txt": minio.ErrorResponse{Code: "AccessDenied", Message: "Access Denied."}},
+				},
+			},
+		}
+		keys, err := deleteObjects(mock, artifact)
+		require.Error(t, err)
+		assert.Equal(t, "failed to delete 1 of 2 objects: /folder/b.txt: Access Denied.", err.Error())
+		assert.Equal(t, []string{"/folder/a.txt"}, keys)
+		assert.True(t, IsS3ErrCode(err, "AccessDenied"))
+	})
+}
+
+func TestDeleteDryRunKeys(t *testing.T) {
+	mock := &mockS3Client{
+		files: map[string][]string{
+			"my-bucket": {"/folder/a.txt", "/folder/b.txt", "/other/c.txt"},
+		},
+	}
+	newArtifact := func(key string) *wfv1.Artifact {
+		return &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				S3: &wfv1.S3Artifact{
+					S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+					Key:      key,
+				},
+			},
+		}
+	}
+
+	keys, err := deleteDryRunKeys(mock, newArtifact("/folder/"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/folder/a.txt", "/folder/b.txt"}, keys)
+
+	keys, err = deleteDryRunKeys(mock, newArtifact("/other/c.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/other/c.txt"}, keys)
+
+	keys, err = deleteDryRunKeys(mock, newArtifact("/other/missing.txt"))
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	assert.Empty(t, mock.deletedKeys)
+}
+
+func TestStatS3Artifact(t *testing.T) {
+	tests := map[string]struct {
+		s3client     S3Client
+		key          string
+		expectedSize int64
+		errMsg       string
+		notFound     bool
+	}{
+		"Found": {
+			s3client:     newMockS3Client(map[string][]string{"my-bucket": {"/folder/hello-art.tar.gz"}}, map[string]error{}),
+			key:          "/folder/hello-art.tar.gz",
+			expectedSize: int64(len("/folder/hello-art.tar.gz")),
+		},
+		"Not found": {
+			s3client: newMockS3Client(map[string][]string{"my-bucket": {}}, map[string]error{}),
+			key:      "/folder/hello-art.tar.gz",
+			errMsg:   "The specified key does not exist.",
+			notFound: true,
+		},
+		"Access denied": {
+			s3client: newMockS3Client(map[string][]string{}, map[string]error{
+				"StatObject": minio.ErrorResponse{Code: "AccessDenied"},
+			}),
+			key:    "/folder/hello-art.tar.gz",
+			errMsg: "failed to stat /folder/hello-art.tar.gz: Access Denied.",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			stat, err := statS3Artifact(tc.s3client, &wfv1.Artifact{
+				ArtifactLocation: wfv1.ArtifactLocation{
+					S3: &wfv1.S3Artifact{
+						S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+						Key:      tc.key,
+					},
+				},
+			})
+			if tc.errMsg == "" {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedSize, stat.Size)
+				assert.Equal(t, "etag-"+tc.key, stat.ETag)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, tc.errMsg, err.Error())
+			assert.Equal(t, tc.notFound, argoerrs.IsCode(argoerrs.CodeNotFound, err))
+		})
+	}
+}
+
+// TestListObjects_Recursive compares recursive and delimited listings of the same keys
+func TestListObjects_Recursive(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	s3client := newMockS3Client(map[string][]string{"my-bucket": {
+		"my-wf/data/a.parquet",
+		"my-wf/data/b.csv",
+		"my-wf/data/nested/c.parquet",
+		"my-wf/data/nested/deeper/d.parquet",
+		"my-wf/data/other/e.parquet",
+		"my-wf/logs/main.log",
+	}}, map[string]error{})
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+		S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+		Key:      "my-wf/data",
+	}}}
+
+	done, files, err := listObjects(ctx, s3client, artifact, true)
+	require.NoError(t, err)
+	assert.True(t, done)
+	assert.Equal(t, []string{
+		"my-wf/data/a.parquet",
+		"my-wf/data/b.csv",
+		"my-wf/data/nested/c.parquet",
+		"my-wf/data/nested/deeper/d.parquet",
+		"my-wf/data/other/e.parquet",
+	}, files)
+
+	done, files, err = listObjects(ctx, s3client, artifact, false)
+	require.NoError(t, err)
+	assert.True(t, done)
+	assert.Equal(t, []string{
+		"my-wf/data/a.parquet",
+		"my-wf/data/b.csv",
+		"my-wf/data/nested/",
+		"my-wf/data/other/",
+	}, files)
+
+	matched, err := filterKeys(files, artifact.S3.Key, "n*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"my-wf/data/nested/"}, matched)
+}
+
+func TestListObjectsPage(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	files := []string{"/folder/a", "/folder/b", "/folder/c", "/folder/d", "/folder/e"}
+	s3client := newMockS3Client(map[string][]string{"my-bucket": files}, map[string]error{})
+	artifact := &wfv1.Artifact{
+		ArtifactLocation: wfv1.ArtifactLocation{
+			S3: &wfv1.S3Artifact{
+				S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+				Key:      "/folder/",
+			},
+		},
+	}
+
+	done, firstPage, token, err := listObjectsPage(ctx, s3client, artifact, 3, "")
+	require.NoError(t, err)
+	assert.True(t, done)
+	assert.Len(t, firstPage, 3)
+	require.NotEmpty(t, token)
+
+	done, secondPage, token, err := listObjectsPage(ctx, s3client, artifact, 3, token)
+	require.NoError(t, err)
+	assert.True(t, done)
+	assert.Len(t, secondPage, 2)
+	assert.Empty(t, token)
+
+	assert.Equal(t, files, append(firstPage, secondPage...))
+
+	t.Run("Error", func(t *testing.T) {
+		s3client := newMockS3Client(map[string][]string{}, map[string]error{
+			"ListDirectoryPage": minio.ErrorResponse{Code: "AccessDenied"},
+		})
+		done, _, _, err := listObjectsPage(ctx, s3client, artifact, 3, "")
+		require.Error(t, err)
+		assert.True(t, done)
+	})
+}
+
+// flakyS3Client fails GetFile with err until it has been called succeedOn times
+type flakyS3Client struct {
+	S3Client
+	err       error
+	succeedOn int
+	calls     int
+}
+
+func (s *flakyS3Client) GetFile(bucket, key string, f *os.File) error {
+	s.calls++
+	if s.calls < s.succeedOn {
+		return s.err
+	}
+	return nil
+}
+
+func TestRetryBackoff(t *testing.T) {
+	t.Setenv("EXECUTOR_RETRY_BACKOFF_DURATION", "1ms")
+	ctx := logging.TestContext(t.Context())
+	artifact := &wfv1.Artifact{
+		ArtifactLocation: wfv1.ArtifactLocation{
+			S3: &wfv1.S3Artifact{
+				S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+				Key:      "/folder/hello-art.tar.gz",
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		err           error
+		succeedOn     int
+		expectedCalls int
+		expectErr     bool
+	}{
+		"Succeeds on third attempt": {
+			err:           minio.ErrorResponse{Code: "ServiceUnavailable", StatusCode: 503},
+			succeedOn:     3,
+			expectedCalls: 3,
+		},
+		"Gives up after max attempts": {
+			err:           minio.ErrorResponse{Code: "ServiceUnavailable", StatusCode: 503},
+			succeedOn:     4,
+			expectedCalls: 3,
+			expectErr:     true,
+		},
+		"Fails fast on access denied": {
+			err:           minio.ErrorResponse{Code: "AccessDenied", StatusCode: 403},
+			succeedOn:     3,
+			expectedCalls: 1,
+			expectErr:     true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			driver := &ArtifactDriver{MaxRetryAttempts: DefaultMaxRetryAttempts}
+			s3cli := &flakyS3Client{S3Client: newMockS3Client(map[string][]string{}, map[string]error{}), err: tc.err, succeedOn: tc.succeedOn}
+			err := backoff(driver.retryBackoff(ctx), func() (bool, error) {
+				return loadS3Artifact(ctx, s3cli, artifact, emptyTempFile(t), "")
+			})
+			assert.Equal(t, tc.expectedCalls, s3cli.calls)
+			if tc.expectErr {
+				require.Error(t, err)
+				// The S3 error can still be inspected once the attempts are exhausted
+				var minioErr minio.ErrorResponse
+				assert.ErrorAs(t, err, &minioErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDriverSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	path := filepath.Join(t.TempDir(), "hello-art.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("hello"), 0o600))
+	artifact := &wfv1.Artifact{
+		ArtifactLocation: wfv1.ArtifactLocation{
+			S3: &wfv1.S3Artifact{
+				S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+				Key:      "/folder/hello-art.tar.gz",
+			},
+		},
+	}
+
+	_, span := startSpan(t.Context(), "S3 Save", artifact)
+	endSpan(span, nil, path)
+	_, span = startSpan(t.Context(), "S3 Delete", artifact)
+	endSpan(span, errors.New("boom"), "")
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "S3 Save", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.String("s3.bucket", "my-bucket"))
+	assert.Contains(t, spans[0].Attributes(), attribute.String("s3.key", "/folder/hello-art.tar.gz"))
+	assert.Contains(t, spans[0].Attributes(), attribute.Int64("s3.bytes", 5))
+	assert.Equal(t, otelcodes.Error, spans[1].Status().Code)
+}
+
+func TestFilterKeys(t *testing.T) {
+	keys := []string{
+		"my-wf/data/a.parquet",
+		"my-wf/data/b.parquet",
+		"my-wf/data/b.csv",
+		"my-wf/data/c1.csv",
+		"my-wf/data/nested/d.parquet",
+	}
+
+	tests := []struct {
+		name     string
+		pattern  string
+		expected []string
+		errMsg   string
+	}{
+		{name: "star", pattern: "*.parquet", expected: []string{"my-wf/data/a.parquet", "my-wf/data/b.parquet"}},
+		{name: "nested star", pattern: "*/*.parquet", expected: []string{"my-wf/data/nested/d.parquet"}},
+		{name: "question mark", pattern: "?.csv", expected: []string{"my-wf/data/b.csv"}},
+		{name: "character class", pattern: "[ab].*", expected: []string{"my-wf/data/a.parquet", "my-wf/data/b.parquet", "my-wf/data/b.csv"}},
+		{name: "no match", pattern: "*.json"},
+		{name: "invalid pattern", pattern: "[a-", errMsg: `invalid list pattern "[a-": syntax error in pattern`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, err := filterKeys(keys, "my-wf/data/", tt.pattern)
+			if tt.errMsg != "" {
+				require.EqualError(t, err, tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, matched)
+		})
+	}
+
+	t.Run("prefix without trailing slash", func(t *testing.T) {
+		matched, err := filterKeys(keys, "my-wf/data", "*.csv")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"my-wf/data/b.csv", "my-wf/data/c1.csv"}, matched)
+	})
+}
+
+func TestArtifactExists(t *testing.T) {
+	files := map[string][]string{"my-bucket": {"my-wf/hello-art.tar.gz", "my-wf/outputs/result.json"}}
+	tests := map[string]struct {
+		s3client S3Client
+		key      string
+		exists   bool
+		errMsg   string
+	}{
+		"Present object": {
+			s3client: newMockS3Client(files, map[string]error{}),
+			key:      "my-wf/hello-art.tar.gz",
+			exists:   true,
+		},
+		"Absent object": {
+			s3client: newMockS3Client(files, map[string]error{}),
+			key:      "my-wf/missing.tar.gz",
+		},
+		"Present directory": {
+			s3client: newMockS3Client(files, map[string]error{}),
+			key:      "my-wf/outputs/",
+			exists:   true,
+		},
+		"Absent directory": {
+			s3client: newMockS3Client(files, map[string]error{}),
+			key:      "my-wf/missing/",
+		},
+		"Permission error": {
+			s3client: newMockS3Client(files, map[string]error{
+				"KeyExists": minio.ErrorResponse{Code: "AccessDenied", Message: "Access Denied."},
+			}),
+			key:    "my-wf/hello-art.tar.gz",
+			errMsg: "failed to check if key my-wf/hello-art.tar.gz exists from bucket my-bucket: Access Denied.",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			exists, err := artifactExists(tc.s3client, &wfv1.Artifact{
+				ArtifactLocation: wfv1.ArtifactLocation{
+					S3: &wfv1.S3Artifact{
+						S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+						Key:      tc.key,
+					},
+				},
+			})
+			if tc.errMsg != "" {
+				require.EqualError(t, err, tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.exists, exists)
+		})
+	}
+}
+
+func TestContentType(t *testing.T) {
+	dir := t.TempDir()
+	jsonFile := filepath.Join(dir, "result.json")
+	require.NoError(t, os.WriteFile(jsonFile, []byte(`{"ok": true}`), 0o600))
+	blob := filepath.Join(dir, "blob")
+	require.NoError(t, os.WriteFile(blob, []byte{0x00, 0x01, 0x02, 0xff}, 0o600))
+	png := filepath.Join(dir, "image")
+	require.NoError(t, os.WriteFile(png, []byte("\x89PNG\r\n\x1a\n"), 0o600))
+
+	tests := map[string]struct {
+		override    string
+		path        string
+		contentType string
+	}{
+		"Extension":       {path: jsonFile, contentType: "application/json"},
+		"Binary blob":     {path: blob, contentType: "application/octet-stream"},
+		"Sniffed content": {path: png, contentType: "image/png"},
+		"Override":        {override: "text/csv", path: jsonFile, contentType: "text/csv"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := &s3client{S3ClientOpts: S3ClientOpts{ContentType: tc.override}}
+			contentType, err := s.contentType(tc.path)
+			require.NoError(t, err)
+			assert.Equal(t, tc.contentType, contentType)
+		})
+	}
+}
+
+func TestCopyS3Artifact(t *testing.T) {
+	s3Artifact := func(bucket, key string) *wfv1.Artifact {
+		return &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: bucket}, Key: key},
+			},
+		}
+	}
+	files := map[string][]string{"my-bucket": {"staging/model.bin", "staging/huge.bin"}}
+
+	t.Run("Small object", func(t *testing.T) {
+		s3cli := &mockS3Client{files: files}
+		require.NoError(t, copyS3Artifact(s3cli, s3Artifact("my-bucket", "staging/model.bin"), s3Artifact("my-bucket", "release/model.bin")))
+		assert.Equal(t, []string{"CopyObject staging/model.bin release/model.bin"}, s3cli.copies)
+	})
+
+	t.Run("Object at the CopyObject limit", func(t *testing.T) {
+		s3cli := &mockS3Client{files: files, objectSizes: map[string]int64{"staging/huge.bin": maxCopyObjectSize}}
+		require.NoError(t, copyS3Artifact(s3cli, s3Artifact("my-bucket", "staging/huge.bin"), s3Artifact("my-bucket", "release/huge.bin")))
+		assert.Equal(t, []string{"CopyObject staging/huge.bin release/huge.bin"}, s3cli.copies)
+	})
+
+	t.Run("Object over 5GB uses a multipart copy", func(t *testing.T) {
+		s3cli := &mockS3Client{files: files, objectSizes: map[string]int64{"staging/huge.bin": maxCopyObjectSize + 1}}
+		require.NoError(t, copyS3Artifact(s3cli, s3Artifact("my-bucket", "staging/huge.bin"), s3Artifact("my-bucket", "release/huge.bin")))
+		assert.Equal(t, []string{"ComposeObject staging/huge.bin release/huge.bin"}, s3cli.copies)
+	})
+
+	t.Run("Missing source", func(t *testing.T) {
+		s3cli := &mockS3Client{files: files}
+		err := copyS3Artifact(s3cli, s3Artifact("my-bucket", "staging/missing.bin"), s3Artifact("my-bucket", "release/missing.bin"))
+		require.Error(t, err)
+		assert.True(t, argoerrs.IsCode(argoerrs.CodeNotFound, err))
+		assert.Empty(t, s3cli.copies)
+	})
+
+	t.Run("Different buckets", func(t *testing.T) {
+		s3cli := &mockS3Client{files: files}
+		err := copyS3Artifact(s3cli, s3Artifact("my-bucket", "staging/model.bin"), s3Artifact("other-bucket", "release/model.bin"))
+		require.Error(t, err)
+		assert.True(t, argoerrs.IsCode(argoerrs.CodeBadRequest, err))
+	})
+	t.Run("Storage class keeps the metadata", func(t *testing.T) {
+		src := minio.ObjectInfo{Key: "staging/model.bin", ETag: "etag", ContentType: "application/octet-stream", UserMetadata: map[string]string{"Team": "ml"}}
+		srcOpts, dstOpts, err := (&s3client{S3ClientOpts: S3ClientOpts{StorageClass: "STANDARD_IA"}}).copyOptions("my-bucket", src, "release/model.bin")
+		require.NoError(t, err)
+		assert.Equal(t, "etag", srcOpts.MatchETag)
+		assert.True(t, dstOpts.ReplaceMetadata)
+		assert.Equal(t, "application/octet-stream", dstOpts.ContentType)
+		assert.Equal(t, map[string]string{"X-Amz-Storage-Class": "STANDARD_IA", "Team": "ml"}, dstOpts.UserMetadata)
+
+		_, dstOpts, err = (&s3client{}).copyOptions("my-bucket", src, "release/model.bin")
+		require.NoError(t, err)
+		assert.False(t, dstOpts.ReplaceMetadata, "the source metadata is copied as is without a storage class")
+	})
+}
+
+func TestMoveS3Artifact(t *testing.T) {
+	t.Setenv("EXECUTOR_RETRY_BACKOFF_DURATION", "1ms")
+	t.Setenv(transientEnvVarKey, "this error is transient")
+	ctx := logging.TestContext(t.Context())
+	retryBackoff := (&ArtifactDriver{MaxRetryAttempts: 3}).retryBackoff(ctx)
+	s3Artifact := func(key string) *wfv1.Artifact {
+		return &wfv1.Artifact{
+			ArtifactLocation: wfv1.ArtifactLocation{
+				S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: key},
+			},
+		}
+	}
+	files := func() map[string][]string { return map[string][]string{"my-bucket": {"staging/model.bin"}} }
+
+	t.Run("Moved", func(t *testing.T) {
+		s3cli := &mockS3Client{files: files()}
+		require.NoError(t, moveS3Artifact(ctx, s3cli, s3Artifact("staging/model.bin"), s3Artifact("release/model.bin"), retryBackoff))
+		assert.Equal(t, []string{"CopyObject staging/model.bin release/model.bin"}, s3cli.copies)
+		assert.Equal(t, []string{"staging/model.bin"}, s3cli.deletedKeys)
+	})
+
+	t.Run("Copy fails", func(t *testing.T) {
+		s3cli := &mockS3Client{files: files(), mockedErrs: map[string]error{"CopyObject": minio.ErrorResponse{Code: "AccessDenied"}}}
+		err := moveS3Artifact(ctx, s3cli, s3Artifact("staging/model.bin"), s3Artifact("release/model.bin"), retryBackoff)
+		require.Error(t, err)
+		assert.Empty(t, s3cli.deletedKeys)
+	})
+
+	t.Run("Copy not confirmed", func(t *testing.T) {
+		// The copy is reported to succeed, but HEAD finds a destination of a different size
+		s3cli := &mockS3Client{files: files(), objectInfos: map[string]minio.ObjectInfo{"release/model.bin": {Key: "release/model.bin", Size: 1}}}
+		err := moveS3Artifact(ctx, s3cli, s3Artifact("staging/model.bin"), s3Artifact("release/model.bin"), retryBackoff)
+		require.ErrorContains(t, err, "not deleting staging/model.bin")
+		assert.Empty(t, s3cli.deletedKeys)
+	})
+
+	t.Run("Missing source", func(t *testing.T) {
+		s3cli := &mockS3Client{files: files()}
+		err := moveS3Artifact(ctx, s3cli, s3Artifact("staging/missing.bin"), s3Artifact("release/missing.bin"), retryBackoff)
+		assert.True(t, argoerrs.IsCode(argoerrs.CodeNotFound, err))
+		assert.Empty(t, s3cli.deletedKeys)
+	})
+
+	t.Run("Onto itself", func(t *testing.T) {
+		s3cli := &mockS3Client{files: files()}
+		err := moveS3Artifact(ctx, s3cli, s3Artifact("staging/model.bin"), s3Artifact("staging/model.bin"), retryBackoff)
+		assert.True(t, argoerrs.IsCode(argoerrs.CodeBadRequest, err))
+		assert.Empty(t, s3cli.copies)
+		assert.Empty(t, s3cli.deletedKeys)
+	})
+
+	transient := minio.ErrorResponse{Code: "this error is transient"}
+
+	t.Run("Copy retried", func(t *testing.T) {
+		s3cli := &queuedErrS3Client{mockS3Client: &mockS3Client{files: files()}, errs: map[string][]error{"CopyObject": {transient}}}
+		require.NoError(t, moveS3Artifact(ctx, s3cli, s3Artifact("staging/model.bin"), s3Artifact("release/model.bin"), retryBackoff))
+		assert.Len(t, s3cli.copies, 2)
+		assert.Equal(t, []string{"staging/model.bin"}, s3cli.deletedKeys)
+	})
+
+	t.Run("Delete retried", func(t *testing.T) {
+		s3cli := &queuedErrS3Client{mockS3Client: &mockS3Client{files: files()}, errs: map[string][]error{"Delete": {transient}}}
+		require.NoError(t, moveS3Artifact(ctx, s3cli, s3Artifact("staging/model.bin"), s3Artifact("release/model.bin"), retryBackoff))
+		assert.Len(t, s3cli.copies, 1, "the copy shouldn't be repeated when the delete is retried")
+		assert.Equal(t, []string{"staging/model.bin", "staging/model.bin"}, s3cli.deletedKeys)
+	})
+
+	t.Run("Source already deleted", func(t *testing.T) {
+		// The first delete succeeds but its response is lost, so the retry finds no source
+		s3cli := &queuedErrS3Client{mockS3Client: &mockS3Client{files: files()}, errs: map[string][]error{"Delete": {transient, minio.ErrorResponse{Code: "NoSuchKey"}}}}
+		require.NoError(t, moveS3Artifact(ctx, s3cli, s3Artifact("staging/model.bin"), s3Artifact("release/model.bin"), retryBackoff))
+	})
+
+	t.Run("Delete fails", func(t *testing.T) {
+		s3cli := &mockS3Client{files: files(), mockedErrs: map[string]error{"Delete": minio.ErrorResponse{Code: "AccessDenied"}}}
+		err := moveS3Artifact(ctx, s3cli, s3Artifact("staging/model.bin"), s3Artifact("release/model.bin"), retryBackoff)
+		require.ErrorContains(t, err, "failed to delete staging/model.bin after copying it to release/model.bin")
+		assert.Len(t, s3cli.deletedKeys, 1, "a delete which isn't transient shouldn't be retried")
+	})
+}
+
+// queuedErrS3Client fails each call of a method with the next error queued for it, then calls the mock once the
+// queue is empty
+type queuedErrS3Client struct {
+	*mockS3Client
+	errs map[string][]error
+}
+
+func (s *queuedErrS3Client) next(method string) error {
+	errs := s.errs[method]
+	if len(errs) == 0 {
+		return nil
+	}
+	s.errs[method] = errs[1:]
+	return errs[0]
+}
+
+func (s *queuedErrS3Client) CopyObject(bucket string, src minio.ObjectInfo, dstKey string) error {
+	if err := s.next("CopyObject"); err != nil {
+		s.copies = append(s.copies, "CopyObject "+src.Key+" "+dstKey)
+		return err
+	}
+	return s.mockS3Client.CopyObject(bucket, src, dstKey)
+}
+
+func (s *queuedErrS3Client) Delete(bucket, key string) error {
+	if err := s.next("Delete"); err != nil {
+		s.deletedKeys = append(s.deletedKeys, key)
+		return err
+	}
+	return s.mockS3Client.Delete(bucket, key)
+}
+
+func TestPresignedURL(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	driver := &ArtifactDriver{
+		Endpoint:        "minio.example.com:9000",
+		Region:          "us-west-2",
+		Secure:          true,
+		AccessKey:       "AKIAEXAMPLE",
+		SecretKey:       "secret",
+		AddressingStyle: PathStyle,
+	}
+	artifact := &wfv1.Artifact{
+		ArtifactLocation: wfv1.ArtifactLocation{
+			S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: "my-wf/hello-art.tar.gz"},
+		},
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPut} {
+		t.Run(method, func(t *testing.T) {
+			signed, err := driver.PresignedURL(ctx, artifact, method, time.Hour)
+			require.NoError(t, err)
+			u, err := url.Parse(signed)
+			require.NoError(t, err)
+			assert.Equal(t, "minio.example.com:9000", u.Host)
+			assert.Equal(t, "/my-bucket/my-wf/hello-art.tar.gz", u.Path)
+			assert.NotEmpty(t, u.Query().Get("X-Amz-Signature"))
+			assert.Equal(t, "3600", u.Query().Get("X-Amz-Expires"))
+		})
+	}
+
+	for name, tc := range map[string]struct {
+		method string
+		expiry time.Duration
+	}{
+		"Unsupported method": {method: http.MethodDelete, expiry: time.Hour},
+		"Zero expiry":        {method: http.MethodGet},
+		"Negative expiry":    {method: http.MethodGet, expiry: -time.Minute},
+		"Expiry over 7 days": {method: http.MethodGet, expiry: MaxPresignedURLExpiry + time.Second},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := driver.PresignedURL(ctx, artifact, tc.method, tc.expiry)
+			require.Error(t, err)
+			assert.True(t, argoerrs.IsCode(argoerrs.CodeBadRequest, err))
+		})
+	}
+}
+
+func TestListBuckets(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	buckets := []minio.BucketInfo{
+		{Name: "my-bucket", CreationDate: created},
+		{Name: "my-other-bucket", CreationDate: created.Add(24 * time.Hour)},
+	}
+
+	t.Run("Lists the buckets", func(t *testing.T) {
+		s3cli := &mockS3Client{buckets: buckets}
+		listed, err := listBuckets(s3cli)
+		require.NoError(t, err)
+		assert.Equal(t, buckets, listed)
+	})
+
+	t.Run("Access denied", func(t *testing.T) {
+		s3cli := newMockS3Client(nil, map[string]error{
+			"ListBuckets": minio.ErrorResponse{Code: "AccessDenied", Message: "Access Denied", StatusCode: http.StatusForbidden},
+		})
+		_, err := listBuckets(s3cli)
+		require.Error(t, err)
+		assert.True(t, argoerrs.IsCode(argoerrs.CodeForbidden, err))
+		assert.Contains(t, err.Error(), "s3:ListAllMyBuckets")
+	})
+
+	t.Run("Other errors", func(t *testing.T) {
+		s3cli := newMockS3Client(nil, map[string]error{
+			"ListBuckets": minio.ErrorResponse{Code: "InternalError", StatusCode: http.StatusInternalServerError},
+		})
+		_, err := listBuckets(s3cli)
+		require.ErrorAs(t, err, &minio.ErrorResponse{})
+	})
+}
+
+func TestGetAWSCredentials_Profile(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	dir := t.TempDir()
+	credentialsFile := filepath.Join(dir, "credentials")
+	require.NoError(t, os.WriteFile(credentialsFile, []byte(`[default]
+aws_access_key_id = default-access-key
+aws_secret_access_key = default-secret-key
+
+[backup]
+aws_access_key_id = backup-access-key
+aws_secret_access_key = backup-secret-key
+`), 0o600))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv(envVarAccessKeyID, "")
+	t.Setenv(envVarSecretAccessKey, "")
+	t.Setenv(envVarSessionToken, "")
+
+	for profile, expected := range map[string]string{"": "default-access-key", "backup": "backup-access-key"} {
+		t.Run("Profile "+profile, func(t *testing.T) {
+			creds, err := getAWSCredentials(ctx, S3ClientOpts{Region: "us-east-1", Profile: profile})
+			require.NoError(t, err)
+			value, err := creds.Get()
+			require.NoError(t, err)
+			assert.Equal(t, expected, value.AccessKeyID)
+		})
+	}
+
+	t.Run("Missing profile", func(t *testing.T) {
+		_, err := getAWSCredentials(ctx, S3ClientOpts{Region: "us-east-1", Profile: "missing"})
+		require.ErrorContains(t, err, `AWS profile "missing" not found`)
+	})
+}
+
+// TestAnonymous verifies an anonymous driver downloads without signing its requests and rejects writes up front
+func TestAnonymous(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	content := []byte("public artifact")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" || r.URL.Query().Get("X-Amz-Signature") != "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/public-bucket/datasets/iris.csv" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Header().Set("ETag", `"0123456789abcdef0123456789abcdef"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(content)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	driver := &ArtifactDriver{
+		Endpoint:         strings.TrimPrefix(server.URL, "http://"),
+		Region:           "us-east-1",
+		AddressingStyle:  PathStyle,
+		Anonymous:        true,
+		MaxRetryAttempts: 1,
+	}
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+		S3Bucket: wfv1.S3Bucket{Bucket: "public-bucket"},
+		Key:      "datasets/iris.csv",
+	}}}
+
+	t.Run("Load", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "iris.csv")
+		require.NoError(t, driver.Load(ctx, artifact, path))
+		downloaded, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, content, downloaded)
+	})
+
+	t.Run("Save", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "iris.csv")
+		require.NoError(t, os.WriteFile(path, content, 0o600))
+		err := driver.Save(ctx, path, artifact)
+		require.Error(t, err)
+		assert.True(t, argoerrs.IsCode(argoerrs.CodeForbidden, err))
+		assert.Contains(t, err.Error(), "read-only anonymous access")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		err := driver.Delete(ctx, artifact)
+		require.Error(t, err)
+		assert.True(t, argoerrs.IsCode(argoerrs.CodeForbidden, err))
+	})
+}
+
+// fakeMultipartS3 is an S3 server which accepts single and multipart uploads, recording the requests made to it
+type fakeMultipartS3 struct {
+	*httptest.Server
+	// failPart makes the upload of that part number fail, when set
+	failPart string
+
+	mu       sync.Mutex
+	requests []string
+	inFlight int
+	// maxInFlight is the most parts which were uploading at once
+	maxInFlight int
+}
+
+func newFakeMultipartS3(t *testing.T, failPart string) *fakeMultipartS3 {
+	t.Helper()
+	f := &fakeMultipartS3{failPart: failPart}
+	f.Server = httptest.NewServer(f)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeMultipartS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, _ = io.Copy(io.Discard, r.Body)
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.record("CreateMultipartUpload")
+		_, _ = w.Write([]byte(`<InitiateMultipartUploadResult><Bucket>my-bucket</Bucket><Key>big.bin</Key><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>`))
+	case r.Method == http.MethodPut && query.Has("partNumber"):
+		f.uploadPart(w, query.Get("partNumber"))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		f.record("CompleteMultipartUpload")
+		_, _ = w.Write([]byte(`<CompleteMultipartUploadResult><Bucket>my-bucket</Bucket><Key>big.bin</Key><ETag>"etag"</ETag></CompleteMultipartUploadResult>`))
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		f.record("AbortMultipartUpload")
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		f.record("PutObject")
+		w.Header().Set("ETag", `"etag"`)
+	default:
+		f.record(r.Method + " " + r.URL.String())
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func (f *fakeMultipartS3) uploadPart(w http.ResponseWriter, partNumber string) {
+	f.mu.Lock()
+	f.requests = append(f.requests, "UploadPart "+partNumber)
+	f.inFlight++
+	f.maxInFlight = max(f.maxInFlight, f.inFlight)
+	f.mu.Unlock()
+	// Hold the part briefly so concurrent uploads overlap
+	time.Sleep(20 * time.Millisecond)
+	f.mu.Lock()
+	f.inFlight--
+	f.mu.Unlock()
+
+	if partNumber == f.failPart {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`<Error><Code>InvalidArgument</Code><Message>Simulated part failure</Message></Error>`))
+		return
+	}
+	w.Header().Set("ETag", `"etag-`+partNumber+`"`)
+}
+
+func (f *fakeMultipartS3) record(request string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, request)
+}
+
+// sortedRequests returns the recorded requests sorted, as parts upload in any order
+func (f *fakeMultipartS3) sortedRequests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	requests := slices.Clone(f.requests)
+	slices.Sort(requests)
+	return requests
+}
+
+// newFakeMultipartS3Client returns a client for the fake S3 uploading in 5MB parts from 6MB
+func newFakeMultipartS3Client(t *testing.T, f *fakeMultipartS3, concurrency int) S3Client {
+	t.Helper()
+	s3cli, err := NewS3Client(logging.TestContext(t.Context()), S3ClientOpts{
+		Endpoint:             strings.TrimPrefix(f.URL, "http://"),
+		Region:               "us-east-1",
+		AddressingStyle:      PathStyle,
+		AccessKey:            "key",
+		SecretKey:            "secret",
+		MultipartThreshold:   6 * 1024 * 1024,
+		MultipartPartSize:    minMultipartPartSize,
+		MultipartConcurrency: concurrency,
+	})
+	require.NoError(t, err)
+	return s3cli
+}
+
+// writeTestFile writes a file of size zeroed bytes
+func writeTestFile(t *testing.T, size int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "big.bin")
+	require.NoError(t, os.WriteFile(path, make([]byte, size), 0o600))
+	return path
+}
+
+// TestPutFile_Multipart uploads files either side of the multipart threshold to a fake S3 and checks which requests were made
+func TestPutFile_Multipart(t *testing.T) {
+	for name, tc := range map[string]struct {
+		size     int
+		expected []string
+	}{
+		"Below the threshold": {size: 5*1024*1024 + 1, expected: []string{"PutObject"}},
+		"Above the threshold": {size: 7 * 1024 * 1024, expected: []string{"CompleteMultipartUpload", "CreateMultipartUpload", "UploadPart 1", "UploadPart 2"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			f := newFakeMultipartS3(t, "")
+			require.NoError(t, newFakeMultipartS3Client(t, f, 1).PutFile("my-bucket", "big.bin", writeTestFile(t, tc.size)))
+			assert.Equal(t, tc.expected, f.sortedRequests())
+		})
+	}
+}
+
+// TestPutFile_MultipartConcurrency verifies parts upload in parallel, up to the concurrency, and a failed part aborts the upload
+func TestPutFile_MultipartConcurrency(t *testing.T) {
+	size := 4*minMultipartPartSize + 1
+
+	t.Run("Parts upload in parallel", func(t *testing.T) {
+		f := newFakeMultipartS3(t, "")
+		require.NoError(t, newFakeMultipartS3Client(t, f, 2).PutFile("my-bucket", "big.bin", writeTestFile(t, size)))
+		assert.Equal(t, []string{
+			"CompleteMultipartUpload",
+			"CreateMultipartUpload",
+			"UploadPart 1",
+			"UploadPart 2",
+			"UploadPart 3",
+			"UploadPart 4",
+			"UploadPart 5",
+		}, f.sortedRequests())
+		assert.Equal(t, 2, f.maxInFlight)
+	})
+
+	t.Run("Failed part aborts the upload", func(t *testing.T) {
+		f := newFakeMultipartS3(t, "2")
+		err := newFakeMultipartS3Client(t, f, 2).PutFile("my-bucket", "big.bin", writeTestFile(t, size))
+		require.ErrorContains(t, err, "Simulated part failure")
+		requests := f.sortedRequests()
+		assert.Contains(t, requests, "AbortMultipartUpload")
+		assert.NotContains(t, requests, "CompleteMultipartUpload")
+	})
+}
+
+// fakeRangedS3 serves a single object, honouring range requests unless noRanges is set
+type fakeRangedS3 struct {
+	*httptest.Server
+	content  []byte
+	noRanges bool
+
+	mu     sync.Mutex
+	gets   int
+	ranged int
+}
+
+func newFakeRangedS3(t *testing.T, size int, noRanges bool) *fakeRangedS3 {
+	t.Helper()
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	f := &fakeRangedS3{content: content, noRanges: noRanges}
+	f.Server = httptest.NewServer(f)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeRangedS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("ETag", `"fake-etag"`)
+	if r.Method == http.MethodGet {
+		f.mu.Lock()
+		f.gets++
+		if r.Header.Get("Range") != "" && !f.noRanges {
+			f.ranged++
+		}
+		f.mu.Unlock()
+	}
+	if f.noRanges {
+		r.Header.Del("Range")
+	}
+	http.ServeContent(w, r, "big.bin", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), bytes.NewReader(f.content))
+}
+
+func newFakeRangedS3Client(t *testing.T, f *fakeRangedS3, concurrency int) S3Client {
+	t.Helper()
+	s3cli, err := NewS3Client(logging.TestContext(t.Context()), S3ClientOpts{
+		Endpoint:            strings.TrimPrefix(f.URL, "http://"),
+		Region:              "us-east-1",
+		AddressingStyle:     PathStyle,
+		AccessKey:           "key",
+		SecretKey:           "secret",
+		MultipartThreshold:  6 * 1024 * 1024,
+		MultipartPartSize:   minMultipartPartSize,
+		DownloadConcurrency: concurrency,
+	})
+	require.NoError(t, err)
+	return s3cli
+}
+
+// TestGetFile_Ranged downloads objects with and without range requests and checks the file matches the object
+func TestGetFile_Ranged(t *testing.T) {
+	for name, tc := range map[string]struct {
+		size        int
+		concurrency int
+		noRanges    bool
+		gets        int
+		ranged      int
+	}{
+		"Concurrency unset":           {size: 3*minMultipartPartSize + 1, concurrency: 0, gets: 1, ranged: 0},
+		"Below the threshold":         {size: minMultipartPartSize + 1, concurrency: 4, gets: 1, ranged: 0},
+		"Above the threshold":         {size: 3*minMultipartPartSize + 1, concurrency: 4, gets: 4, ranged: 4},
+		"Ranges unsupported by store": {size: 3*minMultipartPartSize + 1, concurrency: 4, noRanges: true, gets: 1, ranged: 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			f := newFakeRangedS3(t, tc.size, tc.noRanges)
+			file := emptyTempFile(t)
+			require.NoError(t, newFakeRangedS3Client(t, f, tc.concurrency).GetFile("my-bucket", "big.bin", file))
+
+			downloaded, err := os.ReadFile(file.Name())
+			require.NoError(t, err)
+			assert.True(t, bytes.Equal(f.content, downloaded), "downloaded file differs from the object")
+			assert.Equal(t, tc.gets, f.gets)
+			assert.Equal(t, tc.ranged, f.ranged)
+		})
+	}
+}
+
+// TestOpenStream_Range streams byte ranges of a known object and checks exactly those bytes are returned
+func TestOpenStream_Range(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	f := newFakeRangedS3(t, 1000, false)
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+		S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+		Key:      "big.bin",
+	}}}
+
+	for name, tc := range map[string]struct {
+		offset, length int64
+		expected       []byte
+		errMsg         string
+	}{
+		"Middle range":    {offset: 100, length: 250, expected: f.content[100:350]},
+		"Offset to end":   {offset: 900, expected: f.content[900:]},
+		"First bytes":     {length: 16, expected: f.content[:16]},
+		"Whole object":    {expected: f.content},
+		"Offset past end": {offset: 1000, length: 1, errMsg: "range of 1 bytes from offset 1000 is outside big.bin, which is 1000 bytes"},
+		"Length past end": {offset: 900, length: 101, errMsg: "range of 101 bytes from offset 900 is outside big.bin, which is 1000 bytes"},
+		"Negative offset": {offset: -1, length: 10, errMsg: "range of 10 bytes from offset -1 is outside big.bin, which is 1000 bytes"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			driver := &ArtifactDriver{
+				Endpoint:        strings.TrimPrefix(f.URL, "http://"),
+				Region:          "us-east-1",
+				AddressingStyle: PathStyle,
+				AccessKey:       "key",
+				SecretKey:       "secret",
+				StreamOffset:    tc.offset,
+				StreamLength:    tc.length,
+			}
+			stream, err := driver.OpenStream(ctx, artifact)
+			if tc.errMsg != "" {
+				require.ErrorIs(t, err, ErrInvalidConfig)
+				assert.ErrorContains(t, err, tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			defer stream.Close()
+			streamed, err := io.ReadAll(stream)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, streamed)
+		})
+	}
+}
+
+// fakeObjectStore keeps the objects PUT to it in memory and serves them back, honouring If-Match and If-None-Match
+type fakeObjectStore struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	objects      map[string][]byte
+	contentTypes map[string]string
+	// objectLockDisabled rejects uploads with a retention, as S3 does for a bucket without object lock enabled
+	objectLockDisabled bool
+	// userAgent is the User-Agent of the last request
+	userAgent string
+	// requestPayers is the x-amz-request-payer header of the last request of each operation, named by fakeOperation
+	requestPayers map[string]string
+	// checksums holds the x-amz-checksum-sha256 uploaded with each object, returned when it is read
+	checksums map[string]string
+	// acls holds the canned ACL uploaded with each object
+	acls map[string]string
+	// puts counts the objects uploaded
+	puts int
+	// slowDown throttles every request with a 503 SlowDown, and requests counts the requests made
+	slowDown bool
+	requests int
+	// uploads holds the parts of each multipart upload in progress by upload ID, uploadCount numbers the next
+	uploads     map[string]map[int][]byte
+	uploadCount int
+	// buckets holds the buckets created
+	buckets []string
+}
+
+func newFakeObjectStore(t *testing.T) *fakeObjectStore {
+	t.Helper()
+	f := &fakeObjectStore{objects: map[string][]byte{}, contentTypes: map[string]string{}, requestPayers: map[string]string{}, checksums: map[string]string{}, acls: map[string]string{}, uploads: map[string]map[int][]byte{}}
+	// TLS, so minio sends bodies as they are rather than with a streaming signature
+	f.Server = httptest.NewTLSServer(f)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeObjectStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.userAgent = r.UserAgent()
+	f.requests++
+	if f.slowDown {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = io.WriteString(w, `<Error><Code>SlowDown</Code><Message>Please reduce your request rate.</Message></Error>`)
+		return
+	}
+	requestPayer := r.Header.Get(headerRequestPayer)
+	if _, signed, _ := strings.Cut(r.Header.Get("Authorization"), "SignedHeaders="); requestPayer != "" && !strings.Contains(signed, headerRequestPayer) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = io.WriteString(w, `<Error><Code>AccessDenied</Code><Message>There were headers present in the request which were not signed</Message></Error>`)
+		return
+	}
+	f.requestPayers[fakeOperation(r)] = requestPayer
+	query := r.URL.Query()
+	if query.Has("uploads") || query.Has("uploadId") {
+		f.multipart(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		if source := r.Header.Get("X-Amz-Copy-Source"); source != "" {
+			f.copyObject(w, r, source)
+			return
+		}
+		if bucket := strings.Trim(r.URL.Path, "/"); !strings.Contains(bucket, "/") {
+			f.buckets = append(f.buckets, bucket)
+			return
+		}
+		if !f.preconditionsHold(r) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			_, _ = io.WriteString(w, `<Error><Code>PreconditionFailed</Code><Message>At least one of the pre-conditions you specified did not hold</Message></Error>`)
+			return
+		}
+		if f.objectLockDisabled && r.Header.Get("X-Amz-Object-Lock-Mode") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `<Error><Code>InvalidRequest</Code><Message>Bucket is missing Object Lock Configuration</Message></Error>`)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		f.objects[r.URL.Path] = body
+		f.contentTypes[r.URL.Path] = r.Header.Get("Content-Type")
+		f.checksums[r.URL.Path] = r.Header.Get("X-Amz-Checksum-Sha256")
+		f.acls[r.URL.Path] = r.Header.Get(headerACL)
+		f.puts++
+		w.Header().Set("ETag", `"fake-etag"`)
+	case http.MethodGet, http.MethodHead:
+		if query.Has("list-type") {
+			f.list(w, r)
+			return
+		}
+		content, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", `"fake-etag"`)
+		w.Header().Set("Content-Type", f.contentTypes[r.URL.Path])
+		if checksum := f.checksums[r.URL.Path]; checksum != "" {
+			w.Header().Set("X-Amz-Checksum-Sha256", checksum)
+		}
+		http.ServeContent(w, r, "", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), bytes.NewReader(content))
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPost:
+		if !query.Has("delete") {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		f.deleteObjects(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// fakeOperation names the S3 operation a request to the fake store makes
+func fakeOperation(r *http.Request) string {
+	query := r.URL.Query()
+	switch {
+	case query.Has("uploads"):
+		return "INIT"
+	case query.Has("uploadId") && r.Method == http.MethodPut:
+		return "PART"
+	case query.Has("uploadId") && r.Method == http.MethodPost:
+		return "COMPLETE"
+	case query.Has("uploadId"):
+		return "ABORT"
+	case query.Has("list-type"):
+		return "LIST"
+	case query.Has("delete"):
+		return "DELETE_OBJECTS"
+	case r.Header.Get("X-Amz-Copy-Source") != "":
+		return "COPY"
+	}
+	return r.Method
+}
+
+// copyObject copies the object at the X-Amz-Copy-Source path to the request's
+func (f *fakeObjectStore) copyObject(w http.ResponseWriter, r *http.Request, source string) {
+	source, _ = url.PathUnescape(source)
+	content, ok := f.objects["/"+strings.TrimPrefix(source, "/")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist</Message></Error>`)
+		return
+	}
+	f.objects[r.URL.Path] = content
+	_, _ = io.WriteString(w, `<CopyObjectResult><ETag>"fake-etag"</ETag><LastModified>2025-01-01T00:00:00.000Z</LastModified></CopyObjectResult>`)
+}
+
+// deleteObjects answers a multi-object delete, removing every key listed
+func (f *fakeObjectStore) deleteObjects(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Objects []struct{ Key string } `xml:"Object"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	bucketPath := "/" + strings.Trim(r.URL.Path, "/") + "/"
+	result := "<DeleteResult>"
+	for _, object := range request.Objects {
+		delete(f.objects, bucketPath+object.Key)
+		result += "<Deleted><Key>" + object.Key + "</Key></Deleted>"
+	}
+	_, _ = io.WriteString(w, result+"</DeleteResult>")
+}
+
+// fakeListResult is the ListObjectsV2 response body
+type fakeListResult struct {
+	XMLName        xml.Name `xml:"ListBucketResult"`
+	Contents       []fakeListObject
+	CommonPrefixes []fakeListPrefix
+}
+
+type fakeListObject struct {
+	Key          string
+	Size         int
+	ETag         string
+	LastModified time.Time
+}
+
+type fakeListPrefix struct {
+	Prefix string
+}
+
+// list answers a ListObjectsV2 request for the keys under the prefix after start-after, grouping them by the
+// delimiter when one is given
+func (f *fakeObjectStore) list(w http.ResponseWriter, r *http.Request) {
+	bucketPath := "/" + strings.Trim(r.URL.Path, "/") + "/"
+	prefix, delimiter := r.URL.Query().Get("prefix"), r.URL.Query().Get("delimiter")
+	startAfter := r.URL.Query().Get("start-after")
+	var result fakeListResult
+	for _, path := range slices.Sorted(maps.Keys(f.objects)) {
+		key, ok := strings.CutPrefix(path, bucketPath)
+		if !ok || !strings.HasPrefix(key, prefix) || key <= startAfter {
+			continue
+		}
+		if i := strings.Index(key[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+			commonPrefix := fakeListPrefix{Prefix: key[:len(prefix)+i+len(delimiter)]}
+			if !slices.Contains(result.CommonPrefixes, commonPrefix) {
+				result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix)
+			}
+			continue
+		}
+		result.Contents = append(result.Contents, fakeListObject{Key: key, Size: len(f.objects[path]), ETag: `"fake-etag"`, LastModified: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)})
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(result)
+}
+
+// multipart answers the requests to initiate, upload a part to, complete and abort a multipart upload
+func (f *fakeObjectStore) multipart(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Has("uploads") {
+		// S3 only takes conditions when the upload is completed
+		if r.Header.Get("If-Match") != "" || r.Header.Get("If-None-Match") != "" {
+			w.WriteHeader(http.StatusNotImplemented)
+			_, _ = io.WriteString(w, `<Error><Code>NotImplemented</Code><Message>A header you provided implies functionality that is not implemented</Message></Error>`)
+			return
+		}
+		f.uploadCount++
+		uploadID := "upload-" + strconv.Itoa(f.uploadCount)
+		f.uploads[uploadID] = map[int][]byte{}
+		_, _ = io.WriteString(w, "<InitiateMultipartUploadResult><UploadId>"+uploadID+"</UploadId></InitiateMultipartUploadResult>")
+		return
+	}
+	parts, ok := f.uploads[query.Get("uploadId")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `<Error><Code>NoSuchUpload</Code><Message>The specified upload does not exist</Message></Error>`)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		body, _ := io.ReadAll(r.Body)
+		parts[partNumber] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, partNumber))
+	case http.MethodPost:
+		var complete struct {
+			Parts []struct{ PartNumber int } `xml:"Part"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&complete); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var content []byte
+		for _, part := range complete.Parts {
+			content = append(content, parts[part.PartNumber]...)
+		}
+		f.objects[r.URL.Path] = content
+		delete(f.uploads, query.Get("uploadId"))
+		bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		_, _ = io.WriteString(w, "<CompleteMultipartUploadResult><Bucket>"+bucket+"</Bucket><Key>"+key+`</Key><ETag>"fake-etag"</ETag></CompleteMultipartUploadResult>`)
+	case http.MethodDelete:
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// preconditionsHold checks the conditional headers of a PUT against the stored object, whose ETag is always fake-etag
+func (f *fakeObjectStore) preconditionsHold(r *http.Request) bool {
+	_, exists := f.objects[r.URL.Path]
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && (!exists || (ifMatch != "*" && ifMatch != `"fake-etag"`)) {
+		return false
+	}
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && exists && (ifNoneMatch == "*" || ifNoneMatch == `"fake-etag"`) {
+		return false
+	}
+	return true
+}
+
+// driver returns a driver using the fake store, trusting its certificate
+func (f *fakeObjectStore) driver() *ArtifactDriver {
+	return &ArtifactDriver{
+		Endpoint:         strings.TrimPrefix(f.URL, "https://"),
+		Region:           "us-east-1",
+		Secure:           true,
+		TrustedCA:        string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: f.Certificate().Raw})),
+		AddressingStyle:  PathStyle,
+		AccessKey:        "key",
+		SecretKey:        "secret",
+		MaxRetryAttempts: 1,
+	}
+}
+
+// TestWriteObject writes inline bytes and reads them back through OpenStream
+func TestWriteObject(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	f := newFakeObjectStore(t)
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+		S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+		Key:      "markers/done.json",
+	}}}
+	payload := []byte(`{"status":"done"}`)
+
+	t.Run("Round trip", func(t *testing.T) {
+		driver := f.driver()
+		require.NoError(t, driver.WriteObject(ctx, artifact, payload, "application/json"))
+		assert.Equal(t, "application/json", f.contentTypes["/my-bucket/markers/done.json"])
+
+		stream, err := driver.OpenStream(ctx, artifact)
+		require.NoError(t, err)
+		defer stream.Close()
+		read, err := io.ReadAll(stream)
+		require.NoError(t, err)
+		assert.Equal(t, payload, read)
+	})
+
+	t.Run("Detected content type", func(t *testing.T) {
+		require.NoError(t, f.driver().WriteObject(ctx, artifact, []byte("plain text marker"), ""))
+		assert.Equal(t, "text/plain; charset=utf-8", f.contentTypes["/my-bucket/markers/done.json"])
+	})
+
+	t.Run("Too large", func(t *testing.T) {
+		err := f.driver().WriteObject(ctx, artifact, make([]byte, DefaultMaxInlineObjectSize+1), "")
+		require.Error(t, err)
+		assert.True(t, argoerrs.IsCode(argoerrs.CodeBadRequest, err))
+	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		driver := f.driver()
+		driver.Anonymous = true
+		err := driver.WriteObject(ctx, artifact, payload, "")
+		require.Error(t, err)
+		assert.True(t, argoerrs.IsCode(argoerrs.CodeForbidden, err))
+	})
+}
+
+// TestSaveFromReader uploads from a reader and reads the object back
+func TestSaveFromReader(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	f := newFakeObjectStore(t)
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+		S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+		Key:      "logs/main.log",
+	}}}
+
+	driver := f.driver()
+	require.NoError(t, driver.SaveFromReader(ctx, strings.NewReader("streamed without a temp file"), artifact))
+	assert.Equal(t, "text/plain; charset=utf-8", f.contentTypes["/my-bucket/logs/main.log"])
+	var buf bytes.Buffer
+	require.NoError(t, driver.LoadToWriter(ctx, artifact, &buf))
+	assert.Equal(t, "streamed without a temp file", buf.String())
+
+	t.Run("Anonymous", func(t *testing.T) {
+		driver := f.driver()
+		driver.Anonymous = true
+		err := driver.SaveFromReader(ctx, strings.NewReader("denied"), artifact)
+		assert.True(t, argoerrs.IsCode(argoerrs.CodeForbidden, err))
+	})
+}
+
+// TestReaderSize verifies the length is taken from readers which report it, and is unknown for any other
+func TestReaderSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.WriteFile(path, []byte("0123456789"), 0o600))
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	_, err = file.Seek(4, io.SeekStart)
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		reader   io.Reader
+		expected int64
+	}{
+		"strings.Reader":   {reader: strings.NewReader("hello"), expected: 5},
+		"bytes.Buffer":     {reader: bytes.NewBufferString("hi"), expected: 2},
+		"File from offset": {reader: file, expected: 6},
+		"Unknown":          {reader: io.MultiReader(strings.NewReader("hello")), expected: -1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			size, err := readerSize(tc.reader)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, size)
+		})
+	}
+}
+
+// TestWriteObject_Conditional verifies IfMatch and IfNoneMatch are sent, and a failed condition leaves the object as it was
+func TestWriteObject_Conditional(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	f := newFakeObjectStore(t)
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+		S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+		Key:      "locks/run.json",
+	}}}
+	const path = "/my-bucket/locks/run.json"
+
+	writeOnce := f.driver()
+	writeOnce.IfNoneMatch = "*"
+	require.NoError(t, writeOnce.WriteObject(ctx, artifact, []byte("first"), ""))
+	assert.Equal(t, []byte("first"), f.objects[path])
+
+	t.Run("If-None-Match on an existing object", func(t *testing.T) {
+		err := writeOnce.WriteObject(ctx, artifact, []byte("second"), "")
+		require.Error(t, err)
+		assert.True(t, IsS3ErrCode(err, "PreconditionFailed"))
+		assert.Equal(t, []byte("first"), f.objects[path])
+	})
+
+	t.Run("If-Match with the current ETag", func(t *testing.T) {
+		driver := f.driver()
+		driver.IfMatch = `"fake-etag"`
+		require.NoError(t, driver.WriteObject(ctx, artifact, []byte("updated"), ""))
+		assert.Equal(t, []byte("updated"), f.objects[path])
+	})
+
+	t.Run("If-Match with a stale ETag", func(t *testing.T) {
+		driver := f.driver()
+		driver.IfMatch = "stale-etag"
+		err := driver.WriteObject(ctx, artifact, []byte("lost update"), "")
+		require.Error(t, err)
+		assert.True(t, IsS3ErrCode(err, "PreconditionFailed"))
+		assert.Equal(t, []byte("updated"), f.objects[path])
+	})
+}
+
+// TestSaveWithStats verifies the object count and total bytes match what was uploaded
+func TestSaveWithStats(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nested", "b.txt"), []byte("world!"), 0o600))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "a.txt"), filepath.Join(dir, "link.txt")))
+	artifact := func(key string) *wfv1.Artifact {
+		return &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+			S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+			Key:      key,
+		}}}
+	}
+
+	t.Run("Directory", func(t *testing.T) {
+		f := newFakeObjectStore(t)
+		stats, err := f.driver().SaveWithStats(ctx, dir, artifact("out"))
+		require.NoError(t, err)
+		assert.Equal(t, SaveStats{ObjectCount: 2, TotalBytes: 11}, stats)
+		assert.Len(t, f.objects, 2)
+	})
+
+	t.Run("File", func(t *testing.T) {
+		f := newFakeObjectStore(t)
+		stats, err := f.driver().SaveWithStats(ctx, filepath.Join(dir, "nested", "b.txt"), artifact("out/b.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, SaveStats{ObjectCount: 1, TotalBytes: 6}, stats)
+	})
+
+	t.Run("Archived directory", func(t *testing.T) {
+		f := newFakeObjectStore(t)
+		driver := f.driver()
+		driver.Archive = ArchiveTar
+		stats, err := driver.SaveWithStats(ctx, dir, artifact("out.tar"))
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.ObjectCount)
+		assert.Equal(t, int64(len(f.objects["/my-bucket/out.tar"])), stats.TotalBytes)
+	})
+
+	t.Run("Failed upload", func(t *testing.T) {
+		f := newFakeObjectStore(t)
+		driver := f.driver()
+		driver.Anonymous = true
+		stats, err := driver.SaveWithStats(ctx, dir, artifact("out"))
+		require.Error(t, err)
+		assert.Zero(t, stats)
+	})
+}
+
+// TestIsDirectory verifies prefixes with children and empty directory markers are directories, and files aren't
+func TestIsDirectory(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	f := newFakeObjectStore(t)
+	f.objects["/my-bucket/reports/2025/summary.csv"] = []byte("a,b\n")
+	f.objects["/my-bucket/empty/"] = []byte{}
+	f.objects["/my-bucket/notes.txt"] = []byte("hello")
+	f.objects["/my-bucket/odd/"] = []byte("not a marker")
+
+	for key, expected := range map[string]bool{
+		"reports":      true,
+		"reports/2025": true,
+		"empty":        true,
+		"empty/":       true,
+		"notes.txt":    false,
+		"odd":          false,
+		"missing":      false,
+	} {
+		t.Run(key, func(t *testing.T) {
+			isDir, err := f.driver().IsDirectory(ctx, &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+				S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+				Key:      key,
+			}}})
+			require.NoError(t, err)
+			assert.Equal(t, expected, isDir)
+		})
+	}
+}
+
+// TestLoad_Directory verifies a directory key is downloaded with its objects' relative structure
+func TestLoad_Directory(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	f := newFakeObjectStore(t)
+	f.objects["/my-bucket/out/a.txt"] = []byte("a")
+	f.objects["/my-bucket/out/sub/b.txt"] = []byte("b")
+	f.objects["/my-bucket/out/sub/deeper/c.txt"] = []byte("c")
+	f.objects["/my-bucket/outside.txt"] = []byte("not in the directory")
+	load := func(key string) (string, error) {
+		dest := filepath.Join(t.TempDir(), "dest")
+		return dest, f.driver().Load(ctx, &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+			S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+			Key:      key,
+		}}}, dest)
+	}
+
+	for _, key := range []string{"out", "out/"} {
+		t.Run(key, func(t *testing.T) {
+			dest, err := load(key)
+			require.NoError(t, err)
+			tree := map[string]string{}
+			require.NoError(t, filepath.WalkDir(dest, func(path string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				content, err := os.ReadFile(path)
+				rel, _ := filepath.Rel(dest, path)
+				tree[filepath.ToSlash(rel)] = string(content)
+				return err
+			}))
+			assert.Equal(t, map[string]string{"a.txt": "a", "sub/b.txt": "b", "sub/deeper/c.txt": "c"}, tree)
+		})
+	}
+
+	t.Run("Empty directory marker", func(t *testing.T) {
+		f.objects["/my-bucket/empty/"] = []byte{}
+		dest, err := load("empty")
+		require.NoError(t, err)
+		entries, err := os.ReadDir(dest)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("Key escaping the destination", func(t *testing.T) {
+		f.objects["/my-bucket/evil/../../escaped.txt"] = []byte("x")
+		_, err := load("evil")
+		require.ErrorContains(t, err, "refusing to download evil/../../escaped.txt outside of")
+	})
+}
+
+// TestRequesterPays verifies the requester-pays header is signed and sent with every request only when enabled
+func TestRequesterPays(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	f := newFakeObjectStore(t)
+	for _, key := range []string{"a.txt", "b.txt", "c.txt"} {
+		f.objects["/my-bucket/data/"+key] = []byte(key)
+	}
+
+	for name, tc := range map[string]struct {
+		requesterPays bool
+		expected      string
+	}{
+		"Enabled":  {requesterPays: true, expected: requestPayerRequester},
+		"Disabled": {expected: ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			clear(f.requestPayers)
+			driver := f.driver()
+			driver.RequesterPays = tc.requesterPays
+			s3cli, err := driver.newS3Client(ctx)
+			require.NoError(t, err)
+
+			_, err = s3cli.StatObject("my-bucket", "data/a.txt")
+			require.NoError(t, err)
+			require.NoError(t, s3cli.GetFile("my-bucket", "data/a.txt", emptyTempFile(t)))
+			keys, err := s3cli.ListDirectory("my-bucket", "data")
+			require.NoError(t, err)
+			assert.Len(t, keys, 3)
+
+			path := filepath.Join(t.TempDir(), "upload.txt")
+			require.NoError(t, os.WriteFile(path, []byte("upload"), 0o600))
+			require.NoError(t, s3cli.PutFile("my-bucket", "out/upload.txt", path))
+			src, err := s3cli.StatObject("my-bucket", "out/upload.txt")
+			require.NoError(t, err)
+			require.NoError(t, s3cli.CopyObject("my-bucket", src, "out/copy.txt"))
+			require.NoError(t, s3cli.Delete("my-bucket", "out/copy.txt"))
+			require.NoError(t, s3cli.DeleteObjects("my-bucket", []string{"out/upload.txt"}))
+
+			uploadID, err := s3cli.NewMultipartUpload("my-bucket", "out/multipart.txt")
+			require.NoError(t, err)
+			etag, err := s3cli.PutObjectPart("my-bucket", "out/multipart.txt", uploadID, 1, []byte("part"))
+			require.NoError(t, err)
+			require.NoError(t, s3cli.CompleteMultipartUpload("my-bucket", "out/multipart.txt", uploadID, []MultipartPart{{PartNumber: 1, ETag: etag}}))
+			uploadID, err = s3cli.NewMultipartUpload("my-bucket", "out/aborted.txt")
+			require.NoError(t, err)
+			require.NoError(t, s3cli.AbortMultipartUpload("my-bucket", "out/aborted.txt", uploadID))
+
+			assert.Equal(t, map[string]string{
+				"GET": tc.expected, "HEAD": tc.expected, "LIST": tc.expected,
+				"PUT": tc.expected, "COPY": tc.expected, "DELETE": tc.expected, "DELETE_OBJECTS": tc.expected,
+				"INIT": tc.expected, "PART": tc.expected, "COMPLETE": tc.expected, "ABORT": tc.expected,
+			}, f.requestPayers)
+			assert.NotContains(t, f.objects, "/my-bucket/out/copy.txt")
+			assert.NotContains(t, f.objects, "/my-bucket/out/upload.txt")
+			assert.Equal(t, []byte("part"), f.objects["/my-bucket/out/multipart.txt"])
+		})
+	}
+
+	t.Run("Paged", func(t *testing.T) {
+		clear(f.requestPayers)
+		driver := f.driver()
+		driver.RequesterPays = true
+		s3cli, err := driver.newS3Client(ctx)
+		require.NoError(t, err)
+
+		keys, token, err := s3cli.ListDirectoryPage("my-bucket", "data", 2, "")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"data/a.txt", "data/b.txt"}, keys)
+		assert.Equal(t, requestPayerRequester, f.requestPayers["LIST"])
+
+		keys, token, err = s3cli.ListDirectoryPage("my-bucket", "data", 2, token)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"data/c.txt"}, keys)
+		assert.Empty(t, token)
+	})
+
+	t.Run("Configured", func(t *testing.T) {
+		config, err := parsePluginConfiguration(ctx, "useSDKCreds: true\nrequesterPays: true\n")
+		require.NoError(t, err)
+		driver, err := getArtifactDriver(ctx, config)
+		require.NoError(t, err)
+		assert.True(t, driver.RequesterPays)
+	})
+}
+
+// TestSave_SkipIfUnchanged verifies a file is only uploaded when the object doesn't already hold its content
+func TestSave_SkipIfUnchanged(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	f := newFakeObjectStore(t)
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+		S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+		Key:      "report.csv",
+	}}}
+	driver := f.driver()
+	driver.UploadChecksum = true
+	driver.ChecksumAlgorithm = ChecksumSHA256
+	driver.SkipIfUnchanged = true
+	path := filepath.Join(t.TempDir(), "report.csv")
+	require.NoError(t, os.WriteFile(path, []byte("a,b\n1,2\n"), 0o600))
+
+	// The first save finds no object, so uploads it
+	stats, err := driver.SaveWithStats(ctx, path, artifact)
+	require.NoError(t, err)
+	assert.Equal(t, SaveStats{ObjectCount: 1, TotalBytes: 8}, stats)
+	assert.Equal(t, 1, f.puts)
+
+	t.Run("Unchanged", func(t *testing.T) {
+		stats, err := driver.SaveWithStats(ctx, path, artifact)
+		require.NoError(t, err)
+		assert.Equal(t, SaveStats{Skipped: true}, stats)
+		assert.Equal(t, 1, f.puts)
+	})
+
+	t.Run("Changed", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("a,b\n3,4\n"), 0o600))
+		stats, err := driver.SaveWithStats(ctx, path, artifact)
+		require.NoError(t, err)
+		assert.False(t, stats.Skipped)
+		assert.Equal(t, 2, f.puts)
+		assert.Equal(t, "a,b\n3,4\n", string(f.objects["/my-bucket/report.csv"]))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		driver := f.driver()
+		stats, err := driver.SaveWithStats(ctx, path, artifact)
+		require.NoError(t, err)
+		assert.False(t, stats.Skipped)
+		assert.Equal(t, 3, f.puts)
+	})
+}
+
+// TestSave_TempDir verifies the archive Save buffers is removed from TempDir, whether the upload succeeds or fails
+func TestSave_TempDir(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	f := newFakeObjectStore(t)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o600))
+	tempDir := t.TempDir()
+	driver := f.driver()
+	driver.Archive = ArchiveTar
+	driver.TempDir = tempDir
+	save := func(bucket string) error {
+		return driver.Save(ctx, dir, &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+			S3Bucket: wfv1.S3Bucket{Bucket: bucket},
+			Key:      "outputs.tar",
+		}}})
+	}
+
+	require.NoError(t, save("my-bucket"))
+	assert.Contains(t, f.objects, "/my-bucket/outputs.tar")
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	f.objectLockDisabled = true
+	driver.ObjectLockMode = "GOVERNANCE"
+	driver.ObjectLockRetention = time.Hour
+	require.Error(t, save("my-bucket"))
+	entries, err = os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+// TestLoad_Overwrite verifies an existing destination is replaced unless overwrite is disabled
+func TestLoad_Overwrite(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	f := newFakeObjectStore(t)
+	f.objects["/my-bucket/report.csv"] = []byte("new")
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+		S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+		Key:      "report.csv",
+	}}}
+	existing := func(t *testing.T) string {
+		path := filepath.Join(t.TempDir(), "report.csv")
+		require.NoError(t, os.WriteFile(path, []byte("old"), 0o600))
+		return path
+	}
+
+	t.Run("Allowed", func(t *testing.T) {
+		path := existing(t)
+		require.NoError(t, f.driver().Load(ctx, artifact, path))
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "new", string(content))
+
+		entries, err := os.ReadDir(filepath.Dir(path))
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "the temporary part file should be removed")
+	})
+
+	t.Run("Denied", func(t *testing.T) {
+		driver := f.driver()
+		driver.NoOverwrite = true
+		path := existing(t)
+		require.ErrorIs(t, driver.Load(ctx, artifact, path), ErrDestinationExists)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "old", string(content))
+
+		// A destination which doesn't exist yet is loaded as usual
+		path = filepath.Join(t.TempDir(), "report.csv")
+		require.NoError(t, driver.Load(ctx, artifact, path))
+	})
+
+	t.Run("Configured", func(t *testing.T) {
+		config, err := parsePluginConfiguration(ctx, "useSDKCreds: true\noverwrite: false\n")
+		require.NoError(t, err)
+		driver, err := getArtifactDriver(ctx, config)
+		require.NoError(t, err)
+		assert.True(t, driver.NoOverwrite)
+	})
+}
+
+// TestSave_ObjectLockDisabled verifies a retention rejected by a bucket without object lock is a configuration error
+func TestSave_ObjectLockDisabled(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	f := newFakeObjectStore(t)
+	path := filepath.Join(t.TempDir(), "report.csv")
+	require.NoError(t, os.WriteFile(path, []byte("a,b\n"), 0o600))
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+		S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+		Key:      "reports/report.csv",
+	}}}
+	driver := f.driver()
+	driver.ObjectLockMode = string(minio.Governance)
+	driver.ObjectLockRetention = 24 * time.Hour
+
+	require.NoError(t, driver.Save(ctx, path, artifact))
+
+	f.objectLockDisabled = true
+	err := driver.Save(ctx, path, artifact)
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	assert.ErrorContains(t, err, "objectLockMode requires object lock to be enabled on bucket my-bucket")
+	assert.True(t, IsS3ErrCode(err, "InvalidRequest"))
+
+	err = driver.WriteObject(ctx, artifact, []byte("a,b\n"), "")
+	require.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+// TestReadObject reads small objects inline and rejects those above the limit
+func TestReadObject(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	f := newFakeObjectStore(t)
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+		S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+		Key:      "config/settings.yaml",
+	}}}
+	payload := []byte("retries: 3\ntimeout: 30s\n")
+	require.NoError(t, f.driver().WriteObject(ctx, artifact, payload, ""))
+
+	t.Run("Small object", func(t *testing.T) {
+		data, err := f.driver().ReadObject(ctx, artifact)
+		require.NoError(t, err)
+		assert.Equal(t, payload, data)
+	})
+
+	t.Run("Object above the limit", func(t *testing.T) {
+		driver := f.driver()
+		driver.MaxInlineObjectSize = 10
+		_, err := driver.ReadObject(ctx, artifact)
+		require.ErrorIs(t, err, ErrObjectTooLarge)
+		assert.ErrorContains(t, err, "config/settings.yaml is 24 bytes, at most 10 bytes can be read inline, use OpenStream instead")
+	})
+
+	t.Run("Missing object", func(t *testing.T) {
+		missing := artifact.DeepCopy()
+		missing.S3.Key = "config/missing.yaml"
+		_, err := f.driver().ReadObject(ctx, missing)
+		require.Error(t, err)
+		assert.True(t, IsS3ErrCode(err, "NoSuchKey"))
+	})
+}
+
+// stallingS3 serves an object whose body stalls after its first bytes, until the request is cancelled
+type stallingS3 struct {
+	*httptest.Server
+	// stalled receives once per GET whose body has stalled
+	stalled chan struct{}
+	// cancelled receives once per GET the client cancelled
+	cancelled chan struct{}
+}
+
+func newStallingS3(t *testing.T) *stallingS3 {
+	t.Helper()
+	f := &stallingS3{stalled: make(chan struct{}, 10), cancelled: make(chan struct{}, 10)}
+	f.Server = httptest.NewServer(f)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *stallingS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Length", "1024")
+	w.Header().Set("ETag", `"0123456789abcdef0123456789abcdef"`)
+	w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+	if r.Method != http.MethodGet {
+		return
+	}
+	_, _ = w.Write(make([]byte, 16))
+	w.(http.Flusher).Flush()
+	f.stalled <- struct{}{}
+	select {
+	case <-r.Context().Done():
+		f.cancelled <- struct{}{}
+	case <-time.After(10 * time.Second):
+	}
+}
+
+// TestContextCancellation verifies cancelling the caller's context aborts an in-flight S3 request
+func TestContextCancellation(t *testing.T) {
+	f := newStallingS3(t)
+	driver := &ArtifactDriver{
+		Endpoint:         strings.TrimPrefix(f.URL, "http://"),
+		Region:           "us-east-1",
+		AddressingStyle:  PathStyle,
+		AccessKey:        "key",
+		SecretKey:        "secret",
+		MaxRetryAttempts: 1,
+	}
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+		S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+		Key:      "big.bin",
+	}}}
+	waitFor := func(t *testing.T, ch chan struct{}, what string) {
+		t.Helper()
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for the request to be %s", what)
+		}
+	}
+
+	t.Run("Load", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(logging.TestContext(t.Context()))
+		defer cancel()
+		done := make(chan error, 1)
+		go func() {
+			done <- driver.Load(ctx, artifact, filepath.Join(t.TempDir(), "big.bin"))
+		}()
+		waitFor(t, f.stalled, "stalled")
+		cancel()
+		waitFor(t, f.cancelled, "cancelled")
+		select {
+		case err := <-done:
+			require.ErrorIs(t, err, context.Canceled)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Load did not return after its context was cancelled")
+		}
+	})
+
+	t.Run("OpenStream", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(logging.TestContext(t.Context()))
+		defer cancel()
+		stream, err := driver.OpenStream(ctx, artifact)
+		require.NoError(t, err)
+		defer stream.Close()
+		_, err = io.ReadFull(stream, make([]byte, 16))
+		require.NoError(t, err)
+		waitFor(t, f.stalled, "stalled")
+
+		cancel()
+		waitFor(t, f.cancelled, "cancelled")
+		_, err = io.ReadAll(stream)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+// TestLoadToWriter verifies an object is written to a writer, and its checksum is verified when enabled
+func TestLoadToWriter(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	f := newFakeObjectStore(t)
+	content := []byte("hello from s3")
+	f.objects["/my-bucket/data/hello.txt"] = content
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: "data/hello.txt"}}}
+
+	var buf bytes.Buffer
+	require.NoError(t, f.driver().LoadToWriter(ctx, artifact, &buf))
+	assert.Equal(t, content, buf.Bytes())
+
+	t.Run("NotFound", func(t *testing.T) {
+		missing := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: "data/missing.txt"}}}
+		err := f.driver().LoadToWriter(ctx, missing, &bytes.Buffer{})
+		assert.True(t, argoerrs.IsCode(argoerrs.CodeNotFound, err))
+	})
+
+	t.Run("VerifyChecksum", func(t *testing.T) {
+		driver := f.driver()
+		driver.VerifyChecksum = true
+		driver.ChecksumAlgorithm = ChecksumSHA256
+		sum := sha256.Sum256(content)
+		f.checksums["/my-bucket/data/hello.txt"] = base64.StdEncoding.EncodeToString(sum[:])
+		buf.Reset()
+		require.NoError(t, driver.LoadToWriter(ctx, artifact, &buf))
+		assert.Equal(t, content, buf.Bytes())
+
+		sum = sha256.Sum256([]byte("something else"))
+		f.checksums["/my-bucket/data/hello.txt"] = base64.StdEncoding.EncodeToString(sum[:])
+		require.ErrorContains(t, driver.LoadToWriter(ctx, artifact, &bytes.Buffer{}), "checksum mismatch")
+	})
+}
@@ -0,0 +1,186 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// envVarSecretCacheTTL overrides how long resolved secret values are cached, as a Go duration
+	envVarSecretCacheTTL = "SECRET_CACHE_TTL"
+	// defaultSecretCacheTTL is how long resolved secret values are cached when not overridden
+	defaultSecretCacheTTL = 60 * time.Second
+)
+
+// SecretErrorCategory classifies why a secret value could not be resolved
+type SecretErrorCategory string
+
+const (
+	// SecretNotFound means the secret doesn't exist in the namespace
+	SecretNotFound SecretErrorCategory = "SecretNotFound"
+	// SecretKeyNotFound means the secret exists but has no such key
+	SecretKeyNotFound SecretErrorCategory = "KeyNotFound"
+	// SecretForbidden means the plugin's service account isn't allowed to get the secret
+	SecretForbidden SecretErrorCategory = "Forbidden"
+	// SecretLookupFailed is any other failure reading the secret from the API server
+	SecretLookupFailed SecretErrorCategory = "LookupFailed"
+)
+
+// SecretResolutionError is returned when a secret value referenced by the plugin configuration can't be resolved
+type SecretResolutionError struct {
+	Namespace string
+	Name      string
+	Key       string
+	Category  SecretErrorCategory
+	// Err is the Kubernetes API error behind the failure, nil for SecretKeyNotFound
+	Err error
+}
+
+func (e *SecretResolutionError) Error() string {
+	switch e.Category {
+	case SecretKeyNotFound:
+		return fmt.Sprintf("secret key %s not found in secret %s/%s", e.Key, e.Namespace, e.Name)
+	case SecretNotFound:
+		return fmt.Sprintf("secret %s/%s not found: %v", e.Namespace, e.Name, e.Err)
+	case SecretForbidden:
+		return fmt.Sprintf("not allowed to get secret %s/%s: %v", e.Namespace, e.Name, e.Err)
+	}
+	return fmt.Sprintf("failed to get secret %s/%s: %v", e.Namespace, e.Name, e.Err)
+}
+
+func (e *SecretResolutionError) Unwrap() error {
+	return e.Err
+}
+
+// secretErrorCategory classifies an error from getting a secret from the Kubernetes API
+func secretErrorCategory(err error) SecretErrorCategory {
+	switch {
+	case apierrors.IsNotFound(err):
+		return SecretNotFound
+	case apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		return SecretForbidden
+	}
+	return SecretLookupFailed
+}
+
+// secretValues caches the secret values resolved for all requests served by this process
+var secretValues = newSecretCache(secretCacheTTL())
+
+var (
+	clientsetOnce sync.Once
+	clientset     kubernetes.Interface
+	clientsetErr  error
+	// newClientset builds the shared Kubernetes client, tests replace it to inject a fake clientset
+	newClientset = newInClusterClientset
+)
+
+// getClientset returns the Kubernetes client shared by all requests, creating it on first use.
+// An initialization error is returned on first use and every use after it.
+func getClientset() (kubernetes.Interface, error) {
+	clientsetOnce.Do(func() {
+		clientset, clientsetErr = newClientset()
+	})
+	return clientset, clientsetErr
+}
+
+// secretResolver reads the values of a configuration's secret selectors. The Kubernetes client is only created once
+// a selector is read, so configurations which select no secrets work outside a cluster.
+type secretResolver struct {
+	namespace string
+	clientset kubernetes.Interface
+}
+
+// value returns the value the selector refers to, see getSelectedSecretValue
+func (r *secretResolver) value(ctx context.Context, selector *corev1.SecretKeySelector) (string, error) {
+	if r.clientset == nil {
+		clientset, err := getClientset()
+		if err != nil {
+			return "", err
+		}
+		r.clientset = clientset
+	}
+	return getSelectedSecretValue(ctx, r.clientset, r.namespace, selector)
+}
+
+// newInClusterClientset creates a Kubernetes client from the pod's service account
+func newInClusterClientset() (kubernetes.Interface, error) {
+	k8sConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get in-cluster config: %w", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(k8sConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	return clientset, nil
+}
+
+// secretCacheKey identifies a single key within a Kubernetes secret
+type secretCacheKey struct {
+	namespace  string
+	secretName string
+	secretKey  string
+}
+
+type secretCacheEntry struct {
+	value   string
+	expires time.Time
+}
+
+// secretCache is a TTL cache in front of the Kubernetes API for secret values, so that parallel
+// artifact operations sharing credentials don't each issue a Secrets().Get
+type secretCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[secretCacheKey]secretCacheEntry
+}
+
+func newSecretCache(ttl time.Duration) *secretCache {
+	return &secretCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[secretCacheKey]secretCacheEntry),
+	}
+}
+
+// secretCacheTTL returns the cache expiry from the environment, falling back to the default when unset or invalid
+func secretCacheTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv(envVarSecretCacheTTL))
+	if err != nil || ttl < 0 {
+		return defaultSecretCacheTTL
+	}
+	return ttl
+}
+
+// get returns the cached secret value, fetching it from the API server when missing or expired.
+// Failed lookups are not cached.
+func (c *secretCache) get(ctx context.Context, clientset kubernetes.Interface, namespace, secretName, secretKey string) (string, error) {
+	key := secretCacheKey{namespace: namespace, secretName: secretName, secretKey: secretKey}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expires) {
+		return entry.value, nil
+	}
+
+	value, err := fetchSecretValue(ctx, clientset, namespace, secretName, secretKey)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = secretCacheEntry{value: value, expires: c.now().Add(c.ttl)}
+	c.mu.Unlock()
+	return value, nil
+}
@@ -0,0 +1,203 @@
+package s3
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// setClientsetConstructor replaces the shared clientset constructor for the duration of the test
+func setClientsetConstructor(t *testing.T, constructor func() (kubernetes.Interface, error)) {
+	reset := func() {
+		clientsetOnce = sync.Once{}
+		clientset = nil
+		clientsetErr = nil
+	}
+	original := newClientset
+	reset()
+	newClientset = constructor
+	t.Cleanup(func() {
+		newClientset = original
+		reset()
+	})
+}
+
+// setNamespace points the service account namespace at a file holding namespace for the duration of the test
+func setNamespace(t *testing.T, namespace string) {
+	path := filepath.Join(t.TempDir(), "namespace")
+	require.NoError(t, os.WriteFile(path, []byte(namespace), 0o600))
+	original := namespaceFile
+	namespaceFile = path
+	t.Cleanup(func() { namespaceFile = original })
+}
+
+func newFakeSecretClientset() *fake.Clientset {
+	return fake.NewClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-minio-cred", Namespace: "argo"},
+		Data: map[string][]byte{
+			"accesskey": []byte("my-access-key"),
+		},
+	})
+}
+
+// countSecretGets returns how many secret get calls reached the fake clientset
+func countSecretGets(clientset *fake.Clientset) int {
+	count := 0
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == "get" && action.GetResource().Resource == "secrets" {
+			count++
+		}
+	}
+	return count
+}
+
+func TestSecretCache(t *testing.T) {
+	ctx := t.Context()
+
+	t.Run("hit within TTL", func(t *testing.T) {
+		clientset := newFakeSecretClientset()
+		cache := newSecretCache(time.Minute)
+
+		value, err := cache.get(ctx, clientset, "argo", "my-minio-cred", "accesskey")
+		require.NoError(t, err)
+		assert.Equal(t, "my-access-key", value)
+
+		value, err = cache.get(ctx, clientset, "argo", "my-minio-cred", "accesskey")
+		require.NoError(t, err)
+		assert.Equal(t, "my-access-key", value)
+		assert.Equal(t, 1, countSecretGets(clientset))
+	})
+
+	t.Run("refresh after expiry", func(t *testing.T) {
+		clientset := newFakeSecretClientset()
+		cache := newSecretCache(time.Minute)
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+
+		_, err := cache.get(ctx, clientset, "argo", "my-minio-cred", "accesskey")
+		require.NoError(t, err)
+
+		now = now.Add(2 * time.Minute)
+		value, err := cache.get(ctx, clientset, "argo", "my-minio-cred", "accesskey")
+		require.NoError(t, err)
+		assert.Equal(t, "my-access-key", value)
+		assert.Equal(t, 2, countSecretGets(clientset))
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		clientset := newFakeSecretClientset()
+		cache := newSecretCache(time.Minute)
+
+		_, err := cache.get(ctx, clientset, "argo", "my-minio-cred", "missing")
+		require.Error(t, err)
+		_, err = cache.get(ctx, clientset, "argo", "my-minio-cred", "missing")
+		require.Error(t, err)
+		assert.Equal(t, 2, countSecretGets(clientset))
+	})
+}
+
+// TestFetchSecretValue_Errors verifies Kubernetes API errors are categorised in a SecretResolutionError
+func TestFetchSecretValue_Errors(t *testing.T) {
+	ctx := t.Context()
+	secrets := corev1.Resource("secrets")
+	failingGets := func(err error) kubernetes.Interface {
+		clientset := newFakeSecretClientset()
+		clientset.PrependReactor("get", "secrets", func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, err
+		})
+		return clientset
+	}
+
+	for name, tc := range map[string]struct {
+		clientset kubernetes.Interface
+		secret    string
+		key       string
+		category  SecretErrorCategory
+		message   string
+	}{
+		"Secret not found": {
+			clientset: newFakeSecretClientset(), secret: "missing-cred", key: "accesskey",
+			category: SecretNotFound, message: "secret argo/missing-cred not found",
+		},
+		"Key not found": {
+			clientset: newFakeSecretClientset(), secret: "my-minio-cred", key: "missing",
+			category: SecretKeyNotFound, message: "secret key missing not found in secret argo/my-minio-cred",
+		},
+		"Forbidden": {
+			clientset: failingGets(apierrors.NewForbidden(secrets, "my-minio-cred", errors.New("RBAC denied"))),
+			secret:    "my-minio-cred", key: "accesskey",
+			category: SecretForbidden, message: "not allowed to get secret argo/my-minio-cred",
+		},
+		"Unauthorized": {
+			clientset: failingGets(apierrors.NewUnauthorized("token expired")), secret: "my-minio-cred", key: "accesskey",
+			category: SecretForbidden, message: "not allowed to get secret argo/my-minio-cred",
+		},
+		"API server unavailable": {
+			clientset: failingGets(apierrors.NewServiceUnavailable("etcd unavailable")), secret: "my-minio-cred", key: "accesskey",
+			category: SecretLookupFailed, message: "failed to get secret argo/my-minio-cred",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := fetchSecretValue(ctx, tc.clientset, "argo", tc.secret, tc.key)
+			var secretErr *SecretResolutionError
+			require.ErrorAs(t, err, &secretErr)
+			assert.Equal(t, tc.category, secretErr.Category)
+			assert.Equal(t, SecretResolutionError{Namespace: "argo", Name: tc.secret, Key: tc.key, Category: tc.category, Err: secretErr.Err}, *secretErr)
+			assert.ErrorContains(t, err, tc.message)
+		})
+	}
+}
+
+func TestSecretCacheTTL(t *testing.T) {
+	t.Setenv(envVarSecretCacheTTL, "")
+	assert.Equal(t, defaultSecretCacheTTL, secretCacheTTL())
+
+	t.Setenv(envVarSecretCacheTTL, "5m")
+	assert.Equal(t, 5*time.Minute, secretCacheTTL())
+
+	t.Setenv(envVarSecretCacheTTL, "not-a-duration")
+	assert.Equal(t, defaultSecretCacheTTL, secretCacheTTL())
+}
+
+func TestGetClientset(t *testing.T) {
+	t.Run("created once", func(t *testing.T) {
+		calls := 0
+		fakeClientset := newFakeSecretClientset()
+		setClientsetConstructor(t, func() (kubernetes.Interface, error) {
+			calls++
+			return fakeClientset, nil
+		})
+
+		first, err := getClientset()
+		require.NoError(t, err)
+		second, err := getClientset()
+		require.NoError(t, err)
+		assert.Same(t, fakeClientset, first)
+		assert.Same(t, first, second)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("initialization error", func(t *testing.T) {
+		setClientsetConstructor(t, func() (kubernetes.Interface, error) {
+			return nil, errors.New("failed to get in-cluster config: not in cluster")
+		})
+
+		_, err := getClientset()
+		require.EqualError(t, err, "failed to get in-cluster config: not in cluster")
+		_, err = getClientset()
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,154 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+
+	argoerrs "github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+)
+
+// SelectQuery is an S3 Select query, filtering an object server-side with a SQL expression so only the records
+// it selects are returned
+type SelectQuery struct {
+	// Expression is the SQL expression, such as SELECT s.id FROM S3Object s WHERE s.status = 'failed'
+	Expression string
+	// InputFormat is the object's format, CSV, JSON or Parquet
+	InputFormat string
+	// OutputFormat is the format of the returned records, CSV or JSON. It defaults to JSON for a JSON object and
+	// CSV otherwise.
+	OutputFormat string
+	// CSVHeader is how a CSV object's first line is treated, USE to name the columns after it, IGNORE to skip it or
+	// NONE, the default, to read it as a record
+	CSVHeader string
+	// JSONType is whether a JSON object is a single DOCUMENT, the default, or LINES of JSON records
+	JSONType string
+	// Compression is the object's compression, NONE, the default, GZIP or BZIP2
+	Compression string
+}
+
+var (
+	selectInputFormats  = []minio.SelectObjectType{minio.SelectObjectTypeCSV, minio.SelectObjectTypeJSON, minio.SelectObjectTypeParquet}
+	selectOutputFormats = []minio.SelectObjectType{minio.SelectObjectTypeCSV, minio.SelectObjectTypeJSON}
+	selectCSVHeaders    = []minio.CSVFileHeaderInfo{minio.CSVFileHeaderInfoNone, minio.CSVFileHeaderInfoIgnore, minio.CSVFileHeaderInfoUse}
+	selectJSONTypes     = []minio.JSONType{minio.JSONDocumentType, minio.JSONLinesType}
+	selectCompressions  = []minio.SelectCompressionType{minio.SelectCompressionNONE, minio.SelectCompressionGZIP, minio.SelectCompressionBZIP}
+)
+
+// Select runs the query over the artifact's object with S3 Select, returning a reader of the selected records
+func (s3Driver *ArtifactDriver) Select(ctx context.Context, artifact *wfv1.Artifact, query SelectQuery) (io.ReadCloser, error) {
+	opts, err := query.options()
+	if err != nil {
+		return nil, err
+	}
+	log := logging.RequireLoggerFromContext(ctx)
+	log.WithFields(logging.Fields{"key": artifact.S3.Key, "inputFormat": query.InputFormat}).Info(ctx, "S3 Select")
+	s3cli, err := s3Driver.newS3Client(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new S3 client: %w", err)
+	}
+	return selectS3Artifact(s3cli, artifact, opts)
+}
+
+// selectS3Artifact runs the S3 Select request over the artifact's object
+func selectS3Artifact(s3cli S3Client, artifact *wfv1.Artifact, opts minio.SelectObjectOptions) (io.ReadCloser, error) {
+	records, err := s3cli.SelectObjectContent(artifact.S3.Bucket, artifact.S3.Key, opts)
+	if err != nil {
+		if IsS3ErrCode(err, "NoSuchKey") {
+			return nil, argoerrs.New(argoerrs.CodeNotFound, err.Error())
+		}
+		return nil, fmt.Errorf("failed to select from %s: %w", artifact.S3.Key, err)
+	}
+	return records, nil
+}
+
+// options validates the query and returns the S3 Select request for it. The formats and settings are matched
+// case-insensitively.
+func (q SelectQuery) options() (minio.SelectObjectOptions, error) {
+	if strings.TrimSpace(q.Expression) == "" {
+		return minio.SelectObjectOptions{}, argoerrs.New(argoerrs.CodeBadRequest, "select expression is required")
+	}
+	inputFormat, err := selectSetting("input format", q.InputFormat, "", selectInputFormats)
+	if err != nil {
+		return minio.SelectObjectOptions{}, err
+	}
+	defaultOutput := minio.SelectObjectTypeCSV
+	if inputFormat == minio.SelectObjectTypeJSON {
+		defaultOutput = minio.SelectObjectTypeJSON
+	}
+	outputFormat, err := selectSetting("output format", q.OutputFormat, defaultOutput, selectOutputFormats)
+	if err != nil {
+		return minio.SelectObjectOptions{}, err
+	}
+	compression, err := selectSetting("compression", q.Compression, minio.SelectCompressionNONE, selectCompressions)
+	if err != nil {
+		return minio.SelectObjectOptions{}, err
+	}
+
+	opts := minio.SelectObjectOptions{
+		Expression:     q.Expression,
+		ExpressionType: minio.QueryExpressionTypeSQL,
+	}
+	switch inputFormat {
+	case minio.SelectObjectTypeCSV:
+		header, err := selectSetting("CSV header", q.CSVHeader, minio.CSVFileHeaderInfoNone, selectCSVHeaders)
+		if err != nil {
+			return minio.SelectObjectOptions{}, err
+		}
+		opts.InputSerialization.CSV = &minio.CSVInputOptions{}
+		opts.InputSerialization.CSV.SetFileHeaderInfo(header)
+	case minio.SelectObjectTypeJSON:
+		jsonType, err := selectSetting("JSON type", q.JSONType, minio.JSONDocumentType, selectJSONTypes)
+		if err != nil {
+			return minio.SelectObjectOptions{}, err
+		}
+		opts.InputSerialization.JSON = &minio.JSONInputOptions{}
+		opts.InputSerialization.JSON.SetType(jsonType)
+	case minio.SelectObjectTypeParquet:
+		// Parquet carries its own compression
+		if compression != minio.SelectCompressionNONE {
+			return minio.SelectObjectOptions{}, argoerrs.Errorf(argoerrs.CodeBadRequest, "select compression %s isn't supported for Parquet", compression)
+		}
+		opts.InputSerialization.Parquet = &minio.ParquetInputOptions{}
+	}
+	opts.InputSerialization.CompressionType = compression
+	if outputFormat == minio.SelectObjectTypeJSON {
+		opts.OutputSerialization.JSON = &minio.JSONOutputOptions{}
+	} else {
+		opts.OutputSerialization.CSV = &minio.CSVOutputOptions{}
+	}
+	return opts, nil
+}
+
+// selectSetting returns the allowed value matching value case-insensitively, or defaultValue when value is empty
+// and there is a default
+func selectSetting[T ~string](name, value string, defaultValue T, allowed []T) (T, error) {
+	if value == "" && defaultValue != "" {
+		return defaultValue, nil
+	}
+	names := make([]string, 0, len(allowed))
+	for _, a := range allowed {
+		if strings.EqualFold(value, string(a)) {
+			return a, nil
+		}
+		names = append(names, string(a))
+	}
+	return "", argoerrs.Errorf(argoerrs.CodeBadRequest, "select %s must be one of %s, got %q", name, strings.Join(names, ", "), value)
+}
+
+// SelectObjectContent runs the S3 Select request over the key, returning a reader of the selected records
+func (s *s3client) SelectObjectContent(bucket, key string, opts minio.SelectObjectOptions) (io.ReadCloser, error) {
+	logging.RequireLoggerFromContext(s.ctx).WithFields(logging.Fields{"endpoint": s.Endpoint, "bucket": bucket, "key": key}).Info(s.ctx, "Selecting from s3 object")
+
+	sse, err := s.customerKey(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	opts.ServerSideEncryption = sse
+	return s.minioClient.SelectObjectContent(s.ctx, bucket, key, opts)
+}
@@ -0,0 +1,92 @@
+package s3
+
+import (
+	"io"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	argoerrs "github.com/argoproj/argo-workflows/v3/errors"
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+)
+
+func TestSelectQueryOptions(t *testing.T) {
+	expression := "SELECT s.id FROM S3Object s WHERE s.status = 'failed'"
+	csvInput := func(header minio.CSVFileHeaderInfo) *minio.CSVInputOptions {
+		opts := &minio.CSVInputOptions{}
+		opts.SetFileHeaderInfo(header)
+		return opts
+	}
+	jsonInput := func(jsonType minio.JSONType) *minio.JSONInputOptions {
+		opts := &minio.JSONInputOptions{}
+		opts.SetType(jsonType)
+		return opts
+	}
+	tests := map[string]struct {
+		query          SelectQuery
+		expectedInput  minio.SelectObjectInputSerialization
+		expectedOutput minio.SelectObjectOutputSerialization
+		errMsg         string
+	}{
+		"CSV": {
+			query:          SelectQuery{Expression: expression, InputFormat: "csv", CSVHeader: "use", Compression: "gzip"},
+			expectedInput:  minio.SelectObjectInputSerialization{CompressionType: minio.SelectCompressionGZIP, CSV: csvInput(minio.CSVFileHeaderInfoUse)},
+			expectedOutput: minio.SelectObjectOutputSerialization{CSV: &minio.CSVOutputOptions{}},
+		},
+		"JSON lines": {
+			query:          SelectQuery{Expression: expression, InputFormat: "JSON", JSONType: "LINES"},
+			expectedInput:  minio.SelectObjectInputSerialization{CompressionType: minio.SelectCompressionNONE, JSON: jsonInput(minio.JSONLinesType)},
+			expectedOutput: minio.SelectObjectOutputSerialization{JSON: &minio.JSONOutputOptions{}},
+		},
+		"Parquet to JSON": {
+			query:          SelectQuery{Expression: expression, InputFormat: "Parquet", OutputFormat: "json"},
+			expectedInput:  minio.SelectObjectInputSerialization{CompressionType: minio.SelectCompressionNONE, Parquet: &minio.ParquetInputOptions{}},
+			expectedOutput: minio.SelectObjectOutputSerialization{JSON: &minio.JSONOutputOptions{}},
+		},
+		"Empty expression":     {query: SelectQuery{Expression: " ", InputFormat: "CSV"}, errMsg: "select expression is required"},
+		"No input format":      {query: SelectQuery{Expression: expression}, errMsg: "select input format must be one of CSV, JSON, Parquet"},
+		"Unknown input format": {query: SelectQuery{Expression: expression, InputFormat: "XML"}, errMsg: `select input format must be one of CSV, JSON, Parquet, got "XML"`},
+		"Parquet output":       {query: SelectQuery{Expression: expression, InputFormat: "CSV", OutputFormat: "Parquet"}, errMsg: "select output format must be one of CSV, JSON"},
+		"Unknown CSV header":   {query: SelectQuery{Expression: expression, InputFormat: "CSV", CSVHeader: "FIRST"}, errMsg: "select CSV header must be one of"},
+		"Compressed Parquet":   {query: SelectQuery{Expression: expression, InputFormat: "Parquet", Compression: "GZIP"}, errMsg: "isn't supported for Parquet"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts, err := tc.query.options()
+			if tc.errMsg != "" {
+				require.ErrorContains(t, err, tc.errMsg)
+				assert.True(t, argoerrs.IsCode(argoerrs.CodeBadRequest, err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, expression, opts.Expression)
+			assert.Equal(t, minio.QueryExpressionTypeSQL, opts.ExpressionType)
+			assert.Equal(t, tc.expectedInput, opts.InputSerialization)
+			assert.Equal(t, tc.expectedOutput, opts.OutputSerialization)
+		})
+	}
+}
+
+func TestSelectS3Artifact(t *testing.T) {
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"}, Key: "runs.csv"}}}
+	opts, err := SelectQuery{Expression: "SELECT s.id FROM S3Object s WHERE s.status = 'failed'", InputFormat: "CSV", CSVHeader: "USE"}.options()
+	require.NoError(t, err)
+
+	s3cli := &mockS3Client{selectRecords: map[string]string{"runs.csv": "run-2\nrun-5\n"}}
+	records, err := selectS3Artifact(s3cli, artifact, opts)
+	require.NoError(t, err)
+	defer records.Close()
+	data, err := io.ReadAll(records)
+	require.NoError(t, err)
+	assert.Equal(t, "run-2\nrun-5\n", string(data))
+	assert.Equal(t, opts, s3cli.selectOpts)
+
+	t.Run("Missing", func(t *testing.T) {
+		s3cli := &mockS3Client{mockedErrs: map[string]error{"SelectObjectContent": minio.ErrorResponse{Code: "NoSuchKey"}}}
+		_, err := selectS3Artifact(s3cli, artifact, opts)
+		assert.True(t, argoerrs.IsCode(argoerrs.CodeNotFound, err))
+	})
+}
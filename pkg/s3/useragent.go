@@ -0,0 +1,36 @@
+package s3
+
+import (
+	"net/http"
+
+	"github.com/pipekit/artifact-plugin-s3/pkg/version"
+)
+
+// userAgentProduct is the product name the User-Agent starts with
+const userAgentProduct = "argo-artifact-plugin-s3"
+
+// userAgent returns the User-Agent identifying the plugin, argo-artifact-plugin-s3/<version>, followed by suffix when set
+func userAgent(suffix string) string {
+	agent := userAgentProduct + "/" + version.Get().Version
+	if suffix != "" {
+		agent += " " + suffix
+	}
+	return agent
+}
+
+// userAgentTransport prefixes the User-Agent of every request with the plugin's, keeping minio's after it.
+// The User-Agent isn't signed, so it may be changed after minio signs the request.
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	agent := t.userAgent
+	if existing := req.Header.Get("User-Agent"); existing != "" {
+		agent += " " + existing
+	}
+	req.Header.Set("User-Agent", agent)
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,52 @@
+package s3
+
+import (
+	"testing"
+
+	wfv1 "github.com/argoproj/argo-workflows/v3/pkg/apis/workflow/v1alpha1"
+	"github.com/argoproj/argo-workflows/v3/util/logging"
+	"github.com/pipekit/artifact-plugin-s3/pkg/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUserAgent verifies S3 requests identify the plugin and its version ahead of minio's User-Agent
+func TestUserAgent(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+	f := newFakeObjectStore(t)
+	artifact := &wfv1.Artifact{ArtifactLocation: wfv1.ArtifactLocation{S3: &wfv1.S3Artifact{
+		S3Bucket: wfv1.S3Bucket{Bucket: "my-bucket"},
+		Key:      "report.csv",
+	}}}
+	previous := version.Version
+	version.Version = "v1.2.3"
+	t.Cleanup(func() { version.Version = previous })
+
+	t.Run("default", func(t *testing.T) {
+		require.NoError(t, f.driver().WriteObject(ctx, artifact, []byte("a,b\n"), ""))
+		assert.Regexp(t, `^argo-artifact-plugin-s3/v1\.2\.3 MinIO \(.*\) minio-go/`, f.userAgent)
+	})
+
+	t.Run("suffix", func(t *testing.T) {
+		driver := f.driver()
+		driver.UserAgentSuffix = "team-a"
+		require.NoError(t, driver.WriteObject(ctx, artifact, []byte("a,b\n"), ""))
+		assert.Regexp(t, `^argo-artifact-plugin-s3/v1\.2\.3 team-a MinIO `, f.userAgent)
+	})
+}
+
+// TestGetArtifactDriver_UserAgentSuffix verifies the suffix is configured on the driver and can't inject headers
+func TestGetArtifactDriver_UserAgentSuffix(t *testing.T) {
+	ctx := logging.TestContext(t.Context())
+
+	config, err := parsePluginConfiguration(ctx, "useSDKCreds: true\nuserAgentSuffix: team-a\n")
+	require.NoError(t, err)
+	require.NoError(t, validatePluginConfig(config))
+	driver, err := getArtifactDriver(ctx, config)
+	require.NoError(t, err)
+	assert.Equal(t, "team-a", driver.UserAgentSuffix)
+
+	err = validatePluginConfig(&PluginConfig{UserAgentSuffix: "team-a\r\nX-Injected: true"})
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	assert.ErrorContains(t, err, "userAgentSuffix")
+}
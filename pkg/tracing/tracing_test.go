@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	callerTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	callerSpanID  = "00f067aa0ba902b7"
+)
+
+// setupRecorder records spans in memory for the duration of the test
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Setenv(envVarExporterEndpoint, "")
+	shutdown, err := Setup(t.Context())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = shutdown(context.Background()) })
+
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	ctx := metadata.NewIncomingContext(t.Context(), metadata.Pairs("traceparent", "00-"+callerTraceID+"-"+callerSpanID+"-01"))
+	info := &grpc.UnaryServerInfo{FullMethod: "/artifact.ArtifactService/Load"}
+	var handlerSpan trace.SpanContext
+	_, err := UnaryServerInterceptor()(ctx, nil, info, func(ctx context.Context, _ any) (any, error) {
+		handlerSpan = trace.SpanContextFromContext(ctx)
+		return nil, errors.New("boom")
+	})
+	require.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "/artifact.ArtifactService/Load", span.Name())
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+	assert.Equal(t, callerTraceID, span.SpanContext().TraceID().String())
+	assert.Equal(t, callerSpanID, span.Parent().SpanID().String())
+	assert.True(t, span.Parent().IsRemote())
+	assert.Equal(t, codes.Error, span.Status().Code)
+	assert.Equal(t, span.SpanContext().SpanID(), handlerSpan.SpanID())
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	stream := &fakeServerStream{ctx: t.Context()}
+	info := &grpc.StreamServerInfo{FullMethod: "/artifact.ArtifactService/OpenStream"}
+	var handlerSpan trace.SpanContext
+	err := StreamServerInterceptor()(nil, stream, info, func(_ any, ss grpc.ServerStream) error {
+		handlerSpan = trace.SpanContextFromContext(ss.Context())
+		return nil
+	})
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "/artifact.ArtifactService/OpenStream", spans[0].Name())
+	assert.False(t, spans[0].Parent().IsValid())
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, spans[0].SpanContext().SpanID(), handlerSpan.SpanID())
+}
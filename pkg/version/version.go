@@ -0,0 +1,86 @@
+package version
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/pipekit/artifact-plugin-s3/internal/grpcutil"
+)
+
+// Version and Commit identify the build. They are set at build time with
+// -ldflags "-X github.com/pipekit/artifact-plugin-s3/pkg/version.Version=<version> -X ...version.Commit=<commit>",
+// and otherwise taken from the module and VCS information Go embeds in the binary.
+var (
+	Version = ""
+	Commit  = ""
+)
+
+const (
+	// ServiceName is the gRPC service serving GetVersion alongside the artifact service
+	ServiceName = "artifactplugin.s3.Version"
+	// GetVersionMethod is the full gRPC method name of GetVersion
+	GetVersionMethod = "/" + ServiceName + "/GetVersion"
+)
+
+// Info describes the running build
+type Info struct {
+	Version   string
+	Commit    string
+	GoVersion string
+}
+
+// Get returns the running build's version, commit and Go version. The version is "dev" when it is unknown.
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, GoVersion: runtime.Version()}
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && buildInfo.Main.Version != "(devel)" {
+			info.Version = buildInfo.Main.Version
+		}
+		for _, setting := range buildInfo.Settings {
+			if setting.Key == "vcs.revision" && info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+}
+
+// serviceDesc describes the version service, which takes an Empty message and returns the build as a Struct
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*any)(nil),
+	Methods:     []grpc.MethodDesc{{MethodName: "GetVersion", Handler: grpcutil.UnaryHandler(GetVersionMethod, getVersion)}},
+	Metadata:    "version",
+}
+
+// Register registers the version service on server
+func Register(server grpc.ServiceRegistrar) {
+	server.RegisterService(&serviceDesc, struct{}{})
+}
+
+func getVersion(struct{}, context.Context, *emptypb.Empty) (*structpb.Struct, error) {
+	info := Get()
+	return structpb.NewStruct(map[string]any{"version": info.Version, "commit": info.Commit, "goVersion": info.GoVersion})
+}
+
+// Fetch calls GetVersion on the server at the other end of conn
+func Fetch(ctx context.Context, conn grpc.ClientConnInterface) (Info, error) {
+	resp := &structpb.Struct{}
+	if err := conn.Invoke(ctx, GetVersionMethod, &emptypb.Empty{}, resp); err != nil {
+		return Info{}, err
+	}
+	fields := resp.GetFields()
+	return Info{
+		Version:   fields["version"].GetStringValue(),
+		Commit:    fields["commit"].GetStringValue(),
+		GoVersion: fields["goVersion"].GetStringValue(),
+	}, nil
+}
@@ -0,0 +1,48 @@
+package version
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGet(t *testing.T) {
+	previousVersion, previousCommit := Version, Commit
+	t.Cleanup(func() { Version, Commit = previousVersion, previousCommit })
+
+	Version, Commit = "", ""
+	info := Get()
+	assert.NotEmpty(t, info.Version)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+
+	Version, Commit = "v1.2.3", "0123abc"
+	assert.Equal(t, Info{Version: "v1.2.3", Commit: "0123abc", GoVersion: runtime.Version()}, Get())
+}
+
+// TestGetVersion calls the RPC over a real connection
+func TestGetVersion(t *testing.T) {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	Register(server)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	info, err := Fetch(t.Context(), conn)
+	require.NoError(t, err)
+	assert.NotEmpty(t, info.Version)
+	assert.Equal(t, Get(), info)
+}